@@ -22,6 +22,7 @@ import (
 	"context"
 	"encoding/binary"
 	"io"
+	"math"
 
 	"github.com/dgraph-io/badger/v2/pb"
 	"github.com/dgraph-io/badger/v2/y"
@@ -58,8 +59,11 @@ func (db *DB) Backup(w io.Writer, since uint64) (uint64, error) {
 // incremental dump of entries that have been added/modified since the last
 // invocation of Stream.Backup().
 //
-// This can be used to backup the data in a database at a given point in time.
+// This can be used to backup the data in a database at a given point in time. The range
+// tombstones written by DeleteRange are included, so that the keys they delete stay deleted when
+// incremental backups are loaded on top of older ones.
 func (stream *Stream) Backup(w io.Writer, since uint64) (uint64, error) {
+	stream.chooseInternal = isBackedUpKey
 	stream.KeyToList = func(key []byte, itr *Iterator) (*pb.KVList, error) {
 		list := &pb.KVList{}
 		a := itr.Alloc
@@ -146,6 +150,12 @@ func (stream *Stream) Backup(w io.Writer, since uint64) (uint64, error) {
 	return maxVersion, nil
 }
 
+// isBackedUpKey returns true for the internal keys included in backups, which are the ones of the
+// keyspaces and the range tombstones.
+func isBackedUpKey(key []byte) bool {
+	return isKeyspaceKey(key) || bytes.HasPrefix(key, rangeDelPrefix)
+}
+
 func writeTo(list *pb.KVList, w io.Writer) error {
 	if err := binary.Write(w, binary.LittleEndian, uint64(proto.Size(list))); err != nil {
 		return err
@@ -241,6 +251,11 @@ func (l *KVLoader) Finish() error {
 // DB.Load() should be called on a database that is not running any other
 // concurrent transactions while it is running.
 func (db *DB) Load(r io.Reader, maxPendingWrites int) error {
+	return db.load(r, maxPendingWrites, math.MaxUint64)
+}
+
+// load works like Load, but skips the entries with a version higher than maxVersion.
+func (db *DB) load(r io.Reader, maxPendingWrites int, maxVersion uint64) error {
 	br := bufio.NewReaderSize(r, 16<<10)
 	unmarshalBuf := make([]byte, 1<<10)
 
@@ -268,6 +283,9 @@ func (db *DB) Load(r io.Reader, maxPendingWrites int) error {
 		}
 
 		for _, kv := range list.Kv {
+			if kv.Version > maxVersion {
+				continue
+			}
 			if err := ldr.Set(kv); err != nil {
 				return err
 			}
@@ -284,5 +302,6 @@ func (db *DB) Load(r io.Reader, maxPendingWrites int) error {
 		return err
 	}
 	db.orc.txnMark.Done(db.orc.nextTxnTs - 1)
-	return nil
+	// The backup may contain keyspace definitions.
+	return db.loadKeyspaces()
 }
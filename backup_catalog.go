@@ -0,0 +1,250 @@
+/*
+ * Copyright 2020 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package badger
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/dgraph-io/badger/v2/y"
+	"github.com/pkg/errors"
+)
+
+const (
+	// BackupCatalogFilename is the name of the file in a backup directory which records the
+	// chain of backups stored in it.
+	BackupCatalogFilename        = "CATALOG"
+	backupCatalogRewriteFilename = "REWRITE-CATALOG"
+)
+
+// BackupInfo describes a single backup file in a BackupCatalog.
+type BackupInfo struct {
+	// File is the name of the backup file, relative to the catalog directory.
+	File string `json:"file"`
+	// Incremental is false for a full backup.
+	Incremental bool `json:"incremental"`
+	// Since is the lowest version that could be part of this backup.
+	Since uint64 `json:"since"`
+	// Upto is the highest version that is part of this backup, or the chain of backups up to and
+	// including this one.
+	Upto uint64 `json:"upto"`
+	// Checksum is the hex encoded SHA-256 checksum of the backup file.
+	Checksum  string    `json:"checksum"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// BackupCatalog keeps track of a chain of backups stored in a directory. The chain starts with a
+// full backup, followed by incremental backups, each one containing the versions written since
+// the previous backup. The catalog is stored as a JSON file in the directory.
+type BackupCatalog struct {
+	dir string
+	// Backups holds the backups in the order they were taken.
+	Backups []BackupInfo `json:"backups"`
+}
+
+// OpenBackupCatalog reads the backup catalog stored in the given directory. An empty catalog is
+// returned, if the directory doesn't have one.
+func OpenBackupCatalog(dir string) (*BackupCatalog, error) {
+	c := &BackupCatalog{dir: dir}
+	buf, err := ioutil.ReadFile(filepath.Join(dir, BackupCatalogFilename))
+	switch {
+	case os.IsNotExist(err):
+		return c, nil
+	case err != nil:
+		return nil, y.Wrapf(err, "while reading backup catalog in %s", dir)
+	}
+	if err := json.Unmarshal(buf, c); err != nil {
+		return nil, y.Wrapf(err, "while parsing backup catalog in %s", dir)
+	}
+	return c, nil
+}
+
+// LastVersion returns the highest version covered by the backups in the catalog.
+func (c *BackupCatalog) LastVersion() uint64 {
+	if len(c.Backups) == 0 {
+		return 0
+	}
+	return c.Backups[len(c.Backups)-1].Upto
+}
+
+// save atomically replaces the catalog file in the directory.
+func (c *BackupCatalog) save() error {
+	buf, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	rewritePath := filepath.Join(c.dir, backupCatalogRewriteFilename)
+	if err := ioutil.WriteFile(rewritePath, buf, 0600); err != nil {
+		return y.Wrapf(err, "while writing backup catalog")
+	}
+	f, err := os.Open(rewritePath)
+	if err != nil {
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(rewritePath, filepath.Join(c.dir, BackupCatalogFilename)); err != nil {
+		return err
+	}
+	return syncDir(c.dir)
+}
+
+// verify checks that the backup file exists and matches the checksum recorded in the catalog.
+func (c *BackupCatalog) verify(info BackupInfo) error {
+	f, err := os.Open(filepath.Join(c.dir, info.File))
+	if err != nil {
+		return y.Wrapf(err, "while opening backup file")
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return y.Wrapf(err, "while reading backup file: %s", info.File)
+	}
+	if sum := hex.EncodeToString(h.Sum(nil)); sum != info.Checksum {
+		return errors.Errorf("Checksum mismatch for backup file %s. Expected: %s, got: %s",
+			info.File, info.Checksum, sum)
+	}
+	return nil
+}
+
+// BackupTo takes a backup of the DB and records it in the catalog. If incremental is true, only
+// the versions written after the last backup in the catalog are dumped. Otherwise, a full backup
+// is taken, which starts a new chain of backups. The backup file is removed if the backup fails,
+// so that a later BackupTo can take its place.
+func (db *DB) BackupTo(c *BackupCatalog, incremental bool) (_ BackupInfo, rerr error) {
+	info := BackupInfo{
+		File:        fmt.Sprintf("%06d.bak", len(c.Backups)+1),
+		Incremental: incremental,
+		CreatedAt:   time.Now().UTC(),
+	}
+	if incremental {
+		if len(c.Backups) == 0 {
+			return info, errors.New("Cannot take an incremental backup without a full backup")
+		}
+		info.Since = c.LastVersion() + 1
+	}
+	if err := os.MkdirAll(c.dir, 0700); err != nil {
+		return info, y.Wrapf(err, "while creating backup directory: %s", c.dir)
+	}
+
+	fpath := filepath.Join(c.dir, info.File)
+	f, err := os.OpenFile(fpath, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return info, y.Wrapf(err, "while creating backup file")
+	}
+	defer func() {
+		f.Close()
+		if rerr != nil {
+			if err := os.Remove(fpath); err != nil {
+				db.opt.Warningf("While removing backup file %s: %v", fpath, err)
+			}
+		}
+	}()
+
+	h := sha256.New()
+	bw := bufio.NewWriterSize(io.MultiWriter(f, h), 4<<20)
+	upto, err := db.Backup(bw, info.Since)
+	if err != nil {
+		return info, err
+	}
+	if err := bw.Flush(); err != nil {
+		return info, err
+	}
+	if err := f.Sync(); err != nil {
+		return info, err
+	}
+	if info.Size, err = f.Seek(0, io.SeekCurrent); err != nil {
+		return info, err
+	}
+	// Nothing was written since the last backup.
+	if upto < info.Since {
+		upto = c.LastVersion()
+	}
+	info.Upto = upto
+	info.Checksum = hex.EncodeToString(h.Sum(nil))
+
+	c.Backups = append(c.Backups, info)
+	if err := c.save(); err != nil {
+		c.Backups = c.Backups[:len(c.Backups)-1]
+		return info, err
+	}
+	return info, nil
+}
+
+// RestoreFrom loads the chain of backups recorded in the catalog into the DB, skipping all the
+// versions higher than untilVersion. This restores the DB to its state as of untilVersion, as
+// far as the versions of keys present in the backups allow. The chain used is the last full
+// backup taken at or before untilVersion, followed by its incremental backups. If there's no
+// such full backup, the first one is used. The checksums of all the backup files in the chain
+// are verified before loading any of them.
+//
+// Like Load, RestoreFrom should be called on a database that is not running any other
+// concurrent transactions while it is running.
+func (db *DB) RestoreFrom(c *BackupCatalog, untilVersion uint64, maxPendingWrites int) error {
+	start := -1
+	for i, info := range c.Backups {
+		if info.Incremental {
+			continue
+		}
+		if start < 0 || info.Upto <= untilVersion {
+			start = i
+		}
+	}
+	if start < 0 {
+		return errors.New("No full backup found in the catalog")
+	}
+	end := start + 1
+	for end < len(c.Backups) && c.Backups[end].Incremental &&
+		c.Backups[end].Since <= untilVersion {
+		end++
+	}
+	chain := c.Backups[start:end]
+	for _, info := range chain {
+		if err := c.verify(info); err != nil {
+			return err
+		}
+	}
+	for _, info := range chain {
+		db.opt.Infof("Restoring backup %s with versions [%d, %d]", info.File, info.Since,
+			info.Upto)
+		f, err := os.Open(filepath.Join(c.dir, info.File))
+		if err != nil {
+			return y.Wrapf(err, "while opening backup file")
+		}
+		err = db.load(f, maxPendingWrites, untilVersion)
+		f.Close()
+		if err != nil {
+			return y.Wrapf(err, "while restoring backup file: %s", info.File)
+		}
+	}
+	return nil
+}
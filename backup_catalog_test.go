@@ -0,0 +1,187 @@
+/*
+ * Copyright 2020 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package badger
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackupCatalog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "badger-test")
+	require.NoError(t, err)
+	defer removeDir(dir)
+	bdir, err := ioutil.TempDir("", "badger-backup")
+	require.NoError(t, err)
+	defer removeDir(bdir)
+
+	db, err := Open(getTestOptions(dir).WithNumVersionsToKeep(math.MaxInt32))
+	require.NoError(t, err)
+	defer func() { require.NoError(t, db.Close()) }()
+
+	catalog, err := OpenBackupCatalog(bdir)
+	require.NoError(t, err)
+	_, err = db.BackupTo(catalog, true)
+	require.Error(t, err, "incremental backup requires a full backup")
+
+	// Every round overwrites the keys written in the previous round, and adds new keys.
+	write := func(round int) {
+		for i := 0; i < 10*(round+1); i++ {
+			txnSet(t, db, rangeKey(i), []byte(fmt.Sprintf("round%d", round)), 0)
+		}
+	}
+	write(0)
+	full, err := db.BackupTo(catalog, false)
+	require.NoError(t, err)
+	require.Equal(t, uint64(0), full.Since)
+	write(1)
+	inc1, err := db.BackupTo(catalog, true)
+	require.NoError(t, err)
+	require.Equal(t, full.Upto+1, inc1.Since)
+	write(2)
+	inc2, err := db.BackupTo(catalog, true)
+	require.NoError(t, err)
+	require.Equal(t, inc1.Upto+1, inc2.Since)
+	// No writes since the last backup.
+	inc3, err := db.BackupTo(catalog, true)
+	require.NoError(t, err)
+	require.Equal(t, inc2.Upto, inc3.Upto)
+
+	// The catalog must be persisted.
+	catalog, err = OpenBackupCatalog(bdir)
+	require.NoError(t, err)
+	require.Equal(t, []BackupInfo{full, inc1, inc2, inc3}, catalog.Backups)
+
+	restore := func(untilVersion uint64, round int) {
+		rdir, err := ioutil.TempDir("", "badger-restore")
+		require.NoError(t, err)
+		defer removeDir(rdir)
+		rdb, err := Open(getTestOptions(rdir).WithNumVersionsToKeep(math.MaxInt32))
+		require.NoError(t, err)
+		defer func() { require.NoError(t, rdb.Close()) }()
+
+		require.NoError(t, rdb.RestoreFrom(catalog, untilVersion, 16))
+		require.NoError(t, rdb.View(func(txn *Txn) error {
+			require.Equal(t, 10*(round+1), countKeys(t, txn, false))
+			for i := 0; i < 10*(round+1); i++ {
+				item, err := txn.Get(rangeKey(i))
+				require.NoError(t, err)
+				require.Equal(t, []byte(fmt.Sprintf("round%d", round)), getItemValue(t, item))
+			}
+			return nil
+		}))
+	}
+	restore(math.MaxUint64, 2)
+	restore(inc1.Upto, 1)
+	restore(full.Upto, 0)
+
+	// A corrupted backup file must not be restored.
+	require.NoError(t, ioutil.WriteFile(filepath.Join(bdir, inc2.File), []byte("junk"), 0600))
+	rdir, err := ioutil.TempDir("", "badger-restore")
+	require.NoError(t, err)
+	defer removeDir(rdir)
+	rdb, err := Open(getTestOptions(rdir))
+	require.NoError(t, err)
+	defer func() { require.NoError(t, rdb.Close()) }()
+	require.Error(t, rdb.RestoreFrom(catalog, math.MaxUint64, 16))
+}
+
+func TestBackupCatalogDeleteRange(t *testing.T) {
+	dir, err := ioutil.TempDir("", "badger-test")
+	require.NoError(t, err)
+	defer removeDir(dir)
+	bdir, err := ioutil.TempDir("", "badger-backup")
+	require.NoError(t, err)
+	defer removeDir(bdir)
+
+	db, err := Open(getTestOptions(dir))
+	require.NoError(t, err)
+	defer func() { require.NoError(t, db.Close()) }()
+	catalog, err := OpenBackupCatalog(bdir)
+	require.NoError(t, err)
+
+	for i := 0; i < 20; i++ {
+		txnSet(t, db, rangeKey(i), []byte("v"), 0)
+	}
+	_, err = db.BackupTo(catalog, false)
+	require.NoError(t, err)
+	require.NoError(t, db.Update(func(txn *Txn) error {
+		return txn.DeleteRange(rangeKey(5), rangeKey(15))
+	}))
+	_, err = db.BackupTo(catalog, true)
+	require.NoError(t, err)
+
+	// The range deleted after the full backup stays deleted once the chain is restored.
+	rdir, err := ioutil.TempDir("", "badger-restore")
+	require.NoError(t, err)
+	defer removeDir(rdir)
+	rdb, err := Open(getTestOptions(rdir))
+	require.NoError(t, err)
+	defer func() { require.NoError(t, rdb.Close()) }()
+	require.NoError(t, rdb.RestoreFrom(catalog, math.MaxUint64, 16))
+	require.NoError(t, rdb.View(func(txn *Txn) error {
+		for i := 0; i < 20; i++ {
+			_, err := txn.Get(rangeKey(i))
+			if i >= 5 && i < 15 {
+				require.Equal(t, ErrKeyNotFound, err, "key %d", i)
+			} else {
+				require.NoError(t, err, "key %d", i)
+			}
+		}
+		require.Equal(t, 10, countKeys(t, txn, false))
+		return nil
+	}))
+}
+
+func TestBackupToFailure(t *testing.T) {
+	dir, err := ioutil.TempDir("", "badger-test")
+	require.NoError(t, err)
+	defer removeDir(dir)
+	bdir, err := ioutil.TempDir("", "badger-backup")
+	require.NoError(t, err)
+	defer removeDir(bdir)
+
+	db, err := Open(getTestOptions(dir))
+	require.NoError(t, err)
+	defer func() { require.NoError(t, db.Close()) }()
+	txnSet(t, db, []byte("key"), []byte("val"), 0)
+
+	// The catalog can't be saved over a directory.
+	catalogPath := filepath.Join(bdir, BackupCatalogFilename)
+	require.NoError(t, os.Mkdir(catalogPath, 0700))
+	catalog, err := OpenBackupCatalog(bdir)
+	require.Error(t, err)
+	catalog = &BackupCatalog{dir: bdir}
+	_, err = db.BackupTo(catalog, false)
+	require.Error(t, err)
+	require.Empty(t, catalog.Backups)
+	files, err := filepath.Glob(filepath.Join(bdir, "*.bak"))
+	require.NoError(t, err)
+	require.Empty(t, files)
+
+	// The next backup takes the place of the failed one.
+	require.NoError(t, os.Remove(catalogPath))
+	info, err := db.BackupTo(catalog, false)
+	require.NoError(t, err)
+	require.Equal(t, "000001.bak", info.File)
+}
@@ -18,6 +18,8 @@ package cmd
 
 import (
 	"bufio"
+	"errors"
+	"fmt"
 	"math"
 	"os"
 
@@ -27,6 +29,8 @@ import (
 
 var bo = struct {
 	backupFile  string
+	backupDir   string
+	incremental bool
 	numVersions int
 }{}
 
@@ -39,7 +43,13 @@ var backupCmd = &cobra.Command{
 Iterates over each key-value pair, encodes it along with its metadata and
 version in protocol buffers and writes them to a file. This file can later be
 used by the restore command to create an identical copy of the
-database.`,
+database.
+
+If --backup-dir is set, the backup is written to that directory instead, and
+recorded in the backup catalog stored in it. With --incremental, only the
+versions written since the last backup in the catalog are backed up. The
+catalog can then be used by the restore command to restore the whole chain of
+backups.`,
 	RunE: doBackup,
 }
 
@@ -47,6 +57,11 @@ func init() {
 	RootCmd.AddCommand(backupCmd)
 	backupCmd.Flags().StringVarP(&bo.backupFile, "backup-file", "f",
 		"badger.bak", "File to backup to")
+	backupCmd.Flags().StringVar(&bo.backupDir, "backup-dir", "",
+		"Directory holding a catalog of backups to add this backup to")
+	backupCmd.Flags().BoolVar(&bo.incremental, "incremental", false,
+		"Only backup the versions written since the last backup in the catalog. "+
+			"Requires --backup-dir.")
 	backupCmd.Flags().IntVarP(&bo.numVersions, "num-versions", "n",
 		0, "Number of versions to keep. A value <= 0 means keep all versions.")
 }
@@ -67,6 +82,13 @@ func doBackup(cmd *cobra.Command, args []string) error {
 	}
 	defer db.Close()
 
+	if bo.backupDir != "" {
+		return doCatalogBackup(db)
+	}
+	if bo.incremental {
+		return errors.New("--incremental requires --backup-dir")
+	}
+
 	// Create File
 	f, err := os.Create(bo.backupFile)
 	if err != nil {
@@ -88,3 +110,17 @@ func doBackup(cmd *cobra.Command, args []string) error {
 
 	return f.Close()
 }
+
+func doCatalogBackup(db *badger.DB) error {
+	catalog, err := badger.OpenBackupCatalog(bo.backupDir)
+	if err != nil {
+		return err
+	}
+	info, err := db.BackupTo(catalog, bo.incremental)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Wrote backup %s with versions [%d, %d] to %s\n",
+		info.File, info.Since, info.Upto, bo.backupDir)
+	return nil
+}
@@ -0,0 +1,89 @@
+/*
+ * Copyright 2020 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/dgraph-io/badger/v2"
+	"github.com/dgraph-io/badger/v2/redis"
+	"github.com/dgraph-io/badger/v2/y"
+	"github.com/spf13/cobra"
+)
+
+var redisCmd = &cobra.Command{
+	Use:   "redis",
+	Short: "Serve the DB to Redis clients.",
+	Long: `
+This command opens the DB, and serves it to the Redis clients via the RESP2 protocol. The keys
+hold strings. The supported commands are GET, SET, DEL, EXISTS, EXPIRE, PERSIST, TTL, SCAN, INCR,
+INCRBY, DECR, DECRBY, MULTI, EXEC and DISCARD, along with PING, ECHO, SELECT and QUIT.
+`,
+	RunE: serveRedis,
+}
+
+var redisOpt = struct {
+	addr    string
+	keyPath string
+}{}
+
+func init() {
+	RootCmd.AddCommand(redisCmd)
+	redisCmd.Flags().StringVar(&redisOpt.addr, "addr", "localhost:6379",
+		"Address to serve the DB on.")
+	redisCmd.Flags().StringVarP(&redisOpt.keyPath, "encryption-key-file", "e", "",
+		"Path of the encryption key file.")
+}
+
+func serveRedis(cmd *cobra.Command, args []string) error {
+	encKey, err := getKey(redisOpt.keyPath)
+	if err != nil {
+		return err
+	}
+	opt := badger.DefaultOptions(sstDir).
+		WithValueDir(vlogDir).
+		WithEncryptionKey(encKey).
+		WithIndexCacheSize(100<<20).
+		WithMergeOperator(nil, redis.IncrMerge)
+	db, err := badger.Open(opt)
+	if err != nil {
+		return y.Wrapf(err, "cannot open DB at %s", sstDir)
+	}
+	defer db.Close()
+
+	l, err := net.Listen("tcp", redisOpt.addr)
+	if err != nil {
+		return err
+	}
+	srv := redis.NewServer(db)
+	defer srv.Close()
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sigCh
+		fmt.Println("Stopping...")
+		srv.Close()
+		l.Close()
+	}()
+	fmt.Printf("Serving the DB at %s to Redis clients on %s\n", sstDir, l.Addr())
+	return srv.Serve(l)
+}
@@ -27,6 +27,8 @@ import (
 )
 
 var restoreFile string
+var restoreDir string
+var untilVersion uint64
 var maxPendingWrites int
 
 // restoreCmd represents the restore command
@@ -39,6 +41,10 @@ It reads a file generated using the backup command (or by calling the
 DB.Backup() API method) and writes each key-value pair found in the file to
 the Badger database.
 
+If --backup-dir is set, the chain of backups recorded in the backup catalog
+of that directory is restored instead. --until-version can be used to only
+restore the versions up to the given one.
+
 Restore creates a new database, and currently does not work on an already
 existing database.`,
 	RunE: doRestore,
@@ -48,6 +54,10 @@ func init() {
 	RootCmd.AddCommand(restoreCmd)
 	restoreCmd.Flags().StringVarP(&restoreFile, "backup-file", "f",
 		"badger.bak", "File to restore from")
+	restoreCmd.Flags().StringVar(&restoreDir, "backup-dir", "",
+		"Directory holding a catalog of backups to restore from")
+	restoreCmd.Flags().Uint64Var(&untilVersion, "until-version", math.MaxUint64,
+		"Only restore the versions up to this one. Requires --backup-dir.")
 	// Default value for maxPendingWrites is 256, to minimise memory usage
 	// and overall finish time.
 	restoreCmd.Flags().IntVarP(&maxPendingWrites, "max-pending-writes", "w",
@@ -74,6 +84,17 @@ func doRestore(cmd *cobra.Command, args []string) error {
 	}
 	defer db.Close()
 
+	if restoreDir != "" {
+		catalog, err := badger.OpenBackupCatalog(restoreDir)
+		if err != nil {
+			return err
+		}
+		return db.RestoreFrom(catalog, untilVersion, maxPendingWrites)
+	}
+	if untilVersion != math.MaxUint64 {
+		return errors.New("--until-version requires --backup-dir")
+	}
+
 	// Open File
 	f, err := os.Open(restoreFile)
 	if err != nil {
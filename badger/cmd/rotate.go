@@ -22,17 +22,28 @@ import (
 	"time"
 
 	"github.com/dgraph-io/badger/v2"
+	"github.com/pkg/errors"
 
 	"github.com/spf13/cobra"
 )
 
 var oldKeyPath string
 var newKeyPath string
+var keyManagerOpt struct {
+	kind      string
+	dir       string
+	envPrefix string
+	newKeyID  string
+}
 var rotateCmd = &cobra.Command{
 	Use:   "rotate",
 	Short: "Rotate encryption key.",
-	Long:  "Rotate will rotate the old key with new encryption key.",
-	RunE:  doRotate,
+	Long: `Rotate will rotate the old key with new encryption key.
+
+With --key-manager, the master keys are read by a key manager, and are referred to by their IDs.
+The data keys are unwrapped with the master key recorded in the key registry, unless
+--old-key-path is set, and are wrapped with the master key given by --new-key-id.`,
+	RunE: doRotate,
 }
 
 func init() {
@@ -41,6 +52,31 @@ func init() {
 		"", "Path of the old key")
 	rotateCmd.Flags().StringVarP(&newKeyPath, "new-key-path", "n",
 		"", "Path of the new key")
+	rotateCmd.Flags().StringVar(&keyManagerOpt.kind, "key-manager", "",
+		"Key manager holding the master keys: file or env")
+	rotateCmd.Flags().StringVar(&keyManagerOpt.dir, "key-dir", "",
+		"Directory of the master key files, for the file key manager")
+	rotateCmd.Flags().StringVar(&keyManagerOpt.envPrefix, "key-env-prefix", "BADGER_MASTER_KEY_",
+		"Prefix of the environment variables holding the master keys, for the env key manager")
+	rotateCmd.Flags().StringVar(&keyManagerOpt.newKeyID, "new-key-id", "",
+		"ID of the new master key, used instead of --new-key-path")
+}
+
+// getKeyManager returns the key manager set via the flags, with the given current master key.
+func getKeyManager(currentID string) (badger.KeyManager, error) {
+	switch keyManagerOpt.kind {
+	case "":
+		return nil, nil
+	case "file":
+		if keyManagerOpt.dir == "" {
+			return nil, errors.New("--key-dir must be set for the file key manager")
+		}
+		return badger.NewFileKeyManager(keyManagerOpt.dir, currentID), nil
+	case "env":
+		return badger.NewEnvKeyManager(keyManagerOpt.envPrefix, currentID), nil
+	default:
+		return nil, errors.Errorf("Invalid key manager: %q", keyManagerOpt.kind)
+	}
 }
 
 func doRotate(cmd *cobra.Command, args []string) error {
@@ -55,14 +91,31 @@ func doRotate(cmd *cobra.Command, args []string) error {
 		EncryptionKeyRotationDuration: 10 * 24 * time.Hour,
 	}
 	kr, err := badger.OpenKeyRegistry(opt)
+	if err == badger.ErrEncryptionKeyMismatch && oldKeyPath == "" && keyManagerOpt.kind != "" {
+		// The registry is encrypted with the master key recorded in it, which is read from the key
+		// manager.
+		if opt.KeyManager, err = getKeyManager(""); err != nil {
+			return err
+		}
+		kr, err = badger.OpenKeyRegistry(opt)
+	}
 	if err != nil {
 		return err
 	}
-	newKey, err := getKey(newKeyPath)
-	if err != nil {
+	opt.EncryptionKey, opt.KeyManager = nil, nil
+	if keyManagerOpt.newKeyID != "" {
+		if newKeyPath != "" {
+			return errors.New("Only one of --new-key-path and --new-key-id can be set")
+		}
+		if opt.KeyManager, err = getKeyManager(keyManagerOpt.newKeyID); err != nil {
+			return err
+		}
+		if opt.KeyManager == nil {
+			return errors.New("--key-manager must be set along with --new-key-id")
+		}
+	} else if opt.EncryptionKey, err = getKey(newKeyPath); err != nil {
 		return err
 	}
-	opt.EncryptionKey = newKey
 	err = badger.WriteKeyRegistry(kr, opt)
 	if err != nil {
 		return err
@@ -20,6 +20,7 @@ import (
 	"io/ioutil"
 	"math/rand"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/dgraph-io/badger/v2"
@@ -152,3 +153,57 @@ func TestRotatePlainTextToEncrypted(t *testing.T) {
 	})
 	require.NoError(t, db.Close())
 }
+
+// This test shows that rotate tool can move the DB from a raw key to the master keys of a key
+// manager, and rotate them.
+func TestRotateKeyManager(t *testing.T) {
+	dir, err := ioutil.TempDir("", "badger-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	keyDir, err := ioutil.TempDir("", "badger-keys")
+	require.NoError(t, err)
+	defer os.RemoveAll(keyDir)
+
+	key := make([]byte, 32)
+	y.Check2(rand.Read(key))
+	fp, err := ioutil.TempFile("", "*.key")
+	require.NoError(t, err)
+	_, err = fp.Write(key)
+	require.NoError(t, err)
+	defer fp.Close()
+	for _, id := range []string{"k1", "k2"} {
+		masterKey := make([]byte, 32)
+		y.Check2(rand.Read(masterKey))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(keyDir, id), masterKey, 0600))
+	}
+
+	opts := badger.DefaultOptions(dir).WithEncryptionKey(key).
+		WithBlockCacheSize(1 << 20).WithIndexCacheSize(1 << 20)
+	db, err := badger.Open(opts)
+	require.NoError(t, err)
+	require.NoError(t, db.Update(func(txn *badger.Txn) error {
+		return txn.Set([]byte("foo"), []byte("bar"))
+	}))
+	require.NoError(t, db.Close())
+
+	defer func() { keyManagerOpt.kind, keyManagerOpt.newKeyID = "", "" }()
+	sstDir = dir
+	keyManagerOpt.kind = "file"
+	keyManagerOpt.dir = keyDir
+	oldKeyPath, newKeyPath = fp.Name(), ""
+	for _, id := range []string{"k1", "k2"} {
+		keyManagerOpt.newKeyID = id
+		require.NoError(t, doRotate(nil, []string{}))
+		oldKeyPath = ""
+
+		opts.EncryptionKey = nil
+		opts.KeyManager = badger.NewFileKeyManager(keyDir, id)
+		db, err = badger.Open(opts)
+		require.NoError(t, err)
+		require.NoError(t, db.View(func(txn *badger.Txn) error {
+			_, err := txn.Get([]byte("foo"))
+			return err
+		}))
+		require.NoError(t, db.Close())
+	}
+}
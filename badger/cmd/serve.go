@@ -0,0 +1,98 @@
+/*
+ * Copyright 2020 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/dgraph-io/badger/v2"
+	"github.com/dgraph-io/badger/v2/server"
+	"github.com/dgraph-io/badger/v2/y"
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc"
+)
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve the DB over gRPC.",
+	Long: `
+This command opens the DB, and serves its key-value API over gRPC: the transactions, the scans of
+the keys, streams and subscriptions. The requests and responses use the KV and KVList messages of
+badgerpb2.proto. See the client package for the Go client.
+`,
+	RunE: serve,
+}
+
+var serveOpt = struct {
+	addr       string
+	txnTimeout time.Duration
+	keyPath    string
+	readOnly   bool
+}{}
+
+func init() {
+	RootCmd.AddCommand(serveCmd)
+	serveCmd.Flags().StringVar(&serveOpt.addr, "addr", "localhost:7080",
+		"Address to serve the DB on.")
+	serveCmd.Flags().DurationVar(&serveOpt.txnTimeout, "txn-timeout", time.Minute,
+		"Transactions unused for this long are discarded.")
+	serveCmd.Flags().StringVarP(&serveOpt.keyPath, "encryption-key-file", "e", "",
+		"Path of the encryption key file.")
+	serveCmd.Flags().BoolVar(&serveOpt.readOnly, "read-only", false,
+		"Open the DB in read-only mode.")
+}
+
+func serve(cmd *cobra.Command, args []string) error {
+	encKey, err := getKey(serveOpt.keyPath)
+	if err != nil {
+		return err
+	}
+	opt := badger.DefaultOptions(sstDir).
+		WithValueDir(vlogDir).
+		WithReadOnly(serveOpt.readOnly).
+		WithEncryptionKey(encKey).
+		WithIndexCacheSize(100 << 20)
+	db, err := badger.Open(opt)
+	if err != nil {
+		return y.Wrapf(err, "cannot open DB at %s", sstDir)
+	}
+	defer db.Close()
+
+	l, err := net.Listen("tcp", serveOpt.addr)
+	if err != nil {
+		return err
+	}
+	srv := server.New(db, serveOpt.txnTimeout)
+	defer srv.Close()
+	gs := grpc.NewServer()
+	srv.Register(gs)
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sigCh
+		fmt.Println("Stopping...")
+		gs.GracefulStop()
+	}()
+	fmt.Printf("Serving the DB at %s on %s\n", sstDir, l.Addr())
+	return gs.Serve(l)
+}
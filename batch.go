@@ -196,6 +196,24 @@ func (wb *WriteBatch) Delete(k []byte) error {
 	return nil
 }
 
+// DeleteRange is equivalent of Txn.DeleteRange.
+func (wb *WriteBatch) DeleteRange(start, end []byte) error {
+	wb.Lock()
+	defer wb.Unlock()
+
+	if err := wb.txn.DeleteRange(start, end); err != ErrTxnTooBig {
+		return err
+	}
+	if err := wb.commit(); err != nil {
+		return err
+	}
+	if err := wb.txn.DeleteRange(start, end); err != nil {
+		wb.err.Store(err)
+		return err
+	}
+	return nil
+}
+
 // Caller to commit must hold a write lock.
 func (wb *WriteBatch) commit() error {
 	if err := wb.Error(); err != nil {
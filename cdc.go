@@ -0,0 +1,320 @@
+/*
+ * Copyright 2020 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package badger
+
+import (
+	"bytes"
+	"context"
+
+	"github.com/dgraph-io/badger/v2/pb"
+	"github.com/dgraph-io/badger/v2/y"
+	"github.com/dgraph-io/ristretto/z"
+	"github.com/pkg/errors"
+)
+
+// SubscribeSince is like Subscribe, but it first sends the changes committed at or after since,
+// so that a subscriber which was down doesn't miss any change. It can be used to keep an external
+// system, like a search index, in sync with the DB across restarts.
+//
+// The changes committed before SubscribeSince was called are read from a snapshot of the DB with a
+// Stream. Only the latest version of every key changed since then is sent, and the keys aren't
+// sorted. Afterwards, the callback is called with the changes as they are committed, like with
+// Subscribe. No change is sent twice, and none is missed in between.
+//
+// Unlike Subscribe, KV.UserMeta holds the user meta, and KV.Meta holds the meta of the entry.
+// ChangeIsDelete returns true for the deletions and expirations. The deletions which have been
+// compacted away before the snapshot is read aren't sent. A range deletion (see Txn.DeleteRange)
+// is sent as a single change, for which ChangeIsRangeDelete returns true, if the deleted range
+// overlaps with any of the prefixes. The range deletions of the snapshot are sent before its keys,
+// which were not deleted as of the snapshot. The changes to keyspaces (see Keyspace) are sent with
+// their internal keys, if a prefix covers them.
+//
+// Every call of cb gets a resume token, the commit timestamp up to which all the changes have been
+// sent. The token plus one can be passed as since to resume after a restart. The token doesn't
+// advance while the snapshot is sent, since its keys aren't sorted by version. The callback is
+// called with an empty KVList once the snapshot has been sent, to advance the token. In the managed
+// mode, the commit timestamps must increase for the changes to be sent in order.
+func (db *DB) SubscribeSince(ctx context.Context, since uint64,
+	cb func(kvs *KVList, resumeTs uint64) error, prefixes ...[]byte) error {
+	if cb == nil {
+		return ErrNilCallback
+	}
+	if len(prefixes) == 0 {
+		return errors.New("At least one prefix must be given")
+	}
+	if since == 0 {
+		since = 1 // The versions start from 1.
+	}
+
+	c := z.NewCloser(1)
+	recvCh, id := db.pub.newSubscriber(c, true, prefixes...)
+	stop := func(err error) error {
+		c.Done()
+		// Delete the subscriber to avoid further updates.
+		db.pub.deleteSubscriber(id)
+		return err
+	}
+
+	// The snapshot is taken after subscribing, so that all the changes committed after it are
+	// published to the subscriber. Keeping the read transaction open protects the snapshot from
+	// compactions.
+	var snap *Txn
+	if db.opt.managedTxns {
+		snap = db.newTransactionAt(db.MaxVersion())
+	} else {
+		snap = db.NewTransaction(false)
+	}
+	readTs := snap.readTs
+	// The published changes are buffered while the snapshot is sent, so that they don't block the
+	// writes.
+	var pending []*pb.KVList
+	done, drained := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(drained)
+		for {
+			select {
+			case kvs := <-recvCh:
+				pending = append(pending, kvs)
+			case <-done:
+				return
+			}
+		}
+	}()
+	resumeTs := since - 1
+	err := db.sendSnapshot(ctx, readTs, since, prefixes, func(kvs *KVList) error {
+		return cb(kvs, resumeTs)
+	})
+	snap.Discard()
+	close(done)
+	<-drained
+	if err != nil {
+		return stop(err)
+	}
+	if readTs > resumeTs {
+		resumeTs = readTs
+		if err := cb(&KVList{}, resumeTs); err != nil {
+			return stop(err)
+		}
+	}
+
+	// send sends the changes committed after the snapshot, and after the token.
+	minTs := resumeTs + 1
+	send := func(lists []*pb.KVList) error {
+		batch := &KVList{}
+		for _, list := range lists {
+			for _, kv := range list.Kv {
+				if kv.Version < minTs ||
+					(bytes.HasPrefix(kv.Key, badgerPrefix) && !isKeyspaceKey(kv.Key)) {
+					continue
+				}
+				if kv.Meta[0]&bitMergeEntry > 0 {
+					if err := db.mergeChange(kv); err != nil {
+						return err
+					}
+				}
+				batch.Kv = append(batch.Kv, kv)
+				if kv.Version > resumeTs {
+					resumeTs = kv.Version
+				}
+			}
+		}
+		if len(batch.Kv) == 0 {
+			return nil
+		}
+		return cb(batch, resumeTs)
+	}
+	slurp := func(batch []*pb.KVList) error {
+		for {
+			select {
+			case kvs := <-recvCh:
+				batch = append(batch, kvs)
+			default:
+				return send(batch)
+			}
+		}
+	}
+	if err := send(pending); err != nil {
+		return stop(err)
+	}
+	for {
+		select {
+		case <-c.HasBeenClosed():
+			// The subscriber is deleted by cleanSubscribers, while closing the DB.
+			err := slurp(nil)
+			c.Done()
+			return err
+		case <-ctx.Done():
+			return stop(ctx.Err())
+		case kvs := <-recvCh:
+			if err := slurp([]*pb.KVList{kvs}); err != nil {
+				return stop(err)
+			}
+		}
+	}
+}
+
+// ChangeIsDelete returns true if the change sent by SubscribeSince deletes the key, or if it has
+// expired.
+func ChangeIsDelete(kv *pb.KV) bool {
+	return len(kv.Meta) > 0 && isDeletedOrExpired(kv.Meta[0], kv.ExpiresAt)
+}
+
+// ChangeIsRangeDelete returns true if the change sent by SubscribeSince deletes the range of keys
+// [KV.Key, KV.Value).
+func ChangeIsRangeDelete(kv *pb.KV) bool {
+	return len(kv.Meta) > 0 && kv.Meta[0]&bitRangeDelete > 0
+}
+
+// rangeDeleteChange returns the change sent by SubscribeSince for a range tombstone.
+func rangeDeleteChange(start, end []byte, version uint64) *pb.KV {
+	return &pb.KV{
+		Key:      y.SafeCopy(nil, start),
+		Value:    y.SafeCopy(nil, end),
+		UserMeta: []byte{0},
+		Meta:     []byte{bitRangeDelete},
+		Version:  version,
+	}
+}
+
+// hasOverlappingPrefix returns true if any key with one of the prefixes lies in [start, end).
+func hasOverlappingPrefix(start, end []byte, prefixes [][]byte) bool {
+	for _, prefix := range prefixes {
+		if (readRange{start: prefix, end: prefixEnd(prefix)}).overlaps(start, end) {
+			return true
+		}
+	}
+	return false
+}
+
+// sendSnapshot sends the range deletions and the latest version of the keys changed at or after
+// since, as of readTs.
+func (db *DB) sendSnapshot(ctx context.Context, readTs, since uint64, prefixes [][]byte,
+	cb func(kvs *KVList) error) error {
+	if readTs < since {
+		// Nothing has changed since then.
+		return nil
+	}
+	// The range deletions go first, since they don't delete the keys of the snapshot.
+	dels := &KVList{}
+	db.rangeDels.RLock()
+	for _, rt := range db.rangeDels.tombstones {
+		if rt.version >= since && rt.version <= readTs &&
+			hasOverlappingPrefix(rt.start, rt.end, prefixes) {
+			dels.Kv = append(dels.Kv, rangeDeleteChange(rt.start, rt.end, rt.version))
+		}
+	}
+	db.rangeDels.RUnlock()
+	if len(dels.Kv) > 0 {
+		if err := cb(dels); err != nil {
+			return err
+		}
+	}
+	stream := db.newStream()
+	stream.LogPrefix = "DB.SubscribeSince"
+	stream.readTs = readTs
+	if len(prefixes) == 1 {
+		stream.Prefix = prefixes[0]
+	} else {
+		stream.ChooseKey = func(item *Item) bool {
+			return hasAnyPrefixes(item.Key(), prefixes)
+		}
+	}
+	stream.KeyToList = latestToList(since)
+	stream.Send = func(buf *z.Buffer) error {
+		list, err := BufferToKVList(buf)
+		if err != nil {
+			return err
+		}
+		out := list.Kv[:0]
+		for _, kv := range list.Kv {
+			if !kv.StreamDone {
+				out = append(out, kv)
+			}
+		}
+		if len(out) == 0 {
+			return nil
+		}
+		list.Kv = out
+		return cb(list)
+	}
+	return stream.Orchestrate(ctx)
+}
+
+// latestToList returns a KeyToList function for Stream, which picks up the latest version of the
+// keys changed at or after since. The merge operands are merged, and the deleted and expired keys
+// are picked up as well.
+func latestToList(since uint64) func(key []byte, itr *Iterator) (*pb.KVList, error) {
+	return func(key []byte, itr *Iterator) (*pb.KVList, error) {
+		item := itr.Item()
+		if item.Version() < since {
+			return nil, nil
+		}
+		a := itr.Alloc
+		meta := item.meta &^ (bitTxn | bitFinTxn | bitMergeEntry)
+		var val []byte
+		switch {
+		case item.IsDeletedOrExpired():
+		case item.meta&bitMergeEntry > 0:
+			// The iterator returns the merge operands of all the versions, so the merged value is
+			// read from the transaction.
+			merged, err := itr.txn.Get(key)
+			if err != nil {
+				return nil, err
+			}
+			if val, err = merged.ValueCopy(nil); err != nil {
+				return nil, err
+			}
+		default:
+			if err := item.Value(func(v []byte) error {
+				val = a.Copy(v)
+				return nil
+			}); err != nil {
+				return nil, err
+			}
+		}
+		kv := y.NewKV(a)
+		*kv = pb.KV{
+			Key:       a.Copy(key),
+			Value:     val,
+			UserMeta:  a.Copy([]byte{item.UserMeta()}),
+			Meta:      a.Copy([]byte{meta}),
+			ExpiresAt: item.ExpiresAt(),
+			Version:   item.Version(),
+		}
+		return &pb.KVList{Kv: []*pb.KV{kv}}, nil
+	}
+}
+
+// mergeChange replaces the merge operand in kv with the value merged at its version.
+func (db *DB) mergeChange(kv *pb.KV) error {
+	txn := db.newTransactionAt(kv.Version)
+	defer txn.Discard()
+	item, err := txn.Get(kv.Key)
+	switch {
+	case err == ErrKeyNotFound:
+		kv.Value = nil
+		kv.Meta = []byte{bitDelete}
+		return nil
+	case err != nil:
+		return err
+	}
+	if kv.Value, err = item.ValueCopy(nil); err != nil {
+		return err
+	}
+	kv.Meta = []byte{kv.Meta[0] &^ bitMergeEntry}
+	return nil
+}
@@ -0,0 +1,186 @@
+/*
+ * Copyright 2020 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package badger
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubscribeSince(t *testing.T) {
+	dir, err := ioutil.TempDir("", "badger-test")
+	require.NoError(t, err)
+	defer removeDir(dir)
+	db, err := Open(getTestOptions(dir).WithMergeOperator([]byte("m"), add))
+	require.NoError(t, err)
+	defer func() { require.NoError(t, db.Close()) }()
+
+	key := func(i int) []byte { return []byte(fmt.Sprintf("a%03d", i)) }
+	for i := 0; i < 10; i++ {
+		txnSet(t, db, key(i), []byte("old"), 0)
+		txnSet(t, db, key(i), []byte("new"), 0)
+	}
+	txnDelete(t, db, key(0))
+	txnSet(t, db, []byte("other"), []byte("value"), 0)
+
+	type change struct {
+		version uint64
+		value   string
+		deleted bool
+	}
+	var mu sync.Mutex
+	var token uint64
+	changes := make(map[string]change)
+	subscribe := func(ctx context.Context, since uint64) chan error {
+		errCh := make(chan error, 1)
+		go func() {
+			errCh <- db.SubscribeSince(ctx, since, func(kvs *KVList, resumeTs uint64) error {
+				mu.Lock()
+				defer mu.Unlock()
+				require.True(t, resumeTs >= token, "%d < %d", resumeTs, token)
+				token = resumeTs
+				for _, kv := range kvs.Kv {
+					require.True(t, kv.Version >= since)
+					changes[string(kv.Key)] = change{kv.Version, string(kv.Value), ChangeIsDelete(kv)}
+				}
+				return nil
+			}, []byte("a"), []byte("m"))
+		}()
+		return errCh
+	}
+	waitFor := func(ts uint64) {
+		waitUntil(t, func() bool {
+			mu.Lock()
+			defer mu.Unlock()
+			return token >= ts
+		})
+	}
+
+	// The snapshot holds the latest versions.
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := subscribe(ctx, 0)
+	waitFor(db.orc.readTs())
+	mu.Lock()
+	require.Len(t, changes, 10)
+	require.True(t, changes[string(key(0))].deleted)
+	require.Equal(t, "new", changes[string(key(1))].value)
+	mu.Unlock()
+
+	// The subscription switches to the live changes.
+	txnSet(t, db, key(1), []byte("live"), 0)
+	require.NoError(t, db.Update(func(txn *Txn) error {
+		require.NoError(t, txn.Merge([]byte("m"), uint64ToBytes(1)))
+		return txn.Merge([]byte("m"), uint64ToBytes(2))
+	}))
+	require.NoError(t, db.Update(func(txn *Txn) error {
+		return txn.Merge([]byte("m"), uint64ToBytes(3))
+	}))
+	last := db.orc.readTs()
+	waitFor(last)
+	cancel()
+	require.Equal(t, context.Canceled, <-errCh)
+	mu.Lock()
+	require.Equal(t, last, token)
+	require.Equal(t, "live", changes[string(key(1))].value)
+	require.Equal(t, uint64(6), bytesToUint64([]byte(changes["m"].value)))
+	mu.Unlock()
+
+	// Resume with the token, after more changes while unsubscribed.
+	txnSet(t, db, key(2), []byte("missed"), 0)
+	txnDelete(t, db, key(3))
+	mu.Lock()
+	since := token + 1
+	changes = make(map[string]change)
+	mu.Unlock()
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	errCh = subscribe(ctx, since)
+	txnSet(t, db, key(4), []byte("live"), 0)
+	waitFor(db.orc.readTs())
+	cancel()
+	require.Equal(t, context.Canceled, <-errCh)
+
+	require.Len(t, changes, 3)
+	require.Equal(t, "missed", changes[string(key(2))].value)
+	require.True(t, changes[string(key(3))].deleted)
+	require.Equal(t, "live", changes[string(key(4))].value)
+}
+
+func TestSubscribeSinceRangeDelete(t *testing.T) {
+	runBadgerTest(t, nil, func(t *testing.T, db *DB) {
+		txnSet(t, db, []byte("a1"), []byte("v"), 0)
+		require.NoError(t, db.Update(func(txn *Txn) error {
+			return txn.DeleteRange([]byte("a0"), []byte("a5"))
+		}))
+		// Outside of the subscribed prefixes.
+		require.NoError(t, db.Update(func(txn *Txn) error {
+			return txn.DeleteRange([]byte("x0"), []byte("x5"))
+		}))
+
+		var mu sync.Mutex
+		var token uint64
+		var ranges [][2]string
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		errCh := make(chan error, 1)
+		go func() {
+			errCh <- db.SubscribeSince(ctx, 0, func(kvs *KVList, resumeTs uint64) error {
+				mu.Lock()
+				defer mu.Unlock()
+				token = resumeTs
+				for _, kv := range kvs.Kv {
+					if ChangeIsRangeDelete(kv) {
+						ranges = append(ranges, [2]string{string(kv.Key), string(kv.Value)})
+					}
+				}
+				return nil
+			}, []byte("a"))
+		}()
+		waitFor := func(ts uint64) {
+			waitUntil(t, func() bool {
+				mu.Lock()
+				defer mu.Unlock()
+				return token >= ts
+			})
+		}
+
+		// The snapshot holds the range tombstone.
+		waitFor(db.orc.readTs())
+		mu.Lock()
+		require.Equal(t, [][2]string{{"a0", "a5"}}, ranges)
+		ranges = nil
+		mu.Unlock()
+
+		// Live range deletes are sent as they are committed.
+		require.NoError(t, db.Update(func(txn *Txn) error {
+			return txn.DeleteRange([]byte("a6"), []byte("a9"))
+		}))
+		require.NoError(t, db.Update(func(txn *Txn) error {
+			return txn.DeleteRange([]byte("x6"), []byte("x9"))
+		}))
+		txnSet(t, db, []byte("a7"), []byte("v"), 0)
+		waitFor(db.orc.readTs())
+		cancel()
+		require.Equal(t, context.Canceled, <-errCh)
+		require.Equal(t, [][2]string{{"a6", "a9"}}, ranges)
+	})
+}
@@ -0,0 +1,257 @@
+/*
+ * Copyright 2020 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package badger
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"github.com/dgraph-io/badger/v2/table"
+	"github.com/dgraph-io/badger/v2/y"
+	"github.com/pkg/errors"
+)
+
+// Checkpoint creates a consistent copy of the DB in the given directory, which can be opened
+// directly via Open. The directory must either not exist, or be empty. It does this in the
+// following way, while the DB keeps accepting writes:
+// - Replace the memtable, and wait for it and all the older memtables to be flushed to level
+//   zero. The checkpoint holds at least the writes made up to this point.
+// - Pause value log GC, so that no value log file gets deleted.
+// - Take a reference to all the tables in the LSM tree, so that compactions don't delete them.
+// - Hard link these tables into the directory, and write out a manifest for them. Tables are
+//   immutable, so the links would keep pointing to valid data, even after the DB deletes them.
+// - Copy the key registry.
+// - Hard link the value log files, except the one being written to, which is copied.
+// - Release the tables and resume GC.
+//
+// Files are copied instead, if they can't be hard linked, for example because the directory is
+// on a different file system. Both the tables and the value log files are placed in the given
+// directory, even if the DB uses a separate ValueDir.
+func (db *DB) Checkpoint(dir string) error {
+	if db.opt.InMemory {
+		return errors.New("Cannot create a checkpoint of an in-memory DB")
+	}
+	if db.opt.ReadOnly {
+		return errors.New("Cannot create a checkpoint of a DB opened in read-only mode")
+	}
+	if err := createCheckpointDir(dir); err != nil {
+		return err
+	}
+	db.opt.Infof("Creating checkpoint in %s", dir)
+
+	if err := db.flushMemTables(); err != nil {
+		return err
+	}
+
+	// Block value log GC, so that none of the value log files get rewritten and deleted.
+	db.vlog.garbageCh <- struct{}{}
+	defer func() {
+		<-db.vlog.garbageCh
+	}()
+
+	manifest, release := db.lc.pinTables()
+	defer release()
+
+	if err := checkpointTables(dir, db.opt.Dir, manifest); err != nil {
+		return err
+	}
+	if err := db.checkpointKeyRegistry(dir); err != nil {
+		return err
+	}
+	if err := db.vlog.checkpoint(dir); err != nil {
+		return err
+	}
+	if err := syncDir(dir); err != nil {
+		return err
+	}
+	db.opt.Infof("Checkpoint created in %s", dir)
+	return nil
+}
+
+func createCheckpointDir(dir string) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return y.Wrapf(err, "while creating checkpoint directory: %s", dir)
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return y.Wrapf(err, "while reading checkpoint directory: %s", dir)
+	}
+	if len(files) > 0 {
+		return errors.Errorf("Checkpoint directory %s is not empty", dir)
+	}
+	return nil
+}
+
+// flushMemTables makes the write goroutine replace the memtable, and waits until it has been
+// flushed to level zero along with all the older memtables. Writes continue meanwhile.
+func (db *DB) flushMemTables() error {
+	atomic.StoreInt32(&db.sealMemTable, 1)
+	// The empty request goes through the write goroutine, which replaces the memtable.
+	if err := db.batchSet(nil); err != nil {
+		return err
+	}
+
+	db.RLock()
+	var last *memTable
+	if n := len(db.imm); n > 0 {
+		last = db.imm[n-1]
+	}
+	db.RUnlock()
+	if last == nil {
+		return nil
+	}
+	// The memtables are flushed in order, so we only need to wait for the last one.
+	for {
+		db.RLock()
+		var pending bool
+		for _, mt := range db.imm {
+			pending = pending || mt == last
+		}
+		db.RUnlock()
+		if !pending {
+			return nil
+		}
+		if db.IsClosed() {
+			return ErrDBClosed
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// pinTables returns a manifest of all the tables in the LSM tree. The tables are referenced, so
+// that their files aren't deleted until the returned function is called.
+func (s *levelsController) pinTables() (Manifest, func()) {
+	// The levels are locked together, so that tables being moved by a compaction are seen at least
+	// once. Compactions also lock the levels in increasing order.
+	for _, l := range s.levels {
+		l.RLock()
+	}
+	manifest := createManifest()
+	var tables []*table.Table
+	for _, l := range s.levels {
+		for _, t := range l.tables {
+			t.IncrRef()
+			tables = append(tables, t)
+			manifest.Tables[t.ID()] = TableManifest{
+				Level:       uint8(l.level),
+				KeyID:       t.KeyID(),
+				Compression: t.CompressionType(),
+			}
+		}
+	}
+	for _, l := range s.levels {
+		l.RUnlock()
+	}
+	return manifest, func() {
+		if err := decrRefs(tables); err != nil {
+			s.kv.opt.Errorf("While releasing the tables of a checkpoint: %v", err)
+		}
+	}
+}
+
+// checkpointTables links all the tables in the manifest from srcDir into dir, and writes out the
+// manifest.
+func checkpointTables(dir, srcDir string, manifest Manifest) error {
+	for id := range manifest.Tables {
+		src := table.NewFilename(id, srcDir)
+		if err := linkOrCopyFile(src, table.NewFilename(id, dir)); err != nil {
+			return err
+		}
+	}
+	fp, _, err := helpRewrite(dir, &manifest)
+	if err != nil {
+		return y.Wrapf(err, "while writing manifest to %s", dir)
+	}
+	return fp.Close()
+}
+
+func (db *DB) checkpointKeyRegistry(dir string) error {
+	db.registry.RLock()
+	defer db.registry.RUnlock()
+
+	src := filepath.Join(db.opt.Dir, KeyRegistryFileName)
+	if _, err := os.Stat(src); os.IsNotExist(err) {
+		return nil
+	}
+	return copyFile(src, filepath.Join(dir, KeyRegistryFileName), -1)
+}
+
+// checkpoint links all the value log files into dir. The file being written to is copied
+// instead, up to the current write offset. An entry being written concurrently might be copied
+// partially, which is truncated when the checkpoint is opened. The caller must ensure that value
+// log GC is paused.
+func (vlog *valueLog) checkpoint(dir string) error {
+	vlog.filesLock.RLock()
+	fids := vlog.sortedFids()
+	maxFid := vlog.maxFid
+	offset := atomic.LoadUint32(&vlog.writableLogOffset)
+	vlog.filesLock.RUnlock()
+
+	for _, fid := range fids {
+		src := vlog.fpath(fid)
+		dst := vlogFilePath(dir, fid)
+		var err error
+		if fid == maxFid {
+			err = copyFile(src, dst, int64(offset))
+		} else {
+			err = linkOrCopyFile(src, dst)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func linkOrCopyFile(src, dst string) error {
+	if err := os.Link(src, dst); err == nil {
+		return nil
+	}
+	return copyFile(src, dst, -1)
+}
+
+// copyFile copies the first size bytes of src into a new file dst. The whole file is copied if
+// size is negative.
+func copyFile(src, dst string, size int64) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return y.Wrapf(err, "while opening file: %s", src)
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return y.Wrapf(err, "while creating file: %s", dst)
+	}
+	var r io.Reader = in
+	if size >= 0 {
+		r = io.LimitReader(in, size)
+	}
+	if _, err := io.Copy(out, r); err != nil {
+		out.Close()
+		return y.Wrapf(err, "while copying %s to %s", src, dst)
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return y.Wrapf(err, "while syncing file: %s", dst)
+	}
+	return out.Close()
+}
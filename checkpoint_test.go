@@ -0,0 +1,166 @@
+/*
+ * Copyright 2020 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package badger
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckpoint(t *testing.T) {
+	test := func(t *testing.T, opt Options) {
+		dir, err := ioutil.TempDir("", "badger-test")
+		require.NoError(t, err)
+		defer removeDir(dir)
+		cdir, err := ioutil.TempDir("", "badger-checkpoint")
+		require.NoError(t, err)
+		defer removeDir(cdir)
+
+		opt.Dir = dir
+		opt.ValueDir = dir
+		db, err := Open(opt)
+		require.NoError(t, err)
+
+		value := func(i int) []byte {
+			// Every other value goes to the value log.
+			return []byte(fmt.Sprintf("%0*d", 16+(i%2)*64, i))
+		}
+		for i := 0; i < 500; i++ {
+			txnSet(t, db, rangeKey(i), value(i), 0)
+		}
+		require.NoError(t, db.Checkpoint(cdir))
+		require.Error(t, db.Checkpoint(cdir), "checkpoint directory is not empty")
+
+		// Writes after the checkpoint should not be part of it.
+		for i := 0; i < 10; i++ {
+			txnSet(t, db, rangeKey(1000+i), value(i), 0)
+		}
+		txnDelete(t, db, rangeKey(0))
+		require.NoError(t, db.Close())
+
+		opt.Dir = cdir
+		opt.ValueDir = cdir
+		cdb, err := Open(opt)
+		require.NoError(t, err)
+		defer func() { require.NoError(t, cdb.Close()) }()
+
+		require.NoError(t, cdb.View(func(txn *Txn) error {
+			for i := 0; i < 500; i++ {
+				item, err := txn.Get(rangeKey(i))
+				require.NoError(t, err)
+				require.Equal(t, value(i), getItemValue(t, item))
+			}
+			require.Equal(t, 500, countKeys(t, txn, false))
+			return nil
+		}))
+		// The checkpoint should be writable.
+		txnSet(t, cdb, rangeKey(0), []byte("new"), 0)
+	}
+
+	t.Run("plain", func(t *testing.T) {
+		test(t, getTestOptions("").WithValueThreshold(32))
+	})
+	t.Run("with encryption", func(t *testing.T) {
+		key := make([]byte, 32)
+		_, err := rand.Read(key)
+		require.NoError(t, err)
+		opt := getTestOptions("").WithValueThreshold(32).WithEncryptionKey(key).
+			WithIndexCacheSize(10 << 20)
+		test(t, opt)
+	})
+}
+
+func TestCheckpointConcurrentWrites(t *testing.T) {
+	dir, err := ioutil.TempDir("", "badger-test")
+	require.NoError(t, err)
+	defer removeDir(dir)
+	cdir, err := ioutil.TempDir("", "badger-checkpoint")
+	require.NoError(t, err)
+	defer removeDir(cdir)
+
+	opt := getTestOptions(dir).WithValueThreshold(32)
+	db, err := Open(opt)
+	require.NoError(t, err)
+
+	for i := 0; i < 500; i++ {
+		txnSet(t, db, rangeKey(i), []byte("val"), 0)
+	}
+
+	// Writes must not be blocked while the checkpoint is being created.
+	stop := make(chan struct{})
+	errCh := make(chan error, 1)
+	go func() {
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				errCh <- nil
+				return
+			default:
+			}
+			err := db.Update(func(txn *Txn) error {
+				return txn.Set([]byte(fmt.Sprintf("new%06d", i)), []byte(fmt.Sprintf("%064d", i)))
+			})
+			if err != nil {
+				errCh <- err
+				return
+			}
+		}
+	}()
+	require.NoError(t, db.Checkpoint(cdir))
+	close(stop)
+	require.NoError(t, <-errCh)
+	require.NoError(t, db.Close())
+
+	cdb, err := Open(opt.WithDir(cdir).WithValueDir(cdir))
+	require.NoError(t, err)
+	defer func() { require.NoError(t, cdb.Close()) }()
+
+	require.NoError(t, cdb.View(func(txn *Txn) error {
+		for i := 0; i < 500; i++ {
+			_, err := txn.Get(rangeKey(i))
+			require.NoError(t, err)
+		}
+		// The concurrent writes in the checkpoint must be a prefix of the writes made.
+		opt := DefaultIteratorOptions
+		opt.Prefix = []byte("new")
+		it := txn.NewIterator(opt)
+		defer it.Close()
+		var i int
+		for it.Rewind(); it.Valid(); it.Next() {
+			require.Equal(t, fmt.Sprintf("new%06d", i), string(it.Item().Key()))
+			require.Equal(t, []byte(fmt.Sprintf("%064d", i)), getItemValue(t, it.Item()))
+			i++
+		}
+		return nil
+	}))
+}
+
+func TestCheckpointInMemory(t *testing.T) {
+	db, err := Open(DefaultOptions("").WithInMemory(true))
+	require.NoError(t, err)
+	defer func() { require.NoError(t, db.Close()) }()
+
+	dir, err := ioutil.TempDir("", "badger-checkpoint")
+	require.NoError(t, err)
+	defer removeDir(dir)
+	require.Error(t, db.Checkpoint(filepath.Join(dir, "checkpoint")))
+}
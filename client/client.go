@@ -0,0 +1,149 @@
+/*
+ * Copyright 2020 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package client accesses a DB served remotely by the server package, via the Badger gRPC
+// service. Its transactions, items and iterators follow the API of the badger package.
+package client
+
+import (
+	"context"
+	"io"
+
+	"github.com/dgraph-io/badger/v2"
+	"github.com/dgraph-io/badger/v2/pb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// Client is a client of a remote DB.
+type Client struct {
+	conn *grpc.ClientConn
+	c    pb.BadgerClient
+}
+
+// Dial connects to the DB served at addr. Without any options, the connection is insecure.
+func Dial(addr string, opts ...grpc.DialOption) (*Client, error) {
+	if len(opts) == 0 {
+		opts = []grpc.DialOption{grpc.WithInsecure()}
+	}
+	conn, err := grpc.Dial(addr, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{conn: conn, c: pb.NewBadgerClient(conn)}, nil
+}
+
+// Close closes the connection to the DB.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// NewTransaction creates a new transaction, like DB.NewTransaction. The transaction is started on
+// the server by its first call.
+func (c *Client) NewTransaction(update bool) *Txn {
+	return &Txn{c: c, update: update}
+}
+
+// View executes a function creating and managing a read-only transaction for the user, like
+// DB.View.
+func (c *Client) View(fn func(txn *Txn) error) error {
+	txn := c.NewTransaction(false)
+	defer txn.Discard()
+	return fn(txn)
+}
+
+// Update executes a function, creating and managing a read-write transaction for the user, like
+// DB.Update.
+func (c *Client) Update(fn func(txn *Txn) error) error {
+	txn := c.NewTransaction(true)
+	defer txn.Discard()
+	if err := fn(txn); err != nil {
+		return err
+	}
+	return txn.Commit()
+}
+
+// Stream calls fn with the latest version of the keys with the given prefix, like a Stream of the
+// DB. It returns once all the keys have been sent.
+func (c *Client) Stream(ctx context.Context, prefix []byte, fn func(list *pb.KVList) error) error {
+	stream, err := c.c.Stream(ctx, &pb.StreamRequest{Prefix: prefix})
+	if err != nil {
+		return fromStatus(err)
+	}
+	return recvAll(stream, fn)
+}
+
+// Subscribe calls fn with the updates of the keys with the given prefixes, like DB.Subscribe. It
+// returns when ctx is done, or when fn returns an error.
+func (c *Client) Subscribe(ctx context.Context, fn func(list *pb.KVList) error,
+	prefixes ...[]byte) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := c.c.Subscribe(ctx, &pb.SubscribeRequest{Prefixes: prefixes})
+	if err != nil {
+		return fromStatus(err)
+	}
+	err = recvAll(stream, fn)
+	if ctx.Err() != nil {
+		return nil
+	}
+	return err
+}
+
+// kvListStream is a stream of KVLists, returned by the Stream and Subscribe calls.
+type kvListStream interface {
+	Recv() (*pb.KVList, error)
+}
+
+func recvAll(stream kvListStream, fn func(list *pb.KVList) error) error {
+	for {
+		list, err := stream.Recv()
+		switch {
+		case err == io.EOF:
+			return nil
+		case err != nil:
+			return fromStatus(err)
+		}
+		if err := fn(list); err != nil {
+			return err
+		}
+	}
+}
+
+// knownErrors are the errors of the DB returned by the server, which are converted back to the
+// errors of the badger package.
+var knownErrors = []error{
+	badger.ErrKeyNotFound,
+	badger.ErrConflict,
+	badger.ErrTxnTooBig,
+	badger.ErrReadOnlyTxn,
+	badger.ErrDiscardedTxn,
+	badger.ErrEmptyKey,
+	badger.ErrInvalidKey,
+}
+
+func fromStatus(err error) error {
+	s, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+	for _, e := range knownErrors {
+		if s.Message() == e.Error() {
+			return e
+		}
+	}
+	return err
+}
@@ -0,0 +1,236 @@
+/*
+ * Copyright 2020 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/badger/v2"
+	"github.com/dgraph-io/badger/v2/pb"
+	"github.com/dgraph-io/badger/v2/server"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+// serveDB serves a new DB, and returns it along with a client connected to it.
+func serveDB(t *testing.T) (*badger.DB, *Client, func()) {
+	dir, err := ioutil.TempDir("", "badger-test")
+	require.NoError(t, err)
+	db, err := badger.Open(badger.DefaultOptions(dir).WithLogger(nil))
+	require.NoError(t, err)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	srv := server.New(db, time.Minute)
+	gs := grpc.NewServer()
+	srv.Register(gs)
+	go gs.Serve(l)
+
+	c, err := Dial(l.Addr().String())
+	require.NoError(t, err)
+	return db, c, func() {
+		require.NoError(t, c.Close())
+		gs.Stop()
+		srv.Close()
+		require.NoError(t, db.Close())
+		require.NoError(t, os.RemoveAll(dir))
+	}
+}
+
+func TestClientTxn(t *testing.T) {
+	db, c, cleanup := serveDB(t)
+	defer cleanup()
+
+	require.NoError(t, c.Update(func(txn *Txn) error {
+		require.NoError(t, txn.SetEntry(badger.NewEntry([]byte("a"), []byte("1")).
+			WithMeta(0x02)))
+		require.NoError(t, txn.Set([]byte("b"), []byte("2")))
+		// The writes of the transaction are visible to it.
+		item, err := txn.Get([]byte("a"))
+		require.NoError(t, err)
+		val, err := item.ValueCopy(nil)
+		require.NoError(t, err)
+		require.Equal(t, []byte("1"), val)
+		return nil
+	}))
+	require.NoError(t, db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte("a"))
+		require.NoError(t, err)
+		require.Equal(t, byte(0x02), item.UserMeta())
+		return nil
+	}))
+
+	require.NoError(t, c.Update(func(txn *Txn) error {
+		return txn.Delete([]byte("b"))
+	}))
+	require.NoError(t, c.View(func(txn *Txn) error {
+		_, err := txn.Get([]byte("b"))
+		require.Equal(t, badger.ErrKeyNotFound, err)
+		require.Equal(t, badger.ErrReadOnlyTxn, txn.Set([]byte("c"), nil))
+		item, err := txn.Get([]byte("a"))
+		require.NoError(t, err)
+		require.Equal(t, db.MaxVersion()-1, item.Version())
+		return nil
+	}))
+
+	// Conflicting transactions are detected on the server.
+	txn1, txn2 := c.NewTransaction(true), c.NewTransaction(true)
+	defer txn1.Discard()
+	defer txn2.Discard()
+	_, err := txn1.Get([]byte("a"))
+	require.NoError(t, err)
+	require.NoError(t, txn1.Set([]byte("a"), []byte("x")))
+	_, err = txn2.Get([]byte("a"))
+	require.NoError(t, err)
+	require.NoError(t, txn2.Set([]byte("a"), []byte("y")))
+	require.NoError(t, txn1.Commit())
+	require.Equal(t, badger.ErrConflict, txn2.Commit())
+	require.Equal(t, badger.ErrDiscardedTxn, txn2.Commit())
+
+	// The transactions can only be used on the connection which began them.
+	txn := c.NewTransaction(false)
+	defer txn.Discard()
+	_, err = txn.Get([]byte("a"))
+	require.NoError(t, err)
+	other, err := Dial(c.conn.Target())
+	require.NoError(t, err)
+	defer other.Close()
+	_, err = (&Txn{c: other, id: txn.id}).Get([]byte("a"))
+	require.Equal(t, badger.ErrDiscardedTxn, err)
+	_, err = txn.Get([]byte("a"))
+	require.NoError(t, err)
+}
+
+func TestClientIterator(t *testing.T) {
+	_, c, cleanup := serveDB(t)
+	defer cleanup()
+
+	// More keys than fit in a batch of the server.
+	key := func(i int) []byte { return []byte(fmt.Sprintf("key%05d", i)) }
+	const n = 2500
+	require.NoError(t, c.Update(func(txn *Txn) error {
+		for i := 0; i < n; i++ {
+			if err := txn.Set(key(i), []byte("v")); err != nil {
+				return err
+			}
+		}
+		return txn.Set([]byte("other"), nil)
+	}))
+
+	require.NoError(t, c.View(func(txn *Txn) error {
+		it := txn.NewIterator(IteratorOptions{Prefix: []byte("key")})
+		defer it.Close()
+		var i int
+		for it.Rewind(); it.Valid(); it.Next() {
+			require.Equal(t, key(i), it.Item().Key())
+			// The transaction can be used while iterating.
+			if i == 1500 {
+				_, err := txn.Get([]byte("other"))
+				require.NoError(t, err)
+			}
+			i++
+		}
+		require.NoError(t, it.Err())
+		require.Equal(t, n, i)
+
+		it.Seek(key(n - 10))
+		for i = 0; it.Valid(); it.Next() {
+			i++
+		}
+		require.Equal(t, 10, i)
+		return nil
+	}))
+
+	var count int
+	require.NoError(t, c.Stream(context.Background(), []byte("key"),
+		func(list *pb.KVList) error {
+			count += len(list.Kv)
+			return nil
+		}))
+	require.Equal(t, n, count)
+}
+
+func TestClientIteratorAllKeys(t *testing.T) {
+	_, c, cleanup := serveDB(t)
+	defer cleanup()
+
+	require.NoError(t, c.Update(func(txn *Txn) error {
+		for _, key := range []string{"a", "b", "c"} {
+			if err := txn.Set([]byte(key), []byte("v")); err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+	// Without a prefix, the iteration covers all the keys.
+	require.NoError(t, c.View(func(txn *Txn) error {
+		it := txn.NewIterator(IteratorOptions{})
+		defer it.Close()
+		var keys []string
+		for it.Rewind(); it.Valid(); it.Next() {
+			keys = append(keys, string(it.Item().Key()))
+		}
+		require.NoError(t, it.Err())
+		require.Equal(t, []string{"a", "b", "c"}, keys)
+		return nil
+	}))
+}
+
+func TestClientSubscribe(t *testing.T) {
+	_, c, cleanup := serveDB(t)
+	defer cleanup()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	updates := make(chan *pb.KV, 10)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- c.Subscribe(ctx, func(list *pb.KVList) error {
+			for _, kv := range list.Kv {
+				select {
+				case updates <- kv:
+				case <-ctx.Done():
+				}
+			}
+			return nil
+		}, []byte("a"))
+	}()
+
+	// Keep writing until the subscription is active.
+	var kv *pb.KV
+	for deadline := time.Now().Add(5 * time.Second); kv == nil; {
+		require.True(t, time.Now().Before(deadline), "No update received")
+		require.NoError(t, c.Update(func(txn *Txn) error {
+			if err := txn.Set([]byte("b"), nil); err != nil {
+				return err
+			}
+			return txn.Set([]byte("a"), []byte("value"))
+		}))
+		select {
+		case kv = <-updates:
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	require.Equal(t, []byte("a"), kv.Key)
+	require.Equal(t, []byte("value"), kv.Value)
+	cancel()
+	require.NoError(t, <-errCh)
+}
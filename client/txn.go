@@ -0,0 +1,277 @@
+/*
+ * Copyright 2020 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package client
+
+import (
+	"bytes"
+	"context"
+	"io"
+
+	"github.com/dgraph-io/badger/v2"
+	"github.com/dgraph-io/badger/v2/pb"
+)
+
+// Txn is a transaction on a remote DB. Like the transactions of the badger package, it must not
+// be used concurrently, and must be discarded once done.
+type Txn struct {
+	c      *Client
+	update bool
+
+	// id is the ID of the transaction on the server, once started.
+	id     uint64
+	readTs uint64
+	done   bool
+}
+
+// begin starts the transaction on the server, if needed.
+func (txn *Txn) begin() error {
+	switch {
+	case txn.done:
+		return badger.ErrDiscardedTxn
+	case txn.id != 0:
+		return nil
+	}
+	out, err := txn.c.c.Begin(context.Background(), &pb.BeginRequest{Update: txn.update})
+	if err != nil {
+		return fromStatus(err)
+	}
+	txn.id, txn.readTs = out.TxnId, out.ReadTs
+	return nil
+}
+
+// ReadTs returns the read timestamp of the transaction.
+func (txn *Txn) ReadTs() (uint64, error) {
+	if err := txn.begin(); err != nil {
+		return 0, err
+	}
+	return txn.readTs, nil
+}
+
+// Get looks for key and returns the corresponding Item. If key is not found, ErrKeyNotFound is
+// returned.
+func (txn *Txn) Get(key []byte) (*Item, error) {
+	if err := txn.begin(); err != nil {
+		return nil, err
+	}
+	out, err := txn.c.c.Get(context.Background(), &pb.GetRequest{TxnId: txn.id, Key: key})
+	if err != nil {
+		return nil, fromStatus(err)
+	}
+	return &Item{kv: out.Item}, nil
+}
+
+// Set adds a key-value pair to the transaction.
+func (txn *Txn) Set(key, val []byte) error {
+	return txn.SetEntry(badger.NewEntry(key, val))
+}
+
+// SetEntry adds the key, value, user meta and expiry of e to the transaction.
+func (txn *Txn) SetEntry(e *badger.Entry) error {
+	if !txn.update {
+		return badger.ErrReadOnlyTxn
+	}
+	if err := txn.begin(); err != nil {
+		return err
+	}
+	_, err := txn.c.c.Set(context.Background(), &pb.SetRequest{
+		TxnId:     txn.id,
+		Key:       e.Key,
+		Value:     e.Value,
+		UserMeta:  uint32(e.UserMeta),
+		ExpiresAt: e.ExpiresAt,
+	})
+	return fromStatus(err)
+}
+
+// Delete deletes a key.
+func (txn *Txn) Delete(key []byte) error {
+	if !txn.update {
+		return badger.ErrReadOnlyTxn
+	}
+	if err := txn.begin(); err != nil {
+		return err
+	}
+	_, err := txn.c.c.Delete(context.Background(), &pb.DeleteRequest{TxnId: txn.id, Key: key})
+	return fromStatus(err)
+}
+
+// Commit commits the transaction. It returns ErrConflict if the transaction conflicts with
+// another one.
+func (txn *Txn) Commit() error {
+	if txn.done {
+		return badger.ErrDiscardedTxn
+	}
+	txn.done = true
+	if txn.id == 0 {
+		return nil
+	}
+	_, err := txn.c.c.Commit(context.Background(), &pb.CommitRequest{TxnId: txn.id})
+	return fromStatus(err)
+}
+
+// Discard discards the transaction. It can be called after Commit.
+func (txn *Txn) Discard() {
+	if txn.done {
+		return
+	}
+	txn.done = true
+	if txn.id != 0 {
+		// The server discards the transaction anyway, once idle.
+		_, _ = txn.c.c.Discard(context.Background(), &pb.DiscardRequest{TxnId: txn.id})
+	}
+}
+
+// Item is a key-value pair returned by a Txn or an Iterator.
+type Item struct {
+	kv *pb.KV
+}
+
+// Key returns the key. It must not be modified.
+func (item *Item) Key() []byte {
+	return item.kv.Key
+}
+
+// KeyCopy returns a copy of the key, appended to dst.
+func (item *Item) KeyCopy(dst []byte) []byte {
+	return append(dst[:0], item.kv.Key...)
+}
+
+// Value calls fn with the value. The value must not be modified.
+func (item *Item) Value(fn func(val []byte) error) error {
+	return fn(item.kv.Value)
+}
+
+// ValueCopy returns a copy of the value, appended to dst.
+func (item *Item) ValueCopy(dst []byte) ([]byte, error) {
+	return append(dst[:0], item.kv.Value...), nil
+}
+
+// Version returns the commit timestamp of the item.
+func (item *Item) Version() uint64 {
+	return item.kv.Version
+}
+
+// UserMeta returns the user metadata set with the value.
+func (item *Item) UserMeta() byte {
+	if len(item.kv.UserMeta) == 0 {
+		return 0
+	}
+	return item.kv.UserMeta[0]
+}
+
+// ExpiresAt returns the Unix time in seconds at which the item expires, or 0 if it doesn't.
+func (item *Item) ExpiresAt() uint64 {
+	return item.kv.ExpiresAt
+}
+
+// IteratorOptions is used to set the options of an Iterator.
+type IteratorOptions struct {
+	// Prefix restricts the iteration to the keys with this prefix.
+	Prefix []byte
+}
+
+// Iterator iterates over the keys of a transaction, in ascending order. The items are fetched
+// from the server in batches.
+type Iterator struct {
+	txn    *Txn
+	opt    IteratorOptions
+	cancel context.CancelFunc
+	stream pb.Badger_ScanClient
+	batch  []*pb.KV
+	err    error
+}
+
+// NewIterator returns a new Iterator. It must be closed once done.
+func (txn *Txn) NewIterator(opt IteratorOptions) *Iterator {
+	return &Iterator{txn: txn, opt: opt}
+}
+
+// Seek moves to the smallest key greater than or equal to key.
+func (it *Iterator) Seek(key []byte) {
+	it.Close()
+	it.err = nil
+	if it.err = it.txn.begin(); it.err != nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := it.txn.c.c.Scan(ctx, &pb.ScanRequest{
+		TxnId:  it.txn.id,
+		Prefix: it.opt.Prefix,
+		Start:  key,
+	})
+	if err != nil {
+		cancel()
+		it.err = fromStatus(err)
+		return
+	}
+	it.cancel, it.stream = cancel, stream
+	it.fill()
+}
+
+// Rewind moves to the first key.
+func (it *Iterator) Rewind() {
+	it.Seek(nil)
+}
+
+// fill receives the next batch, once the current one has been consumed.
+func (it *Iterator) fill() {
+	for len(it.batch) == 0 && it.stream != nil {
+		list, err := it.stream.Recv()
+		if err != nil {
+			if err != io.EOF {
+				it.err = fromStatus(err)
+			}
+			it.Close()
+			return
+		}
+		it.batch = list.Kv
+	}
+}
+
+// Valid returns false when the iteration is done, or has failed. See Err.
+func (it *Iterator) Valid() bool {
+	return len(it.batch) > 0
+}
+
+// ValidForPrefix returns false when the iteration is done, or the key doesn't have prefix.
+func (it *Iterator) ValidForPrefix(prefix []byte) bool {
+	return it.Valid() && bytes.HasPrefix(it.batch[0].Key, prefix)
+}
+
+// Item returns the current item, which stays valid after Next.
+func (it *Iterator) Item() *Item {
+	return &Item{kv: it.batch[0]}
+}
+
+// Next moves to the next item.
+func (it *Iterator) Next() {
+	it.batch = it.batch[1:]
+	it.fill()
+}
+
+// Err returns the error which ended the iteration, if any.
+func (it *Iterator) Err() error {
+	return it.err
+}
+
+// Close stops the iteration.
+func (it *Iterator) Close() {
+	if it.cancel != nil {
+		it.cancel()
+	}
+	it.cancel, it.stream, it.batch = nil, nil, nil
+}
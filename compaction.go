@@ -232,3 +232,30 @@ func (cs *compactStatus) delete(cd compactDef) {
 		delete(cs.tables, t.ID())
 	}
 }
+
+// addRewrite registers the rewrite of table t within level l, which doesn't move the table to
+// the next level. It returns false if the key range of t is being compacted.
+func (cs *compactStatus) addRewrite(_ levelHandlerRLocked, l int, t *table.Table,
+	kr keyRange) bool {
+	cs.Lock()
+	defer cs.Unlock()
+
+	if _, ok := cs.tables[t.ID()]; ok {
+		return false
+	}
+	thisLevel := cs.levels[l]
+	if thisLevel.overlapsWith(kr) {
+		return false
+	}
+	thisLevel.ranges = append(thisLevel.ranges, kr)
+	cs.tables[t.ID()] = struct{}{}
+	return true
+}
+
+func (cs *compactStatus) deleteRewrite(l int, t *table.Table, kr keyRange) {
+	cs.Lock()
+	defer cs.Unlock()
+
+	y.AssertTrue(cs.levels[l].remove(kr))
+	delete(cs.tables, t.ID())
+}
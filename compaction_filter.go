@@ -0,0 +1,92 @@
+/*
+ * Copyright 2020 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package badger
+
+import (
+	"bytes"
+
+	"github.com/dgraph-io/badger/v2/y"
+)
+
+// CompactionDecision tells the compaction what to do with a version of a key passed to a
+// CompactionFilter.
+type CompactionDecision int
+
+const (
+	// CompactionKeep keeps the version of the key as it is.
+	CompactionKeep CompactionDecision = iota
+	// CompactionRemove deletes the key as of this version. The version is replaced by a delete
+	// marker, which is dropped along with all the older versions of the key once no transaction
+	// can read them anymore.
+	CompactionRemove
+	// CompactionChangeValue replaces the value of this version with the value returned by the
+	// filter. The new value is always stored in the LSM tree.
+	CompactionChangeValue
+)
+
+// CompactionFilter allows the application to drop or rewrite keys while they're being compacted.
+// This is useful for garbage collecting logically dead records without a separate pass over
+// the DB.
+type CompactionFilter interface {
+	// Filter is called for every version of every user key processed by a compaction, which no
+	// running transaction can read anymore, except for delete markers, expired entries and merge
+	// operands. level is the level the compacted tables are being written to. The value is read
+	// from the value log if needed. The filter must not retain key or value after returning. The
+	// returned value is only used with CompactionChangeValue.
+	//
+	// Filter is called concurrently from multiple compactions, and should not block.
+	Filter(level int, key []byte, version uint64, value []byte,
+		userMeta byte) (CompactionDecision, []byte)
+}
+
+// applyCompactionFilter runs the compaction filter on the given key and returns the value struct
+// which should be written for it. updateStats is called with the value struct being replaced, so
+// that the space it occupies in the value log can be reclaimed.
+func (s *levelsController) applyCompactionFilter(level int, key []byte, vs y.ValueStruct,
+	updateStats func(y.ValueStruct)) (y.ValueStruct, error) {
+
+	if bytes.HasPrefix(key, badgerPrefix) || isDeletedOrExpired(vs.Meta, vs.ExpiresAt) {
+		return vs, nil
+	}
+
+	value := vs.Value
+	if vs.Meta&bitValuePointer > 0 {
+		var vp valuePointer
+		vp.Decode(vs.Value)
+		buf, cb, err := s.kv.vlog.Read(vp, new(y.Slice))
+		defer runCallback(cb)
+		if err != nil {
+			return vs, y.Wrapf(err, "while reading value for compaction filter")
+		}
+		value = buf
+	}
+
+	decision, newValue := s.kv.opt.CompactionFilter.Filter(
+		level, y.ParseKey(key), y.ParseTs(key), value, vs.UserMeta)
+	switch decision {
+	case CompactionRemove:
+		updateStats(vs)
+		return y.ValueStruct{Meta: bitDelete, Version: vs.Version}, nil
+	case CompactionChangeValue:
+		updateStats(vs)
+		vs.Meta &^= bitValuePointer
+		vs.Value = newValue
+		return vs, nil
+	default:
+		return vs, nil
+	}
+}
@@ -0,0 +1,144 @@
+/*
+ * Copyright 2020 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package badger
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// testFilter removes the values prefixed with "dead" and rewrites the ones prefixed with "old".
+type testFilter struct {
+	calls int32
+}
+
+func (f *testFilter) Filter(level int, key []byte, version uint64, value []byte,
+	userMeta byte) (CompactionDecision, []byte) {
+	atomic.AddInt32(&f.calls, 1)
+	switch {
+	case bytes.HasPrefix(value, []byte("dead")):
+		return CompactionRemove, nil
+	case bytes.HasPrefix(value, []byte("old")):
+		return CompactionChangeValue, append([]byte("new"), value[3:]...)
+	}
+	return CompactionKeep, nil
+}
+
+func TestCompactionFilter(t *testing.T) {
+	opt := DefaultOptions("").WithNumCompactors(0).WithNumVersionsToKeep(1).
+		WithCompactionFilter(&testFilter{})
+	opt.managedTxns = true
+
+	run := func(t *testing.T, discardTs uint64, after []keyValVersion) {
+		runBadgerTest(t, &opt, func(t *testing.T, db *DB) {
+			l0 := []keyValVersion{
+				{"a", "dead", 3, 0}, {"a", "bar", 2, 0}, {"b", "old", 2, 0}, {"c", "bar", 2, 0},
+			}
+			l1 := []keyValVersion{
+				{"a", "bar", 1, 0}, {"d", "dead", 1, bitDelete}, {"e", "dead", 1, bitMergeEntry},
+			}
+			createAndOpen(db, l0, 0)
+			createAndOpen(db, l1, 1)
+
+			db.SetDiscardTs(discardTs)
+			cdef := compactDef{
+				thisLevel: db.lc.levels[0],
+				nextLevel: db.lc.levels[1],
+				top:       db.lc.levels[0].tables,
+				bot:       db.lc.levels[1].tables,
+				t:         db.lc.levelTargets(),
+			}
+			cdef.t.baseLevel = 1
+			require.NoError(t, db.lc.runCompactDef(-1, 0, cdef))
+			getAllAndCheck(t, db, after)
+		})
+	}
+	t.Run("keys below discardTs", func(t *testing.T) {
+		// All versions of a are dropped, since the latest version was removed. The merge
+		// operand for e is not passed to the filter.
+		run(t, 10, []keyValVersion{
+			{"b", "new", 2, 0}, {"c", "bar", 2, 0}, {"e", "dead", 1, bitMergeEntry},
+		})
+	})
+	t.Run("keys above discardTs", func(t *testing.T) {
+		// The filter is only run on a@1, since the other versions can still be read. The
+		// existing delete marker for d is dropped as usual.
+		run(t, 1, []keyValVersion{
+			{"a", "dead", 3, 0}, {"a", "bar", 2, 0}, {"a", "bar", 1, 0},
+			{"b", "old", 2, 0}, {"c", "bar", 2, 0}, {"e", "dead", 1, bitMergeEntry},
+		})
+	})
+}
+
+func TestCompactionFilterValueLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "badger-test")
+	require.NoError(t, err)
+	defer removeDir(dir)
+
+	filter := &testFilter{}
+	opt := getTestOptions(dir).WithValueThreshold(32).WithNumCompactors(0)
+	db, err := Open(opt)
+	require.NoError(t, err)
+
+	value := func(prefix string, i int) []byte {
+		return []byte(fmt.Sprintf("%s-%064d", prefix, i))
+	}
+	for i := 0; i < 30; i++ {
+		prefix := []string{"dead", "old", "live"}[i%3]
+		txnSet(t, db, rangeKey(i), value(prefix, i), 0)
+	}
+	// Closing the DB flushes the memtable to level 0.
+	require.NoError(t, db.Close())
+
+	db, err = Open(opt.WithCompactionFilter(filter))
+	require.NoError(t, err)
+	defer func() { require.NoError(t, db.Close()) }()
+
+	cdef := compactDef{
+		thisLevel: db.lc.levels[0],
+		nextLevel: db.lc.levels[1],
+		top:       db.lc.levels[0].tables,
+		bot:       db.lc.levels[1].tables,
+		t:         db.lc.levelTargets(),
+	}
+	cdef.t.baseLevel = 1
+	require.NotEmpty(t, cdef.top)
+	require.NoError(t, db.lc.runCompactDef(-1, 0, cdef))
+	require.Equal(t, int32(30), atomic.LoadInt32(&filter.calls))
+
+	require.NoError(t, db.View(func(txn *Txn) error {
+		for i := 0; i < 30; i++ {
+			item, err := txn.Get(rangeKey(i))
+			switch i % 3 {
+			case 0:
+				require.Equal(t, ErrKeyNotFound, err)
+			case 1:
+				require.NoError(t, err)
+				require.Equal(t, value("new", i), getItemValue(t, item))
+			case 2:
+				require.NoError(t, err)
+				require.Equal(t, value("live", i), getItemValue(t, item))
+			}
+		}
+		return nil
+	}))
+}
@@ -59,6 +59,7 @@ type closers struct {
 	valueGC     *z.Closer
 	pub         *z.Closer
 	cacheHealth *z.Closer
+	expiry      *z.Closer
 }
 
 // DB provides the various functions required to interact with Badger.
@@ -88,14 +89,44 @@ type DB struct {
 
 	blockWrites int32
 	isClosed    uint32
+	// ingestLock is held by IngestExternalFiles, which places tables in the levels with the
+	// compactions stopped. Close, DropAll, DropPrefix and Flatten take it as well, so that they
+	// don't stop and restart the compactions concurrently with an ingestion.
+	ingestLock sync.Mutex
+	// sealMemTable is set to make the write goroutine replace the memtable, even if it isn't
+	// full. It is cleared once the memtable has been handed over for flushing.
+	sealMemTable int32
 
 	orc *oracle
 
-	pub        *publisher
-	registry   *KeyRegistry
-	blockCache *ristretto.Cache
-	indexCache *ristretto.Cache
-	allocPool  *z.AllocatorPool
+	// Range tombstones written via DeleteRange.
+	rangeDels rangeDelList
+	// Keyspaces created via CreateKeyspace.
+	keyspaces keyspaceList
+	metrics   *Metrics
+	// locks holds the key locks taken by Txn.GetForUpdate.
+	locks *lockManager
+	// snapshotLock serializes CreateSnapshot and ReleaseSnapshot.
+	snapshotLock sync.Mutex
+	// indexes holds the secondary indexes added via AddIndex, guarded by indexLock.
+	// indexDefLock serializes AddIndex and DropIndex.
+	indexLock    sync.RWMutex
+	indexes      *indexSet
+	indexDefLock sync.Mutex
+
+	pub      *publisher
+	registry *KeyRegistry
+	// expired queues the expired versions dropped by the compactions for Options.OnExpire, if set.
+	expired chan expiredVersion
+	// reencryptKeyID is the ID of the data key generated by the last ReencryptAll. The memtable
+	// and value log files encrypted with older keys are replaced as soon as possible.
+	reencryptKeyID uint64
+	// primary ships the committed writes to the replicas, if created via NewPrimary.
+	primaryLock sync.RWMutex
+	primary     *Primary
+	blockCache  *ristretto.Cache
+	indexCache  *ristretto.Cache
+	allocPool   *z.AllocatorPool
 }
 
 const (
@@ -145,7 +176,12 @@ func checkAndSetOptions(opt *Options) error {
 		opt.CompactL0OnClose = false
 	}
 
-	needCache := (opt.Compression != options.None) || (len(opt.EncryptionKey) > 0)
+	if len(opt.EncryptionKey) > 0 && opt.KeyManager != nil {
+		return errors.New("Only one of EncryptionKey and KeyManager can be set")
+	}
+
+	needCache := (opt.Compression != options.None) || (len(opt.EncryptionKey) > 0) ||
+		opt.KeyManager != nil
 	if needCache && opt.BlockCacheSize == 0 {
 		panic("BlockCacheSize should be set since compression/encryption are enabled")
 	}
@@ -218,9 +254,11 @@ func Open(opt Options) (*DB, error) {
 		dirLockGuard:  dirLockGuard,
 		valueDirGuard: valueDirLockGuard,
 		orc:           newOracle(opt),
+		locks:         newLockManager(opt),
 		pub:           newPublisher(),
 		allocPool:     z.NewAllocatorPool(8),
 	}
+	db.metrics = newMetrics(db)
 	// Cleanup all the goroutines started by badger in case of an error.
 	defer func() {
 		if err != nil {
@@ -287,6 +325,7 @@ func Open(opt Options) (*DB, error) {
 		EncryptionKey:                 opt.EncryptionKey,
 		EncryptionKeyRotationDuration: opt.EncryptionKeyRotationDuration,
 		InMemory:                      opt.InMemory,
+		KeyManager:                    opt.KeyManager,
 	}
 
 	if db.registry, err = OpenKeyRegistry(krOpt); err != nil {
@@ -314,7 +353,25 @@ func Open(opt Options) (*DB, error) {
 	// Initialize vlog struct.
 	db.vlog.init(db)
 
+	if err = db.loadRangeTombstones(); err != nil {
+		return db, y.Wrapf(err, "while loading range tombstones")
+	}
+	if err = db.loadKeyspaces(); err != nil {
+		return db, y.Wrapf(err, "while loading keyspaces")
+	}
+	if err = db.loadClock(); err != nil {
+		return db, y.Wrapf(err, "while loading the clock samples")
+	}
+	if err = db.loadSnapshots(); err != nil {
+		return db, y.Wrapf(err, "while loading snapshots")
+	}
+
 	if !opt.ReadOnly {
+		if opt.OnExpire != nil {
+			db.expired = make(chan expiredVersion, expiryQueueSize)
+			db.closers.expiry = z.NewCloser(1)
+			go db.runOnExpire(db.closers.expiry)
+		}
 		db.closers.compactors = z.NewCloser(1)
 		db.lc.startCompact(db.closers.compactors)
 
@@ -423,6 +480,9 @@ func (db *DB) monitorCache(c *z.Closer) {
 func (db *DB) cleanup() {
 	db.stopMemoryFlush()
 	db.stopCompactions()
+	if db.closers.expiry != nil {
+		db.closers.expiry.SignalAndWait()
+	}
 
 	db.blockCache.Close()
 	db.indexCache.Close()
@@ -484,6 +544,9 @@ func (db *DB) close() (err error) {
 	db.opt.Infof("Lifetime L0 stalled for: %s\n", time.Duration(atomic.LoadInt64(&db.lc.l0stallsMs)))
 
 	atomic.StoreInt32(&db.blockWrites, 1)
+	// Wait for the ingestion in progress, if any. The later ones fail since writes are blocked.
+	db.ingestLock.Lock()
+	defer db.ingestLock.Unlock()
 
 	if !db.opt.InMemory {
 		// Stop value GC first.
@@ -559,6 +622,10 @@ func (db *DB) close() (err error) {
 		}
 	}
 
+	if db.closers.expiry != nil {
+		db.closers.expiry.SignalAndWait()
+	}
+
 	db.opt.Infof(db.LevelsToString())
 	if lcErr := db.lc.close(); err == nil {
 		err = y.Wrap(lcErr, "DB.Close")
@@ -625,7 +692,11 @@ func (db *DB) Sync() error {
 func (db *DB) getMemTables() ([]*memTable, func()) {
 	db.RLock()
 	defer db.RUnlock()
+	return db.getMemTablesLocked()
+}
 
+// getMemTablesLocked is like getMemTables, but must be called with db.RLock held.
+func (db *DB) getMemTablesLocked() ([]*memTable, func()) {
 	var tables []*memTable
 
 	// Mutable memtable does not exist in read-only mode.
@@ -673,9 +744,11 @@ func (db *DB) get(key []byte) (y.ValueStruct, error) {
 	version := y.ParseTs(key)
 
 	y.NumGets.Add(1)
+	db.metrics.Gets.Add(1)
 	for i := 0; i < len(tables); i++ {
 		vs := tables[i].sl.Get(key)
 		y.NumMemtableGets.Add(1)
+		db.metrics.MemtableGets.Add(1)
 		if vs.Meta == 0 && vs.Value == nil {
 			continue
 		}
@@ -696,8 +769,10 @@ var requestPool = sync.Pool{
 	},
 }
 
-func (opt Options) skipVlog(e *Entry) bool {
-	return len(e.Value) < opt.ValueThreshold
+// skipVlog returns true if the value of the entry should be stored in the LSM tree, instead of the
+// value log. The value threshold of the keyspace of the entry is used, if it has one.
+func (db *DB) skipVlog(e *Entry) bool {
+	return len(e.Value) < db.valueThreshold(e.Key)
 }
 
 func (db *DB) writeToLSM(b *request) error {
@@ -710,7 +785,7 @@ func (db *DB) writeToLSM(b *request) error {
 
 	for i, entry := range b.Entries {
 		var err error
-		if db.opt.skipVlog(entry) {
+		if db.skipVlog(entry) {
 			// Will include deletion / tombstone case.
 			err = db.mt.Put(entry.Key,
 				y.ValueStruct{
@@ -736,6 +811,11 @@ func (db *DB) writeToLSM(b *request) error {
 		if err != nil {
 			return y.Wrapf(err, "while writing to memTable")
 		}
+		if entry.meta&bitRangeDelete > 0 {
+			if err := db.addRangeTombstone(entry.Key); err != nil {
+				return err
+			}
+		}
 	}
 	if db.opt.SyncWrites {
 		return db.mt.SyncWAL()
@@ -749,11 +829,13 @@ func (db *DB) writeRequests(reqs []*request) error {
 		return nil
 	}
 
+	timeStart := time.Now()
 	done := func(err error) {
 		for _, r := range reqs {
 			r.Err = err
 			r.Wg.Done()
 		}
+		db.metrics.SetLatency.Observe(time.Since(timeStart))
 	}
 	db.opt.Debugf("writeRequests called. Writing to value log")
 	err := db.vlog.write(reqs)
@@ -762,16 +844,18 @@ func (db *DB) writeRequests(reqs []*request) error {
 		return err
 	}
 
-	db.opt.Debugf("Sending updates to subscribers")
-	db.pub.sendUpdates(reqs)
 	db.opt.Debugf("Writing to memtable")
 	var count int
 	for _, b := range reqs {
-		if len(b.Entries) == 0 {
+		// An empty request is still used to replace a memtable encrypted with a stale data key,
+		// or one which has to be sealed.
+		if len(b.Entries) == 0 && !db.staleKey(db.mt.wal) &&
+			atomic.LoadInt32(&db.sealMemTable) == 0 {
 			continue
 		}
 		count += len(b.Entries)
 		var i uint64
+		stallStart := time.Now()
 		for err = db.ensureRoomForWrite(); err == errNoRoom; err = db.ensureRoomForWrite() {
 			i++
 			if i%100 == 0 {
@@ -782,6 +866,9 @@ func (db *DB) writeRequests(reqs []*request) error {
 			// you will get a deadlock.
 			time.Sleep(10 * time.Millisecond)
 		}
+		if i > 0 {
+			db.metrics.StallTime.Add(int64(time.Since(stallStart)))
+		}
 		if err != nil {
 			done(err)
 			return y.Wrap(err, "writeRequests")
@@ -791,6 +878,15 @@ func (db *DB) writeRequests(reqs []*request) error {
 			return y.Wrap(err, "writeRequests")
 		}
 	}
+	// The updates are published once they are readable, so that the subscribers can read the
+	// DB at their version.
+	db.opt.Debugf("Sending updates to subscribers")
+	db.pub.sendUpdates(reqs)
+	db.primaryLock.RLock()
+	if db.primary != nil {
+		db.primary.add(reqs)
+	}
+	db.primaryLock.RUnlock()
 	done(nil)
 	db.opt.Debugf("%d entries written", count)
 	return nil
@@ -818,6 +914,7 @@ func (db *DB) sendToWriteCh(entries []*Entry) (*request, error) {
 	req.IncrRef()     // for db write
 	db.writeCh <- req // Handled in doWrites.
 	y.NumPuts.Add(int64(len(entries)))
+	db.metrics.Puts.Add(int64(len(entries)))
 
 	return req, nil
 }
@@ -849,6 +946,7 @@ func (db *DB) doWrites(lc *z.Closer) {
 		for {
 			reqs = append(reqs, r)
 			reqLen.Set(int64(len(reqs)))
+			db.metrics.PendingWrites.Set(int64(len(reqs)))
 
 			if len(reqs) >= 3*kvWriteChCapacity {
 				pendingCh <- struct{}{} // blocking.
@@ -883,6 +981,7 @@ func (db *DB) doWrites(lc *z.Closer) {
 		go writeRequests(reqs)
 		reqs = make([]*request, 0, 10)
 		reqLen.Set(0)
+		db.metrics.PendingWrites.Set(0)
 	}
 }
 
@@ -926,7 +1025,13 @@ func (db *DB) ensureRoomForWrite() error {
 	defer db.Unlock()
 
 	y.AssertTrue(db.mt != nil) // A nil mt indicates that DB is being closed.
-	if !db.mt.isFull() {
+	seal := atomic.LoadInt32(&db.sealMemTable) == 1
+	if seal && db.mt.sl.Empty() {
+		// There is nothing to flush.
+		atomic.StoreInt32(&db.sealMemTable, 0)
+		seal = false
+	}
+	if !seal && !db.mt.isFull() && !db.staleKey(db.mt.wal) {
 		return nil
 	}
 
@@ -936,6 +1041,7 @@ func (db *DB) ensureRoomForWrite() error {
 			db.mt.sl.MemSize(), len(db.flushChan))
 		// We manage to push this task. Let's modify imm.
 		db.imm = append(db.imm, db.mt)
+		atomic.StoreInt32(&db.sealMemTable, 0)
 		db.mt, err = db.newMemTable()
 		if err != nil {
 			return y.Wrapf(err, "cannot create new mem table")
@@ -1435,6 +1541,8 @@ func (db *DB) startMemoryFlush() {
 // stopped. Ideally, no writes are going on during Flatten. Otherwise, it would create competition
 // between flattening the tree and new tables being created at level zero.
 func (db *DB) Flatten(workers int) error {
+	db.ingestLock.Lock()
+	defer db.ingestLock.Unlock()
 
 	db.stopCompactions()
 	defer db.startCompactions()
@@ -1571,6 +1679,9 @@ func (db *DB) DropAll() error {
 }
 
 func (db *DB) dropAll() (func(), error) {
+	db.ingestLock.Lock()
+	defer db.ingestLock.Unlock()
+
 	db.opt.Infof("DropAll called. Blocking writes...")
 	f, err := db.prepareToDrop()
 	if err != nil {
@@ -1610,6 +1721,9 @@ func (db *DB) dropAll() (func(), error) {
 		return resume, err
 	}
 	db.lc.nextFileID = 1
+	db.rangeDels.reset()
+	db.keyspaces.reset()
+	db.orc.resetSnapshots()
 	db.opt.Infof("Deleted %d value log files. DropAll done.\n", num)
 	db.blockCache.Clear()
 	db.indexCache.Clear()
@@ -1628,10 +1742,22 @@ func (db *DB) dropAll() (func(), error) {
 // - Compact L0->L1, skipping over Kp.
 // - Compact rest of the levels, Li->Li, picking tables which have Kp.
 // - Resume memtable flushes, compactions and writes.
+//
+// The prefixes must not have keys covered by a secondary index (see DB.AddIndex), since the
+// entries of the index would be left behind.
 func (db *DB) DropPrefix(prefixes ...[]byte) error {
 	if len(prefixes) == 0 {
 		return nil
 	}
+	indexes := db.currentIndexes()
+	for _, p := range prefixes {
+		if indexes.overlaps(p, prefixEnd(p)) {
+			return errors.Errorf("Cannot drop prefix %q, which has keys covered by an index", p)
+		}
+	}
+	db.ingestLock.Lock()
+	defer db.ingestLock.Unlock()
+
 	db.opt.Infof("DropPrefix called for %s", prefixes)
 	f, err := db.prepareToDrop()
 	if err != nil {
@@ -1693,6 +1819,7 @@ func (db *DB) filterPrefixesToDrop(prefixes [][]byte) ([][]byte, error) {
 			iopts := DefaultIteratorOptions
 			iopts.Prefix = prefix
 			iopts.PrefetchValues = false
+			iopts.InternalAccess = bytes.HasPrefix(prefix, badgerPrefix)
 			itr := txn.NewIterator(iopts)
 			defer itr.Close()
 			itr.Rewind()
@@ -1723,7 +1850,7 @@ func (db *DB) Subscribe(ctx context.Context, cb func(kv *KVList) error, prefixes
 	}
 
 	c := z.NewCloser(1)
-	recvCh, id := db.pub.newSubscriber(c, prefixes...)
+	recvCh, id := db.pub.newSubscriber(c, false, prefixes...)
 	slurp := func(batch *pb.KVList) error {
 		for {
 			select {
@@ -1765,7 +1892,7 @@ func (db *DB) Subscribe(ctx context.Context, cb func(kv *KVList) error, prefixes
 
 // shouldEncrypt returns bool, which tells whether to encrypt or not.
 func (db *DB) shouldEncrypt() bool {
-	return len(db.opt.EncryptionKey) > 0
+	return len(db.opt.EncryptionKey) > 0 || db.opt.KeyManager != nil
 }
 
 func (db *DB) syncDir(dir string) error {
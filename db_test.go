@@ -107,6 +107,15 @@ func txnDelete(t *testing.T, kv *DB, key []byte) {
 	require.NoError(t, txn.Commit())
 }
 
+// waitUntil polls cond until it returns true, and fails the test if it doesn't within 5 seconds.
+// require.Eventually isn't used, as it can panic when cond is slow.
+func waitUntil(t *testing.T, cond func() bool) {
+	for deadline := time.Now().Add(5 * time.Second); !cond(); {
+		require.True(t, time.Now().Before(deadline), "Condition not met in time")
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
 // Opens a badger db and runs a a test on it.
 func runBadgerTest(t *testing.T, opts *Options, test func(t *testing.T, db *DB)) {
 	dir, err := ioutil.TempDir("", "badger-test")
@@ -55,6 +55,48 @@ var (
 	// reserved for internal usage.
 	ErrInvalidKey = errors.New("Key is using a reserved !badger! prefix")
 
+	// ErrInvalidRange is returned if the start of a range is not smaller than its end.
+	ErrInvalidRange = errors.New("Range start must be smaller than range end")
+
+	// ErrKeyspaceNotFound is returned by DB.Keyspace if the keyspace doesn't exist.
+	ErrKeyspaceNotFound = errors.New("Keyspace not found")
+
+	// ErrNoMergeOperator is returned by Txn.Merge if no merge operator is registered for the key.
+	ErrNoMergeOperator = errors.New("No merge operator registered for the key")
+
+	// ErrInvalidSavepoint is returned by Txn.RollbackTo if the savepoint belongs to another
+	// transaction, or was created after the savepoint last rolled back to.
+	ErrInvalidSavepoint = errors.New("Invalid savepoint")
+
+	// ErrLockTimeout is returned by Txn.GetForUpdate if the lock of the key couldn't be acquired
+	// within Options.LockTimeout.
+	ErrLockTimeout = errors.New("Timed out waiting for the lock of the key")
+
+	// ErrDeadlock is returned by Txn.GetForUpdate if waiting for the lock of the key would
+	// deadlock.
+	ErrDeadlock = errors.New("Waiting for the lock of the key would deadlock")
+
+	// ErrSnapshotTooOld is returned by DB.SnapshotAt and DB.NewTransactionAsOf if the versions
+	// visible at the requested time may have been discarded. See Options.VersionRetention.
+	ErrSnapshotTooOld = errors.New("Snapshot is older than the retained versions")
+
+	// ErrSnapshotExists is returned by DB.CreateSnapshot if a snapshot with the name exists.
+	ErrSnapshotExists = errors.New("Snapshot already exists")
+
+	// ErrSnapshotNotFound is returned by DB.OpenSnapshot and DB.ReleaseSnapshot if the snapshot
+	// doesn't exist.
+	ErrSnapshotNotFound = errors.New("Snapshot not found")
+
+	// ErrIndexExists is returned by DB.AddIndex if an index with the name has been added already.
+	ErrIndexExists = errors.New("Index already exists")
+
+	// ErrIndexNotFound is returned by the index lookups if no index with the name has been added.
+	ErrIndexNotFound = errors.New("Index not found")
+
+	// ErrIndexNotBuilt is returned by the index lookups if the index hasn't been built yet, as
+	// of the read timestamp of the transaction.
+	ErrIndexNotBuilt = errors.New("Index has not been built")
+
 	// ErrThresholdZero is returned if threshold is set to zero, and value log GC is called.
 	// In such a case, GC can't be run.
 	ErrThresholdZero = errors.New(
@@ -114,9 +156,19 @@ var (
 	// ErrInvalidEncryptionKey is returned if length of encryption keys is invalid.
 	ErrInvalidEncryptionKey = errors.New("Encryption key's length should be" +
 		"either 16, 24, or 32 bytes")
+
+	// ErrNotEncrypted is returned when the master key of a DB without encryption is rotated, or
+	// when it is re-encrypted.
+	ErrNotEncrypted = errors.New("DB is not encrypted")
+
 	// ErrGCInMemoryMode is returned when db.RunValueLogGC is called in in-memory mode.
 	ErrGCInMemoryMode = errors.New("Cannot run value log GC when DB is opened in InMemory mode")
 
+	// ErrIngestWithSubscribers is returned by IngestExternalFiles if the DB has subscribers or a
+	// primary, which wouldn't see the ingested keys.
+	ErrIngestWithSubscribers = errors.New(
+		"Cannot ingest external files while the DB has subscribers or a primary")
+
 	// ErrDBClosed is returned when a get operation is performed after closing the DB.
 	ErrDBClosed = errors.New("DB Closed")
 )
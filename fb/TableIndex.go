@@ -128,8 +128,44 @@ func (rcv *TableIndex) MutateOnDiskSize(n uint32) bool {
 	return rcv._tab.MutateUint32Slot(14, n)
 }
 
+func (rcv *TableIndex) MinExpiresAt() uint64 {
+	o := flatbuffers.UOffsetT(rcv._tab.Offset(16))
+	if o != 0 {
+		return rcv._tab.GetUint64(o + rcv._tab.Pos)
+	}
+	return 0
+}
+
+func (rcv *TableIndex) MutateMinExpiresAt(n uint64) bool {
+	return rcv._tab.MutateUint64Slot(16, n)
+}
+
+func (rcv *TableIndex) MaxExpiresAt() uint64 {
+	o := flatbuffers.UOffsetT(rcv._tab.Offset(18))
+	if o != 0 {
+		return rcv._tab.GetUint64(o + rcv._tab.Pos)
+	}
+	return 0
+}
+
+func (rcv *TableIndex) MutateMaxExpiresAt(n uint64) bool {
+	return rcv._tab.MutateUint64Slot(18, n)
+}
+
+func (rcv *TableIndex) ExpiringCount() uint32 {
+	o := flatbuffers.UOffsetT(rcv._tab.Offset(20))
+	if o != 0 {
+		return rcv._tab.GetUint32(o + rcv._tab.Pos)
+	}
+	return 0
+}
+
+func (rcv *TableIndex) MutateExpiringCount(n uint32) bool {
+	return rcv._tab.MutateUint32Slot(20, n)
+}
+
 func TableIndexStart(builder *flatbuffers.Builder) {
-	builder.StartObject(6)
+	builder.StartObject(9)
 }
 func TableIndexAddOffsets(builder *flatbuffers.Builder, offsets flatbuffers.UOffsetT) {
 	builder.PrependUOffsetTSlot(0, flatbuffers.UOffsetT(offsets), 0)
@@ -155,6 +191,15 @@ func TableIndexAddUncompressedSize(builder *flatbuffers.Builder, uncompressedSiz
 func TableIndexAddOnDiskSize(builder *flatbuffers.Builder, onDiskSize uint32) {
 	builder.PrependUint32Slot(5, onDiskSize, 0)
 }
+func TableIndexAddMinExpiresAt(builder *flatbuffers.Builder, minExpiresAt uint64) {
+	builder.PrependUint64Slot(6, minExpiresAt, 0)
+}
+func TableIndexAddMaxExpiresAt(builder *flatbuffers.Builder, maxExpiresAt uint64) {
+	builder.PrependUint64Slot(7, maxExpiresAt, 0)
+}
+func TableIndexAddExpiringCount(builder *flatbuffers.Builder, expiringCount uint32) {
+	builder.PrependUint32Slot(8, expiringCount, 0)
+}
 func TableIndexEnd(builder *flatbuffers.Builder) flatbuffers.UOffsetT {
 	return builder.EndObject()
 }
@@ -0,0 +1,85 @@
+/*
+ * Copyright 2020 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package badger
+
+import "time"
+
+// KeyVersion is a version of a key, returned by Txn.History.
+type KeyVersion struct {
+	Version uint64
+	// Value is nil for the delete markers. The operands written by Txn.Merge are returned as they
+	// were written.
+	Value     []byte
+	UserMeta  byte
+	ExpiresAt uint64
+	// Deleted is set for the delete markers, and the versions deleted by DeleteRange.
+	Deleted bool
+}
+
+// IsExpired returns true if the version has expired.
+func (kv *KeyVersion) IsExpired() bool {
+	return kv.ExpiresAt != 0 && kv.ExpiresAt <= uint64(time.Now().Unix())
+}
+
+// History returns the versions of the key committed between fromTs and toTs inclusive, from the
+// latest one to the oldest one. A toTs of 0, or one above the read timestamp of the transaction,
+// is taken to be the read timestamp. At most limit versions are returned if limit is positive.
+//
+// Unlike Get, the delete markers and the expired versions are returned as well. The versions
+// discarded by compactions are not, see Options.NumVersionsToKeep and Options.VersionRetention.
+func (txn *Txn) History(key []byte, fromTs, toTs uint64, limit int) ([]*KeyVersion, error) {
+	if len(key) == 0 {
+		return nil, ErrEmptyKey
+	} else if txn.discarded {
+		return nil, ErrDiscardedTxn
+	}
+	if toTs == 0 || toTs > txn.readTs {
+		toTs = txn.readTs
+	}
+	if fromTs > toTs {
+		return nil, nil
+	}
+
+	// The key iterator only picks the tables whose bloom filters may have the key, and the seek
+	// lands directly on the latest version at or below toTs.
+	it := txn.NewKeyIterator(key, IteratorOptions{})
+	defer it.Close()
+	it.readTs = toTs
+
+	var out []*KeyVersion
+	for it.Rewind(); it.Valid() && (limit <= 0 || len(out) < limit); it.Next() {
+		item := it.Item()
+		if item.Version() < fromTs {
+			break
+		}
+		kv := &KeyVersion{
+			Version:   item.Version(),
+			UserMeta:  item.UserMeta(),
+			ExpiresAt: item.ExpiresAt(),
+			Deleted:   item.meta&bitDelete > 0,
+		}
+		if !kv.Deleted {
+			val, err := item.ValueCopy(nil)
+			if err != nil {
+				return nil, err
+			}
+			kv.Value = val
+		}
+		out = append(out, kv)
+	}
+	return out, nil
+}
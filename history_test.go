@@ -0,0 +1,80 @@
+/*
+ * Copyright 2020 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package badger
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTxnHistory(t *testing.T) {
+	runBadgerTest(t, nil, func(t *testing.T, db *DB) {
+		key := []byte("key")
+		txnSet(t, db, key, []byte("v1"), 0x01)
+		txnSet(t, db, []byte("key2"), []byte("other"), 0)
+		txnSet(t, db, key, []byte("v2"), 0x02)
+		txnDelete(t, db, key)
+		require.NoError(t, db.Update(func(txn *Txn) error {
+			return txn.SetEntry(NewEntry(key, []byte("v3")).WithTTL(time.Hour))
+		}))
+
+		txn := db.NewTransaction(false)
+		defer txn.Discard()
+		// Versions committed after the transaction started are not returned.
+		txnSet(t, db, key, []byte("v4"), 0)
+
+		history, err := txn.History(key, 0, 0, 0)
+		require.NoError(t, err)
+		require.Len(t, history, 4)
+		var versions []uint64
+		for _, kv := range history {
+			versions = append(versions, kv.Version)
+		}
+		require.Equal(t, []uint64{5, 4, 3, 1}, versions)
+		require.Equal(t, []byte("v3"), history[0].Value)
+		require.NotZero(t, history[0].ExpiresAt)
+		require.False(t, history[0].IsExpired())
+		require.True(t, history[1].Deleted)
+		require.Nil(t, history[1].Value)
+		require.Equal(t, &KeyVersion{Version: 3, Value: []byte("v2"), UserMeta: 0x02}, history[2])
+		require.Equal(t, &KeyVersion{Version: 1, Value: []byte("v1"), UserMeta: 0x01}, history[3])
+
+		history, err = txn.History(key, 3, 4, 0)
+		require.NoError(t, err)
+		require.Len(t, history, 2)
+		require.Equal(t, uint64(4), history[0].Version)
+		require.Equal(t, uint64(3), history[1].Version)
+
+		history, err = txn.History(key, 0, 4, 1)
+		require.NoError(t, err)
+		require.Len(t, history, 1)
+		require.Equal(t, uint64(4), history[0].Version)
+
+		history, err = txn.History(key, 2, 2, 0)
+		require.NoError(t, err)
+		require.Empty(t, history)
+
+		history, err = txn.History([]byte("ke"), 0, 0, 0)
+		require.NoError(t, err)
+		require.Empty(t, history)
+
+		_, err = txn.History(nil, 0, 0, 0)
+		require.Equal(t, ErrEmptyKey, err)
+	})
+}
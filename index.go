@@ -0,0 +1,585 @@
+/*
+ * Copyright 2020 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package badger
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"sync/atomic"
+
+	"github.com/dgraph-io/badger/v2/y"
+	"github.com/pkg/errors"
+)
+
+// indexPrefix is the prefix of the internal keys which hold the entries of the secondary indexes.
+// See indexEntryKey.
+var indexPrefix = []byte("!badger!index!")
+
+// indexMetaPrefix is the prefix of the internal keys which mark the secondary indexes as built.
+// The key of an index is indexMetaPrefix followed by the index name.
+var indexMetaPrefix = []byte("!badger!indexmeta!")
+
+// IndexFunc returns the index keys of a key-value pair, under which the key is found by the
+// lookups of a secondary index. It must only depend on the key and the value, and must not keep
+// references to them.
+type IndexFunc func(key, value []byte) [][]byte
+
+// indexMeta is the value of the key marking an index as built.
+type indexMeta struct {
+	Prefix []byte
+}
+
+// secondaryIndex is an index added via DB.AddIndex.
+type secondaryIndex struct {
+	name   string
+	prefix []byte
+	fn     IndexFunc
+	// builtTs is the timestamp from which the index has the entries of all the keys it covers, or
+	// zero while the index is being built. Accessed atomically.
+	builtTs uint64
+}
+
+// overlaps returns true if the range [start, end) has keys covered by the index.
+func (idx *secondaryIndex) overlaps(start, end []byte) bool {
+	return readRange{start: idx.prefix, end: prefixEnd(idx.prefix)}.overlaps(start, end)
+}
+
+// indexSet is a set of secondary indexes. It's replaced as a whole whenever an index is added or
+// dropped, so that the transactions keep maintaining the indexes they started with. A nil set is
+// empty.
+type indexSet struct {
+	indexes map[string]*secondaryIndex
+}
+
+func (s *indexSet) all() map[string]*secondaryIndex {
+	if s == nil {
+		return nil
+	}
+	return s.indexes
+}
+
+func (s *indexSet) get(name string) *secondaryIndex {
+	return s.all()[name]
+}
+
+// covers returns true if the key is covered by any of the indexes.
+func (s *indexSet) covers(key []byte) bool {
+	for _, idx := range s.all() {
+		if bytes.HasPrefix(key, idx.prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// overlaps returns true if the range [start, end) has keys covered by any of the indexes.
+func (s *indexSet) overlaps(start, end []byte) bool {
+	for _, idx := range s.all() {
+		if idx.overlaps(start, end) {
+			return true
+		}
+	}
+	return false
+}
+
+func indexMetaKey(name string) []byte {
+	return append(y.SafeCopy(nil, indexMetaPrefix), name...)
+}
+
+// indexEntriesPrefix returns the prefix of the keys of the entries of the index.
+func indexEntriesPrefix(name string) []byte {
+	return append(append(y.SafeCopy(nil, indexPrefix), name...), 0)
+}
+
+// appendIndexKey appends the index key to buf, escaped so that the escaped keys sort like the index
+// keys, and are never a prefix of each other: a zero byte is escaped as 0x00 0xff. If terminate is
+// set, the escaped key is terminated by 0x00 0x01, which separates it from a primary key.
+func appendIndexKey(buf, indexKey []byte, terminate bool) []byte {
+	for _, b := range indexKey {
+		buf = append(buf, b)
+		if b == 0 {
+			buf = append(buf, 0xff)
+		}
+	}
+	if terminate {
+		buf = append(buf, 0, 1)
+	}
+	return buf
+}
+
+// indexEntryKey returns the key of the entry of the index which maps the index key to the primary
+// key. It's the prefix of the index, followed by the terminated escaped index key and the primary
+// key, so that the entries are sorted by index key.
+func indexEntryKey(name string, indexKey, key []byte) []byte {
+	return append(appendIndexKey(indexEntriesPrefix(name), indexKey, true), key...)
+}
+
+// parseIndexEntryKey returns copies of the index key and the primary key of an entry, given its
+// key without the prefix of the index.
+func parseIndexEntryKey(entryKey []byte) (indexKey, key []byte, err error) {
+	indexKey = make([]byte, 0, len(entryKey))
+	for i := 0; i+1 < len(entryKey); i++ {
+		if b := entryKey[i]; b != 0 {
+			indexKey = append(indexKey, b)
+			continue
+		}
+		switch entryKey[i+1] {
+		case 0xff:
+			indexKey = append(indexKey, 0)
+			i++
+			continue
+		case 1:
+			return indexKey, y.SafeCopy(nil, entryKey[i+2:]), nil
+		}
+		break
+	}
+	return nil, nil, errors.Errorf("Invalid index entry key %q", entryKey)
+}
+
+// indexEntries returns the entries which update the entries of the index for the key, by deleting
+// its old index keys and writing the new ones. The new entries expire at expiresAt, along with the
+// value they were derived from.
+func indexEntries(name string, key []byte, oldKeys, newKeys [][]byte,
+	expiresAt uint64) ([]*Entry, error) {
+	var entries []*Entry
+	add := func(indexKey []byte, meta byte, written map[string]struct{}) error {
+		ek := indexEntryKey(name, indexKey, key)
+		if len(ek) > maxKeySize {
+			return exceedsSize("Index entry key", maxKeySize, ek)
+		}
+		if _, ok := written[string(ek)]; ok {
+			return nil
+		}
+		written[string(ek)] = struct{}{}
+		e := &Entry{Key: ek, meta: meta}
+		if meta == 0 {
+			e.ExpiresAt = expiresAt
+		}
+		entries = append(entries, e)
+		return nil
+	}
+	written := make(map[string]struct{}, len(oldKeys)+len(newKeys))
+	for _, indexKey := range newKeys {
+		if err := add(indexKey, 0, written); err != nil {
+			return nil, err
+		}
+	}
+	// The index keys found among the new ones are skipped, since they have been written already.
+	for _, indexKey := range oldKeys {
+		if err := add(indexKey, bitDelete, written); err != nil {
+			return nil, err
+		}
+	}
+	return entries, nil
+}
+
+// fits returns ErrTxnTooBig if the entries can't be added to the transaction. See checkSize.
+func (txn *Txn) fits(entries []*Entry) error {
+	count, size := txn.count, txn.size
+	for _, e := range entries {
+		count++
+		size += int64(e.estimateSize(txn.db.opt.ValueThreshold)) + 10
+	}
+	if count >= txn.db.opt.maxBatchCount || size >= txn.db.opt.maxBatchSize {
+		return ErrTxnTooBig
+	}
+	return nil
+}
+
+// modifyIndexed is like modifyInternal, for a key covered by the indexes of the transaction. The
+// value of the key read by the transaction is replaced in the indexes by the new one. Either all
+// the entries are added to the transaction, or none.
+func (txn *Txn) modifyIndexed(e *Entry) error {
+	var old []byte
+	item, err := txn.Get(e.Key)
+	switch {
+	case err == nil:
+		if old, err = item.ValueCopy(nil); err != nil {
+			return err
+		}
+	case err != ErrKeyNotFound:
+		return err
+	}
+
+	entries := []*Entry{e}
+	for _, idx := range txn.indexes.all() {
+		if !bytes.HasPrefix(e.Key, idx.prefix) {
+			continue
+		}
+		var oldKeys, newKeys [][]byte
+		if item != nil {
+			oldKeys = idx.fn(e.Key, old)
+		}
+		if !isDeletedOrExpired(e.meta, e.ExpiresAt) {
+			newKeys = idx.fn(e.Key, e.Value)
+		}
+		ies, err := indexEntries(idx.name, e.Key, oldKeys, newKeys, e.ExpiresAt)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, ies...)
+	}
+	if err := txn.fits(entries); err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if err := txn.modifyInternal(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mergeIndexed merges the operand into the value of a key covered by the indexes of the
+// transaction, and writes the merged value, which the indexes need.
+func (txn *Txn) mergeIndexed(key, operand []byte, f MergeFunc) error {
+	item, err := txn.Get(key)
+	switch {
+	case err == ErrKeyNotFound:
+		return txn.SetEntry(NewEntry(key, operand))
+	case err != nil:
+		return err
+	}
+	val, err := item.ValueCopy(nil)
+	if err != nil {
+		return err
+	}
+	e := NewEntry(key, f(val, operand)).WithMeta(item.UserMeta())
+	e.ExpiresAt = item.ExpiresAt()
+	return txn.SetEntry(e)
+}
+
+// missesIndexes returns true if the writes of the transaction might not have updated an index
+// which has been added or dropped since the transaction started. Must be called under
+// orc.writeChLock, which is held while the indexes change, so that every write committed
+// afterwards updates the indexes.
+func (txn *Txn) missesIndexes() bool {
+	cur := txn.db.currentIndexes()
+	if cur == txn.indexes {
+		return false
+	}
+	for name, idx := range cur.all() {
+		if txn.indexes.get(name) != idx && txn.writesTo(idx) {
+			return true
+		}
+	}
+	for name, idx := range txn.indexes.all() {
+		if cur.get(name) != idx && txn.writesTo(idx) {
+			return true
+		}
+	}
+	return false
+}
+
+// writesTo returns true if the transaction writes to the keys covered by the index, or to its
+// entries.
+func (txn *Txn) writesTo(idx *secondaryIndex) bool {
+	entries := indexEntriesPrefix(idx.name)
+	for k := range txn.pendingWrites {
+		if strings.HasPrefix(k, string(entries)) ||
+			!strings.HasPrefix(k, string(badgerPrefix)) && strings.HasPrefix(k, string(idx.prefix)) {
+			return true
+		}
+	}
+	for _, rt := range txn.rangeDels {
+		if idx.overlaps(rt.start, rt.end) {
+			return true
+		}
+	}
+	return false
+}
+
+// SetIndexEntries updates the entries of the index with the given name for the key, by deleting
+// the entries of the index keys in del which aren't in set, and writing the entries of the index
+// keys in set, which expire at expiresAt. Unlike the writes of the keys, it doesn't read the value
+// of the key.
+//
+// It's meant for building an index, as the index package does. The writes of the keys covered by
+// the index update its entries already.
+func (txn *Txn) SetIndexEntries(name string, key []byte, del, set [][]byte,
+	expiresAt uint64) error {
+	idx := txn.indexes.get(name)
+	switch {
+	case idx == nil:
+		return ErrIndexNotFound
+	case !bytes.HasPrefix(key, idx.prefix) || bytes.HasPrefix(key, badgerPrefix):
+		return errors.Errorf("Key %q is not covered by index %q", key, name)
+	}
+	entries, err := indexEntries(name, key, del, set, expiresAt)
+	if err != nil {
+		return err
+	}
+	if err := txn.fits(entries); err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if err := txn.modifyInternal(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// IndexLookup returns the keys which have the given index key in the index with the given name,
+// sorted. The keys written by the transaction are taken into account.
+//
+// ErrIndexNotFound is returned if the index hasn't been added via DB.AddIndex, and
+// ErrIndexNotBuilt if it hasn't been built as of the read timestamp of the transaction.
+func (txn *Txn) IndexLookup(name string, indexKey []byte) ([][]byte, error) {
+	opt := IteratorOptions{Prefix: appendIndexKey(indexEntriesPrefix(name), indexKey, true)}
+	var keys [][]byte
+	err := txn.iterateIndex(name, opt, func(_, key []byte) error {
+		keys = append(keys, key)
+		return nil
+	})
+	return keys, err
+}
+
+// IndexScan calls fn for the keys of the index with the given name whose index keys are in the
+// range [start, end), sorted by index key, then by key. A nil end leaves the range unbounded. The
+// scan stops at the first error returned by fn, which is returned.
+//
+// Like an iterator, the scan makes an update transaction conflict with the transactions
+// committing keys into the scanned range. Since it uses an iterator, fn can't create iterators in
+// an update transaction.
+//
+// The errors are the same as for IndexLookup.
+func (txn *Txn) IndexScan(name string, start, end []byte,
+	fn func(indexKey, key []byte) error) error {
+	opt := IteratorOptions{
+		Prefix:     indexEntriesPrefix(name),
+		LowerBound: appendIndexKey(indexEntriesPrefix(name), start, false),
+	}
+	if end != nil {
+		opt.UpperBound = appendIndexKey(indexEntriesPrefix(name), end, false)
+	}
+	return txn.iterateIndex(name, opt, fn)
+}
+
+// iterateIndex calls fn for the entries of the index picked by the iterator options.
+func (txn *Txn) iterateIndex(name string, opt IteratorOptions,
+	fn func(indexKey, key []byte) error) error {
+	if txn.discarded {
+		return ErrDiscardedTxn
+	}
+	idx := txn.db.getIndex(name)
+	if idx == nil {
+		return ErrIndexNotFound
+	}
+	if ts := atomic.LoadUint64(&idx.builtTs); ts == 0 || txn.readTs < ts {
+		return ErrIndexNotBuilt
+	}
+
+	opt.InternalAccess = true
+	it := txn.NewIterator(opt)
+	defer it.Close()
+	skip := len(indexEntriesPrefix(name))
+	for it.Rewind(); it.Valid(); it.Next() {
+		indexKey, key, err := parseIndexEntryKey(it.Item().Key()[skip:])
+		if err != nil {
+			return err
+		}
+		if err := fn(indexKey, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (db *DB) currentIndexes() *indexSet {
+	db.indexLock.RLock()
+	defer db.indexLock.RUnlock()
+	return db.indexes
+}
+
+func (db *DB) getIndex(name string) *secondaryIndex {
+	return db.currentIndexes().get(name)
+}
+
+// updateIndexes replaces the set of indexes with a copy changed by f. The commits are blocked
+// meanwhile, see Txn.missesIndexes.
+func (db *DB) updateIndexes(f func(indexes map[string]*secondaryIndex)) {
+	db.orc.writeChLock.Lock()
+	defer db.orc.writeChLock.Unlock()
+	db.indexLock.Lock()
+	defer db.indexLock.Unlock()
+
+	indexes := make(map[string]*secondaryIndex)
+	for name, idx := range db.indexes.all() {
+		indexes[name] = idx
+	}
+	f(indexes)
+	db.indexes = nil
+	if len(indexes) > 0 {
+		db.indexes = &indexSet{indexes: indexes}
+	}
+}
+
+// indexBuiltTs returns the version of the key marking the index as built, or zero if the index
+// hasn't been built.
+func (db *DB) indexBuiltTs(name string, prefix []byte) (uint64, error) {
+	txn := db.NewTransaction(false)
+	defer txn.Discard()
+	item, err := txn.Get(indexMetaKey(name))
+	switch {
+	case err == ErrKeyNotFound:
+		return 0, nil
+	case err != nil:
+		return 0, err
+	}
+	var meta indexMeta
+	if err := item.Value(func(val []byte) error {
+		return json.Unmarshal(val, &meta)
+	}); err != nil {
+		return 0, y.Wrapf(err, "while reading index %q", name)
+	}
+	if !bytes.Equal(meta.Prefix, prefix) {
+		return 0, errors.Errorf("Index %q has been built for the keys with prefix %q", name,
+			meta.Prefix)
+	}
+	return item.Version(), nil
+}
+
+// dropIndexEntries deletes the entries of the index, if it has any.
+func (db *DB) dropIndexEntries(name string) error {
+	prefix := indexEntriesPrefix(name)
+	if filtered, err := db.filterPrefixesToDrop([][]byte{prefix}); err != nil || len(filtered) == 0 {
+		return err
+	}
+	return db.DropPrefix(prefix)
+}
+
+// AddIndex adds a secondary index with the given name, which maps the keys with the given prefix
+// to the index keys returned by fn for their values. Most users should use the index package,
+// which builds the index as well.
+//
+// From then on, every write of a key covered by the index updates the entries of the index within
+// the same transaction. To find the index keys to delete, the old value of the key is read by the
+// transaction, so the writes of the key conflict with the concurrent ones. This applies to
+// WriteBatch as well. Merge operands are merged right away, and DeleteRange returns an error for
+// the ranges with keys covered by the index. The transactions writing to the keys covered by the
+// index when it's added fail with ErrConflict.
+//
+// DropPrefix and IngestExternalFiles return an error for the keys covered by the index, since
+// they bypass the transactions. The entries of the indexes are local to the DB, they're not
+// included in backups (see DB.Backup) nor shipped to replicas (see DB.NewPrimary), so the indexes
+// must be built again on a restored DB or a replica.
+//
+// The indexes aren't persisted, they must be added again after opening the DB. AddIndex returns
+// true if the index has been built before, in which case the lookups can use it right away.
+// Otherwise, its entries need to be built by the caller, via Txn.SetIndexEntries, before marking
+// it as built via SetIndexBuilt. The entries left by an interrupted build are deleted. Indexes
+// are not supported in the managed mode.
+func (db *DB) AddIndex(name string, prefix []byte, fn IndexFunc) (bool, error) {
+	switch {
+	case len(name) == 0:
+		return false, errors.New("Index name cannot be empty")
+	case strings.IndexByte(name, 0) >= 0:
+		return false, errors.Errorf("Index name %q cannot have zero bytes", name)
+	case fn == nil:
+		return false, errors.New("Index function cannot be nil")
+	case bytes.HasPrefix(prefix, badgerPrefix):
+		return false, ErrInvalidKey
+	case db.opt.managedTxns:
+		return false, ErrManagedTxn
+	}
+
+	db.indexDefLock.Lock()
+	defer db.indexDefLock.Unlock()
+	if db.getIndex(name) != nil {
+		return false, ErrIndexExists
+	}
+	builtTs, err := db.indexBuiltTs(name, prefix)
+	if err != nil {
+		return false, err
+	}
+	if builtTs == 0 {
+		if db.opt.ReadOnly {
+			return false, errors.Errorf("Index %q hasn't been built, in read-only mode", name)
+		}
+		if err := db.dropIndexEntries(name); err != nil {
+			return false, y.Wrapf(err, "while deleting the entries of index %q", name)
+		}
+	}
+
+	idx := &secondaryIndex{name: name, prefix: y.SafeCopy(nil, prefix), fn: fn, builtTs: builtTs}
+	db.updateIndexes(func(indexes map[string]*secondaryIndex) {
+		indexes[name] = idx
+	})
+	return builtTs > 0, nil
+}
+
+// SetIndexBuilt marks the index with the given name as built, once it has the entries of all the
+// keys it covers. The lookups of the transactions reading at or after the mark can use the index.
+// The mark is kept across restarts, until the index is dropped.
+func (db *DB) SetIndexBuilt(name string) error {
+	db.indexDefLock.Lock()
+	defer db.indexDefLock.Unlock()
+	idx := db.getIndex(name)
+	if idx == nil {
+		return ErrIndexNotFound
+	}
+	val, err := json.Marshal(indexMeta{Prefix: idx.prefix})
+	if err != nil {
+		return err
+	}
+
+	txn := db.newTransaction(true, false)
+	defer txn.Discard()
+	if err := txn.modifyInternal(&Entry{Key: indexMetaKey(name), Value: val}); err != nil {
+		return err
+	}
+	if err := txn.Commit(); err != nil {
+		return y.Wrapf(err, "while marking index %q as built", name)
+	}
+	builtTs, err := db.indexBuiltTs(name, idx.prefix)
+	if err != nil {
+		return err
+	}
+	atomic.StoreUint64(&idx.builtTs, builtTs)
+	return nil
+}
+
+// DropIndex stops maintaining the index with the given name, and deletes its entries, whether or
+// not it has been added since the DB was opened. The transactions writing to the keys covered by
+// the index when it's dropped fail with ErrConflict. Deleting the entries blocks the writes for a
+// while, see DropPrefix.
+func (db *DB) DropIndex(name string) error {
+	switch {
+	case db.opt.ReadOnly:
+		return errors.New("Cannot drop an index in read-only mode")
+	case db.opt.managedTxns:
+		return ErrManagedTxn
+	}
+
+	db.indexDefLock.Lock()
+	defer db.indexDefLock.Unlock()
+	db.updateIndexes(func(indexes map[string]*secondaryIndex) {
+		delete(indexes, name)
+	})
+
+	txn := db.newTransaction(true, false)
+	defer txn.Discard()
+	if err := txn.modifyInternal(&Entry{Key: indexMetaKey(name), meta: bitDelete}); err != nil {
+		return err
+	}
+	if err := txn.Commit(); err != nil {
+		return y.Wrapf(err, "while dropping index %q", name)
+	}
+	return db.dropIndexEntries(name)
+}
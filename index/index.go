@@ -0,0 +1,299 @@
+/*
+ * Copyright 2020 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package index maintains secondary indexes of the keys of a DB, which find the keys by the index
+// keys derived from their values. An index is defined by a Definition, built once via Build, and
+// registered via Register whenever the DB is opened afterwards. From then on, the writes of the
+// keys update the entries of the index within their transactions, and the keys can be looked up
+// via Txn.IndexLookup and Txn.IndexScan:
+//
+//	byEmail := index.Definition{
+//		Name:   "users-by-email",
+//		Prefix: []byte("user/"),
+//		Func: func(key, value []byte) [][]byte {
+//			return [][]byte{emailOf(value)}
+//		},
+//	}
+//	if err := index.Build(ctx, db, byEmail); err != nil {
+//		return err
+//	}
+//	err := db.View(func(txn *badger.Txn) error {
+//		keys, err := txn.IndexLookup("users-by-email", []byte("alice@example.com"))
+//		...
+//	})
+//
+// An index is dropped via DB.DropIndex. See DB.AddIndex for the costs of the indexes. The entries
+// of an index are local to the DB, so the index is built again via Build on a DB restored from a
+// backup, or on a replica.
+package index
+
+import (
+	"bytes"
+	"context"
+	"sync"
+
+	"github.com/dgraph-io/badger/v2"
+	"github.com/dgraph-io/badger/v2/pb"
+	"github.com/dgraph-io/ristretto/z"
+	"github.com/pkg/errors"
+)
+
+// ErrNotBuilt is returned by Register if the index hasn't been built via Build.
+var ErrNotBuilt = errors.New("Index has not been built")
+
+// errCaughtUp stops the catch-up of Build.
+var errCaughtUp = errors.New("Caught up with the commits")
+
+// badgerPrefix is the prefix of the internal keys of the DB, such as the definitions of the
+// keyspaces, which the Stream and DB.SubscribeSince return along with the keys of an empty prefix.
+// They are never covered by an index.
+var badgerPrefix = []byte("!badger!")
+
+// Definition defines a secondary index.
+type Definition struct {
+	// Name identifies the index in the lookups. It must not contain zero bytes.
+	Name string
+	// Prefix restricts the index to the keys with the prefix. The writes of the other keys don't
+	// pay for the index.
+	Prefix []byte
+	// Func returns the index keys of a key-value pair. Changing what it returns for the existing
+	// keys requires dropping the index and building it again.
+	Func badger.IndexFunc
+}
+
+// Register registers an index which has been built via Build, so that the writes update it and the
+// lookups can use it. It must be called whenever the DB is opened, before writing to the keys
+// covered by the index, which would otherwise be missing from it.
+//
+// ErrNotBuilt is returned if the index hasn't been built, or if its build was interrupted.
+func Register(db *badger.DB, def Definition) error {
+	built, err := db.AddIndex(def.Name, def.Prefix, def.Func)
+	if err != nil || built {
+		return err
+	}
+	if err := db.DropIndex(def.Name); err != nil {
+		return err
+	}
+	return ErrNotBuilt
+}
+
+// Build registers the index like Register, and builds it unless it has been built before. The DB
+// stays online meanwhile: the writes committed after Build is called update the index, while the
+// existing keys are indexed in the background. The lookups return badger.ErrIndexNotBuilt until
+// Build returns.
+//
+// The keys are indexed from a snapshot of the DB, read via a Stream. Then, the keys changed since
+// the snapshot was taken are indexed again, as read from the commit log via DB.SubscribeSince. If
+// the build fails, the index is dropped.
+func Build(ctx context.Context, db *badger.DB, def Definition) (rerr error) {
+	built, err := db.AddIndex(def.Name, def.Prefix, def.Func)
+	if err != nil || built {
+		return err
+	}
+	defer func() {
+		if rerr == nil {
+			return
+		}
+		if err := db.DropIndex(def.Name); err != nil {
+			rerr = errors.Wrapf(rerr, "(while dropping the index: %v)", err)
+		}
+	}()
+
+	// The snapshot is taken after adding the index, so that the writes it misses update the index.
+	// It also keeps the versions committed since then, which the catch-up reads.
+	snap := db.NewTransaction(false)
+	defer snap.Discard()
+	if err := backfill(ctx, db, def); err != nil {
+		return errors.Wrapf(err, "while indexing the keys of index %q", def.Name)
+	}
+	if err := catchUp(ctx, db, def, snap); err != nil {
+		return errors.Wrapf(err, "while catching up with the commits for index %q", def.Name)
+	}
+	return db.SetIndexBuilt(def.Name)
+}
+
+// backfill writes the entries of the keys covered by the index, as read by a Stream.
+func backfill(ctx context.Context, db *badger.DB, def Definition) error {
+	w := &writer{db: db, name: def.Name, txn: db.NewTransaction(true)}
+	defer func() { w.txn.Discard() }()
+
+	stream := db.NewStream()
+	stream.Prefix = def.Prefix
+	stream.LogPrefix = "index.Build"
+	stream.ChooseKey = func(item *badger.Item) bool {
+		return !bytes.HasPrefix(item.Key(), badgerPrefix)
+	}
+	stream.KeyToList = latestValue
+	// The Stream cancels its context when Send fails, and might return the cancellation instead of
+	// the error of Send, which is kept here.
+	var mu sync.Mutex
+	var sendErr error
+	send := func(buf *z.Buffer) error {
+		list, err := badger.BufferToKVList(buf)
+		if err != nil {
+			return err
+		}
+		for _, kv := range list.Kv {
+			if kv.StreamDone {
+				continue
+			}
+			if err := w.set(kv.Key, nil, def.Func(kv.Key, kv.Value), kv.ExpiresAt); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	stream.Send = func(buf *z.Buffer) error {
+		mu.Lock()
+		defer mu.Unlock()
+		sendErr = send(buf)
+		return sendErr
+	}
+	if err := stream.Orchestrate(ctx); err != nil {
+		mu.Lock()
+		defer mu.Unlock()
+		if sendErr != nil {
+			return sendErr
+		}
+		return err
+	}
+	return w.txn.Commit()
+}
+
+// latestValue is a KeyToList function for Stream, which picks up the latest value of the key,
+// unless it has been deleted or has expired.
+func latestValue(key []byte, itr *badger.Iterator) (*pb.KVList, error) {
+	item := itr.Item()
+	if item.IsDeletedOrExpired() {
+		return nil, nil
+	}
+	val, err := item.ValueCopy(nil)
+	if err != nil {
+		return nil, err
+	}
+	kv := &pb.KV{Key: item.KeyCopy(nil), Value: val, ExpiresAt: item.ExpiresAt()}
+	return &pb.KVList{Kv: []*pb.KV{kv}}, nil
+}
+
+// writer writes the entries of an index, committing its transaction whenever it gets too big.
+type writer struct {
+	db   *badger.DB
+	name string
+	txn  *badger.Txn
+}
+
+// set calls Txn.SetIndexEntries.
+func (w *writer) set(key []byte, del, set [][]byte, expiresAt uint64) error {
+	err := w.txn.SetIndexEntries(w.name, key, del, set, expiresAt)
+	if err != badger.ErrTxnTooBig {
+		return err
+	}
+	if err := w.txn.Commit(); err != nil {
+		return err
+	}
+	w.txn = w.db.NewTransaction(true)
+	return w.txn.SetIndexEntries(w.name, key, del, set, expiresAt)
+}
+
+// catchUp indexes again the keys changed since the snapshot was taken, since the backfill might
+// have indexed the values they had before the change, after the change updated the index. The
+// changes committed after catchUp is called are left out, as the backfill has ended by then.
+func catchUp(ctx context.Context, db *badger.DB, def Definition, snap *badger.Txn) error {
+	latest := db.NewTransaction(false)
+	endTs := latest.ReadTs()
+	latest.Discard()
+	if endTs <= snap.ReadTs() {
+		return nil // Nothing has been committed since the snapshot was taken.
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	err := db.SubscribeSince(ctx, snap.ReadTs()+1, func(kvs *badger.KVList, resumeTs uint64) error {
+		for _, kv := range kvs.Kv {
+			if bytes.HasPrefix(kv.Key, badgerPrefix) {
+				continue
+			}
+			if err := reindex(db, def, snap, kv.Key); err != nil {
+				return err
+			}
+		}
+		if resumeTs >= endTs {
+			return errCaughtUp
+		}
+		return nil
+	}, def.Prefix)
+	switch err {
+	case errCaughtUp:
+		return nil
+	case nil:
+		return badger.ErrDBClosed
+	}
+	return err
+}
+
+// reindex writes the entries of the current value of the key, and deletes the other entries the
+// backfill might have written: those of the values the key had since the snapshot was taken.
+func reindex(db *badger.DB, def Definition, snap *badger.Txn, key []byte) error {
+	indexKeys := func(item *badger.Item) ([][]byte, error) {
+		val, err := item.ValueCopy(nil)
+		if err != nil {
+			return nil, err
+		}
+		return def.Func(key, val), nil
+	}
+	update := func(txn *badger.Txn) error {
+		var del [][]byte
+		item, err := snap.Get(key)
+		switch {
+		case err == nil:
+			if del, err = indexKeys(item); err != nil {
+				return err
+			}
+		case err != badger.ErrKeyNotFound:
+			return err
+		}
+		versions, err := txn.History(key, snap.ReadTs()+1, 0, 0)
+		if err != nil {
+			return err
+		}
+		for _, kv := range versions {
+			if !kv.Deleted {
+				del = append(del, def.Func(key, kv.Value)...)
+			}
+		}
+
+		var set [][]byte
+		var expiresAt uint64
+		item, err = txn.Get(key)
+		switch {
+		case err == nil:
+			if set, err = indexKeys(item); err != nil {
+				return err
+			}
+			expiresAt = item.ExpiresAt()
+		case err != badger.ErrKeyNotFound:
+			return err
+		}
+		return txn.SetIndexEntries(def.Name, key, del, set, expiresAt)
+	}
+	for {
+		// The key is read by the transaction, so the concurrent writes of the key make it
+		// conflict, rather than leaving outdated entries behind.
+		if err := db.Update(update); err != badger.ErrConflict {
+			return err
+		}
+	}
+}
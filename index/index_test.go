@@ -0,0 +1,199 @@
+/*
+ * Copyright 2020 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package index
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/dgraph-io/badger/v2"
+	"github.com/stretchr/testify/require"
+)
+
+// byColor indexes the keys with the item/ prefix by their values.
+var byColor = Definition{
+	Name:   "by-color",
+	Prefix: []byte("item/"),
+	Func: func(key, value []byte) [][]byte {
+		return [][]byte{value}
+	},
+}
+
+var colors = []string{"red", "green", "blue", "black"}
+
+// checkIndex checks that the index has the keys covered by it, under their current values.
+func checkIndex(t *testing.T, db *badger.DB) {
+	want := make(map[string][]string)
+	got := make(map[string][]string)
+	require.NoError(t, db.View(func(txn *badger.Txn) error {
+		opt := badger.DefaultIteratorOptions
+		opt.Prefix = byColor.Prefix
+		it := txn.NewIterator(opt)
+		for it.Rewind(); it.Valid(); it.Next() {
+			val, err := it.Item().ValueCopy(nil)
+			require.NoError(t, err)
+			want[string(val)] = append(want[string(val)], string(it.Item().Key()))
+		}
+		it.Close()
+
+		return txn.IndexScan(byColor.Name, nil, nil, func(indexKey, key []byte) error {
+			got[string(indexKey)] = append(got[string(indexKey)], string(key))
+			return nil
+		})
+	}))
+	require.Equal(t, want, got)
+}
+
+func TestBuild(t *testing.T) {
+	dir, err := ioutil.TempDir("", "badger-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	opt := badger.DefaultOptions(dir).WithMemTableSize(1 << 20).WithLoggingLevel(badger.WARNING)
+	db, err := badger.Open(opt)
+	require.NoError(t, err)
+
+	set := func(i int, color string) error {
+		return db.Update(func(txn *badger.Txn) error {
+			return txn.Set([]byte(fmt.Sprintf("item/%04d", i)), []byte(color))
+		})
+	}
+	for i := 0; i < 5000; i++ {
+		require.NoError(t, set(i, colors[i%len(colors)]))
+	}
+	require.NoError(t, db.Update(func(txn *badger.Txn) error {
+		return txn.Set([]byte("other"), []byte("red"))
+	}))
+	require.Equal(t, ErrNotBuilt, Register(db, byColor))
+
+	// The keys keep changing while the index is built.
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				i := rand.Intn(5100)
+				var err error
+				if rand.Intn(10) == 0 {
+					err = db.Update(func(txn *badger.Txn) error {
+						return txn.Delete([]byte(fmt.Sprintf("item/%04d", i)))
+					})
+				} else {
+					err = set(i, colors[rand.Intn(len(colors))])
+				}
+				if err != badger.ErrConflict {
+					require.NoError(t, err)
+				}
+			}
+		}()
+	}
+	require.NoError(t, Build(context.Background(), db, byColor))
+	close(done)
+	wg.Wait()
+	checkIndex(t, db)
+
+	require.NoError(t, set(0, "white"))
+	require.NoError(t, db.View(func(txn *badger.Txn) error {
+		keys, err := txn.IndexLookup(byColor.Name, []byte("white"))
+		require.Equal(t, [][]byte{[]byte("item/0000")}, keys)
+		return err
+	}))
+	// The index is registered already.
+	require.Equal(t, badger.ErrIndexExists, Build(context.Background(), db, byColor))
+
+	require.NoError(t, db.Close())
+	db, err = badger.Open(opt)
+	require.NoError(t, err)
+	defer func() { require.NoError(t, db.Close()) }()
+	require.NoError(t, Register(db, byColor))
+	require.NoError(t, set(1, "white"))
+	checkIndex(t, db)
+}
+
+func TestBuildCanceled(t *testing.T) {
+	dir, err := ioutil.TempDir("", "badger-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	db, err := badger.Open(badger.DefaultOptions(dir).WithLoggingLevel(badger.WARNING))
+	require.NoError(t, err)
+	defer func() { require.NoError(t, db.Close()) }()
+	require.NoError(t, db.Update(func(txn *badger.Txn) error {
+		return txn.Set([]byte("item/1"), []byte("red"))
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.Error(t, Build(ctx, db, byColor))
+	// The index has been dropped, so it can be built again.
+	require.Equal(t, ErrNotBuilt, Register(db, byColor))
+	require.NoError(t, Build(context.Background(), db, byColor))
+	checkIndex(t, db)
+}
+
+func TestBuildEmptyPrefix(t *testing.T) {
+	dir, err := ioutil.TempDir("", "badger-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	db, err := badger.Open(badger.DefaultOptions(dir).WithLoggingLevel(badger.WARNING))
+	require.NoError(t, err)
+	defer func() { require.NoError(t, db.Close()) }()
+	_, err = db.CreateKeyspace("ks", db.DefaultKeyspaceOptions())
+	require.NoError(t, err)
+	for i := 0; i < 100; i++ {
+		require.NoError(t, db.Update(func(txn *badger.Txn) error {
+			return txn.Set([]byte(fmt.Sprintf("key/%03d", i)), []byte(colors[i%len(colors)]))
+		}))
+	}
+
+	// The internal keys of the keyspaces, read by the backfill and the catch-up, aren't indexed.
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			_, err := db.CreateKeyspace("ks", db.DefaultKeyspaceOptions())
+			require.NoError(t, err)
+		}
+	}()
+	def := Definition{Name: "all-by-color", Func: byColor.Func}
+	err = Build(context.Background(), db, def)
+	close(done)
+	wg.Wait()
+	require.NoError(t, err)
+	require.NoError(t, db.View(func(txn *badger.Txn) error {
+		keys, err := txn.IndexLookup(def.Name, []byte("red"))
+		require.Len(t, keys, 25)
+		return err
+	}))
+}
@@ -0,0 +1,253 @@
+/*
+ * Copyright 2020 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package badger
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// tagsIndex indexes the keys with the user/ prefix by the comma-separated tags in their values.
+func tagsIndex(key, value []byte) [][]byte {
+	if len(value) == 0 {
+		return nil
+	}
+	return bytes.Split(value, []byte(","))
+}
+
+func indexLookup(t *testing.T, db *DB, name, indexKey string) []string {
+	var keys []string
+	require.NoError(t, db.View(func(txn *Txn) error {
+		found, err := txn.IndexLookup(name, []byte(indexKey))
+		for _, key := range found {
+			keys = append(keys, string(key))
+		}
+		return err
+	}))
+	return keys
+}
+
+func TestIndexEntryKey(t *testing.T) {
+	indexKeys := []string{"", "\x00", "\x00\x00", "\x00\x01", "\x00\xff", "a", "a\x00", "a\x00b",
+		"a\x01", "ab", "b"}
+	var entryKeys []string
+	for _, ik := range indexKeys {
+		for _, key := range []string{"", "\x00", "k", "\xff"} {
+			ek := indexEntryKey("name", []byte(ik), []byte(key))
+			entryKeys = append(entryKeys, string(ek))
+
+			prefix := indexEntriesPrefix("name")
+			require.True(t, bytes.HasPrefix(ek, prefix))
+			gotIndexKey, gotKey, err := parseIndexEntryKey(ek[len(prefix):])
+			require.NoError(t, err)
+			require.Equal(t, ik, string(gotIndexKey))
+			require.Equal(t, key, string(gotKey))
+		}
+	}
+	// The entries are sorted by index key, then by key.
+	require.True(t, sort.StringsAreSorted(entryKeys))
+
+	_, _, err := parseIndexEntryKey([]byte("a\x00b"))
+	require.Error(t, err)
+}
+
+func TestIndex(t *testing.T) {
+	dir, err := ioutil.TempDir("", "badger-test")
+	require.NoError(t, err)
+	defer removeDir(dir)
+	opt := getTestOptions(dir).WithMergeOperator([]byte("user/"), func(existing, val []byte) []byte {
+		return append(append(existing, ','), val...)
+	})
+	db, err := Open(opt)
+	require.NoError(t, err)
+
+	// A transaction started before the index is added doesn't update it.
+	early := db.NewTransaction(true)
+	defer early.Discard()
+	require.NoError(t, early.Set([]byte("user/0"), []byte("red")))
+	require.NoError(t, early.Set([]byte("other"), []byte("red")))
+
+	built, err := db.AddIndex("tags", []byte("user/"), tagsIndex)
+	require.NoError(t, err)
+	require.False(t, built)
+	_, err = db.AddIndex("tags", []byte("user/"), tagsIndex)
+	require.Equal(t, ErrIndexExists, err)
+	require.Equal(t, ErrConflict, early.Commit())
+
+	require.NoError(t, db.View(func(txn *Txn) error {
+		_, err := txn.IndexLookup("tags", []byte("red"))
+		require.Equal(t, ErrIndexNotBuilt, err)
+		_, err = txn.IndexLookup("colors", []byte("red"))
+		require.Equal(t, ErrIndexNotFound, err)
+		return nil
+	}))
+	require.NoError(t, db.SetIndexBuilt("tags"))
+
+	txnSet(t, db, []byte("user/1"), []byte("red,blue"), 0)
+	txnSet(t, db, []byte("user/2"), []byte("blue"), 0)
+	txnSet(t, db, []byte("other"), []byte("red"), 0)
+	require.Equal(t, []string{"user/1"}, indexLookup(t, db, "tags", "red"))
+	require.Equal(t, []string{"user/1", "user/2"}, indexLookup(t, db, "tags", "blue"))
+
+	// The old index keys are replaced.
+	txnSet(t, db, []byte("user/1"), []byte("green"), 0)
+	require.Empty(t, indexLookup(t, db, "tags", "red"))
+	require.Equal(t, []string{"user/2"}, indexLookup(t, db, "tags", "blue"))
+	require.Equal(t, []string{"user/1"}, indexLookup(t, db, "tags", "green"))
+
+	// The merged value is indexed.
+	require.NoError(t, db.Update(func(txn *Txn) error {
+		return txn.Merge([]byte("user/2"), []byte("green"))
+	}))
+	require.Equal(t, []string{"user/1", "user/2"}, indexLookup(t, db, "tags", "green"))
+	require.Equal(t, []string{"user/2"}, indexLookup(t, db, "tags", "blue"))
+
+	require.NoError(t, db.Update(func(txn *Txn) error {
+		// The writes of the transaction are looked up, and rolled back with it.
+		require.NoError(t, txn.Set([]byte("user/3"), []byte("red")))
+		sp := txn.Savepoint()
+		require.NoError(t, txn.Delete([]byte("user/1")))
+		keys, err := txn.IndexLookup("tags", []byte("green"))
+		require.NoError(t, err)
+		require.Equal(t, [][]byte{[]byte("user/2")}, keys)
+		require.NoError(t, txn.RollbackTo(sp))
+		keys, err = txn.IndexLookup("tags", []byte("red"))
+		require.NoError(t, err)
+		require.Equal(t, [][]byte{[]byte("user/3")}, keys)
+
+		err = txn.DeleteRange([]byte("u"), []byte("v"))
+		require.Error(t, err)
+		require.NoError(t, txn.DeleteRange([]byte("o"), []byte("p")))
+		return nil
+	}))
+	require.Equal(t, []string{"user/1", "user/2"}, indexLookup(t, db, "tags", "green"))
+
+	txnDelete(t, db, []byte("user/2"))
+	require.Equal(t, []string{"user/1"}, indexLookup(t, db, "tags", "green"))
+	require.Empty(t, indexLookup(t, db, "tags", "blue"))
+
+	scan := func(start, end string) []string {
+		var out []string
+		var endKey []byte
+		if end != "" {
+			endKey = []byte(end)
+		}
+		require.NoError(t, db.View(func(txn *Txn) error {
+			return txn.IndexScan("tags", []byte(start), endKey, func(indexKey, key []byte) error {
+				out = append(out, fmt.Sprintf("%s=%s", indexKey, key))
+				return nil
+			})
+		}))
+		return out
+	}
+	txnSet(t, db, []byte("user/4"), []byte("green,red"), 0)
+	require.Equal(t, []string{"green=user/1", "green=user/4", "red=user/3", "red=user/4"},
+		scan("", ""))
+	require.Equal(t, []string{"green=user/1", "green=user/4"}, scan("", "red"))
+	require.Equal(t, []string{"red=user/3", "red=user/4"}, scan("gz", "rz"))
+	require.Empty(t, scan("x", ""))
+
+	// The index is kept across restarts.
+	require.NoError(t, db.Close())
+	db, err = Open(opt)
+	require.NoError(t, err)
+	defer func() { require.NoError(t, db.Close()) }()
+	require.NoError(t, db.View(func(txn *Txn) error {
+		_, err := txn.IndexLookup("tags", []byte("red"))
+		require.Equal(t, ErrIndexNotFound, err)
+		return nil
+	}))
+	_, err = db.AddIndex("tags", []byte("users/"), tagsIndex)
+	require.Error(t, err)
+	built, err = db.AddIndex("tags", []byte("user/"), tagsIndex)
+	require.NoError(t, err)
+	require.True(t, built)
+	require.Equal(t, []string{"user/3", "user/4"}, indexLookup(t, db, "tags", "red"))
+
+	// A dropped index has to be built again.
+	require.NoError(t, db.DropIndex("tags"))
+	require.NoError(t, db.View(func(txn *Txn) error {
+		_, err := txn.IndexLookup("tags", []byte("red"))
+		require.Equal(t, ErrIndexNotFound, err)
+		return nil
+	}))
+	built, err = db.AddIndex("tags", []byte("user/"), tagsIndex)
+	require.NoError(t, err)
+	require.False(t, built)
+	require.NoError(t, db.SetIndexBuilt("tags"))
+	require.Empty(t, indexLookup(t, db, "tags", "red"))
+}
+
+func TestIndexBypassingWrites(t *testing.T) {
+	runBadgerTest(t, nil, func(t *testing.T, db *DB) {
+		edir, err := ioutil.TempDir("", "badger-external")
+		require.NoError(t, err)
+		defer removeDir(edir)
+
+		_, err = db.AddIndex("tags", []byte("key1"), tagsIndex)
+		require.NoError(t, err)
+		require.NoError(t, db.SetIndexBuilt("tags"))
+
+		// The writes bypassing the transactions can't update the index.
+		require.Error(t, db.DropPrefix([]byte("key1")))
+		require.Error(t, db.DropPrefix([]byte("key12")))
+		require.Error(t, db.DropPrefix([]byte("key")))
+		require.Error(t, db.DropPrefix([]byte{}))
+		require.NoError(t, db.DropPrefix([]byte("key2")))
+		path := buildExternalFile(t, edir, "a.sst", 50, 150)
+		require.Error(t, db.IngestExternalFiles([]string{path}, externalTableOptions()))
+		path = buildExternalFile(t, edir, "b.sst", 200, 300)
+		require.NoError(t, db.IngestExternalFiles([]string{path}, externalTableOptions()))
+	})
+}
+
+func TestIndexTxnTooBig(t *testing.T) {
+	runBadgerTest(t, nil, func(t *testing.T, db *DB) {
+		_, err := db.AddIndex("tags", nil, tagsIndex)
+		require.NoError(t, err)
+		require.NoError(t, db.SetIndexBuilt("tags"))
+
+		txn := db.NewTransaction(true)
+		defer txn.Discard()
+		var n int
+		for ; ; n++ {
+			key := []byte(fmt.Sprintf("key%05d", n))
+			err := txn.Set(key, []byte(fmt.Sprintf("a%d,b%d,c%d", n, n, n)))
+			if err == ErrTxnTooBig {
+				break
+			}
+			require.NoError(t, err)
+		}
+		require.NoError(t, txn.Commit())
+
+		// Either all the entries of a key are written, or none.
+		var count int
+		require.NoError(t, db.View(func(txn *Txn) error {
+			return txn.IndexScan("tags", nil, nil, func(indexKey, key []byte) error {
+				count++
+				return nil
+			})
+		}))
+		require.Equal(t, 3*n, count)
+		require.Empty(t, indexLookup(t, db, "tags", fmt.Sprintf("a%d", n)))
+	})
+}
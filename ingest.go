@@ -0,0 +1,414 @@
+/*
+ * Copyright 2020 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package badger
+
+import (
+	"bytes"
+	"os"
+	"sort"
+	"sync/atomic"
+
+	"github.com/dgraph-io/badger/v2/pb"
+	"github.com/dgraph-io/badger/v2/table"
+	"github.com/dgraph-io/badger/v2/y"
+	"github.com/dgraph-io/ristretto/z"
+	humanize "github.com/dustin/go-humanize"
+	"github.com/pkg/errors"
+)
+
+// IngestExternalFiles atomically adds the key-value pairs stored in the given table files to the
+// DB. The files must have been built via SSTWriter or table.Builder, using the given table
+// options. Values which aren't stored in a table must be in the value file written alongside it
+// by SSTWriter. The key ranges of the files must not overlap.
+//
+// All the keys get a new commit version, which is higher than the version of any key in the DB.
+// When a file contains multiple versions of a key, only the latest one is ingested. The tables are
+// rewritten into the DB directory, with the values of at least ValueThreshold bytes written to the
+// value log, and placed at the lowest level where they don't overlap with any table in that level
+// or the levels above it. All the tables are added to the MANIFEST in one atomic change. Writes
+// aren't blocked during ingestion, but compactions are paused while the tables are being placed.
+// Concurrent ingestions run one after the other, and ErrBlockedWrites is returned once writes are
+// blocked by DropAll, DropPrefix or Close.
+// Transactions which read any of the ingested keys, or iterated over the key range of any of the
+// files, conflict with the ingestion.
+//
+// The ingested keys bypass the write path, so they are not sent to subscribers (see
+// DB.Subscribe and DB.SubscribeSince) or shipped to replicas. IngestExternalFiles returns
+// ErrIngestWithSubscribers if the DB has subscribers or a primary (see DB.NewPrimary).
+//
+// The ingested keys don't update the secondary indexes either (see DB.AddIndex), so the key ranges
+// of the files must not have keys covered by an index.
+//
+// The files are not modified or deleted, and can be removed once IngestExternalFiles returns.
+func (db *DB) IngestExternalFiles(paths []string, opts table.Options) error {
+	if db.opt.managedTxns {
+		panic("Cannot use IngestExternalFiles with managedDB=true. Use IngestExternalFilesAt instead.")
+	}
+	return db.ingestExternalFiles(paths, opts, 0)
+}
+
+// IngestExternalFilesAt follows the same logic as IngestExternalFiles, but uses the provided
+// commit timestamp as the version of all the ingested keys. This is only useful for databases
+// built on top of Badger (like Dgraph), and can be used only in managed mode.
+func (db *DB) IngestExternalFilesAt(paths []string, opts table.Options, commitTs uint64) error {
+	if !db.opt.managedTxns {
+		panic("Cannot use IngestExternalFilesAt with managedDB=false. " +
+			"Use IngestExternalFiles instead.")
+	}
+	if commitTs == 0 {
+		return errors.New("Commit timestamp must be greater than zero")
+	}
+	return db.ingestExternalFiles(paths, opts, commitTs)
+}
+
+func (db *DB) ingestExternalFiles(paths []string, opts table.Options, commitTs uint64) error {
+	if db.opt.InMemory {
+		return errors.New("Cannot ingest external files into an in-memory DB")
+	}
+	if db.opt.ReadOnly {
+		return errors.New("Cannot ingest external files into a DB opened in read-only mode")
+	}
+	if len(paths) == 0 {
+		return nil
+	}
+	// Ingestions are serialized, so that the tables placed by one are taken into account by the
+	// next one. See DB.ingestLock.
+	db.ingestLock.Lock()
+	defer db.ingestLock.Unlock()
+	if atomic.LoadInt32(&db.blockWrites) == 1 {
+		return ErrBlockedWrites
+	}
+	db.primaryLock.RLock()
+	hasPrimary := db.primary != nil
+	db.primaryLock.RUnlock()
+	if hasPrimary || db.pub.noOfSubscribers() > 0 {
+		return ErrIngestWithSubscribers
+	}
+
+	ext, err := db.openExternalTables(paths, opts)
+	defer func() {
+		for _, t := range ext {
+			_ = t.close()
+		}
+	}()
+	if err != nil {
+		return err
+	}
+
+	// Collect the keys for conflict detection, while validating the files.
+	txn := db.newTransaction(true, db.opt.managedTxns)
+	defer txn.Discard()
+	indexes := db.currentIndexes()
+	for _, t := range ext {
+		if err := t.validate(txn.conflictKeys); err != nil {
+			return err
+		}
+		// The key ranges of the files are recorded like range deletions, so that iterators over
+		// any of them conflict with the ingestion, without listing every key.
+		rt := rangeTombstone{
+			start: y.SafeCopy(nil, y.ParseKey(t.tbl.Smallest())),
+			end:   append(y.SafeCopy(nil, y.ParseKey(t.tbl.Biggest())), 0),
+		}
+		if indexes.overlaps(rt.start, rt.end) {
+			return errors.Errorf("Cannot ingest %s, which has keys covered by an index", t.path)
+		}
+		txn.rangeDels = append(txn.rangeDels, rt)
+	}
+
+	// Get the commit timestamp in the same way as a transaction commit does. Readers don't get to
+	// see this version until the tables have been placed in the LSM tree.
+	txn.commitTs = commitTs
+	commitTs = db.orc.newCommitTs(txn)
+	y.AssertTrue(commitTs > 0)
+	defer db.orc.doneCommit(commitTs)
+
+	var tables []*table.Table
+	defer func() {
+		// Release the references held by CreateTable. Tables which weren't added to a level get
+		// deleted.
+		_ = decrRefs(tables)
+	}()
+	for _, t := range ext {
+		tbls, err := db.rewriteExternalTable(t, commitTs)
+		tables = append(tables, tbls...)
+		if err != nil {
+			return err
+		}
+	}
+	return db.lc.ingestTables(tables)
+}
+
+// externalTable is a table file which isn't part of any DB.
+type externalTable struct {
+	path string
+	mf   *z.MmapFile
+	tbl  *table.Table
+	// vlog is the value file written by SSTWriter. It is nil if the table has no such file.
+	vlog *logFile
+}
+
+func (db *DB) openExternalTables(paths []string, opts table.Options) ([]*externalTable, error) {
+	if opts.DataKey != nil && opts.IndexCache == nil {
+		// The index of an encrypted table is read via the index cache.
+		if db.indexCache == nil {
+			return nil, errors.New("Index cache must be set to ingest encrypted files")
+		}
+		opts.IndexCache = db.indexCache
+	}
+	var ext []*externalTable
+	for _, path := range paths {
+		mf, err := z.OpenMmapFile(path, os.O_RDONLY, 0)
+		if err != nil {
+			return ext, y.Wrapf(err, "while opening external file: %s", path)
+		}
+		// The table is opened as an in-memory one, so that the file doesn't have to follow the
+		// naming convention of the DB tables, and doesn't get deleted when the table is closed.
+		// It still needs a unique ID, which is used as the key in the caches.
+		tbl, err := table.OpenInMemoryTable(mf.Data, db.lc.reserveFileID(), &opts)
+		if err != nil {
+			_ = mf.Close(-1)
+			return ext, y.Wrapf(err, "while opening external table: %s", path)
+		}
+		t := &externalTable{path: path, mf: mf, tbl: tbl}
+		ext = append(ext, t)
+		if t.vlog, err = openSSTValueFile(path, opts.DataKey); err != nil {
+			return ext, err
+		}
+	}
+
+	sort.Slice(ext, func(i, j int) bool {
+		return y.CompareKeys(ext[i].tbl.Smallest(), ext[j].tbl.Smallest()) < 0
+	})
+	for i := 1; i < len(ext); i++ {
+		prev, cur := ext[i-1], ext[i]
+		if bytes.Compare(y.ParseKey(prev.tbl.Biggest()), y.ParseKey(cur.tbl.Smallest())) >= 0 {
+			return ext, errors.Errorf("External files %s and %s have overlapping key ranges",
+				prev.path, cur.path)
+		}
+	}
+	return ext, nil
+}
+
+func (t *externalTable) close() error {
+	if t.vlog != nil {
+		if err := t.vlog.Close(-1); err != nil {
+			return err
+		}
+	}
+	if err := t.tbl.DecrRef(); err != nil {
+		return err
+	}
+	return t.mf.Close(-1)
+}
+
+// validate checks that the table only contains user keys, with values that can be read, and adds
+// the fingerprints of the keys to conflictKeys, if it isn't nil.
+func (t *externalTable) validate(conflictKeys map[uint64]struct{}) error {
+	it := t.tbl.NewIterator(0)
+	defer it.Close()
+	for it.Rewind(); it.Valid(); it.Next() {
+		key := y.ParseKey(it.Key())
+		if bytes.HasPrefix(key, badgerPrefix) {
+			return errors.Errorf("External file %s contains an internal key: %q", t.path, key)
+		}
+		if it.Value().Meta&bitValuePointer > 0 && t.vlog == nil {
+			return errors.Errorf("Value file for external file %s not found, for key: %q",
+				t.path, key)
+		}
+		if conflictKeys != nil {
+			conflictKeys[z.MemHash(key)] = struct{}{}
+		}
+	}
+	return nil
+}
+
+// rewriteExternalTable writes out the latest version of all the keys in the external table into
+// new tables in the DB directory, with their versions set to commitTs. The returned tables are
+// not part of the LSM tree yet.
+func (db *DB) rewriteExternalTable(t *externalTable, commitTs uint64) ([]*table.Table, error) {
+	var tables []*table.Table
+	bopts := buildTableOptions(db)
+	builder := table.NewTableBuilder(bopts)
+	finish := func() error {
+		defer builder.Close()
+		if builder.Empty() {
+			return nil
+		}
+		fname := table.NewFilename(db.lc.reserveFileID(), db.opt.Dir)
+		tbl, err := table.CreateTable(fname, builder)
+		if err != nil {
+			return y.Wrapf(err, "while creating table: %s", fname)
+		}
+		tables = append(tables, tbl)
+		return nil
+	}
+
+	// The entries are written to the value log in batches, before being added to the tables.
+	var pending []*Entry
+	var pendingSize int64
+	flush := func() error {
+		if len(pending) == 0 {
+			return nil
+		}
+		req := &request{Entries: pending}
+		if err := db.vlog.write([]*request{req}); err != nil {
+			return y.Wrapf(err, "while writing values of external file %s", t.path)
+		}
+		for i, e := range pending {
+			if builder.ReachedCapacity() {
+				if err := finish(); err != nil {
+					return err
+				}
+				builder = table.NewTableBuilder(bopts)
+			}
+			vs := y.ValueStruct{Value: e.Value, Meta: e.meta, UserMeta: e.UserMeta,
+				ExpiresAt: e.ExpiresAt}
+			var vp valuePointer
+			if !db.skipVlog(e) {
+				vp = req.Ptrs[i]
+				vs.Value, vs.Meta = vp.Encode(), vs.Meta|bitValuePointer
+			}
+			builder.Add(e.Key, vs, vp.Len)
+		}
+		pending, pendingSize = pending[:0], 0
+		return nil
+	}
+
+	var lastKey []byte
+	it := t.tbl.NewIterator(0)
+	defer it.Close()
+	for it.Rewind(); it.Valid(); it.Next() {
+		key := y.ParseKey(it.Key())
+		if bytes.Equal(key, lastKey) {
+			// Older version of the same key.
+			continue
+		}
+		lastKey = y.SafeCopy(lastKey, key)
+
+		vs := it.Value()
+		val := vs.Value
+		if vs.Meta&bitValuePointer > 0 {
+			var vp valuePointer
+			vp.Decode(vs.Value)
+			var err error
+			if val, err = t.vlog.readValue(vp); err != nil {
+				return tables, err
+			}
+		}
+		e := &Entry{
+			Key:       y.KeyWithTs(y.SafeCopy(nil, key), commitTs),
+			Value:     y.SafeCopy(nil, val),
+			UserMeta:  vs.UserMeta,
+			ExpiresAt: vs.ExpiresAt,
+			// Only retain the bits which make sense outside of the transaction which wrote the
+			// key.
+			meta: vs.Meta & (bitDelete | bitDiscardEarlierVersions),
+		}
+		pending = append(pending, e)
+		pendingSize += int64(e.estimateSize(db.valueThreshold(e.Key)))
+		if pendingSize >= db.opt.maxBatchSize || int64(len(pending)) >= db.opt.maxBatchCount {
+			if err := flush(); err != nil {
+				return tables, err
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return tables, err
+	}
+	// The tables must not point to values which could be lost in a crash.
+	if err := db.vlog.sync(); err != nil {
+		return tables, err
+	}
+	if err := finish(); err != nil {
+		return tables, err
+	}
+	db.opt.Infof("Rewrote external file %s into %d tables at version %d",
+		t.path, len(tables), commitTs)
+	return tables, nil
+}
+
+// ingestTables places the given tables in the LSM tree. Each table goes to the lowest level at
+// which it doesn't overlap with the tables in that level or the levels above it. The tables must
+// not overlap with each other. It must be called while holding DB.ingestLock.
+func (s *levelsController) ingestTables(tables []*table.Table) error {
+	if len(tables) == 0 {
+		return nil
+	}
+	// No compaction should change the levels while the tables are being placed. Level zero can
+	// still get new tables from memtable flushes, but those are always added to the end.
+	s.kv.stopCompactions()
+	defer s.kv.startCompactions()
+
+	levels := make([]int, len(tables))
+	changes := make([]*pb.ManifestChange, 0, len(tables))
+	for i, t := range tables {
+		levels[i] = s.ingestLevel(t)
+		changes = append(changes, newCreateChange(t.ID(), levels[i], t.KeyID(),
+			t.CompressionType()))
+	}
+	// Update the manifest _before_ the tables become part of the levels, just like it's done for
+	// level zero tables.
+	if err := s.kv.manifest.addChanges(changes); err != nil {
+		return err
+	}
+
+	toAdd := make([][]*table.Table, len(s.levels))
+	for i, t := range tables {
+		toAdd[levels[i]] = append(toAdd[levels[i]], t)
+		s.kv.opt.Infof("Ingested table %d at level %d. Size: %s\n",
+			t.ID(), levels[i], humanize.Bytes(uint64(t.Size())))
+	}
+	for _, t := range toAdd[0] {
+		s.levels[0].addTable(t)
+	}
+	for level := 1; level < len(s.levels); level++ {
+		if len(toAdd[level]) == 0 {
+			continue
+		}
+		if err := s.levels[level].replaceTables(nil, toAdd[level]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ingestLevel returns the lowest level at which the table doesn't overlap with the tables in that
+// level or the levels above it.
+func (s *levelsController) ingestLevel(t *table.Table) int {
+	kr := getKeyRange(t)
+
+	l0 := s.levels[0]
+	l0.RLock()
+	for _, lt := range l0.tables {
+		if getKeyRange(lt).overlapsWith(kr) {
+			l0.RUnlock()
+			return 0
+		}
+	}
+	l0.RUnlock()
+
+	for i := 1; i < len(s.levels); i++ {
+		lh := s.levels[i]
+		lh.RLock()
+		left, right := lh.overlappingTables(levelHandlerRLocked{}, kr)
+		lh.RUnlock()
+		if right > left {
+			return i - 1
+		}
+	}
+	return len(s.levels) - 1
+}
@@ -0,0 +1,284 @@
+/*
+ * Copyright 2020 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package badger
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/dgraph-io/badger/v2/options"
+	"github.com/dgraph-io/badger/v2/table"
+	"github.com/dgraph-io/badger/v2/y"
+	"github.com/stretchr/testify/require"
+)
+
+func externalTableOptions() table.Options {
+	return table.Options{
+		BlockSize:          4 << 10,
+		BloomFalsePositive: 0.01,
+		Compression:        options.Snappy,
+	}
+}
+
+// buildExternalFile writes the keys in [from, to) with two versions each into a table file.
+func buildExternalFile(t *testing.T, dir, name string, from, to int) string {
+	b := table.NewTableBuilder(externalTableOptions())
+	defer b.Close()
+	for i := from; i < to; i++ {
+		b.Add(y.KeyWithTs(rangeKey(i), 2), y.ValueStruct{Value: []byte(fmt.Sprintf("new%d", i))}, 0)
+		b.Add(y.KeyWithTs(rangeKey(i), 1), y.ValueStruct{Value: []byte(fmt.Sprintf("old%d", i))}, 0)
+	}
+	path := filepath.Join(dir, name)
+	require.NoError(t, ioutil.WriteFile(path, b.Finish(), 0600))
+	return path
+}
+
+func TestIngestExternalFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "badger-test")
+	require.NoError(t, err)
+	defer removeDir(dir)
+	edir, err := ioutil.TempDir("", "badger-external")
+	require.NoError(t, err)
+	defer removeDir(edir)
+
+	opt := getTestOptions(dir)
+	db, err := Open(opt)
+	require.NoError(t, err)
+
+	for i := 0; i < 100; i++ {
+		txnSet(t, db, rangeKey(i), []byte("db"), 0)
+	}
+	// Closing the DB flushes the memtable to level 0.
+	require.NoError(t, db.Close())
+	db, err = Open(opt)
+	require.NoError(t, err)
+	readTs := db.MaxVersion()
+
+	files := []string{
+		buildExternalFile(t, edir, "b.sst", 500, 900),
+		buildExternalFile(t, edir, "a.sst", 50, 150),
+	}
+	require.NoError(t, db.IngestExternalFiles(files, externalTableOptions()))
+
+	levels := make(map[int]int)
+	for _, ti := range db.Tables() {
+		levels[ti.Level]++
+	}
+	// The file overlapping with the existing keys goes to level 0, the other one to the last level.
+	require.Equal(t, 2, levels[0])
+	require.Equal(t, 1, levels[len(db.lc.levels)-1])
+
+	check := func(db *DB) {
+		require.NoError(t, db.View(func(txn *Txn) error {
+			require.Equal(t, 550, countKeys(t, txn, false))
+			for _, i := range []int{0, 49, 50, 99, 149, 500, 899} {
+				item, err := txn.Get(rangeKey(i))
+				require.NoError(t, err)
+				if i < 50 {
+					require.Equal(t, []byte("db"), getItemValue(t, item))
+					continue
+				}
+				require.Equal(t, []byte(fmt.Sprintf("new%d", i)), getItemValue(t, item))
+				require.Equal(t, readTs+1, item.Version())
+			}
+			return nil
+		}))
+	}
+	check(db)
+
+	// The ingested keys must survive a restart, and later writes must get a higher version.
+	require.NoError(t, db.Close())
+	db, err = Open(opt)
+	require.NoError(t, err)
+	defer func() { require.NoError(t, db.Close()) }()
+	check(db)
+	txnSet(t, db, rangeKey(50), []byte("db"), 0)
+	require.NoError(t, db.View(func(txn *Txn) error {
+		item, err := txn.Get(rangeKey(50))
+		require.NoError(t, err)
+		require.Equal(t, []byte("db"), getItemValue(t, item))
+		return nil
+	}))
+}
+
+func TestIngestExternalFilesConcurrent(t *testing.T) {
+	dir, err := ioutil.TempDir("", "badger-test")
+	require.NoError(t, err)
+	defer removeDir(dir)
+	edir, err := ioutil.TempDir("", "badger-external")
+	require.NoError(t, err)
+	defer removeDir(edir)
+
+	db, err := Open(getTestOptions(dir))
+	require.NoError(t, err)
+
+	// The files of neighbouring ingestions overlap, so they can't be placed at the same level.
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		path := buildExternalFile(t, edir, fmt.Sprintf("%d.sst", i), i*50, i*50+100)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			require.NoError(t, db.IngestExternalFiles([]string{path}, externalTableOptions()))
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		require.NoError(t, db.Flatten(2))
+	}()
+	wg.Wait()
+	require.NoError(t, db.lc.validate())
+	require.NoError(t, db.View(func(txn *Txn) error {
+		require.Equal(t, 450, countKeys(t, txn, false))
+		return nil
+	}))
+
+	// The ingestions racing with Close either finish before it, or fail.
+	for i := 0; i < 4; i++ {
+		path := filepath.Join(edir, fmt.Sprintf("%d.sst", i))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := db.IngestExternalFiles([]string{path}, externalTableOptions())
+			if err != nil {
+				require.Equal(t, ErrBlockedWrites, err)
+			}
+		}()
+	}
+	require.NoError(t, db.Close())
+	wg.Wait()
+	require.Equal(t, ErrBlockedWrites,
+		db.IngestExternalFiles([]string{filepath.Join(edir, "0.sst")}, externalTableOptions()))
+}
+
+func TestIngestExternalFilesInvalid(t *testing.T) {
+	runBadgerTest(t, nil, func(t *testing.T, db *DB) {
+		edir, err := ioutil.TempDir("", "badger-external")
+		require.NoError(t, err)
+		defer removeDir(edir)
+
+		overlapping := []string{
+			buildExternalFile(t, edir, "a.sst", 0, 100),
+			buildExternalFile(t, edir, "b.sst", 99, 200),
+		}
+		require.Error(t, db.IngestExternalFiles(overlapping, externalTableOptions()))
+
+		b := table.NewTableBuilder(externalTableOptions())
+		b.Add(y.KeyWithTs([]byte("!badger!head"), 1), y.ValueStruct{Value: []byte("foo")}, 0)
+		internal := filepath.Join(edir, "c.sst")
+		require.NoError(t, ioutil.WriteFile(internal, b.Finish(), 0600))
+		b.Close()
+		require.Error(t, db.IngestExternalFiles([]string{internal}, externalTableOptions()))
+
+		require.Error(t, db.IngestExternalFiles([]string{filepath.Join(edir, "missing.sst")},
+			externalTableOptions()))
+		require.Empty(t, db.Tables())
+	})
+}
+
+func TestIngestExternalFilesConflicts(t *testing.T) {
+	runBadgerTest(t, nil, func(t *testing.T, db *DB) {
+		edir, err := ioutil.TempDir("", "badger-external")
+		require.NoError(t, err)
+		defer removeDir(edir)
+
+		// Both the transaction reading a key and the one iterating over the range of a file
+		// conflict with the ingestion.
+		get := db.NewTransaction(true)
+		defer get.Discard()
+		_, err = get.Get(rangeKey(5))
+		require.Equal(t, ErrKeyNotFound, err)
+		require.NoError(t, get.Set([]byte("foo"), []byte("bar")))
+
+		iter := db.NewTransaction(true)
+		defer iter.Discard()
+		opt := DefaultIteratorOptions
+		opt.Prefix = []byte("key")
+		require.Equal(t, 0, func() int {
+			it := iter.NewIterator(opt)
+			defer it.Close()
+			var n int
+			for it.Rewind(); it.Valid(); it.Next() {
+				n++
+			}
+			return n
+		}())
+		require.NoError(t, iter.Set([]byte("foo"), []byte("bar")))
+
+		files := []string{buildExternalFile(t, edir, "a.sst", 0, 10)}
+		require.NoError(t, db.IngestExternalFiles(files, externalTableOptions()))
+		require.Equal(t, ErrConflict, get.Commit())
+		require.Equal(t, ErrConflict, iter.Commit())
+	})
+}
+
+func TestIngestExternalFilesWithSubscribers(t *testing.T) {
+	runBadgerTest(t, nil, func(t *testing.T, db *DB) {
+		edir, err := ioutil.TempDir("", "badger-external")
+		require.NoError(t, err)
+		defer removeDir(edir)
+		files := []string{buildExternalFile(t, edir, "a.sst", 0, 10)}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			_ = db.Subscribe(ctx, func(kvs *KVList) error { return nil }, []byte("key"))
+		}()
+		waitUntil(t, func() bool { return db.pub.noOfSubscribers() > 0 })
+		require.Equal(t, ErrIngestWithSubscribers,
+			db.IngestExternalFiles(files, externalTableOptions()))
+		cancel()
+		<-done
+
+		p, err := db.NewPrimary(1 << 20)
+		require.NoError(t, err)
+		require.Equal(t, ErrIngestWithSubscribers,
+			db.IngestExternalFiles(files, externalTableOptions()))
+		require.NoError(t, p.Close())
+	})
+}
+
+func TestIngestExternalFilesAt(t *testing.T) {
+	opt := getTestOptions("")
+	opt.managedTxns = true
+	runBadgerTest(t, &opt, func(t *testing.T, db *DB) {
+		edir, err := ioutil.TempDir("", "badger-external")
+		require.NoError(t, err)
+		defer removeDir(edir)
+
+		files := []string{buildExternalFile(t, edir, "a.sst", 0, 10)}
+		require.NoError(t, db.IngestExternalFilesAt(files, externalTableOptions(), 10))
+
+		txn := db.NewTransactionAt(9, false)
+		_, err = txn.Get(rangeKey(0))
+		require.Equal(t, ErrKeyNotFound, err)
+		txn.Discard()
+
+		txn = db.NewTransactionAt(10, false)
+		defer txn.Discard()
+		item, err := txn.Get(rangeKey(0))
+		require.NoError(t, err)
+		require.Equal(t, uint64(10), item.Version())
+		require.Equal(t, []byte("new0"), getItemValue(t, item))
+	})
+}
@@ -56,6 +56,14 @@ type Item struct {
 	status   prefetchStatus
 	meta     byte // We need to store meta to know about bitValuePointer.
 	userMeta byte
+
+	// prefixLen is the length of the keyspace prefix of the key, which is hidden from Key().
+	prefixLen int
+
+	// merge is set for the merge operands which are folded when the value is read. mergeTs is
+	// the version from which the older operands are looked up.
+	merge   MergeFunc
+	mergeTs uint64
 }
 
 // String returns a string representation of Item
@@ -68,14 +76,14 @@ func (item *Item) String() string {
 // Key is only valid as long as item is valid, or transaction is valid.  If you need to use it
 // outside its validity, please use KeyCopy.
 func (item *Item) Key() []byte {
-	return item.key
+	return item.key[item.prefixLen:]
 }
 
 // KeyCopy returns a copy of the key of the item, writing it to dst slice.
 // If nil is passed, or capacity of dst isn't sufficient, a new slice would be allocated and
 // returned.
 func (item *Item) KeyCopy(dst []byte) []byte {
-	return y.SafeCopy(dst, item.key)
+	return y.SafeCopy(dst, item.Key())
 }
 
 // Version returns the commit timestamp of the item.
@@ -154,6 +162,10 @@ func (item *Item) yieldItemValue() ([]byte, func(), error) {
 	if !item.hasValue() {
 		return nil, nil, nil
 	}
+	if item.merge != nil {
+		val, err := item.yieldMergedValue()
+		return val, nil, err
+	}
 
 	if item.slice == nil {
 		item.slice = new(y.Slice)
@@ -330,6 +342,37 @@ type IteratorOptions struct {
 	// prefix are picked based on their range of keys.
 	prefixIsKey bool   // If set, use the prefix for bloom filter lookup.
 	Prefix      []byte // Only iterate over this given prefix.
+
+	// LowerBound and UpperBound limit the iteration to the keys in [LowerBound, UpperBound). These
+	// are keys without timestamps, and a nil bound is ignored. Like Prefix, the bounds are used to
+	// skip the SSTables which can't have any keys in the range. Blocks beyond the bounds are not
+	// read either.
+	LowerBound []byte
+	UpperBound []byte
+}
+
+// compareToBounds returns -1 if the key is smaller than LowerBound, 1 if it is greater than or
+// equal to UpperBound, and 0 otherwise. The key must be without the timestamp.
+func (opt *IteratorOptions) compareToBounds(key []byte) int {
+	if len(opt.LowerBound) > 0 && bytes.Compare(key, opt.LowerBound) < 0 {
+		return -1
+	}
+	if len(opt.UpperBound) > 0 && bytes.Compare(key, opt.UpperBound) >= 0 {
+		return 1
+	}
+	return 0
+}
+
+// overlapsBounds returns true if the range of keys [smallest, biggest] can have keys within the
+// bounds. The keys must contain timestamps.
+func (opt *IteratorOptions) overlapsBounds(smallest, biggest []byte) bool {
+	if len(opt.UpperBound) > 0 && bytes.Compare(y.ParseKey(smallest), opt.UpperBound) >= 0 {
+		return false
+	}
+	if len(opt.LowerBound) > 0 && bytes.Compare(y.ParseKey(biggest), opt.LowerBound) < 0 {
+		return false
+	}
+	return true
 }
 
 func (opt *IteratorOptions) compareToPrefix(key []byte) int {
@@ -342,6 +385,9 @@ func (opt *IteratorOptions) compareToPrefix(key []byte) int {
 }
 
 func (opt *IteratorOptions) pickTable(t table.TableInterface) bool {
+	if !opt.overlapsBounds(t.Smallest(), t.Biggest()) {
+		return false
+	}
 	if len(opt.Prefix) == 0 {
 		return true
 	}
@@ -362,6 +408,7 @@ func (opt *IteratorOptions) pickTable(t table.TableInterface) bool {
 // pickTables picks the necessary table for the iterator. This function also assumes
 // that the tables are sorted in the right order.
 func (opt *IteratorOptions) pickTables(all []*table.Table) []*table.Table {
+	all = opt.tablesInBounds(all)
 	if len(opt.Prefix) == 0 {
 		out := make([]*table.Table, len(all))
 		copy(out, all)
@@ -408,6 +455,25 @@ func (opt *IteratorOptions) pickTables(all []*table.Table) []*table.Table {
 	return out
 }
 
+// tablesInBounds returns the sorted tables which can have keys within the bounds.
+func (opt *IteratorOptions) tablesInBounds(all []*table.Table) []*table.Table {
+	if len(opt.LowerBound) > 0 {
+		// If table.Biggest < opt.LowerBound, then so are all the preceding tables.
+		sIdx := sort.Search(len(all), func(i int) bool {
+			return bytes.Compare(y.ParseKey(all[i].Biggest()), opt.LowerBound) >= 0
+		})
+		all = all[sIdx:]
+	}
+	if len(opt.UpperBound) > 0 {
+		// If table.Smallest >= opt.UpperBound, then so are all the following tables.
+		eIdx := sort.Search(len(all), func(i int) bool {
+			return bytes.Compare(y.ParseKey(all[i].Smallest()), opt.UpperBound) >= 0
+		})
+		all = all[:eIdx]
+	}
+	return all
+}
+
 // DefaultIteratorOptions contains default options when iterating over Badger key-value stores.
 var DefaultIteratorOptions = IteratorOptions{
 	PrefetchValues: true,
@@ -429,6 +495,15 @@ type Iterator struct {
 
 	lastKey []byte // Used to skip over multiple versions of the same key.
 
+	// keyPrefix is the prefix of the keyspace being iterated over. It is added to the keys passed
+	// to Seek and ValidForPrefix, and hidden from the keys of the items.
+	keyPrefix []byte
+
+	// seekKey is the key the iterator was last positioned at by Seek. The range of keys between
+	// it and the current item is added to the reads of the transaction. See recordReadRange.
+	seekKey []byte
+	seeked  bool
+
 	closed bool
 
 	// ThreadId is an optional value that can be set to identify which goroutine created
@@ -499,7 +574,7 @@ func (txn *Txn) NewKeyIterator(key []byte, opt IteratorOptions) *Iterator {
 func (it *Iterator) newItem() *Item {
 	item := it.waste.pop()
 	if item == nil {
-		item = &Item{slice: new(y.Slice), txn: it.txn}
+		item = &Item{slice: new(y.Slice), txn: it.txn, prefixLen: len(it.keyPrefix)}
 	}
 	return item
 }
@@ -508,7 +583,7 @@ func (it *Iterator) newItem() *Item {
 // This item is only valid until it.Next() gets called.
 func (it *Iterator) Item() *Item {
 	tx := it.txn
-	tx.addReadKey(it.item.Key())
+	tx.addReadKey(it.item.key)
 	return it.item
 }
 
@@ -517,6 +592,9 @@ func (it *Iterator) Valid() bool {
 	if it.item == nil {
 		return false
 	}
+	if it.opt.compareToBounds(it.item.key) != 0 {
+		return false
+	}
 	if it.opt.prefixIsKey {
 		return bytes.Equal(it.item.key, it.opt.Prefix)
 	}
@@ -526,7 +604,7 @@ func (it *Iterator) Valid() bool {
 // ValidForPrefix returns false when iteration is done
 // or when the current key is not prefixed by the specified prefix.
 func (it *Iterator) ValidForPrefix(prefix []byte) bool {
-	return it.Valid() && bytes.HasPrefix(it.item.key, prefix)
+	return it.Valid() && bytes.HasPrefix(it.item.Key(), prefix)
 }
 
 // Close would close the iterator. It is important to call this when you're done with iteration.
@@ -535,6 +613,7 @@ func (it *Iterator) Close() {
 		return
 	}
 	it.closed = true
+	it.recordReadRange()
 
 	it.iitr.Close()
 	// It is important to wait for the fill goroutines to finish. Otherwise, we might leave zombie
@@ -564,7 +643,7 @@ func (it *Iterator) Next() {
 	// Set next item to current
 	it.item = it.data.pop()
 
-	for it.iitr.Valid() {
+	for it.iitr.Valid() && !it.pastEnd() {
 		if it.parseItem() {
 			// parseItem calls one extra next.
 			// This is used to deal with the complexity of reverse iteration.
@@ -607,6 +686,13 @@ func (it *Iterator) parseItem() bool {
 		return false
 	}
 
+	// Skip the keys outside the bounds. Keys past the end are never parsed, so these can only be
+	// the keys at the upper bound, when seeking to it in reverse.
+	if it.opt.compareToBounds(y.ParseKey(key)) != 0 {
+		mi.Next()
+		return false
+	}
+
 	// Skip any versions which are beyond the readTs.
 	version := y.ParseTs(key)
 	if version > it.readTs {
@@ -619,6 +705,9 @@ func (it *Iterator) parseItem() bool {
 		// whether the key was deleted.
 		item := it.newItem()
 		it.fill(item)
+		if it.isRangeDeleted(item.key, version) {
+			item.meta |= bitDelete
+		}
 		setItem(item)
 		mi.Next()
 		return true
@@ -642,7 +731,8 @@ func (it *Iterator) parseItem() bool {
 FILL:
 	// If deleted, advance and return.
 	vs := mi.Value()
-	if isDeletedOrExpired(vs.Meta, vs.ExpiresAt) {
+	if isDeletedOrExpired(vs.Meta, vs.ExpiresAt) ||
+		it.isRangeDeleted(y.ParseKey(mi.Key()), y.ParseTs(mi.Key())) {
 		mi.Next()
 		return false
 	}
@@ -670,6 +760,28 @@ FILL:
 	return true
 }
 
+// isRangeDeleted returns true if the given version of the key is hidden by a range tombstone. This
+// includes the ranges deleted by the iterator's own transaction, which hide every version of a key
+// not written by the transaction itself.
+func (it *Iterator) isRangeDeleted(key []byte, version uint64) bool {
+	if len(it.txn.rangeDels) > 0 {
+		if _, ok := it.txn.pendingWrites[string(key)]; !ok && it.txn.pendingRangeDeleted(key) {
+			return true
+		}
+	}
+	return it.txn.db.isRangeDeleted(key, version, it.readTs)
+}
+
+// pastEnd returns true if the underlying iterator has moved past the bound in the direction of
+// the iteration. No more items can be found after that.
+func (it *Iterator) pastEnd() bool {
+	c := it.opt.compareToBounds(y.ParseKey(it.iitr.Key()))
+	if it.opt.Reverse {
+		return c < 0
+	}
+	return c > 0
+}
+
 func (it *Iterator) fill(item *Item) {
 	vs := it.iitr.Value()
 	item.meta = vs.Meta
@@ -681,6 +793,15 @@ func (it *Iterator) fill(item *Item) {
 
 	item.vptr = y.SafeCopy(item.vptr, vs.Value)
 	item.val = nil
+	item.merge = nil
+	if vs.Meta&bitMergeEntry > 0 && !it.opt.AllVersions {
+		item.merge = it.txn.db.opt.mergeFunc(item.key)
+		item.mergeTs = item.version - 1
+		if _, ok := it.txn.pendingWrites[string(item.key)]; ok && item.version == it.readTs {
+			// The operand was written by the transaction, and is merged into the committed value.
+			item.mergeTs = item.version
+		}
+	}
 	if it.opt.PrefetchValues {
 		item.wg.Add(1)
 		go func() {
@@ -700,7 +821,7 @@ func (it *Iterator) prefetch() {
 	i := it.iitr
 	var count int
 	it.item = nil
-	for i.Valid() {
+	for i.Valid() && !it.pastEnd() {
 		if !it.parseItem() {
 			continue
 		}
@@ -715,9 +836,13 @@ func (it *Iterator) prefetch() {
 // smallest key greater than the provided key if iterating in the forward direction.
 // Behavior would be reversed if iterating backwards.
 func (it *Iterator) Seek(key []byte) {
+	if len(key) > 0 && len(it.keyPrefix) > 0 {
+		key = append(y.SafeCopy(nil, it.keyPrefix), key...)
+	}
 	if len(key) > 0 {
 		it.txn.addReadKey(key)
 	}
+	it.recordReadRange()
 	for i := it.data.pop(); i != nil; i = it.data.pop() {
 		i.wg.Wait()
 		it.waste.push(i)
@@ -726,7 +851,21 @@ func (it *Iterator) Seek(key []byte) {
 	it.lastKey = it.lastKey[:0]
 	if len(key) == 0 {
 		key = it.opt.Prefix
+		if it.opt.Reverse && len(it.opt.UpperBound) > 0 {
+			key = it.opt.UpperBound
+		}
+	}
+	// Start from the bound, if the key is beyond it.
+	if !it.opt.Reverse && len(it.opt.LowerBound) > 0 &&
+		bytes.Compare(key, it.opt.LowerBound) < 0 {
+		key = it.opt.LowerBound
+	}
+	if it.opt.Reverse && len(it.opt.UpperBound) > 0 &&
+		bytes.Compare(key, it.opt.UpperBound) > 0 {
+		key = it.opt.UpperBound
 	}
+	it.seekKey = y.SafeCopy(it.seekKey, key)
+	it.seeked = true
 	if len(key) == 0 {
 		it.iitr.Rewind()
 		it.prefetch()
@@ -734,7 +873,7 @@ func (it *Iterator) Seek(key []byte) {
 	}
 
 	if !it.opt.Reverse {
-		key = y.KeyWithTs(key, it.txn.readTs)
+		key = y.KeyWithTs(key, it.readTs)
 	} else {
 		key = y.KeyWithTs(key, 0)
 	}
@@ -120,6 +120,103 @@ func TestPickSortTables(t *testing.T) {
 	filtered = opt.pickTables(tables)
 	require.Equal(t, y.ParseKey(filtered[0].Smallest()), []byte("a"))
 	require.Equal(t, y.ParseKey(filtered[0].Biggest()), []byte("abc"))
+
+	opt.Prefix = nil
+	opt.LowerBound = []byte("abd")
+	opt.UpperBound = []byte("ckr")
+	filtered = opt.pickTables(tables)
+	require.Equal(t, 2, len(filtered))
+	require.Equal(t, y.ParseKey(filtered[0].Smallest()), []byte("abcd"))
+	require.Equal(t, y.ParseKey(filtered[1].Smallest()), []byte("cge"))
+	require.False(t, opt.pickTable(tables[0]))
+	require.True(t, opt.pickTable(tables[2]))
+	require.False(t, opt.pickTable(tables[3]))
+
+	opt.Prefix = []byte("c")
+	filtered = opt.pickTables(tables)
+	require.Equal(t, 1, len(filtered))
+	require.Equal(t, y.ParseKey(filtered[0].Smallest()), []byte("cge"))
+}
+
+func TestIteratorBounds(t *testing.T) {
+	dir, err := ioutil.TempDir("", "badger-test")
+	require.NoError(t, err)
+	defer removeDir(dir)
+
+	opt := getTestOptions(dir)
+	db, err := Open(opt)
+	require.NoError(t, err)
+	// Spread the keys across the lower levels, level 0 and the memtable.
+	for i := 0; i < 300; i++ {
+		txnSet(t, db, rangeKey(i), []byte("lower"), 0)
+	}
+	require.NoError(t, db.Close())
+	db, err = Open(opt)
+	require.NoError(t, err)
+	require.NoError(t, db.Flatten(1))
+	for i := 0; i < 300; i += 2 {
+		txnSet(t, db, rangeKey(i), []byte("l0"), 0)
+	}
+	require.NoError(t, db.Close())
+	db, err = Open(opt)
+	require.NoError(t, err)
+	defer func() { require.NoError(t, db.Close()) }()
+	for i := 0; i < 300; i += 3 {
+		txnSet(t, db, rangeKey(i), []byte("mem"), 0)
+	}
+
+	iterate := func(iopt IteratorOptions, seek []byte) []string {
+		var keys []string
+		require.NoError(t, db.View(func(txn *Txn) error {
+			it := txn.NewIterator(iopt)
+			defer it.Close()
+			for it.Seek(seek); it.Valid(); it.Next() {
+				keys = append(keys, string(it.Item().Key()))
+			}
+			return nil
+		}))
+		return keys
+	}
+	expected := func(from, to int, reverse bool) []string {
+		var keys []string
+		for i := from; i < to; i++ {
+			keys = append(keys, string(rangeKey(i)))
+		}
+		if reverse {
+			for i, j := 0, len(keys)-1; i < j; i, j = i+1, j-1 {
+				keys[i], keys[j] = keys[j], keys[i]
+			}
+		}
+		return keys
+	}
+
+	for _, reverse := range []bool{false, true} {
+		iopt := DefaultIteratorOptions
+		iopt.Reverse = reverse
+		iopt.PrefetchSize = 10
+		iopt.LowerBound = rangeKey(100)
+		iopt.UpperBound = rangeKey(200)
+		require.Equal(t, expected(100, 200, reverse), iterate(iopt, nil))
+		// Seeking before the start of the range starts from the bound. Seeking past its end finds
+		// nothing.
+		if reverse {
+			require.Equal(t, expected(100, 200, reverse), iterate(iopt, []byte("z")))
+			require.Empty(t, iterate(iopt, []byte("key")))
+			require.Equal(t, expected(100, 151, reverse), iterate(iopt, rangeKey(150)))
+		} else {
+			require.Equal(t, expected(100, 200, reverse), iterate(iopt, []byte("key")))
+			require.Empty(t, iterate(iopt, []byte("z")))
+			require.Equal(t, expected(150, 200, reverse), iterate(iopt, rangeKey(150)))
+		}
+
+		iopt.LowerBound = nil
+		require.Equal(t, expected(0, 200, reverse), iterate(iopt, nil))
+		iopt.LowerBound = rangeKey(100)
+		iopt.UpperBound = nil
+		require.Equal(t, expected(100, 300, reverse), iterate(iopt, nil))
+		iopt.UpperBound = rangeKey(100)
+		require.Empty(t, iterate(iopt, nil))
+	}
 }
 
 func TestIteratePrefix(t *testing.T) {
@@ -0,0 +1,156 @@
+/*
+ * Copyright 2020 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package badger
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dgraph-io/badger/v2/y"
+	"github.com/pkg/errors"
+)
+
+// dataKeySize is the size of the data keys generated when a KeyManager is used. The data keys are
+// used for AES-256.
+const dataKeySize = 32
+
+// KeyManager protects the data keys of the key registry with a master key, which never has to be
+// handed to Badger. This allows keeping the master keys in an external key management service or
+// an HSM. Every master key is identified by an ID, which is recorded in the key registry, so that
+// the data keys can be unwrapped after the current master key has been rotated.
+//
+// The methods are called concurrently, and must be safe for concurrent use.
+type KeyManager interface {
+	// CurrentMasterKeyID returns the ID of the master key used to wrap the data keys when the key
+	// registry is written. The ID must not be empty.
+	CurrentMasterKeyID() string
+	// WrapDataKey encrypts the data key with the master key with the given ID. iv is a random
+	// initialization vector generated for every data key, which can be used by the encryption.
+	WrapDataKey(masterKeyID string, iv, dataKey []byte) ([]byte, error)
+	// UnwrapDataKey decrypts the data key wrapped by WrapDataKey.
+	UnwrapDataKey(masterKeyID string, iv, wrapped []byte) ([]byte, error)
+}
+
+// rawKeyManager wraps the data keys with the key passed via Options.EncryptionKey. Its master key
+// ID is empty, which keeps the key registry in the format used before key managers existed.
+type rawKeyManager struct {
+	key []byte
+}
+
+func (m rawKeyManager) CurrentMasterKeyID() string {
+	return ""
+}
+
+func (m rawKeyManager) WrapDataKey(masterKeyID string, iv, dataKey []byte) ([]byte, error) {
+	return y.XORBlockAllocate(dataKey, m.key, iv)
+}
+
+func (m rawKeyManager) UnwrapDataKey(masterKeyID string, iv, wrapped []byte) ([]byte, error) {
+	return y.XORBlockAllocate(wrapped, m.key, iv)
+}
+
+// localKeyManager implements KeyManager for the master keys that can be loaded into the process.
+// The data keys are encrypted with AES in CTR mode, like they are with a raw encryption key.
+type localKeyManager struct {
+	currentID string
+	loadKey   func(id string) ([]byte, error)
+}
+
+func (m *localKeyManager) CurrentMasterKeyID() string {
+	return m.currentID
+}
+
+func (m *localKeyManager) masterKey(id string) ([]byte, error) {
+	key, err := m.loadKey(id)
+	if err != nil {
+		return nil, y.Wrapf(err, "while loading master key %q", id)
+	}
+	switch len(key) {
+	case 16, 24, 32:
+		return key, nil
+	default:
+		return nil, y.Wrapf(ErrInvalidEncryptionKey, "master key %q", id)
+	}
+}
+
+func (m *localKeyManager) WrapDataKey(masterKeyID string, iv, dataKey []byte) ([]byte, error) {
+	key, err := m.masterKey(masterKeyID)
+	if err != nil {
+		return nil, err
+	}
+	return y.XORBlockAllocate(dataKey, key, iv)
+}
+
+func (m *localKeyManager) UnwrapDataKey(masterKeyID string, iv, wrapped []byte) ([]byte, error) {
+	key, err := m.masterKey(masterKeyID)
+	if err != nil {
+		return nil, err
+	}
+	return y.XORBlockAllocate(wrapped, key, iv)
+}
+
+// NewFileKeyManager returns a KeyManager which reads the master keys from the files in dir. The
+// ID of a master key is the name of its file, which holds the raw key of 16, 24 or 32 bytes.
+// currentID is the ID of the master key used to wrap the data keys. The files are read whenever a
+// key is needed, so the directory can be mounted from a secret store, and the old keys can be
+// removed once no key registry uses them.
+func NewFileKeyManager(dir, currentID string) KeyManager {
+	return &localKeyManager{
+		currentID: currentID,
+		loadKey: func(id string) ([]byte, error) {
+			if id == "" || filepath.Base(id) != id {
+				return nil, errors.Errorf("Invalid master key ID: %q", id)
+			}
+			return ioutil.ReadFile(filepath.Join(dir, id))
+		},
+	}
+}
+
+// NewEnvKeyManager returns a KeyManager which reads the master keys from the environment. The
+// master key with ID id is read from the variable prefix+id, and holds the key of 16, 24 or 32
+// bytes encoded in standard base64. currentID is the ID of the master key used to wrap the data
+// keys.
+func NewEnvKeyManager(prefix, currentID string) KeyManager {
+	return &localKeyManager{
+		currentID: currentID,
+		loadKey: func(id string) ([]byte, error) {
+			if id == "" {
+				return nil, errors.Errorf("Invalid master key ID: %q", id)
+			}
+			val, ok := os.LookupEnv(prefix + id)
+			if !ok {
+				return nil, errors.Errorf("Environment variable %s is not set", prefix+id)
+			}
+			return base64.StdEncoding.DecodeString(strings.TrimSpace(val))
+		},
+	}
+}
+
+// keyManager returns the key manager protecting the data keys, or nil if encryption is disabled.
+func (opt *KeyRegistryOptions) keyManager() KeyManager {
+	switch {
+	case opt.KeyManager != nil:
+		return opt.KeyManager
+	case len(opt.EncryptionKey) > 0:
+		return rawKeyManager{key: opt.EncryptionKey}
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,150 @@
+/*
+ * Copyright 2020 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package badger
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeMasterKey(t *testing.T, dir, id string) []byte {
+	key := make([]byte, 32)
+	_, err := rand.Read(key)
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, id), key, 0600))
+	return key
+}
+
+func TestFileKeyManager(t *testing.T) {
+	dir, err := ioutil.TempDir("", "badger-test")
+	require.NoError(t, err)
+	defer removeDir(dir)
+	keyDir, err := ioutil.TempDir("", "badger-keys")
+	require.NoError(t, err)
+	defer removeDir(keyDir)
+	writeMasterKey(t, keyDir, "k1")
+	writeMasterKey(t, keyDir, "k2")
+
+	opt := getTestOptions(dir).WithKeyManager(NewFileKeyManager(keyDir, "k1")).
+		WithBlockCacheSize(1 << 20).WithIndexCacheSize(1 << 20)
+	db, err := Open(opt)
+	require.NoError(t, err)
+	require.Equal(t, "k1", db.registry.MasterKeyID())
+	for i := 0; i < 100; i++ {
+		txnSet(t, db, rangeKey(i), []byte("value"), 0)
+	}
+	require.NoError(t, db.Close())
+
+	// The master key ID is recorded in the registry, instead of the raw key.
+	data, err := ioutil.ReadFile(filepath.Join(dir, KeyRegistryFileName))
+	require.NoError(t, err)
+	require.True(t, bytes.HasPrefix(data, keyRegistryMagic))
+	require.Contains(t, string(data), "k1")
+
+	// Rotate the master key. The data keys are unwrapped with the key recorded in the registry.
+	krOpt := KeyRegistryOptions{Dir: dir, ReadOnly: true, KeyManager: NewFileKeyManager(keyDir, "")}
+	kr, err := OpenKeyRegistry(krOpt)
+	require.NoError(t, err)
+	require.Equal(t, "k1", kr.MasterKeyID())
+	krOpt.KeyManager = NewFileKeyManager(keyDir, "k2")
+	require.NoError(t, WriteKeyRegistry(kr, krOpt))
+
+	// The old master key is no longer needed.
+	require.NoError(t, os.Remove(filepath.Join(keyDir, "k1")))
+	db, err = Open(opt)
+	require.NoError(t, err)
+	require.Equal(t, "k2", db.registry.MasterKeyID())
+	require.NoError(t, db.View(func(txn *Txn) error {
+		require.Equal(t, 100, countKeys(t, txn, false))
+		return nil
+	}))
+	require.NoError(t, db.Close())
+
+	// A different key with the same ID is detected.
+	writeMasterKey(t, keyDir, "k2")
+	_, err = Open(opt)
+	require.Equal(t, ErrEncryptionKeyMismatch, err)
+
+	_, err = Open(opt.WithEncryptionKey(make([]byte, 32)))
+	require.Error(t, err)
+}
+
+func TestEnvKeyManager(t *testing.T) {
+	dir, err := ioutil.TempDir("", "badger-test")
+	require.NoError(t, err)
+	defer removeDir(dir)
+
+	key := make([]byte, 16)
+	_, err = rand.Read(key)
+	require.NoError(t, err)
+	require.NoError(t, os.Setenv("BADGER_TEST_KEY_a", base64.StdEncoding.EncodeToString(key)))
+	defer os.Unsetenv("BADGER_TEST_KEY_a")
+
+	opt := getTestOptions(dir).WithKeyManager(NewEnvKeyManager("BADGER_TEST_KEY_", "a")).
+		WithBlockCacheSize(1 << 20).WithIndexCacheSize(1 << 20)
+	db, err := Open(opt)
+	require.NoError(t, err)
+	txnSet(t, db, []byte("key"), []byte("value"), 0)
+	require.NoError(t, db.Close())
+
+	db, err = Open(opt)
+	require.NoError(t, err)
+	require.NoError(t, db.View(func(txn *Txn) error {
+		item, err := txn.Get([]byte("key"))
+		require.NoError(t, err)
+		require.Equal(t, []byte("value"), getItemValue(t, item))
+		return nil
+	}))
+	require.NoError(t, db.Close())
+
+	require.NoError(t, os.Unsetenv("BADGER_TEST_KEY_a"))
+	_, err = Open(opt)
+	require.Error(t, err)
+}
+
+func TestRawKeyRegistryFormat(t *testing.T) {
+	dir, err := ioutil.TempDir("", "badger-test")
+	require.NoError(t, err)
+	defer removeDir(dir)
+
+	key := make([]byte, 32)
+	_, err = rand.Read(key)
+	require.NoError(t, err)
+	kr, err := OpenKeyRegistry(getRegistryTestOptions(dir, key))
+	require.NoError(t, err)
+	dk, err := kr.LatestDataKey()
+	require.NoError(t, err)
+	require.Len(t, dk.Data, 32)
+	require.NoError(t, kr.Close())
+
+	// A raw encryption key keeps the registry in the original format: the IV followed by the
+	// encrypted sanity text.
+	data, err := ioutil.ReadFile(filepath.Join(dir, KeyRegistryFileName))
+	require.NoError(t, err)
+	require.False(t, bytes.HasPrefix(data, keyRegistryMagic))
+	iv := data[:16]
+	sanity, err := rawKeyManager{key: key}.UnwrapDataKey("", iv, data[16:16+len(sanityText)])
+	require.NoError(t, err)
+	require.Equal(t, sanityText, sanity)
+}
@@ -23,6 +23,7 @@ import (
 	"encoding/binary"
 	"hash/crc32"
 	"io"
+	"math"
 	"os"
 	"path/filepath"
 	"sync"
@@ -30,6 +31,7 @@ import (
 
 	"github.com/dgraph-io/badger/v2/pb"
 	"github.com/dgraph-io/badger/v2/y"
+	"github.com/pkg/errors"
 )
 
 const (
@@ -42,6 +44,10 @@ const (
 // SanityText is used to check whether the given user provided storage key is valid or not
 var sanityText = []byte("Hello Badger")
 
+// keyRegistryMagic starts the key registry files which record the ID of their master key. The
+// files written without a key manager start with the IV instead.
+var keyRegistryMagic = []byte("BDGKREG2")
+
 // KeyRegistry used to maintain all the data keys.
 type KeyRegistry struct {
 	sync.RWMutex
@@ -50,6 +56,9 @@ type KeyRegistry struct {
 	nextKeyID   uint64
 	fp          *os.File
 	opt         KeyRegistryOptions
+	// km wraps the data keys with the master key identified by masterKeyID.
+	km          KeyManager
+	masterKeyID string
 }
 
 type KeyRegistryOptions struct {
@@ -58,15 +67,30 @@ type KeyRegistryOptions struct {
 	EncryptionKey                 []byte
 	EncryptionKeyRotationDuration time.Duration
 	InMemory                      bool
+	// KeyManager is used instead of EncryptionKey to protect the data keys, if set.
+	KeyManager KeyManager
 }
 
 // newKeyRegistry returns KeyRegistry.
 func newKeyRegistry(opt KeyRegistryOptions) *KeyRegistry {
-	return &KeyRegistry{
+	kr := &KeyRegistry{
 		dataKeys:  make(map[uint64]*pb.DataKey),
 		nextKeyID: 0,
 		opt:       opt,
+		km:        opt.keyManager(),
+	}
+	if kr.km != nil {
+		kr.masterKeyID = kr.km.CurrentMasterKeyID()
 	}
+	return kr
+}
+
+// MasterKeyID returns the ID of the master key which protects the data keys. It is empty if the
+// data keys are protected by a raw encryption key, or aren't encrypted.
+func (kr *KeyRegistry) MasterKeyID() string {
+	kr.RLock()
+	defer kr.RUnlock()
+	return kr.masterKeyID
 }
 
 // OpenKeyRegistry opens key registry if it exists, otherwise it'll create key registry
@@ -130,44 +154,72 @@ func OpenKeyRegistry(opt KeyRegistryOptions) (*KeyRegistry, error) {
 
 // keyRegistryIterator reads all the datakey from the key registry
 type keyRegistryIterator struct {
-	encryptionKey []byte
-	fp            *os.File
+	km          KeyManager
+	masterKeyID string
+	fp          *os.File
 	// lenCrcBuf contains crc buf and data length to move forward.
 	lenCrcBuf [8]byte
 }
 
 // newKeyRegistryIterator returns iterator which will allow you to iterate
 // over the data key of the key registry.
-func newKeyRegistryIterator(fp *os.File, encryptionKey []byte) (*keyRegistryIterator, error) {
-	return &keyRegistryIterator{
-		encryptionKey: encryptionKey,
-		fp:            fp,
-		lenCrcBuf:     [8]byte{},
-	}, validRegistry(fp, encryptionKey)
+func newKeyRegistryIterator(fp *os.File, km KeyManager) (*keyRegistryIterator, error) {
+	kri := &keyRegistryIterator{
+		km:        km,
+		fp:        fp,
+		lenCrcBuf: [8]byte{},
+	}
+	var err error
+	kri.masterKeyID, err = validRegistry(fp, km)
+	return kri, err
 }
 
-// validRegistry checks that given encryption key is valid or not.
-func validRegistry(fp *os.File, encryptionKey []byte) error {
+// validRegistry checks that given encryption key is valid or not. It returns the ID of the master
+// key recorded in the registry.
+func validRegistry(fp *os.File, km KeyManager) (string, error) {
 	iv := make([]byte, aes.BlockSize)
 	var err error
-	if _, err = fp.Read(iv); err != nil {
-		return y.Wrapf(err, "Error while reading IV for key registry.")
+	if _, err = io.ReadFull(fp, iv); err != nil {
+		return "", y.Wrapf(err, "Error while reading IV for key registry.")
 	}
+	var masterKeyID string
 	eSanityText := make([]byte, len(sanityText))
-	if _, err = fp.Read(eSanityText); err != nil {
-		return y.Wrapf(err, "Error while reading sanity text.")
+	if bytes.Equal(iv[:len(keyRegistryMagic)], keyRegistryMagic) {
+		// The rest of the header holds the master key ID, the IV and the length of the sanity
+		// text, which depends on the key manager. Its start has already been read.
+		r := io.MultiReader(bytes.NewReader(iv[len(keyRegistryMagic):]), fp)
+		var lenBuf [4]byte
+		if _, err = io.ReadFull(r, lenBuf[:2]); err != nil {
+			return "", y.Wrapf(err, "Error while reading header of key registry.")
+		}
+		id := make([]byte, binary.BigEndian.Uint16(lenBuf[:2]))
+		if _, err = io.ReadFull(r, id); err != nil {
+			return "", y.Wrapf(err, "Error while reading master key ID.")
+		}
+		masterKeyID = string(id)
+		iv = make([]byte, aes.BlockSize)
+		if _, err = io.ReadFull(r, iv); err != nil {
+			return "", y.Wrapf(err, "Error while reading IV for key registry.")
+		}
+		if _, err = io.ReadFull(r, lenBuf[:]); err != nil {
+			return "", y.Wrapf(err, "Error while reading length of sanity text.")
+		}
+		eSanityText = make([]byte, binary.BigEndian.Uint32(lenBuf[:]))
+	}
+	if _, err = io.ReadFull(fp, eSanityText); err != nil {
+		return "", y.Wrapf(err, "Error while reading sanity text.")
 	}
-	if len(encryptionKey) > 0 {
+	if km != nil {
 		// Decrypting sanity text.
-		if eSanityText, err = y.XORBlockAllocate(eSanityText, encryptionKey, iv); err != nil {
-			return y.Wrapf(err, "During validRegistry")
+		if eSanityText, err = km.UnwrapDataKey(masterKeyID, iv, eSanityText); err != nil {
+			return "", y.Wrapf(err, "During validRegistry")
 		}
 	}
 	// Check the given key is valid or not.
 	if !bytes.Equal(eSanityText, sanityText) {
-		return ErrEncryptionKeyMismatch
+		return "", ErrEncryptionKeyMismatch
 	}
-	return nil
+	return masterKeyID, nil
 }
 
 func (kri *keyRegistryIterator) next() (*pb.DataKey, error) {
@@ -198,9 +250,10 @@ func (kri *keyRegistryIterator) next() (*pb.DataKey, error) {
 	if err = dataKey.Unmarshal(data); err != nil {
 		return nil, y.Wrapf(err, "While unmarshal of datakey in keyRegistryIterator.next")
 	}
-	if len(kri.encryptionKey) > 0 {
+	if kri.km != nil {
 		// Decrypt the key if the storage key exists.
-		if dataKey.Data, err = y.XORBlockAllocate(dataKey.Data, kri.encryptionKey, dataKey.Iv); err != nil {
+		dataKey.Data, err = kri.km.UnwrapDataKey(kri.masterKeyID, dataKey.Iv, dataKey.Data)
+		if err != nil {
 			return nil, y.Wrapf(err, "While decrypting datakey in keyRegistryIterator.next")
 		}
 	}
@@ -209,11 +262,13 @@ func (kri *keyRegistryIterator) next() (*pb.DataKey, error) {
 
 // readKeyRegistry will read the key registry file and build the key registry struct.
 func readKeyRegistry(fp *os.File, opt KeyRegistryOptions) (*KeyRegistry, error) {
-	itr, err := newKeyRegistryIterator(fp, opt.EncryptionKey)
+	kr := newKeyRegistry(opt)
+	itr, err := newKeyRegistryIterator(fp, kr.km)
 	if err != nil {
 		return nil, err
 	}
-	kr := newKeyRegistry(opt)
+	// The new data keys are wrapped with the master key of the registry, until it is rewritten.
+	kr.masterKeyID = itr.masterKeyID
 	var dk *pb.DataKey
 	dk, err = itr.next()
 	for err == nil && dk != nil {
@@ -242,29 +297,52 @@ Structure of Key Registry.
 +-------------------+---------------------+--------------------+--------------+------------------+
 |     IV            | Sanity Text         | DataKey1           | DataKey2     | ...              |
 +-------------------+---------------------+--------------------+--------------+------------------+
+
+If the master key has an ID, the registry starts with a header recording it instead:
++-------+---------------+---------------+----+--------------------+-------------+----------+-----+
+| Magic | ID Len (2 B)  | Master Key ID | IV | Sanity Len (4 B)   | Sanity Text | DataKey1 | ... |
++-------+---------------+---------------+----+--------------------+-------------+----------+-----+
 */
 
 // WriteKeyRegistry will rewrite the existing key registry file with new one.
 // It is okay to give closed key registry. Since, it's using only the datakey.
+// The data keys are wrapped with the current master key of opt.KeyManager, if set.
 func WriteKeyRegistry(reg *KeyRegistry, opt KeyRegistryOptions) error {
 	buf := &bytes.Buffer{}
 	iv, err := y.GenerateIV()
 	y.Check(err)
+	km := opt.keyManager()
+	var masterKeyID string
 	// Encrypt sanity text if the encryption key is presents.
 	eSanity := sanityText
-	if len(opt.EncryptionKey) > 0 {
+	if km != nil {
+		masterKeyID = km.CurrentMasterKeyID()
 		var err error
-		eSanity, err = y.XORBlockAllocate(eSanity, opt.EncryptionKey, iv)
+		eSanity, err = km.WrapDataKey(masterKeyID, iv, eSanity)
 		if err != nil {
 			return y.Wrapf(err, "Error while encrpting sanity text in WriteKeyRegistry")
 		}
 	}
-	y.Check2(buf.Write(iv))
+	if len(masterKeyID) > 0 {
+		if len(masterKeyID) > math.MaxUint16 {
+			return errors.Errorf("Master key ID is too long: %d bytes", len(masterKeyID))
+		}
+		var lenBuf [4]byte
+		y.Check2(buf.Write(keyRegistryMagic))
+		binary.BigEndian.PutUint16(lenBuf[:2], uint16(len(masterKeyID)))
+		y.Check2(buf.Write(lenBuf[:2]))
+		y.Check2(buf.WriteString(masterKeyID))
+		y.Check2(buf.Write(iv))
+		binary.BigEndian.PutUint32(lenBuf[:], uint32(len(eSanity)))
+		y.Check2(buf.Write(lenBuf[:]))
+	} else {
+		y.Check2(buf.Write(iv))
+	}
 	y.Check2(buf.Write(eSanity))
 	// Write all the datakeys to the buf.
 	for _, k := range reg.dataKeys {
 		// Writing the datakey to the given buffer.
-		if err := storeDataKey(buf, opt.EncryptionKey, k); err != nil {
+		if err := storeDataKey(buf, km, masterKeyID, k); err != nil {
 			return y.Wrapf(err, "Error while storing datakey in WriteKeyRegistry")
 		}
 	}
@@ -313,7 +391,7 @@ func (kr *KeyRegistry) DataKey(id uint64) (*pb.DataKey, error) {
 // period. If the last generated datakey lifetime exceeds the rotation period.
 // It'll create new datakey.
 func (kr *KeyRegistry) LatestDataKey() (*pb.DataKey, error) {
-	if len(kr.opt.EncryptionKey) == 0 {
+	if kr.km == nil {
 		// nil is for no encryption.
 		return nil, nil
 	}
@@ -342,7 +420,12 @@ func (kr *KeyRegistry) LatestDataKey() (*pb.DataKey, error) {
 	if valid {
 		return key, nil
 	}
-	k := make([]byte, len(kr.opt.EncryptionKey))
+	keySize := dataKeySize
+	if kr.opt.KeyManager == nil {
+		// The data keys have the size of the raw encryption key.
+		keySize = len(kr.opt.EncryptionKey)
+	}
+	k := make([]byte, keySize)
 	iv, err := y.GenerateIV()
 	if err != nil {
 		return nil, err
@@ -363,7 +446,7 @@ func (kr *KeyRegistry) LatestDataKey() (*pb.DataKey, error) {
 	if !kr.opt.InMemory {
 		// Store the datekey.
 		buf := &bytes.Buffer{}
-		if err = storeDataKey(buf, kr.opt.EncryptionKey, dk); err != nil {
+		if err = storeDataKey(buf, kr.km, kr.masterKeyID, dk); err != nil {
 			return nil, err
 		}
 		// Persist the datakey to the disk
@@ -371,13 +454,52 @@ func (kr *KeyRegistry) LatestDataKey() (*pb.DataKey, error) {
 			return nil, err
 		}
 	}
-	// storeDatakey encrypts the datakey So, placing un-encrypted key in the memory.
-	dk.Data = k
 	kr.lastCreated = dk.CreatedAt
 	kr.dataKeys[kr.nextKeyID] = dk
 	return dk, nil
 }
 
+// rotateMasterKey rewrites the key registry with the data keys wrapped with the new master key,
+// which is either the raw key or the current master key of km. The new data keys are appended to
+// the rewritten file afterwards.
+func (kr *KeyRegistry) rotateMasterKey(key []byte, km KeyManager) error {
+	kr.Lock()
+	defer kr.Unlock()
+
+	opt := kr.opt
+	opt.EncryptionKey = key
+	opt.KeyManager = km
+	if !opt.InMemory {
+		if err := WriteKeyRegistry(kr, opt); err != nil {
+			return y.Wrapf(err, "while rotating the master key")
+		}
+		fp, err := y.OpenExistingFile(filepath.Join(opt.Dir, KeyRegistryFileName), y.Sync)
+		if err != nil {
+			return y.Wrapf(err, "Error while opening rewritten key registry.")
+		}
+		if _, err := fp.Seek(0, io.SeekEnd); err != nil {
+			fp.Close()
+			return y.Wrapf(err, "Error while seeking rewritten key registry.")
+		}
+		// The old file has been replaced by the rename, so errors while closing it don't matter.
+		_ = kr.fp.Close()
+		kr.fp = fp
+	}
+	kr.opt = opt
+	kr.km = opt.keyManager()
+	kr.masterKeyID = kr.km.CurrentMasterKeyID()
+	return nil
+}
+
+// rotateDataKey generates a new data key, even if the rotation period of the latest one hasn't
+// elapsed yet.
+func (kr *KeyRegistry) rotateDataKey() (*pb.DataKey, error) {
+	kr.Lock()
+	kr.lastCreated = 0
+	kr.Unlock()
+	return kr.LatestDataKey()
+}
+
 // Close closes the key registry.
 func (kr *KeyRegistry) Close() error {
 	if !(kr.opt.ReadOnly || kr.opt.InMemory) {
@@ -386,39 +508,29 @@ func (kr *KeyRegistry) Close() error {
 	return nil
 }
 
-// storeDataKey stores datakey in an encrypted format in the given buffer. If storage key preset.
-func storeDataKey(buf *bytes.Buffer, storageKey []byte, k *pb.DataKey) error {
-	// xor will encrypt the IV and xor with the given data.
-	// It'll used for both encryption and decryption.
-	xor := func() error {
-		if len(storageKey) == 0 {
-			return nil
-		}
+// storeDataKey stores datakey in an encrypted format in the given buffer, if a key manager is
+// given. The data key itself is left unencrypted.
+func storeDataKey(buf *bytes.Buffer, km KeyManager, masterKeyID string, k *pb.DataKey) error {
+	stored := &pb.DataKey{
+		KeyId:     k.KeyId,
+		Data:      k.Data,
+		Iv:        k.Iv,
+		CreatedAt: k.CreatedAt,
+	}
+	if km != nil {
 		var err error
-		k.Data, err = y.XORBlockAllocate(k.Data, storageKey, k.Iv)
-		return err
-	}
-	// In memory datakey will be plain text so encrypting before storing to the disk.
-	var err error
-	if err = xor(); err != nil {
-		return y.Wrapf(err, "Error while encrypting datakey in storeDataKey")
-	}
-	var data []byte
-	if data, err = k.Marshal(); err != nil {
-		err = y.Wrapf(err, "Error while marshaling datakey in storeDataKey")
-		var err2 error
-		// decrypting the datakey back.
-		if err2 = xor(); err2 != nil {
-			return y.Wrapf(err,
-				y.Wrapf(err2, "Error while decrypting datakey in storeDataKey").Error())
+		if stored.Data, err = km.WrapDataKey(masterKeyID, k.Iv, k.Data); err != nil {
+			return y.Wrapf(err, "Error while encrypting datakey in storeDataKey")
 		}
-		return err
+	}
+	data, err := stored.Marshal()
+	if err != nil {
+		return y.Wrapf(err, "Error while marshaling datakey in storeDataKey")
 	}
 	var lenCrcBuf [8]byte
 	binary.BigEndian.PutUint32(lenCrcBuf[0:4], uint32(len(data)))
 	binary.BigEndian.PutUint32(lenCrcBuf[4:8], crc32.Checksum(data, y.CastagnoliCrcTable))
 	y.Check2(buf.Write(lenCrcBuf[:]))
 	y.Check2(buf.Write(data))
-	// Decrypting the datakey back since we're using the pointer.
-	return xor()
+	return nil
 }
@@ -0,0 +1,163 @@
+/*
+ * Copyright 2020 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package badger
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/dgraph-io/badger/v2/y"
+	"github.com/pkg/errors"
+)
+
+// RotateMasterKey replaces the encryption key protecting the data keys with newKey, while the DB
+// keeps serving requests. The key registry is rewritten to a temporary file, which atomically
+// replaces the old one. The data itself stays encrypted with the same data keys, see ReencryptAll.
+//
+// The DB must be opened with newKey afterwards. newKey must be 16, 24 or 32 bytes long.
+func (db *DB) RotateMasterKey(newKey []byte) error {
+	switch len(newKey) {
+	case 16, 24, 32:
+	default:
+		return y.Wrapf(ErrInvalidEncryptionKey, "During RotateMasterKey")
+	}
+	return db.rotateMasterKey(newKey, nil)
+}
+
+// RotateKeyManager is like RotateMasterKey, but the data keys are wrapped with the current master
+// key of km. It can be used to rotate the master key of a KeyManager, or to move a DB encrypted
+// with a raw key to a KeyManager. The DB must be opened with km afterwards.
+func (db *DB) RotateKeyManager(km KeyManager) error {
+	if km == nil {
+		return errors.New("KeyManager cannot be nil")
+	}
+	if len(km.CurrentMasterKeyID()) == 0 {
+		return errors.New("Master key ID of the KeyManager cannot be empty")
+	}
+	return db.rotateMasterKey(nil, km)
+}
+
+func (db *DB) rotateMasterKey(key []byte, km KeyManager) error {
+	if db.opt.ReadOnly {
+		return errors.New("Cannot rotate the master key in read-only mode")
+	}
+	if !db.shouldEncrypt() {
+		return ErrNotEncrypted
+	}
+	return db.registry.rotateMasterKey(key, km)
+}
+
+// ReencryptAll generates a new data key, and rewrites all the tables and value log files
+// encrypted with the older data keys, so that the older keys don't protect any data afterwards.
+// The tables are rewritten via compactions, and the value log files via value log GC, while the
+// DB keeps serving requests. ReencryptAll blocks until all of it is done, so it would usually be
+// run in a separate goroutine.
+//
+// ReencryptAll returns ErrRejected if value log GC is running. It can be called again, and only
+// the data still encrypted with older keys is rewritten.
+func (db *DB) ReencryptAll() error {
+	if db.opt.ReadOnly {
+		return errors.New("Cannot re-encrypt the data in read-only mode")
+	}
+	if !db.shouldEncrypt() {
+		return ErrNotEncrypted
+	}
+	dk, err := db.registry.rotateDataKey()
+	if err != nil {
+		return y.Wrapf(err, "while generating a new data key")
+	}
+	atomic.StoreUint64(&db.reencryptKeyID, dk.KeyId)
+
+	// The empty request makes the write goroutine replace the memtable and the value log file being
+	// written to, which are encrypted with an older key.
+	if err := db.batchSet(nil); err != nil {
+		return err
+	}
+	for db.hasStaleMemtables() {
+		if db.IsClosed() {
+			return ErrDBClosed
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !db.opt.InMemory {
+		if err := db.vlog.reencrypt(dk.KeyId); err != nil {
+			return err
+		}
+	}
+
+	// Compactions started before the new key was generated can still write tables encrypted with
+	// an older key, so we keep going until there are no such tables.
+	for {
+		var stale, busy int
+		// The tables of level 0 are sorted by age, so they are compacted to the base level.
+		if n := len(db.lc.staleTables(0, dk.KeyId)); n > 0 {
+			stale += n
+			cp := compactionPriority{level: 0, score: 1.71}
+			switch err := db.lc.doCompact(-1, cp); err {
+			case nil:
+			case errFillTables:
+				busy++
+			default:
+				return err
+			}
+		}
+		for l := 1; l < len(db.lc.levels); l++ {
+			for _, t := range db.lc.staleTables(l, dk.KeyId) {
+				stale++
+				switch err := db.lc.rewriteTable(l, t); err {
+				case nil:
+				case errFillTables:
+					busy++
+				default:
+					return err
+				}
+			}
+		}
+		if stale == 0 {
+			break
+		}
+		if db.IsClosed() {
+			return ErrDBClosed
+		}
+		if busy > 0 {
+			// Wait for the compactions working on the same tables.
+			time.Sleep(100 * time.Millisecond)
+		}
+	}
+	db.opt.Infof("Re-encrypted all the data with data key %d", dk.KeyId)
+	return nil
+}
+
+// staleKey returns true if lf is encrypted with a data key older than the one generated by the
+// last ReencryptAll.
+func (db *DB) staleKey(lf *logFile) bool {
+	keyID := atomic.LoadUint64(&db.reencryptKeyID)
+	return lf != nil && keyID > 0 && lf.keyID() < keyID
+}
+
+// hasStaleMemtables returns true if any of the memtables waiting to be flushed is encrypted with a
+// data key older than the one generated by the last ReencryptAll.
+func (db *DB) hasStaleMemtables() bool {
+	db.RLock()
+	defer db.RUnlock()
+	for _, mt := range db.imm {
+		if db.staleKey(mt.wal) {
+			return true
+		}
+	}
+	return false
+}
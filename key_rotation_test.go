@@ -0,0 +1,158 @@
+/*
+ * Copyright 2020 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package badger
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRotateMasterKey(t *testing.T) {
+	dir, err := ioutil.TempDir("", "badger-test")
+	require.NoError(t, err)
+	defer removeDir(dir)
+	keyDir, err := ioutil.TempDir("", "badger-keys")
+	require.NoError(t, err)
+	defer removeDir(keyDir)
+
+	oldKey := make([]byte, 32)
+	_, err = rand.Read(oldKey)
+	require.NoError(t, err)
+	newKey := make([]byte, 16)
+	_, err = rand.Read(newKey)
+	require.NoError(t, err)
+
+	opt := getTestOptions(dir).WithBlockCacheSize(1 << 20).WithIndexCacheSize(1 << 20)
+	db, err := Open(opt.WithEncryptionKey(oldKey))
+	require.NoError(t, err)
+	for i := 0; i < 100; i++ {
+		txnSet(t, db, rangeKey(i), []byte("value"), 0)
+	}
+	require.Error(t, db.RotateMasterKey([]byte("short")))
+	require.NoError(t, db.RotateMasterKey(newKey))
+	// The new data keys are appended to the rewritten registry.
+	require.NoError(t, db.ReencryptAll())
+	for i := 100; i < 200; i++ {
+		txnSet(t, db, rangeKey(i), []byte("value"), 0)
+	}
+	require.NoError(t, db.Close())
+
+	_, err = Open(opt.WithEncryptionKey(oldKey))
+	require.Equal(t, ErrEncryptionKeyMismatch, err)
+	db, err = Open(opt.WithEncryptionKey(newKey))
+	require.NoError(t, err)
+	require.NoError(t, db.View(func(txn *Txn) error {
+		require.Equal(t, 200, countKeys(t, txn, false))
+		return nil
+	}))
+
+	// Move to a key manager.
+	writeMasterKey(t, keyDir, "k1")
+	km := NewFileKeyManager(keyDir, "k1")
+	require.NoError(t, db.RotateKeyManager(km))
+	require.Equal(t, "k1", db.registry.MasterKeyID())
+	require.NoError(t, db.Close())
+
+	db, err = Open(opt.WithKeyManager(km))
+	require.NoError(t, err)
+	require.NoError(t, db.View(func(txn *Txn) error {
+		require.Equal(t, 200, countKeys(t, txn, false))
+		return nil
+	}))
+	require.NoError(t, db.Close())
+
+	plainDir, err := ioutil.TempDir("", "badger-test")
+	require.NoError(t, err)
+	defer removeDir(plainDir)
+	db, err = Open(getTestOptions(plainDir))
+	require.NoError(t, err)
+	require.Equal(t, ErrNotEncrypted, db.RotateMasterKey(newKey))
+	require.Equal(t, ErrNotEncrypted, db.ReencryptAll())
+	require.NoError(t, db.Close())
+}
+
+func TestReencryptAll(t *testing.T) {
+	dir, err := ioutil.TempDir("", "badger-test")
+	require.NoError(t, err)
+	defer removeDir(dir)
+
+	key := make([]byte, 32)
+	_, err = rand.Read(key)
+	require.NoError(t, err)
+	opt := getTestOptions(dir).WithEncryptionKey(key).
+		WithBlockCacheSize(1 << 20).WithIndexCacheSize(1 << 20).
+		WithValueThreshold(32).WithValueLogMaxEntries(100)
+	db, err := Open(opt)
+	require.NoError(t, err)
+
+	value := func(i int) []byte {
+		return []byte(fmt.Sprintf("%0100d", i))
+	}
+	for i := 0; i < 1000; i++ {
+		txnSet(t, db, rangeKey(i), value(i), 0)
+	}
+	// Move some tables out of level 0.
+	for {
+		err := db.lc.doCompact(-1, compactionPriority{level: 0, score: 1.71})
+		if err != errFillTables {
+			require.NoError(t, err)
+			break
+		}
+	}
+
+	// The DB keeps serving writes while the data is re-encrypted.
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 1000; i < 1500; i++ {
+			txnSet(t, db, rangeKey(i), value(i), 0)
+		}
+	}()
+	require.NoError(t, db.ReencryptAll())
+	wg.Wait()
+
+	keyID := atomic.LoadUint64(&db.reencryptKeyID)
+	require.NotZero(t, keyID)
+	for l := range db.lc.levels {
+		require.Empty(t, db.lc.staleTables(l, keyID), "level %d", l)
+	}
+	db.vlog.filesLock.RLock()
+	for _, fid := range db.vlog.sortedFids() {
+		require.Equal(t, keyID, db.vlog.filesMap[fid].keyID())
+	}
+	db.vlog.filesLock.RUnlock()
+	require.NoError(t, db.Close())
+
+	db, err = Open(opt)
+	require.NoError(t, err)
+	defer func() { require.NoError(t, db.Close()) }()
+	require.NoError(t, db.View(func(txn *Txn) error {
+		for i := 0; i < 1500; i++ {
+			item, err := txn.Get(rangeKey(i))
+			require.NoError(t, err)
+			require.Equal(t, value(i), getItemValue(t, item))
+		}
+		return nil
+	}))
+}
@@ -0,0 +1,464 @@
+/*
+ * Copyright 2020 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package badger
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/dgraph-io/badger/v2/options"
+	"github.com/dgraph-io/badger/v2/table"
+	"github.com/dgraph-io/badger/v2/y"
+	"github.com/pkg/errors"
+)
+
+var (
+	// keyspacePrefix is the prefix of the keys stored in keyspaces. The key of an entry is
+	// keyspacePrefix, followed by the big endian encoded keyspace ID and the user key.
+	keyspacePrefix = []byte("!badger!ks!")
+	// keyspaceDefPrefix is the prefix of the internal keys which store the keyspace definitions.
+	// The key of a definition is keyspaceDefPrefix followed by the keyspace name.
+	keyspaceDefPrefix = []byte("!badger!ksdef!")
+)
+
+// KeyspaceOptions are the options of a keyspace. These override the corresponding DB options for
+// the keys in the keyspace.
+type KeyspaceOptions struct {
+	// NumVersionsToKeep is the maximum number of versions of a key kept by compactions.
+	NumVersionsToKeep int
+	// ValueThreshold is the size above which values are stored in the value log.
+	ValueThreshold int
+	// DefaultTTL is the TTL of the entries written without an expiry time. Zero means the entries
+	// never expire.
+	DefaultTTL time.Duration
+
+	// The following options are used for the tables written by compactions. The tables in level 0
+	// are shared by all the keyspaces, and are built using the DB options.
+	Compression          options.CompressionType
+	ZSTDCompressionLevel int
+	BlockSize            int
+	BloomFalsePositive   float64
+}
+
+// DefaultKeyspaceOptions returns the options for a keyspace which behaves like the default
+// keyspace of the DB.
+func (db *DB) DefaultKeyspaceOptions() KeyspaceOptions {
+	return KeyspaceOptions{
+		NumVersionsToKeep:    db.opt.NumVersionsToKeep,
+		ValueThreshold:       db.opt.ValueThreshold,
+		Compression:          db.opt.Compression,
+		ZSTDCompressionLevel: db.opt.ZSTDCompressionLevel,
+		BlockSize:            db.opt.BlockSize,
+		BloomFalsePositive:   db.opt.BloomFalsePositive,
+	}
+}
+
+// WithNumVersionsToKeep returns a new KeyspaceOptions value with NumVersionsToKeep set to the
+// given value.
+func (opt KeyspaceOptions) WithNumVersionsToKeep(val int) KeyspaceOptions {
+	opt.NumVersionsToKeep = val
+	return opt
+}
+
+// WithValueThreshold returns a new KeyspaceOptions value with ValueThreshold set to the given
+// value.
+func (opt KeyspaceOptions) WithValueThreshold(val int) KeyspaceOptions {
+	opt.ValueThreshold = val
+	return opt
+}
+
+// WithDefaultTTL returns a new KeyspaceOptions value with DefaultTTL set to the given value.
+func (opt KeyspaceOptions) WithDefaultTTL(val time.Duration) KeyspaceOptions {
+	opt.DefaultTTL = val
+	return opt
+}
+
+// WithCompression returns a new KeyspaceOptions value with Compression set to the given value.
+func (opt KeyspaceOptions) WithCompression(cType options.CompressionType) KeyspaceOptions {
+	opt.Compression = cType
+	return opt
+}
+
+// WithZSTDCompressionLevel returns a new KeyspaceOptions value with ZSTDCompressionLevel set to
+// the given value.
+func (opt KeyspaceOptions) WithZSTDCompressionLevel(cLevel int) KeyspaceOptions {
+	opt.ZSTDCompressionLevel = cLevel
+	return opt
+}
+
+// WithBlockSize returns a new KeyspaceOptions value with BlockSize set to the given value.
+func (opt KeyspaceOptions) WithBlockSize(val int) KeyspaceOptions {
+	opt.BlockSize = val
+	return opt
+}
+
+// WithBloomFalsePositive returns a new KeyspaceOptions value with BloomFalsePositive set to the
+// given value.
+func (opt KeyspaceOptions) WithBloomFalsePositive(val float64) KeyspaceOptions {
+	opt.BloomFalsePositive = val
+	return opt
+}
+
+func (opt KeyspaceOptions) validate(dbOpt Options) error {
+	switch {
+	case opt.NumVersionsToKeep < 1:
+		return errors.New("NumVersionsToKeep must be at least 1")
+	case opt.ValueThreshold > maxValueThreshold:
+		return errors.Errorf("Invalid ValueThreshold, must be less or equal to %d",
+			maxValueThreshold)
+	case int64(opt.ValueThreshold) > dbOpt.maxBatchSize:
+		return errors.Errorf("Valuethreshold %d greater than max batch size of %d",
+			opt.ValueThreshold, dbOpt.maxBatchSize)
+	case opt.DefaultTTL < 0:
+		return errors.New("DefaultTTL cannot be negative")
+	case opt.BlockSize <= 0:
+		return errors.New("BlockSize must be greater than zero")
+	}
+	return nil
+}
+
+// tableOptions overrides the table options with the ones of the keyspace.
+func (opt KeyspaceOptions) tableOptions(bopts table.Options) table.Options {
+	bopts.Compression = opt.Compression
+	bopts.ZSTDCompressionLevel = opt.ZSTDCompressionLevel
+	bopts.BlockSize = opt.BlockSize
+	bopts.BloomFalsePositive = opt.BloomFalsePositive
+	return bopts
+}
+
+// keyspaceDef is the definition of a keyspace, as stored in the LSM tree.
+type keyspaceDef struct {
+	ID      uint32
+	Name    string
+	Options KeyspaceOptions
+}
+
+func keyspaceDefKey(name string) []byte {
+	return append(y.SafeCopy(nil, keyspaceDefPrefix), name...)
+}
+
+func keyspaceKeyPrefix(id uint32) []byte {
+	out := make([]byte, len(keyspacePrefix)+4)
+	copy(out, keyspacePrefix)
+	binary.BigEndian.PutUint32(out[len(keyspacePrefix):], id)
+	return out
+}
+
+// keyspaceID returns the ID of the keyspace of the key, which may have a timestamp. It returns
+// zero for the keys which aren't in any keyspace.
+func keyspaceID(key []byte) uint32 {
+	if len(key) < len(keyspacePrefix)+4 || !bytes.HasPrefix(key, keyspacePrefix) {
+		return 0
+	}
+	return binary.BigEndian.Uint32(key[len(keyspacePrefix):])
+}
+
+// isKeyspaceKey returns true if the key stores an entry of a keyspace, or a keyspace definition.
+// Unlike the other internal keys, these are included in streams and change streams.
+func isKeyspaceKey(key []byte) bool {
+	return bytes.HasPrefix(key, keyspacePrefix) || bytes.HasPrefix(key, keyspaceDefPrefix)
+}
+
+// keyspaceList holds the definitions of all the keyspaces. These are kept in memory, so that
+// writes and compactions can look up the options for a key. They are rebuilt from the LSM tree
+// when the DB is opened.
+type keyspaceList struct {
+	sync.RWMutex
+	defs  map[uint32]*keyspaceDef
+	ids   map[string]uint32
+	maxID uint32
+
+	// createLock serializes the calls to CreateKeyspace.
+	createLock sync.Mutex
+}
+
+func (l *keyspaceList) get(id uint32) *keyspaceDef {
+	l.RLock()
+	defer l.RUnlock()
+	return l.defs[id]
+}
+
+func (l *keyspaceList) getByName(name string) *keyspaceDef {
+	l.RLock()
+	defer l.RUnlock()
+	id, ok := l.ids[name]
+	if !ok {
+		return nil
+	}
+	return l.defs[id]
+}
+
+func (l *keyspaceList) add(def *keyspaceDef) {
+	l.Lock()
+	defer l.Unlock()
+	if l.defs == nil {
+		l.defs = make(map[uint32]*keyspaceDef)
+		l.ids = make(map[string]uint32)
+	}
+	l.defs[def.ID] = def
+	l.ids[def.Name] = def.ID
+	if def.ID > l.maxID {
+		l.maxID = def.ID
+	}
+}
+
+func (l *keyspaceList) reset() {
+	l.Lock()
+	defer l.Unlock()
+	l.defs, l.ids, l.maxID = nil, nil, 0
+}
+
+// valueThreshold returns the value threshold for the key, taking its keyspace into account.
+func (db *DB) valueThreshold(key []byte) int {
+	if db.opt.InMemory {
+		return db.opt.ValueThreshold
+	}
+	if id := keyspaceID(key); id > 0 {
+		if def := db.keyspaces.get(id); def != nil {
+			return def.Options.ValueThreshold
+		}
+		return db.opt.ValueThreshold
+	}
+	if bytes.HasPrefix(key, keyspaceDefPrefix) || bytes.HasPrefix(key, clockKey) ||
+		bytes.HasPrefix(key, snapshotPrefix) {
+		// Keyspace definitions, clock samples and snapshots are read while opening the DB,
+		// straight from the LSM tree.
+		return math.MaxInt32
+	}
+	return db.opt.ValueThreshold
+}
+
+// loadKeyspaces rebuilds the list of keyspaces by reading their definitions from the memtables
+// and the LSM tree.
+func (db *DB) loadKeyspaces() error {
+	tables, decr := db.getMemTables()
+	defer decr()
+
+	opt := IteratorOptions{Prefix: keyspaceDefPrefix}
+	var iters []y.Iterator
+	for _, mt := range tables {
+		iters = append(iters, mt.sl.NewUniIterator(false))
+	}
+	iters = db.lc.appendIterators(iters, &opt)
+	if len(iters) == 0 {
+		return nil
+	}
+	it := table.NewMergeIterator(iters, false)
+	defer it.Close()
+
+	var lastKey []byte
+	for it.Seek(y.KeyWithTs(keyspaceDefPrefix, math.MaxUint64)); it.Valid(); it.Next() {
+		if !bytes.HasPrefix(it.Key(), keyspaceDefPrefix) {
+			break
+		}
+		// Only the latest version of a definition is used.
+		if y.SameKey(it.Key(), lastKey) {
+			continue
+		}
+		lastKey = y.SafeCopy(lastKey, it.Key())
+		if err := db.addKeyspaceDef(it.Value().Value); err != nil {
+			return y.Wrapf(err, "while loading keyspace definition %q", it.Key())
+		}
+	}
+	return nil
+}
+
+// addKeyspaceDef adds the keyspace with the given encoded definition to the list of keyspaces.
+func (db *DB) addKeyspaceDef(val []byte) error {
+	def := &keyspaceDef{}
+	if err := json.Unmarshal(val, def); err != nil {
+		return y.Wrap(err, "while decoding keyspace definition")
+	}
+	db.keyspaces.add(def)
+	return nil
+}
+
+// writeKeyspaceDef persists the definition of a keyspace.
+func (db *DB) writeKeyspaceDef(def *keyspaceDef) error {
+	val, err := json.Marshal(def)
+	if err != nil {
+		return err
+	}
+	txn := db.newTransaction(true, db.opt.managedTxns)
+	defer txn.Discard()
+	if err := txn.modifyInternal(&Entry{Key: keyspaceDefKey(def.Name), Value: val}); err != nil {
+		return err
+	}
+	if db.opt.managedTxns {
+		// The definitions are read regardless of their version. Use a version higher than the
+		// ones of any older definition.
+		return txn.CommitAt(db.MaxVersion()+1, nil)
+	}
+	return txn.Commit()
+}
+
+// Keyspace is a named set of keys, with its own options. The keys in a keyspace are independent of
+// the keys in the default keyspace, and in other keyspaces. All the keyspaces share the write-ahead
+// log, the value log and the commit timestamps of the DB, so a single transaction can atomically
+// read and write keys across keyspaces.
+//
+// The keys in a keyspace are stored with an internal prefix, and are not visible to the
+// iterators of the default keyspace. Streams over the whole DB, backups and change streams
+// include them with their internal keys, along with the definitions of the keyspaces, so that
+// restoring a backup into an empty DB restores the keyspaces. Keyspaces are removed by
+// DB.DropAll, after which the existing handles must not be used.
+type Keyspace struct {
+	db     *DB
+	id     uint32
+	name   string
+	prefix []byte
+}
+
+// CreateKeyspace creates the keyspace with the given name, and returns a handle to it. If the
+// keyspace already exists, its options are replaced by the given ones. The options are persisted,
+// and used for the keyspace from then on, including after the DB is reopened.
+func (db *DB) CreateKeyspace(name string, opt KeyspaceOptions) (*Keyspace, error) {
+	switch {
+	case len(name) == 0:
+		return nil, errors.New("Keyspace name cannot be empty")
+	case db.opt.ReadOnly:
+		return nil, errors.New("Cannot create a keyspace in read-only mode")
+	}
+	if err := opt.validate(db.opt); err != nil {
+		return nil, y.Wrapf(err, "invalid options for keyspace %q", name)
+	}
+
+	db.keyspaces.createLock.Lock()
+	defer db.keyspaces.createLock.Unlock()
+	def := &keyspaceDef{Name: name, Options: opt}
+	if old := db.keyspaces.getByName(name); old != nil {
+		def.ID = old.ID
+	} else {
+		db.keyspaces.RLock()
+		def.ID = db.keyspaces.maxID + 1
+		db.keyspaces.RUnlock()
+	}
+	if err := db.writeKeyspaceDef(def); err != nil {
+		return nil, y.Wrapf(err, "while writing definition of keyspace %q", name)
+	}
+	db.keyspaces.add(def)
+	return db.newKeyspace(def), nil
+}
+
+// Keyspace returns a handle to the existing keyspace with the given name.
+func (db *DB) Keyspace(name string) (*Keyspace, error) {
+	def := db.keyspaces.getByName(name)
+	if def == nil {
+		return nil, ErrKeyspaceNotFound
+	}
+	return db.newKeyspace(def), nil
+}
+
+func (db *DB) newKeyspace(def *keyspaceDef) *Keyspace {
+	return &Keyspace{db: db, id: def.ID, name: def.Name, prefix: keyspaceKeyPrefix(def.ID)}
+}
+
+// Name returns the name of the keyspace.
+func (ks *Keyspace) Name() string {
+	return ks.name
+}
+
+// Options returns the current options of the keyspace.
+func (ks *Keyspace) Options() KeyspaceOptions {
+	if def := ks.db.keyspaces.get(ks.id); def != nil {
+		return def.Options
+	}
+	return ks.db.DefaultKeyspaceOptions()
+}
+
+func (ks *Keyspace) key(key []byte) []byte {
+	out := make([]byte, 0, len(ks.prefix)+len(key))
+	out = append(out, ks.prefix...)
+	return append(out, key...)
+}
+
+func (ks *Keyspace) check(txn *Txn, key []byte) error {
+	if txn.db != ks.db {
+		return errors.Errorf("Transaction doesn't belong to the DB of keyspace %q", ks.name)
+	}
+	if len(key) == 0 {
+		return ErrEmptyKey
+	}
+	return nil
+}
+
+// Set adds a key-value pair to the keyspace, within the given transaction. See Txn.Set.
+func (ks *Keyspace) Set(txn *Txn, key, val []byte) error {
+	return ks.SetEntry(txn, NewEntry(key, val))
+}
+
+// SetEntry adds the entry to the keyspace, within the given transaction. If the entry doesn't have
+// an expiry time, it gets the default TTL of the keyspace. See Txn.SetEntry.
+func (ks *Keyspace) SetEntry(txn *Txn, e *Entry) error {
+	if err := ks.check(txn, e.Key); err != nil {
+		return err
+	}
+	ne := *e
+	ne.Key = ks.key(e.Key)
+	if ttl := ks.Options().DefaultTTL; ne.ExpiresAt == 0 && ttl > 0 {
+		ne.WithTTL(ttl)
+	}
+	return txn.modifyInternal(&ne)
+}
+
+// Delete deletes a key from the keyspace, within the given transaction. See Txn.Delete.
+func (ks *Keyspace) Delete(txn *Txn, key []byte) error {
+	if err := ks.check(txn, key); err != nil {
+		return err
+	}
+	return txn.modifyInternal(&Entry{Key: ks.key(key), meta: bitDelete})
+}
+
+// Get looks for the key in the keyspace, within the given transaction. See Txn.Get.
+func (ks *Keyspace) Get(txn *Txn, key []byte) (*Item, error) {
+	if err := ks.check(txn, key); err != nil {
+		return nil, err
+	}
+	item, err := txn.Get(ks.key(key))
+	if err != nil {
+		return nil, err
+	}
+	item.prefixLen = len(ks.prefix)
+	return item, nil
+}
+
+// NewIterator returns an iterator over the keys in the keyspace, within the given transaction.
+// The Prefix, LowerBound and UpperBound options, and the keys passed to Seek and ValidForPrefix,
+// are relative to the keyspace. See Txn.NewIterator.
+func (ks *Keyspace) NewIterator(txn *Txn, opt IteratorOptions) *Iterator {
+	if txn.db != ks.db {
+		panic("Transaction doesn't belong to the DB of the keyspace")
+	}
+	opt.Prefix = ks.key(opt.Prefix)
+	if len(opt.LowerBound) > 0 {
+		opt.LowerBound = ks.key(opt.LowerBound)
+	}
+	if len(opt.UpperBound) > 0 {
+		opt.UpperBound = ks.key(opt.UpperBound)
+	} else {
+		// Let reverse iteration start from the end of the keyspace.
+		opt.UpperBound = keyspaceKeyPrefix(ks.id + 1)
+	}
+	opt.InternalAccess = true
+	it := txn.NewIterator(opt)
+	it.keyPrefix = ks.prefix
+	return it
+}
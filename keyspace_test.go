@@ -0,0 +1,280 @@
+/*
+ * Copyright 2020 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package badger
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/badger/v2/options"
+	"github.com/stretchr/testify/require"
+)
+
+func keyspaceKeys(t *testing.T, ks *Keyspace, txn *Txn, opt IteratorOptions) []string {
+	it := ks.NewIterator(txn, opt)
+	defer it.Close()
+	var keys []string
+	for it.Rewind(); it.Valid(); it.Next() {
+		keys = append(keys, string(it.Item().Key()))
+	}
+	return keys
+}
+
+func TestKeyspace(t *testing.T) {
+	dir, err := ioutil.TempDir("", "badger-test")
+	require.NoError(t, err)
+	defer removeDir(dir)
+
+	opt := getTestOptions(dir)
+	db, err := Open(opt)
+	require.NoError(t, err)
+
+	meta, err := db.CreateKeyspace("meta", db.DefaultKeyspaceOptions())
+	require.NoError(t, err)
+	blobs, err := db.CreateKeyspace("blobs", db.DefaultKeyspaceOptions())
+	require.NoError(t, err)
+	_, err = db.CreateKeyspace("", db.DefaultKeyspaceOptions())
+	require.Error(t, err)
+	_, err = db.CreateKeyspace("bad", db.DefaultKeyspaceOptions().WithNumVersionsToKeep(0))
+	require.Error(t, err)
+
+	// The same key is written to the default keyspace and to both the keyspaces, atomically.
+	require.NoError(t, db.Update(func(txn *Txn) error {
+		for i := 0; i < 10; i++ {
+			require.NoError(t, txn.Set(rangeKey(i), []byte("default")))
+			require.NoError(t, meta.Set(txn, rangeKey(i), []byte("meta")))
+			require.NoError(t, blobs.Set(txn, rangeKey(i), []byte("blobs")))
+		}
+		require.NoError(t, meta.Delete(txn, rangeKey(0)))
+		require.Equal(t, ErrEmptyKey, meta.Set(txn, nil, []byte("meta")))
+		return nil
+	}))
+	require.NoError(t, db.Update(func(txn *Txn) error {
+		return blobs.Delete(txn, rangeKey(9))
+	}))
+
+	check := func(db *DB, meta, blobs *Keyspace) {
+		require.NoError(t, db.View(func(txn *Txn) error {
+			require.Equal(t, 10, countKeys(t, txn, false))
+			for _, ks := range []*Keyspace{meta, blobs} {
+				item, err := ks.Get(txn, rangeKey(5))
+				require.NoError(t, err)
+				require.Equal(t, rangeKey(5), item.Key())
+				require.Equal(t, []byte(ks.Name()), getItemValue(t, item))
+			}
+			_, err := meta.Get(txn, rangeKey(0))
+			require.Equal(t, ErrKeyNotFound, err)
+
+			keys := keyspaceKeys(t, meta, txn, DefaultIteratorOptions)
+			require.Len(t, keys, 9)
+			require.Equal(t, string(rangeKey(1)), keys[0])
+
+			iopt := DefaultIteratorOptions
+			iopt.Reverse = true
+			keys = keyspaceKeys(t, blobs, txn, iopt)
+			require.Len(t, keys, 9)
+			require.Equal(t, string(rangeKey(8)), keys[0])
+
+			iopt.UpperBound = rangeKey(5)
+			require.Equal(t, []string{"key004", "key003", "key002", "key001", "key000"},
+				keyspaceKeys(t, blobs, txn, iopt))
+
+			it := meta.NewIterator(txn, DefaultIteratorOptions)
+			defer it.Close()
+			it.Seek(rangeKey(7))
+			require.True(t, it.ValidForPrefix([]byte("key")))
+			require.Equal(t, rangeKey(7), it.Item().KeyCopy(nil))
+			return nil
+		}))
+	}
+	check(db, meta, blobs)
+
+	// The keyspaces survive a restart.
+	require.NoError(t, db.Close())
+	db, err = Open(opt)
+	require.NoError(t, err)
+	defer func() { require.NoError(t, db.Close()) }()
+	_, err = db.Keyspace("missing")
+	require.Equal(t, ErrKeyspaceNotFound, err)
+	meta, err = db.Keyspace("meta")
+	require.NoError(t, err)
+	blobs, err = db.Keyspace("blobs")
+	require.NoError(t, err)
+	check(db, meta, blobs)
+
+	// Recreating a keyspace keeps its keys, and replaces its options.
+	meta, err = db.CreateKeyspace("meta", db.DefaultKeyspaceOptions().WithNumVersionsToKeep(3))
+	require.NoError(t, err)
+	require.Equal(t, 3, meta.Options().NumVersionsToKeep)
+	check(db, meta, blobs)
+}
+
+func TestKeyspaceConflict(t *testing.T) {
+	runBadgerTest(t, nil, func(t *testing.T, db *DB) {
+		ks, err := db.CreateKeyspace("ks", db.DefaultKeyspaceOptions())
+		require.NoError(t, err)
+
+		txn1 := db.NewTransaction(true)
+		defer txn1.Discard()
+		txn2 := db.NewTransaction(true)
+		defer txn2.Discard()
+		_, err = ks.Get(txn1, []byte("key"))
+		require.Equal(t, ErrKeyNotFound, err)
+		require.NoError(t, txn1.Set([]byte("other"), []byte("value")))
+
+		require.NoError(t, ks.Set(txn2, []byte("key"), []byte("value")))
+		require.NoError(t, txn2.Commit())
+		require.Equal(t, ErrConflict, txn1.Commit())
+	})
+}
+
+func TestKeyspaceOptions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "badger-test")
+	require.NoError(t, err)
+	defer removeDir(dir)
+
+	opt := getTestOptions(dir).WithValueThreshold(32).WithCompression(options.ZSTD)
+	db, err := Open(opt)
+	require.NoError(t, err)
+	defer func() { require.NoError(t, db.Close()) }()
+
+	hot, err := db.CreateKeyspace("hot", db.DefaultKeyspaceOptions().
+		WithNumVersionsToKeep(3).
+		WithValueThreshold(1<<10).
+		WithCompression(options.None).
+		WithDefaultTTL(time.Hour))
+	require.NoError(t, err)
+
+	value := bytes.Repeat([]byte("a"), 100)
+	for v := 0; v < 5; v++ {
+		for i := 0; i < 40; i++ {
+			require.NoError(t, db.Update(func(txn *Txn) error {
+				require.NoError(t, txn.Set(rangeKey(i), value))
+				return hot.Set(txn, rangeKey(i), []byte(fmt.Sprintf("%s%d", value, v)))
+			}))
+		}
+	}
+
+	require.NoError(t, db.View(func(txn *Txn) error {
+		// The values of the keyspace are kept in the LSM tree.
+		item, err := hot.Get(txn, rangeKey(1))
+		require.NoError(t, err)
+		require.Zero(t, item.meta&bitValuePointer)
+		require.NotZero(t, item.ExpiresAt())
+		item, err = txn.Get(rangeKey(1))
+		require.NoError(t, err)
+		require.NotZero(t, item.meta&bitValuePointer)
+		require.Zero(t, item.ExpiresAt())
+		return nil
+	}))
+
+	// Move the keys from level 0 to level 1, via a compaction.
+	require.NoError(t, db.Close())
+	db, err = Open(opt)
+	require.NoError(t, err)
+	cdef := compactDef{
+		thisLevel: db.lc.levels[0],
+		nextLevel: db.lc.levels[1],
+		top:       db.lc.levels[0].tables,
+		bot:       db.lc.levels[1].tables,
+		t:         db.lc.levelTargets(),
+	}
+	cdef.t.baseLevel = 1
+	require.NotEmpty(t, cdef.top)
+	require.NoError(t, db.lc.runCompactDef(-1, 0, cdef))
+
+	var numHotTables int
+	for _, l := range db.lc.levels[1:] {
+		l.RLock()
+		for _, tbl := range l.tables {
+			id := keyspaceID(tbl.Smallest())
+			require.Equal(t, id, keyspaceID(tbl.Biggest()), "table spans keyspaces")
+			if id == 0 {
+				require.Equal(t, options.ZSTD, tbl.CompressionType())
+				continue
+			}
+			numHotTables++
+			require.Equal(t, options.None, tbl.CompressionType())
+		}
+		l.RUnlock()
+	}
+	require.NotZero(t, numHotTables)
+
+	hot, err = db.Keyspace("hot")
+	require.NoError(t, err)
+	require.NoError(t, db.View(func(txn *Txn) error {
+		iopt := DefaultIteratorOptions
+		iopt.AllVersions = true
+		require.Len(t, keyspaceKeys(t, hot, txn, iopt), 120)
+		it := txn.NewIterator(iopt)
+		defer it.Close()
+		var count int
+		for it.Rewind(); it.Valid(); it.Next() {
+			count++
+		}
+		require.Equal(t, 40, count)
+		return nil
+	}))
+}
+
+func TestKeyspaceBackup(t *testing.T) {
+	var buf bytes.Buffer
+	var ksOpt KeyspaceOptions
+	runBadgerTest(t, nil, func(t *testing.T, db *DB) {
+		ksOpt = db.DefaultKeyspaceOptions().WithNumVersionsToKeep(3).WithValueThreshold(32)
+		ks, err := db.CreateKeyspace("ks", ksOpt)
+		require.NoError(t, err)
+		require.NoError(t, db.Update(func(txn *Txn) error {
+			for i := 0; i < 10; i++ {
+				require.NoError(t, txn.Set(rangeKey(i), []byte("default")))
+				require.NoError(t, ks.Set(txn, rangeKey(i), []byte(fmt.Sprintf("%064d", i))))
+			}
+			return nil
+		}))
+		require.NoError(t, db.Update(func(txn *Txn) error {
+			return ks.Delete(txn, rangeKey(0))
+		}))
+		_, err = db.Backup(&buf, 0)
+		require.NoError(t, err)
+	})
+
+	runBadgerTest(t, nil, func(t *testing.T, db *DB) {
+		require.NoError(t, db.Load(&buf, 16))
+		ks, err := db.Keyspace("ks")
+		require.NoError(t, err)
+		require.Equal(t, ksOpt, ks.Options())
+
+		require.NoError(t, db.View(func(txn *Txn) error {
+			require.Equal(t, 10, countKeys(t, txn, false))
+			_, err := ks.Get(txn, rangeKey(0))
+			require.Equal(t, ErrKeyNotFound, err)
+			for i := 1; i < 10; i++ {
+				item, err := ks.Get(txn, rangeKey(i))
+				require.NoError(t, err)
+				require.Equal(t, []byte(fmt.Sprintf("%064d", i)), getItemValue(t, item))
+			}
+			return nil
+		}))
+		// New keyspaces must not reuse the ID of the restored one.
+		other, err := db.CreateKeyspace("other", db.DefaultKeyspaceOptions())
+		require.NoError(t, err)
+		require.NotEqual(t, ks.id, other.id)
+	})
+}
@@ -260,6 +260,7 @@ func (s *levelHandler) get(key []byte) (y.ValueStruct, error) {
 	for _, th := range tables {
 		if th.DoesNotHave(hash) {
 			y.NumLSMBloomHits.Add(s.strLevel, 1)
+			s.db.metrics.LSMBloomHits[s.level].Add(1)
 			continue
 		}
 
@@ -267,6 +268,7 @@ func (s *levelHandler) get(key []byte) (y.ValueStruct, error) {
 		defer it.Close()
 
 		y.NumLSMGets.Add(s.strLevel, 1)
+		s.db.metrics.LSMGets[s.level].Add(1)
 		it.Seek(key)
 		if !it.Valid() {
 			continue
@@ -301,14 +303,22 @@ func (s *levelHandler) appendIterators(iters []y.Iterator, opt *IteratorOptions)
 				out = append(out, t)
 			}
 		}
-		return appendIteratorsReversed(iters, out, topt)
+		for i := len(out) - 1; i >= 0; i-- {
+			// This will increment the reference of the table handler.
+			it := out[i].NewIterator(topt)
+			it.SetBounds(opt.LowerBound, opt.UpperBound)
+			iters = append(iters, it)
+		}
+		return iters
 	}
 
 	tables := opt.pickTables(s.tables)
 	if len(tables) == 0 {
 		return iters
 	}
-	return append(iters, table.NewConcatIterator(tables, topt))
+	it := table.NewConcatIterator(tables, topt)
+	it.SetBounds(opt.LowerBound, opt.UpperBound)
+	return append(iters, it)
 }
 
 type levelHandlerRLocked struct{}
@@ -353,6 +353,10 @@ func (s *levelsController) startCompact(lc *z.Closer) {
 	for i := 0; i < n; i++ {
 		go s.runCompactor(i, lc)
 	}
+	if n > 0 && s.kv.opt.TTLCompactionInterval > 0 {
+		lc.AddRunning(1)
+		go s.runTTLCompactor(lc)
+	}
 }
 
 type targets struct {
@@ -499,6 +503,8 @@ type compactionPriority struct {
 	adjusted     float64
 	dropPrefixes [][]byte
 	t            targets
+	// ttl is set for the TTL compactions, which pick the tables holding mostly expired keys.
+	ttl bool
 }
 
 func (s *levelsController) lastLevel() *levelHandler {
@@ -644,12 +650,46 @@ func (s *levelsController) subcompact(it y.Iterator, kr keyRange, cd compactDef,
 
 	var lastKey, skipKey []byte
 	var numBuilds, numVersions int
+	hasRangeDels := !s.kv.rangeDels.empty()
+
+	// Every table holds the keys of a single keyspace, so that it can be built using the options
+	// of the keyspace. These are set for every table.
+	var tableKsID uint32
+	var numVersionsToKeep int
 
 	addKeys := func(builder *table.Builder) {
 		timeStart := time.Now()
 		var numKeys, numSkips uint64
 		var rangeCheck int
 		var tableKr keyRange
+
+		// merge collapses the chain of merge operands of a key below discardTs. The operands are
+		// folded from the newest to the oldest, which relies on the merge function being
+		// associative. The result is written with the version of the newest operand.
+		var merge struct {
+			f   MergeFunc
+			key []byte
+			vs  y.ValueStruct
+		}
+		// flushMerge adds the folded operands to the table. If complete is set, the operands have
+		// been folded into the value of the key, or no older value exists in the lower levels, so
+		// the result is a plain value.
+		flushMerge := func(complete bool) {
+			if merge.f == nil {
+				return
+			}
+			vs := merge.vs
+			if complete {
+				vs.Meta &^= bitMergeEntry
+				numVersions++
+				if vs.Meta&bitDiscardEarlierVersions > 0 || numVersions == numVersionsToKeep {
+					skipKey = y.SafeCopy(skipKey, merge.key)
+				}
+			}
+			numKeys++
+			builder.Add(merge.key, vs, 0)
+			merge.f = nil
+		}
 		for ; it.Valid(); it.Next() {
 			// See if we need to skip the prefix.
 			if len(cd.dropPrefixes) > 0 && hasAnyPrefixes(it.Key(), cd.dropPrefixes) {
@@ -661,6 +701,11 @@ func (s *levelsController) subcompact(it y.Iterator, kr keyRange, cd compactDef,
 			// See if we need to skip this key.
 			if len(skipKey) > 0 {
 				if y.SameKey(it.Key(), skipKey) {
+					if it.Value().Meta&bitRangeDelete > 0 {
+						// A newer tombstone over the same range is kept, and hides everything
+						// this one does.
+						s.dropRangeTombstone(it.Key())
+					}
 					numSkips++
 					updateStats(it.Value())
 					continue
@@ -670,9 +715,14 @@ func (s *levelsController) subcompact(it y.Iterator, kr keyRange, cd compactDef,
 			}
 
 			if !y.SameKey(it.Key(), lastKey) {
+				flushMerge(!hasOverlap)
 				if len(kr.right) > 0 && y.CompareKeys(it.Key(), kr.right) >= 0 {
 					break
 				}
+				if keyspaceID(it.Key()) != tableKsID {
+					// Start a new table for the next keyspace.
+					break
+				}
 				if builder.ReachedCapacity() {
 					// Only break if we are on a different key, and have reached capacity. We want
 					// to ensure that all versions of the key are stored in the same sstable, and
@@ -703,6 +753,88 @@ func (s *levelsController) subcompact(it y.Iterator, kr keyRange, cd compactDef,
 
 			vs := it.Value()
 			version := y.ParseTs(it.Key())
+			// Drop a range tombstone visible to all the running transactions, once it has reached
+			// the last level and no older version is left in its range.
+			if vs.Meta&bitRangeDelete > 0 && version <= discardTs &&
+				cd.nextLevel.level == len(s.levels)-1 && s.rangeTombstoneObsolete(it.Key()) {
+				s.dropRangeTombstone(it.Key())
+				skipKey = y.SafeCopy(skipKey, it.Key())
+				numSkips++
+				updateStats(vs)
+				continue
+			}
+			// Drop the versions deleted by a range tombstone which is visible to all the running
+			// transactions. The tombstone itself is retained until the step above drops it, so
+			// older versions of the key lying in the lower levels stay hidden.
+			if hasRangeDels &&
+				s.kv.rangeDels.deletedBy(y.ParseKey(it.Key()), version, discardTs) > 0 {
+				numSkips++
+				updateStats(vs)
+				continue
+			}
+			// Only run the filter on the versions which no running transaction can read
+			// anymore. Merge operands are left for the merge operator.
+			if s.kv.opt.CompactionFilter != nil && version <= discardTs &&
+				vs.Meta&bitMergeEntry == 0 {
+				var err error
+				vs, err = s.applyCompactionFilter(cd.nextLevel.level, it.Key(), vs, updateStats)
+				if err != nil {
+					// Keep the key as it is, if the filter could not be run.
+					s.kv.opt.Errorf("Unable to run compaction filter on key %q: %v", it.Key(), err)
+				}
+			}
+			if merge.f != nil {
+				// Fold this version into the chain of merge operands of the key.
+				isExpired := isDeletedOrExpired(vs.Meta, vs.ExpiresAt)
+				var val []byte
+				var err error
+				if !isExpired {
+					if val, err = s.kv.valueCopy(vs); err != nil {
+						s.kv.opt.Errorf("Unable to read merge operand of key %q: %v", it.Key(), err)
+					}
+				}
+				switch {
+				case err != nil:
+					flushMerge(false)
+				case isExpired:
+					flushMerge(true)
+				case vs.Meta&bitMergeEntry > 0:
+					merge.vs.Value = merge.f(val, merge.vs.Value)
+					numSkips++
+					updateStats(vs)
+					continue
+				default:
+					merge.vs.Value = merge.f(val, merge.vs.Value)
+					merge.vs.Meta |= vs.Meta & bitDiscardEarlierVersions
+					merge.vs.UserMeta, merge.vs.ExpiresAt = vs.UserMeta, vs.ExpiresAt
+					numSkips++
+					updateStats(vs)
+					flushMerge(true)
+					continue
+				}
+				if len(skipKey) > 0 {
+					numSkips++
+					updateStats(vs)
+					continue
+				}
+			}
+			if vs.Meta&bitMergeEntry > 0 && version <= discardTs &&
+				!isDeletedOrExpired(vs.Meta, vs.ExpiresAt) {
+				if f := s.kv.opt.mergeFunc(y.ParseKey(it.Key())); f != nil {
+					if val, err := s.kv.valueCopy(vs); err != nil {
+						s.kv.opt.Errorf("Unable to read merge operand of key %q: %v", it.Key(), err)
+					} else {
+						// Start a chain of merge operands. The value is always kept in the LSM
+						// tree.
+						merge.f, merge.key = f, y.SafeCopy(merge.key, it.Key())
+						merge.vs = vs
+						merge.vs.Meta &^= bitValuePointer
+						merge.vs.Value = val
+						updateStats(vs)
+						continue
+					}
+				}
+			}
 			// Do not discard entries inserted by merge operator. These entries will be
 			// discarded once they're merged
 			if version <= discardTs && vs.Meta&bitMergeEntry == 0 {
@@ -716,7 +848,7 @@ func (s *levelsController) subcompact(it y.Iterator, kr keyRange, cd compactDef,
 				// - We've already processed `NumVersionsToKeep` number of versions
 				// (including the current item being processed)
 				lastValidVersion := vs.Meta&bitDiscardEarlierVersions > 0 ||
-					numVersions == s.kv.opt.NumVersionsToKeep
+					numVersions == numVersionsToKeep
 
 				isExpired := isDeletedOrExpired(vs.Meta, vs.ExpiresAt)
 
@@ -737,6 +869,9 @@ func (s *levelsController) subcompact(it y.Iterator, kr keyRange, cd compactDef,
 						// so the following key versions would be skipped.
 					default:
 						// If no overlap, we can skip all the versions, by continuing here.
+						if isExpired && s.kv.expired != nil {
+							s.queueExpired(it.Key(), vs, bytes.Equal(it.Key(), lastKey))
+						}
 						numSkips++
 						updateStats(vs)
 						continue // Skip adding this key.
@@ -750,6 +885,7 @@ func (s *levelsController) subcompact(it y.Iterator, kr keyRange, cd compactDef,
 			}
 			builder.Add(it.Key(), vs, vp.Len)
 		}
+		flushMerge(!hasOverlap)
 		s.kv.opt.Debugf("LOG Compact. Added %d keys. Skipped %d keys. Iteration took: %v",
 			numKeys, numSkips, time.Since(timeStart).Round(time.Millisecond))
 	} // End of function: addKeys
@@ -765,6 +901,11 @@ func (s *levelsController) subcompact(it y.Iterator, kr keyRange, cd compactDef,
 		}
 
 		bopts := buildTableOptions(s.kv)
+		tableKsID, numVersionsToKeep = keyspaceID(it.Key()), s.kv.opt.NumVersionsToKeep
+		if def := s.kv.keyspaces.get(tableKsID); def != nil {
+			bopts = def.Options.tableOptions(bopts)
+			numVersionsToKeep = def.Options.NumVersionsToKeep
+		}
 		// Set TableSize to the target file size for that level.
 		bopts.TableSize = uint64(cd.t.fileSz[cd.nextLevel.level])
 		builder := table.NewTableBuilder(bopts)
@@ -1214,6 +1355,9 @@ func (s *levelsController) fillTables(cd *compactDef) bool {
 	// We pick tables, so we compact older tables first. This is similar to
 	// kOldestLargestSeqFirst in RocksDB.
 	s.sortByHeuristic(tables, cd)
+	if cd.p.ttl {
+		tables = ttlTables(tables, uint64(time.Now().Unix()), s.kv.orc.discardAtOrBelow())
+	}
 
 	for _, t := range tables {
 		cd.thisSize = t.Size()
@@ -1266,6 +1410,8 @@ func (s *levelsController) runCompactDef(id, l int, cd compactDef) (err error) {
 		return errors.New("Filesizes cannot be zero. Targets are not set")
 	}
 	timeStart := time.Now()
+	s.kv.metrics.CompactionsRunning.Add(1)
+	defer s.kv.metrics.CompactionsRunning.Add(-1)
 
 	thisLevel := cd.thisLevel
 	nextLevel := cd.nextLevel
@@ -1315,7 +1461,9 @@ func (s *levelsController) runCompactDef(id, l int, cd compactDef) (err error) {
 	from := append(tablesToString(cd.top), tablesToString(cd.bot)...)
 	to := tablesToString(newTables)
 
-	if dur := time.Since(timeStart); dur > 2*time.Second {
+	dur := time.Since(timeStart)
+	s.kv.metrics.CompactionDuration[thisLevel.level].Observe(dur)
+	if dur > 2*time.Second {
 		var expensive string
 		if dur > time.Second {
 			expensive = " [E]"
@@ -1345,6 +1493,76 @@ func tablesToString(tables []*table.Table) []string {
 	return res
 }
 
+// staleTables returns the tables of level l encrypted with a data key older than keyID.
+func (s *levelsController) staleTables(l int, keyID uint64) []*table.Table {
+	lh := s.levels[l]
+	lh.RLock()
+	defer lh.RUnlock()
+
+	var out []*table.Table
+	for _, t := range lh.tables {
+		if t.KeyID() < keyID {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// rewriteTable rewrites the table t of level l in place, so that the new tables are encrypted
+// with the latest data key. Unlike a compaction, it works on the last level too. It returns
+// errFillTables if t is being compacted, and does nothing if t isn't in level l anymore.
+func (s *levelsController) rewriteTable(l int, t *table.Table) (err error) {
+	// Level 0 tables must stay sorted by age, so they need to be compacted to the base level.
+	y.AssertTrue(l > 0)
+	lh := s.levels[l]
+	kr := getKeyRange(t)
+
+	lh.RLock()
+	var found bool
+	for _, lt := range lh.tables {
+		if lt == t {
+			found = true
+			break
+		}
+	}
+	if found && !s.cstatus.addRewrite(levelHandlerRLocked{}, l, t, kr) {
+		lh.RUnlock()
+		return errFillTables
+	}
+	lh.RUnlock()
+	if !found {
+		return nil
+	}
+	defer s.cstatus.deleteRewrite(l, t, kr)
+
+	cd := compactDef{
+		compactorId: -1,
+		t:           s.levelTargets(),
+		thisLevel:   lh,
+		nextLevel:   lh,
+		top:         []*table.Table{t},
+		thisRange:   kr,
+		nextRange:   kr,
+		splits:      []keyRange{{}},
+	}
+	newTables, decr, err := s.compactBuildTables(l, cd)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		// Only assign to err, if it's not already nil.
+		if decErr := decr(); err == nil {
+			err = decErr
+		}
+	}()
+	changeSet := buildChangeSet(&cd, newTables)
+	if err := s.kv.manifest.addChanges(changeSet.Changes); err != nil {
+		return err
+	}
+	// The new tables replace t atomically, so the level never holds overlapping tables.
+	return lh.replaceTables(cd.top, newTables)
+}
+
 var errFillTables = errors.New("Unable to fill tables")
 
 // doCompact picks some table on level l and compacts it away to the next level.
@@ -1463,6 +1681,12 @@ func (s *levelsController) get(key []byte, maxVs y.ValueStruct, startLevel int)
 			maxVs = vs
 		}
 	}
+	// The latest version of the key might have been deleted by a range tombstone.
+	if maxVs.Version > 0 {
+		if delTs := s.kv.rangeDels.deletedBy(y.ParseKey(key), maxVs.Version, version); delTs > 0 {
+			return y.ValueStruct{Meta: bitDelete, Version: delTs}, nil
+		}
+	}
 	return maxVs, nil
 }
 
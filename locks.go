@@ -0,0 +1,239 @@
+/*
+ * Copyright 2020 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package badger
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/dgraph-io/badger/v2/options"
+	"github.com/dgraph-io/badger/v2/y"
+	"github.com/dgraph-io/ristretto/z"
+)
+
+// lockManager holds the key locks taken by Txn.GetForUpdate. The keys are locked by their
+// fingerprints, so the keys sharing a fingerprint share their lock.
+type lockManager struct {
+	policy  options.LockPolicy
+	timeout time.Duration
+
+	sync.Mutex
+	locks  map[uint64]*keyLock
+	nextID uint64
+}
+
+type keyLock struct {
+	owner *txnLocks
+	// released is closed when the lock is released.
+	released chan struct{}
+}
+
+// txnLocks holds the locks of a transaction.
+type txnLocks struct {
+	// id orders the transactions by age, for WaitDie and WoundWait. Lower is older.
+	id   uint64
+	keys []uint64
+	// waitingFor is the lock the transaction waits for, if any. It's protected by the
+	// lockManager.
+	waitingFor *keyLock
+	// wounded is set, and woundCh is closed, when the transaction is aborted by an older one.
+	wounded int32
+	woundCh chan struct{}
+}
+
+// lockedRead is a read done by GetForUpdate at ts, after locking the key with fingerprint fp.
+type lockedRead struct {
+	fp uint64
+	ts uint64
+}
+
+func newLockManager(opt Options) *lockManager {
+	return &lockManager{
+		policy:  opt.LockPolicy,
+		timeout: opt.LockTimeout,
+		locks:   make(map[uint64]*keyLock),
+	}
+}
+
+// newTxnLocks returns the locks of a new transaction, which is younger than all the previous
+// ones.
+func (lm *lockManager) newTxnLocks() *txnLocks {
+	lm.Lock()
+	defer lm.Unlock()
+	lm.nextID++
+	return &txnLocks{id: lm.nextID, woundCh: make(chan struct{})}
+}
+
+func (tl *txnLocks) isWounded() bool {
+	return atomic.LoadInt32(&tl.wounded) == 1
+}
+
+// wound aborts the transaction. It must be called by the lockManager, with its lock held.
+func (tl *txnLocks) wound() {
+	if atomic.CompareAndSwapInt32(&tl.wounded, 0, 1) {
+		close(tl.woundCh)
+	}
+}
+
+// lock acquires the lock of the key with fingerprint fp for the transaction, waiting for it to be
+// released by the transaction holding it, as allowed by the policy.
+func (lm *lockManager) lock(tl *txnLocks, fp uint64) error {
+	var timeout <-chan time.Time
+	if lm.timeout > 0 {
+		timer := time.NewTimer(lm.timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	for {
+		kl, err := lm.tryLock(tl, fp)
+		if kl == nil {
+			return err
+		}
+		select {
+		case <-kl.released:
+		case <-tl.woundCh:
+		case <-timeout:
+			lm.Lock()
+			tl.waitingFor = nil
+			lm.Unlock()
+			return ErrLockTimeout
+		}
+	}
+}
+
+// tryLock acquires the lock of the key for the transaction. It returns the lock to wait for, if
+// it's held by another transaction and the policy allows waiting.
+func (lm *lockManager) tryLock(tl *txnLocks, fp uint64) (*keyLock, error) {
+	lm.Lock()
+	defer lm.Unlock()
+	tl.waitingFor = nil
+	if tl.isWounded() {
+		return nil, ErrConflict
+	}
+	kl, ok := lm.locks[fp]
+	switch {
+	case !ok:
+		lm.locks[fp] = &keyLock{owner: tl, released: make(chan struct{})}
+		tl.keys = append(tl.keys, fp)
+		return nil, nil
+	case kl.owner == tl:
+		return nil, nil
+	}
+	switch lm.policy {
+	case options.WaitDie:
+		if tl.id > kl.owner.id {
+			return nil, ErrConflict
+		}
+	case options.WoundWait:
+		if tl.id < kl.owner.id {
+			// The younger transaction releases the lock once it's discarded.
+			kl.owner.wound()
+		}
+	default:
+		// The transactions never wait in a cycle, so following the waits from the owner either
+		// ends, or comes back to this transaction.
+		for owner := kl.owner; owner.waitingFor != nil; {
+			owner = owner.waitingFor.owner
+			if owner == tl {
+				return nil, ErrDeadlock
+			}
+		}
+	}
+	tl.waitingFor = kl
+	return kl, nil
+}
+
+// release releases the locks of the transaction.
+func (lm *lockManager) release(tl *txnLocks) {
+	lm.Lock()
+	defer lm.Unlock()
+	for _, fp := range tl.keys {
+		if kl := lm.locks[fp]; kl != nil && kl.owner == tl {
+			delete(lm.locks, fp)
+			close(kl.released)
+		}
+	}
+	tl.keys = nil
+}
+
+// GetForUpdate locks key, and returns its latest committed value, or the value written by this
+// transaction. The lock is held until the transaction is committed or discarded. While the lock
+// is held, the other transactions calling GetForUpdate for the key wait for it, so that hot keys
+// can be updated without the conflicts of the optimistic transactions. A transaction writing the
+// key without locking it still conflicts with this transaction, which is checked at commit.
+//
+// GetForUpdate reads the latest version of the key, which can be newer than the versions read by
+// Get. The keys locked by GetForUpdate should be read via GetForUpdate again, which doesn't wait
+// for the locks already held.
+//
+// ErrLockTimeout is returned if the lock isn't acquired within Options.LockTimeout. Depending on
+// Options.LockPolicy, ErrDeadlock or ErrConflict are returned instead of waiting, and ErrConflict
+// is returned if the transaction is aborted by an older one. The transaction should then be
+// discarded and retried, to release its locks.
+func (txn *Txn) GetForUpdate(key []byte) (*Item, error) {
+	switch {
+	case !txn.update:
+		return nil, ErrReadOnlyTxn
+	case txn.discarded:
+		return nil, ErrDiscardedTxn
+	case len(key) == 0:
+		return nil, ErrEmptyKey
+	case txn.db.opt.managedTxns:
+		return nil, ErrManagedTxn
+	}
+	if txn.locks == nil {
+		txn.locks = txn.db.locks.newTxnLocks()
+	}
+	fp := z.MemHash(key)
+	if err := txn.db.locks.lock(txn.locks, fp); err != nil {
+		return nil, err
+	}
+
+	if e, has := txn.pendingWrites[string(key)]; has && bytes.Equal(key, e.Key) {
+		if isDeletedOrExpired(e.meta, e.ExpiresAt) {
+			return nil, ErrKeyNotFound
+		}
+		return txn.pendingItem(key, e), nil
+	}
+	if txn.pendingRangeDeleted(key) {
+		return nil, ErrKeyNotFound
+	}
+	// The versions newer than the read timestamp of the transaction are kept by compactions, as
+	// long as the transaction is open.
+	readTs := txn.db.orc.latestTs()
+	txn.lockedReads = append(txn.lockedReads, lockedRead{fp: fp, ts: readTs})
+	vs, err := txn.db.get(y.KeyWithTs(key, readTs))
+	if err != nil {
+		return nil, y.Wrapf(err, "DB::GetForUpdate key: %q", key)
+	}
+	if (vs.Value == nil && vs.Meta == 0) || isDeletedOrExpired(vs.Meta, vs.ExpiresAt) {
+		return nil, ErrKeyNotFound
+	}
+	return txn.newItem(key, vs), nil
+}
+
+// latestTs returns the timestamp of the latest commit, once it's visible to the reads.
+func (o *oracle) latestTs() uint64 {
+	o.Lock()
+	ts := o.nextTxnTs - 1
+	o.Unlock()
+	y.Check(o.txnMark.WaitForMark(context.Background(), ts))
+	return ts
+}
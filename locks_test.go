@@ -0,0 +1,183 @@
+/*
+ * Copyright 2020 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package badger
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/badger/v2/options"
+	"github.com/stretchr/testify/require"
+)
+
+func lockTestOptions(policy options.LockPolicy) *Options {
+	opt := getTestOptions("").WithLockPolicy(policy).WithLockTimeout(5 * time.Second)
+	return &opt
+}
+
+// requireBlocked returns a channel receiving the result of fn, and checks that fn is blocked.
+func requireBlocked(t *testing.T, fn func() error) chan error {
+	errCh := make(chan error, 1)
+	go func() { errCh <- fn() }()
+	select {
+	case err := <-errCh:
+		t.Fatalf("Expected to block, got: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	return errCh
+}
+
+func getForUpdate(txn *Txn, key string) func() error {
+	return func() error {
+		_, err := txn.GetForUpdate([]byte(key))
+		if err == ErrKeyNotFound {
+			return nil
+		}
+		return err
+	}
+}
+
+func TestGetForUpdateCounter(t *testing.T) {
+	runBadgerTest(t, lockTestOptions(options.DeadlockDetection), func(t *testing.T, db *DB) {
+		key := []byte("counter")
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 50; j++ {
+					// The transactions never conflict, as they wait for each other.
+					require.NoError(t, db.Update(func(txn *Txn) error {
+						var n int
+						item, err := txn.GetForUpdate(key)
+						switch {
+						case err == nil:
+							n, err = strconv.Atoi(string(getItemValue(t, item)))
+							require.NoError(t, err)
+						case err != ErrKeyNotFound:
+							return err
+						}
+						return txn.Set(key, []byte(strconv.Itoa(n+1)))
+					}))
+				}
+			}()
+		}
+		wg.Wait()
+		require.NoError(t, db.View(func(txn *Txn) error {
+			item, err := txn.Get(key)
+			require.NoError(t, err)
+			require.Equal(t, []byte("400"), getItemValue(t, item))
+			return nil
+		}))
+	})
+}
+
+func TestGetForUpdateConflicts(t *testing.T) {
+	runBadgerTest(t, lockTestOptions(options.DeadlockDetection), func(t *testing.T, db *DB) {
+		// The latest version is read, even if committed after the transaction started.
+		txn := db.NewTransaction(true)
+		defer txn.Discard()
+		txnSet(t, db, []byte("a"), []byte("1"), 0)
+		item, err := txn.GetForUpdate([]byte("a"))
+		require.NoError(t, err)
+		require.Equal(t, []byte("1"), getItemValue(t, item))
+		require.NoError(t, txn.Set([]byte("a"), []byte("2")))
+		require.NoError(t, txn.Commit())
+
+		// A transaction writing the key without locking it still conflicts.
+		txn = db.NewTransaction(true)
+		defer txn.Discard()
+		_, err = txn.GetForUpdate([]byte("a"))
+		require.NoError(t, err)
+		txnSet(t, db, []byte("a"), []byte("3"), 0)
+		require.NoError(t, txn.Set([]byte("a"), []byte("4")))
+		require.Equal(t, ErrConflict, txn.Commit())
+
+		// The locks are released on Discard.
+		txn = db.NewTransaction(true)
+		_, err = txn.GetForUpdate([]byte("a"))
+		require.NoError(t, err)
+		other := db.NewTransaction(true)
+		defer other.Discard()
+		errCh := requireBlocked(t, getForUpdate(other, "a"))
+		txn.Discard()
+		require.NoError(t, <-errCh)
+
+		readOnly := db.NewTransaction(false)
+		defer readOnly.Discard()
+		_, err = readOnly.GetForUpdate([]byte("a"))
+		require.Equal(t, ErrReadOnlyTxn, err)
+	})
+}
+
+func TestGetForUpdateTimeout(t *testing.T) {
+	opt := lockTestOptions(options.DeadlockDetection).WithLockTimeout(50 * time.Millisecond)
+	runBadgerTest(t, &opt, func(t *testing.T, db *DB) {
+		txn1, txn2 := db.NewTransaction(true), db.NewTransaction(true)
+		defer txn1.Discard()
+		defer txn2.Discard()
+		require.NoError(t, getForUpdate(txn1, "a")())
+		require.Equal(t, ErrLockTimeout, getForUpdate(txn2, "a")())
+	})
+}
+
+func TestGetForUpdateDeadlock(t *testing.T) {
+	runBadgerTest(t, lockTestOptions(options.DeadlockDetection), func(t *testing.T, db *DB) {
+		txn1, txn2 := db.NewTransaction(true), db.NewTransaction(true)
+		defer txn1.Discard()
+		require.NoError(t, getForUpdate(txn1, "a")())
+		require.NoError(t, getForUpdate(txn2, "b")())
+		errCh := requireBlocked(t, getForUpdate(txn1, "b"))
+		require.Equal(t, ErrDeadlock, getForUpdate(txn2, "a")())
+		txn2.Discard()
+		require.NoError(t, <-errCh)
+	})
+}
+
+func TestGetForUpdateWaitDie(t *testing.T) {
+	runBadgerTest(t, lockTestOptions(options.WaitDie), func(t *testing.T, db *DB) {
+		older, younger := db.NewTransaction(true), db.NewTransaction(true)
+		defer older.Discard()
+		require.NoError(t, getForUpdate(older, "a")())
+		require.NoError(t, getForUpdate(younger, "b")())
+		// The younger transaction dies, the older one waits.
+		require.Equal(t, ErrConflict, getForUpdate(younger, "a")())
+		errCh := requireBlocked(t, getForUpdate(older, "b"))
+		younger.Discard()
+		require.NoError(t, <-errCh)
+	})
+}
+
+func TestGetForUpdateWoundWait(t *testing.T) {
+	runBadgerTest(t, lockTestOptions(options.WoundWait), func(t *testing.T, db *DB) {
+		older, younger := db.NewTransaction(true), db.NewTransaction(true)
+		defer older.Discard()
+		require.NoError(t, getForUpdate(older, "a")())
+		require.NoError(t, getForUpdate(younger, "b")())
+		// The younger transaction waits.
+		errCh := requireBlocked(t, getForUpdate(younger, "a"))
+		// The older transaction wounds the younger one, which stops waiting, and can't commit.
+		olderCh := requireBlocked(t, getForUpdate(older, "b"))
+		require.Equal(t, ErrConflict, <-errCh)
+		require.NoError(t, younger.Set([]byte("b"), nil))
+		require.Equal(t, ErrConflict, younger.Commit())
+		younger.Discard()
+		require.NoError(t, <-olderCh)
+	})
+}
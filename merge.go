@@ -17,6 +17,7 @@
 package badger
 
 import (
+	"bytes"
 	"sync"
 	"time"
 
@@ -25,7 +26,8 @@ import (
 	"github.com/pkg/errors"
 )
 
-// MergeOperator represents a Badger merge operator.
+// MergeOperator represents a Badger merge operator. It runs a goroutine per key, so for merging
+// many keys, use Options.WithMergeOperator and Txn.Merge instead.
 type MergeOperator struct {
 	sync.RWMutex
 	f      MergeFunc
@@ -176,3 +178,108 @@ func (op *MergeOperator) Get() ([]byte, error) {
 func (op *MergeOperator) Stop() {
 	op.closer.SignalAndWait()
 }
+
+// Merge writes operand as a merge operand for the key, using the merge function registered for
+// the key via Options.WithMergeOperator. Reads return the result of folding all the operands of
+// the key into its latest value, or into the oldest operand if the key has no value. Unlike
+// MergeOperator, no background goroutine is needed: compactions collapse the operands once no
+// transaction can read the individual versions anymore.
+//
+// If the transaction has already written the key, the operand is merged into the pending write.
+// If the key is covered by a secondary index (see DB.AddIndex), the operand is merged into the
+// value read by the transaction right away, since the index needs the merged value.
+// ErrNoMergeOperator is returned if no merge function is registered for the key.
+//
+// The current transaction keeps a reference to the key and operand byte slices. Users must not
+// modify them until the end of the transaction.
+func (txn *Txn) Merge(key, operand []byte) error {
+	f := txn.db.opt.mergeFunc(key)
+	if f == nil {
+		return ErrNoMergeOperator
+	}
+	if txn.indexes.covers(key) {
+		return txn.mergeIndexed(key, operand, f)
+	}
+	e := &Entry{Key: key, Value: operand, meta: bitMergeEntry}
+	old, has := txn.pendingWrites[string(key)]
+	switch {
+	case has && bytes.Equal(key, old.Key) && old.version == e.version:
+		switch {
+		case old.meta&bitMergeEntry > 0:
+			e.Value = f(y.SafeCopy(nil, old.Value), operand)
+		case isDeletedOrExpired(old.meta, old.ExpiresAt):
+			// The key has no value to merge into, so the operand becomes its value.
+			e.meta = 0
+		default:
+			e.meta = old.meta & bitDiscardEarlierVersions
+			e.Value = f(y.SafeCopy(nil, old.Value), operand)
+			e.UserMeta = old.UserMeta
+			e.ExpiresAt = old.ExpiresAt
+		}
+	case !has && txn.pendingRangeDeleted(key):
+		e.meta = 0
+	}
+	return txn.modify(e)
+}
+
+// foldOperands folds the operands, ordered from the newest to the oldest, into base. If there is
+// no base, the oldest operand is used as the base.
+func foldOperands(f MergeFunc, base []byte, hasBase bool, operands [][]byte) []byte {
+	i := len(operands) - 1
+	if !hasBase {
+		base = operands[i]
+		i--
+	}
+	for ; i >= 0; i-- {
+		base = f(base, operands[i])
+	}
+	return base
+}
+
+// yieldMergedValue returns the value of a merge item. It folds the operand of the item, and the
+// older operands of the key, into the latest value of the key older than the operands.
+func (item *Item) yieldMergedValue() ([]byte, error) {
+	db := item.txn.db
+	operand, err := db.valueCopy(y.ValueStruct{Meta: item.meta, Value: item.vptr})
+	if err != nil {
+		return nil, err
+	}
+	operands := [][]byte{operand}
+	var base []byte
+	var hasBase bool
+	for ts := item.mergeTs; ts > 0; {
+		vs, err := db.get(y.KeyWithTs(item.key, ts))
+		if err != nil {
+			return nil, y.Wrapf(err, "while reading the merge operands of key: %q", item.Key())
+		}
+		if (vs.Value == nil && vs.Meta == 0) || isDeletedOrExpired(vs.Meta, vs.ExpiresAt) {
+			break
+		}
+		val, err := db.valueCopy(vs)
+		if err != nil {
+			return nil, err
+		}
+		if vs.Meta&bitMergeEntry == 0 {
+			base, hasBase = val, true
+			break
+		}
+		operands = append(operands, val)
+		ts = vs.Version - 1
+	}
+	return foldOperands(item.merge, base, hasBase, operands), nil
+}
+
+// valueCopy returns a copy of the value of vs, reading it from the value log if needed.
+func (db *DB) valueCopy(vs y.ValueStruct) ([]byte, error) {
+	if vs.Meta&bitValuePointer == 0 {
+		return y.SafeCopy(nil, vs.Value), nil
+	}
+	var vp valuePointer
+	vp.Decode(vs.Value)
+	buf, cb, err := db.vlog.Read(vp, new(y.Slice))
+	defer runCallback(cb)
+	if err != nil {
+		return nil, y.Wrapf(err, "while reading value pointer: %+v", vp)
+	}
+	return y.SafeCopy(nil, buf), nil
+}
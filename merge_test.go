@@ -18,6 +18,7 @@ package badger
 
 import (
 	"encoding/binary"
+	"fmt"
 	"io/ioutil"
 	"testing"
 	"time"
@@ -168,3 +169,143 @@ func bytesToUint64(b []byte) uint64 {
 func add(existing, latest []byte) []byte {
 	return uint64ToBytes(bytesToUint64(existing) + bytesToUint64(latest))
 }
+
+func TestTxnMerge(t *testing.T) {
+	dir, err := ioutil.TempDir("", "badger-test")
+	require.NoError(t, err)
+	defer removeDir(dir)
+
+	appendFn := func(existing, latest []byte) []byte {
+		return append(existing, latest...)
+	}
+	// The list operands are large enough to be stored in the value log.
+	opt := getTestOptions(dir).WithValueThreshold(32).
+		WithMergeOperator([]byte("cnt"), add).
+		WithMergeOperator([]byte("c"), appendFn)
+	db, err := Open(opt)
+	require.NoError(t, err)
+	defer func() { require.NoError(t, db.Close()) }()
+
+	merge := func(key string, operand []byte) {
+		require.NoError(t, db.Update(func(txn *Txn) error {
+			return txn.Merge([]byte(key), operand)
+		}))
+	}
+	getValue := func(txn *Txn, key string) []byte {
+		item, err := txn.Get([]byte(key))
+		require.NoError(t, err)
+		return getItemValue(t, item)
+	}
+	op := func(i int) []byte {
+		return []byte(fmt.Sprintf("%040d", i))
+	}
+
+	require.Equal(t, ErrNoMergeOperator, db.Update(func(txn *Txn) error {
+		return txn.Merge([]byte("other"), []byte("value"))
+	}))
+	for i := 1; i <= 10; i++ {
+		merge("cnt", uint64ToBytes(uint64(i)))
+		merge("clist", op(i))
+	}
+	// An existing value is merged into, and a delete resets the key.
+	txnSet(t, db, []byte("cset"), []byte("base-"), 0)
+	merge("cset", []byte("a"))
+	merge("cset", []byte("b"))
+	merge("cdel", []byte("old"))
+	txnDelete(t, db, []byte("cdel"))
+	merge("cdel", []byte("new"))
+
+	var list []byte
+	for i := 1; i <= 10; i++ {
+		list = append(list, op(i)...)
+	}
+	check := func() {
+		require.NoError(t, db.View(func(txn *Txn) error {
+			require.Equal(t, uint64(55), bytesToUint64(getValue(txn, "cnt")))
+			require.Equal(t, list, getValue(txn, "clist"))
+			require.Equal(t, []byte("base-ab"), getValue(txn, "cset"))
+			require.Equal(t, []byte("new"), getValue(txn, "cdel"))
+
+			items, err := txn.MultiGet([][]byte{[]byte("cset"), []byte("cnt")})
+			require.NoError(t, err)
+			require.Equal(t, []byte("base-ab"), getItemValue(t, items[0]))
+			require.Equal(t, uint64(55), bytesToUint64(getItemValue(t, items[1])))
+
+			for _, reverse := range []bool{false, true} {
+				iopt := DefaultIteratorOptions
+				iopt.Reverse = reverse
+				it := txn.NewIterator(iopt)
+				values := make(map[string][]byte)
+				for it.Rewind(); it.Valid(); it.Next() {
+					values[string(it.Item().Key())] = getItemValue(t, it.Item())
+				}
+				it.Close()
+				require.Len(t, values, 4)
+				require.Equal(t, list, values["clist"])
+				require.Equal(t, []byte("base-ab"), values["cset"])
+			}
+			return nil
+		}))
+	}
+	check()
+
+	// Operands written by the transaction are merged into the committed value.
+	txn := db.NewTransaction(true)
+	require.NoError(t, txn.Merge([]byte("cnt"), uint64ToBytes(100)))
+	require.NoError(t, txn.Merge([]byte("cnt"), uint64ToBytes(1000)))
+	require.NoError(t, txn.Merge([]byte("cset"), []byte("c")))
+	require.Equal(t, uint64(1155), bytesToUint64(getValue(txn, "cnt")))
+	it := txn.NewIterator(DefaultIteratorOptions)
+	it.Seek([]byte("cset"))
+	require.True(t, it.Valid())
+	require.Equal(t, []byte("base-abc"), getItemValue(t, it.Item()))
+	it.Close()
+	txn.Discard()
+
+	// The operands are kept as individual versions.
+	require.NoError(t, db.View(func(txn *Txn) error {
+		iopt := DefaultIteratorOptions
+		iopt.AllVersions = true
+		it := txn.NewKeyIterator([]byte("cnt"), iopt)
+		defer it.Close()
+		var count int
+		for it.Rewind(); it.Valid(); it.Next() {
+			require.Len(t, getItemValue(t, it.Item()), 8)
+			count++
+		}
+		require.Equal(t, 10, count)
+		return nil
+	}))
+
+	// Compactions collapse the operands.
+	require.NoError(t, db.Close())
+	db, err = Open(opt)
+	require.NoError(t, err)
+	cdef := compactDef{
+		thisLevel: db.lc.levels[0],
+		nextLevel: db.lc.levels[1],
+		top:       db.lc.levels[0].tables,
+		bot:       db.lc.levels[1].tables,
+		t:         db.lc.levelTargets(),
+	}
+	cdef.t.baseLevel = 1
+	require.NotEmpty(t, cdef.top)
+	require.NoError(t, db.lc.runCompactDef(-1, 0, cdef))
+
+	check()
+	require.NoError(t, db.View(func(txn *Txn) error {
+		iopt := DefaultIteratorOptions
+		iopt.AllVersions = true
+		it := txn.NewIterator(iopt)
+		defer it.Close()
+		versions := make(map[string]int)
+		for it.Rewind(); it.Valid(); it.Next() {
+			item := it.Item()
+			versions[string(item.Key())]++
+			require.Zero(t, item.meta&bitMergeEntry, "key: %s", item.Key())
+		}
+		require.Equal(t, 1, versions["cnt"])
+		require.Equal(t, 1, versions["clist"])
+		return nil
+	}))
+}
@@ -0,0 +1,310 @@
+/*
+ * Copyright 2020 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package badger
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// Counter is a metric whose value only goes up.
+type Counter struct {
+	v int64
+}
+
+// Add adds delta to the counter.
+func (c *Counter) Add(delta int64) {
+	atomic.AddInt64(&c.v, delta)
+}
+
+// Value returns the current value of the counter.
+func (c *Counter) Value() int64 {
+	return atomic.LoadInt64(&c.v)
+}
+
+// Gauge is a metric whose value can go up and down.
+type Gauge struct {
+	v int64
+}
+
+// Add adds delta to the gauge. The delta can be negative.
+func (g *Gauge) Add(delta int64) {
+	atomic.AddInt64(&g.v, delta)
+}
+
+// Set sets the gauge to val.
+func (g *Gauge) Set(val int64) {
+	atomic.StoreInt64(&g.v, val)
+}
+
+// Value returns the current value of the gauge.
+func (g *Gauge) Value() int64 {
+	return atomic.LoadInt64(&g.v)
+}
+
+// Histogram counts observed durations in buckets.
+type Histogram struct {
+	// bounds are the upper bounds of the buckets, in seconds. counts has an extra bucket for the
+	// durations above the last bound.
+	bounds []float64
+	counts []uint64
+	count  uint64
+	sum    int64 // In nanoseconds.
+}
+
+// latencyBuckets are the bucket bounds used for the latencies of the operations, in seconds.
+var latencyBuckets = []float64{
+	1e-5, 5e-5, 1e-4, 5e-4, 1e-3, 5e-3, 1e-2, 5e-2, 0.1, 0.5, 1, 5, 10,
+}
+
+// durationBuckets are the bucket bounds used for the duration of the background jobs, in
+// seconds.
+var durationBuckets = []float64{0.01, 0.05, 0.1, 0.5, 1, 5, 10, 30, 60, 300, 600}
+
+func newHistogram(bounds []float64) *Histogram {
+	return &Histogram{bounds: bounds, counts: make([]uint64, len(bounds)+1)}
+}
+
+// Observe adds the duration to the histogram.
+func (h *Histogram) Observe(d time.Duration) {
+	idx := sort.SearchFloat64s(h.bounds, d.Seconds())
+	atomic.AddUint64(&h.counts[idx], 1)
+	atomic.AddUint64(&h.count, 1)
+	atomic.AddInt64(&h.sum, int64(d))
+}
+
+// Count returns the number of observed durations.
+func (h *Histogram) Count() uint64 {
+	return atomic.LoadUint64(&h.count)
+}
+
+// Sum returns the sum of the observed durations.
+func (h *Histogram) Sum() time.Duration {
+	return time.Duration(atomic.LoadInt64(&h.sum))
+}
+
+// Metrics holds the metrics of a DB. Unlike the expvar metrics in package y, which are shared by
+// all the DBs in the process, these only track the operations done on a single DB. Metrics can
+// be exported in the Prometheus text format via WritePrometheus, or by mounting it on an HTTP
+// mux, since it implements http.Handler.
+type Metrics struct {
+	db *DB
+
+	// Gets is the number of lookups of a key.
+	Gets Counter
+	// MemtableGets is the number of lookups in the memtables.
+	MemtableGets Counter
+	// LSMGets and LSMBloomHits hold the number of table lookups, and of the lookups avoided by
+	// the bloom filters, for every level.
+	LSMGets      []Counter
+	LSMBloomHits []Counter
+	// Puts is the number of entries written.
+	Puts Counter
+	// BytesWritten is the number of bytes written to the value log.
+	BytesWritten Counter
+	// StallTime is the time for which the writes were stalled, waiting for the memtables to be
+	// flushed. The flushes themselves stall when level 0 has too many tables.
+	StallTime Counter
+	// CompactionsRunning is the number of compactions in progress.
+	CompactionsRunning Gauge
+	// PendingWrites is the number of write requests being batched for the next write.
+	PendingWrites Gauge
+	// VlogGCReclaimedBytes is the space reclaimed by the value log garbage collection.
+	VlogGCReclaimedBytes Counter
+	// ReplicaReadTs is the commit timestamp of the primary up to which a Replica has applied the
+	// changes, and ReplicaLag is the number of commit timestamps by which it is behind the primary.
+	ReplicaReadTs Gauge
+	ReplicaLag    Gauge
+	// ReplicaPrimaryTs is the latest commit timestamp received by a Replica from its primary.
+	ReplicaPrimaryTs Gauge
+
+	// GetLatency is the latency of Txn.Get calls.
+	GetLatency *Histogram
+	// SetLatency is the time taken to write a batch of requests to the value log and the
+	// memtable.
+	SetLatency *Histogram
+	// CommitLatency is the latency of Txn.Commit calls.
+	CommitLatency *Histogram
+	// CompactionDuration holds the duration of the compactions, for every level being compacted.
+	CompactionDuration []*Histogram
+}
+
+func newMetrics(db *DB) *Metrics {
+	m := &Metrics{
+		db:                 db,
+		LSMGets:            make([]Counter, db.opt.MaxLevels),
+		LSMBloomHits:       make([]Counter, db.opt.MaxLevels),
+		GetLatency:         newHistogram(latencyBuckets),
+		SetLatency:         newHistogram(latencyBuckets),
+		CommitLatency:      newHistogram(latencyBuckets),
+		CompactionDuration: make([]*Histogram, db.opt.MaxLevels),
+	}
+	for i := range m.CompactionDuration {
+		m.CompactionDuration[i] = newHistogram(durationBuckets)
+	}
+	return m
+}
+
+// Metrics returns the metrics of the DB.
+func (db *DB) Metrics() *Metrics {
+	return db.metrics
+}
+
+// ServeHTTP implements http.Handler. It writes the metrics in the Prometheus text format.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if err := m.WritePrometheus(w); err != nil {
+		m.db.opt.Warningf("While writing metrics: %v", err)
+	}
+}
+
+// WritePrometheus writes the metrics in the Prometheus text exposition format.
+func (m *Metrics) WritePrometheus(w io.Writer) error {
+	pw := &promWriter{w: bufio.NewWriter(w)}
+
+	pw.header("badger_gets_total", "counter", "Number of key lookups.")
+	pw.sample("badger_gets_total", "", float64(m.Gets.Value()))
+	pw.header("badger_memtable_gets_total", "counter", "Number of lookups in the memtables.")
+	pw.sample("badger_memtable_gets_total", "", float64(m.MemtableGets.Value()))
+	pw.header("badger_lsm_level_gets_total", "counter", "Number of table lookups per level.")
+	for i := range m.LSMGets {
+		pw.sample("badger_lsm_level_gets_total", levelLabel(i), float64(m.LSMGets[i].Value()))
+	}
+	pw.header("badger_lsm_bloom_hits_total", "counter",
+		"Number of table lookups avoided by the bloom filters per level.")
+	for i := range m.LSMBloomHits {
+		pw.sample("badger_lsm_bloom_hits_total", levelLabel(i),
+			float64(m.LSMBloomHits[i].Value()))
+	}
+	pw.header("badger_puts_total", "counter", "Number of entries written.")
+	pw.sample("badger_puts_total", "", float64(m.Puts.Value()))
+	pw.header("badger_written_bytes_total", "counter", "Number of bytes written to the value log.")
+	pw.sample("badger_written_bytes_total", "", float64(m.BytesWritten.Value()))
+	pw.header("badger_write_stall_seconds_total", "counter",
+		"Time for which the writes were stalled.")
+	pw.sample("badger_write_stall_seconds_total", "",
+		time.Duration(m.StallTime.Value()).Seconds())
+	pw.header("badger_compactions_running", "gauge", "Number of compactions in progress.")
+	pw.sample("badger_compactions_running", "", float64(m.CompactionsRunning.Value()))
+	pw.header("badger_vlog_gc_reclaimed_bytes_total", "counter",
+		"Space reclaimed by the value log garbage collection.")
+	pw.sample("badger_vlog_gc_reclaimed_bytes_total", "",
+		float64(m.VlogGCReclaimedBytes.Value()))
+	pw.header("badger_replica_read_ts", "gauge",
+		"Commit timestamp of the primary up to which the replica has applied the changes.")
+	pw.sample("badger_replica_read_ts", "", float64(m.ReplicaReadTs.Value()))
+	pw.header("badger_replica_primary_ts", "gauge",
+		"Latest commit timestamp received by the replica from the primary.")
+	pw.sample("badger_replica_primary_ts", "", float64(m.ReplicaPrimaryTs.Value()))
+	pw.header("badger_replica_lag", "gauge",
+		"Number of commit timestamps by which the replica is behind the primary.")
+	pw.sample("badger_replica_lag", "", float64(m.ReplicaLag.Value()))
+
+	lsm, vlog := m.db.Size()
+	pw.header("badger_lsm_size_bytes", "gauge", "Size of the LSM tree.")
+	pw.sample("badger_lsm_size_bytes", "", float64(lsm))
+	pw.header("badger_vlog_size_bytes", "gauge", "Size of the value log.")
+	pw.sample("badger_vlog_size_bytes", "", float64(vlog))
+	pw.header("badger_pending_writes", "gauge", "Number of write requests waiting to be written.")
+	pw.sample("badger_pending_writes", "", float64(m.PendingWrites.Value()))
+	if m.db.blockCache != nil {
+		pw.header("badger_block_cache_hit_ratio", "gauge", "Hit ratio of the block cache.")
+		pw.sample("badger_block_cache_hit_ratio", "", m.db.BlockCacheMetrics().Ratio())
+	}
+	if m.db.indexCache != nil {
+		pw.header("badger_index_cache_hit_ratio", "gauge", "Hit ratio of the index cache.")
+		pw.sample("badger_index_cache_hit_ratio", "", m.db.IndexCacheMetrics().Ratio())
+	}
+
+	pw.histogram("badger_get_latency_seconds", "Latency of the key lookups.", "",
+		m.GetLatency)
+	pw.histogram("badger_set_latency_seconds",
+		"Time taken to write a batch of requests to the value log and the memtable.", "",
+		m.SetLatency)
+	pw.histogram("badger_commit_latency_seconds", "Latency of the transaction commits.", "",
+		m.CommitLatency)
+	pw.header("badger_compaction_duration_seconds", "histogram",
+		"Duration of the compactions per level being compacted.")
+	for i, h := range m.CompactionDuration {
+		pw.histogramSamples("badger_compaction_duration_seconds", levelLabel(i), h)
+	}
+
+	if pw.err != nil {
+		return pw.err
+	}
+	return pw.w.Flush()
+}
+
+func levelLabel(level int) string {
+	return fmt.Sprintf("level=%q", strconv.Itoa(level))
+}
+
+// promWriter writes metrics in the Prometheus text format. It keeps the first error, so that the
+// errors need to be checked only once.
+type promWriter struct {
+	w   *bufio.Writer
+	err error
+}
+
+func (pw *promWriter) printf(format string, args ...interface{}) {
+	if pw.err != nil {
+		return
+	}
+	_, pw.err = fmt.Fprintf(pw.w, format, args...)
+}
+
+func (pw *promWriter) header(name, typ, help string) {
+	pw.printf("# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+// sample writes a single sample. labels holds the comma separated labels, if any.
+func (pw *promWriter) sample(name, labels string, val float64) {
+	if len(labels) > 0 {
+		name = name + "{" + labels + "}"
+	}
+	pw.printf("%s %s\n", name, strconv.FormatFloat(val, 'g', -1, 64))
+}
+
+func (pw *promWriter) histogram(name, help, labels string, h *Histogram) {
+	pw.header(name, "histogram", help)
+	pw.histogramSamples(name, labels, h)
+}
+
+func (pw *promWriter) histogramSamples(name, labels string, h *Histogram) {
+	withLe := func(le string) string {
+		if len(labels) > 0 {
+			return labels + ",le=" + strconv.Quote(le)
+		}
+		return "le=" + strconv.Quote(le)
+	}
+	// The buckets are cumulative.
+	var cum uint64
+	for i, bound := range h.bounds {
+		cum += atomic.LoadUint64(&h.counts[i])
+		pw.sample(name+"_bucket", withLe(strconv.FormatFloat(bound, 'g', -1, 64)), float64(cum))
+	}
+	cum += atomic.LoadUint64(&h.counts[len(h.bounds)])
+	pw.sample(name+"_bucket", withLe("+Inf"), float64(cum))
+	pw.sample(name+"_sum", labels, h.Sum().Seconds())
+	pw.sample(name+"_count", labels, float64(cum))
+}
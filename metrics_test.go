@@ -0,0 +1,117 @@
+/*
+ * Copyright 2020 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package badger
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistogramObserve(t *testing.T) {
+	h := newHistogram([]float64{0.001, 0.01})
+	h.Observe(500 * time.Microsecond)
+	h.Observe(time.Millisecond)
+	h.Observe(5 * time.Millisecond)
+	h.Observe(time.Second)
+	require.Equal(t, []uint64{2, 1, 1}, h.counts)
+	require.Equal(t, uint64(4), h.Count())
+	require.Equal(t, time.Second+6500*time.Microsecond, h.Sum())
+
+	var buf bytes.Buffer
+	pw := &promWriter{w: bufio.NewWriter(&buf)}
+	pw.histogram("lat", "Latency.", `level="1"`, h)
+	require.NoError(t, pw.w.Flush())
+	require.Equal(t, `# HELP lat Latency.
+# TYPE lat histogram
+lat_bucket{level="1",le="0.001"} 2
+lat_bucket{level="1",le="0.01"} 3
+lat_bucket{level="1",le="+Inf"} 4
+lat_sum{level="1"} 1.0065
+lat_count{level="1"} 4
+`, buf.String())
+}
+
+func TestMetrics(t *testing.T) {
+	dir, err := ioutil.TempDir("", "badger-test")
+	require.NoError(t, err)
+	defer removeDir(dir)
+
+	db, err := Open(getTestOptions(dir))
+	require.NoError(t, err)
+	defer func() { require.NoError(t, db.Close()) }()
+	// A second DB doesn't affect the metrics of the first one.
+	dir2, err := ioutil.TempDir("", "badger-test")
+	require.NoError(t, err)
+	defer removeDir(dir2)
+	db2, err := Open(getTestOptions(dir2))
+	require.NoError(t, err)
+	txnSet(t, db2, []byte("other"), []byte("value"), 0)
+	require.NoError(t, db2.Close())
+
+	for i := 0; i < 10; i++ {
+		txnSet(t, db, rangeKey(i), []byte("value"), 0)
+	}
+	require.NoError(t, db.View(func(txn *Txn) error {
+		for i := 0; i < 5; i++ {
+			_, err := txn.Get(rangeKey(i))
+			require.NoError(t, err)
+		}
+		return nil
+	}))
+
+	m := db.Metrics()
+	// Every commit also writes the entry marking the end of the transaction.
+	require.Equal(t, int64(20), m.Puts.Value())
+	require.Equal(t, int64(5), m.Gets.Value())
+	require.Equal(t, uint64(5), m.GetLatency.Count())
+	require.Equal(t, uint64(10), m.CommitLatency.Count())
+	require.NotZero(t, m.SetLatency.Count())
+
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	require.Equal(t, 200, rec.Code)
+	require.Contains(t, rec.Header().Get("Content-Type"), "text/plain")
+	out := rec.Body.String()
+	for _, line := range []string{
+		"# TYPE badger_puts_total counter",
+		"badger_puts_total 20",
+		"badger_gets_total 5",
+		`badger_lsm_level_gets_total{level="0"} 0`,
+		"badger_get_latency_seconds_count 5",
+		`badger_get_latency_seconds_bucket{le="+Inf"} 5`,
+		"badger_commit_latency_seconds_count 10",
+		`badger_compaction_duration_seconds_count{level="6"} 0`,
+		"# TYPE badger_block_cache_hit_ratio gauge",
+		"badger_replica_primary_ts 0",
+	} {
+		require.Contains(t, out, line+"\n")
+	}
+	// Every sample must have a value.
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		require.Len(t, strings.Fields(line), 2, line)
+	}
+}
@@ -0,0 +1,302 @@
+/*
+ * Copyright 2020 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package badger
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/dgraph-io/badger/v2/table"
+	"github.com/dgraph-io/badger/v2/y"
+)
+
+// multiGetConcurrency is the maximum number of value log reads run in parallel by MultiGet.
+const multiGetConcurrency = 16
+
+// MultiGet looks up multiple keys at once, and returns the items in the same order as the keys.
+// The item is nil for a key which isn't found. Looking up a batch of keys is cheaper than calling
+// Get for each of them: the keys are sorted, the memtables are acquired only once, and keys which
+// fall in the same table share the bloom filter lookup and the block iterator. The values stored
+// in the value log are read in parallel, before MultiGet returns.
+//
+// The returned items follow the same rules as the ones returned by Get.
+func (txn *Txn) MultiGet(keys [][]byte) ([]*Item, error) {
+	if txn.discarded {
+		return nil, ErrDiscardedTxn
+	}
+	for _, key := range keys {
+		if len(key) == 0 {
+			return nil, ErrEmptyKey
+		}
+	}
+
+	items := make([]*Item, len(keys))
+	// lookups holds the indices of the keys which need to be looked up in the DB.
+	lookups := make([]int, 0, len(keys))
+	for i, key := range keys {
+		if txn.update {
+			if e, has := txn.pendingWrites[string(key)]; has && bytes.Equal(key, e.Key) {
+				if !isDeletedOrExpired(e.meta, e.ExpiresAt) {
+					items[i] = txn.pendingItem(key, e)
+				}
+				continue
+			}
+			txn.addReadKey(key)
+			if txn.pendingRangeDeleted(key) {
+				continue
+			}
+		}
+		lookups = append(lookups, i)
+	}
+	sort.Slice(lookups, func(i, j int) bool {
+		return bytes.Compare(keys[lookups[i]], keys[lookups[j]]) < 0
+	})
+
+	seeks := make([][]byte, len(lookups))
+	for i, idx := range lookups {
+		seeks[i] = y.KeyWithTs(keys[idx], txn.readTs)
+	}
+	vss, err := txn.db.multiGet(seeks)
+	if err != nil {
+		return nil, y.Wrapf(err, "DB::MultiGet")
+	}
+
+	throttle := y.NewThrottle(multiGetConcurrency)
+	for i, idx := range lookups {
+		vs := vss[i]
+		if vs.Value == nil && vs.Meta == 0 {
+			continue
+		}
+		if isDeletedOrExpired(vs.Meta, vs.ExpiresAt) {
+			continue
+		}
+		item := txn.newItem(keys[idx], vs)
+		items[idx] = item
+		if vs.Meta&bitValuePointer == 0 {
+			continue
+		}
+		if err := throttle.Do(); err != nil {
+			return nil, err
+		}
+		go func(item *Item) {
+			item.prefetchValue()
+			throttle.Done(nil)
+		}(item)
+	}
+	if err := throttle.Finish(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// multiGet is the batched version of get. The keys must be sorted.
+func (db *DB) multiGet(keys [][]byte) ([]y.ValueStruct, error) {
+	if db.IsClosed() {
+		return nil, ErrDBClosed
+	}
+	tables, decr := db.getMemTables() // Lock should be released.
+	defer decr()
+
+	vss := make([]y.ValueStruct, len(keys))
+	// done is set for the keys for which the required version has been found.
+	done := make([]bool, len(keys))
+	for i, key := range keys {
+		version := y.ParseTs(key)
+		y.NumGets.Add(1)
+		db.metrics.Gets.Add(1)
+		for _, mt := range tables {
+			vs := mt.sl.Get(key)
+			y.NumMemtableGets.Add(1)
+			db.metrics.MemtableGets.Add(1)
+			if vs.Meta == 0 && vs.Value == nil {
+				continue
+			}
+			if vs.Version == version {
+				vss[i], done[i] = vs, true
+				break
+			}
+			if vss[i].Version < vs.Version {
+				vss[i] = vs
+			}
+		}
+	}
+	if err := db.lc.multiGet(keys, vss, done); err != nil {
+		return nil, err
+	}
+	return vss, nil
+}
+
+// multiGet is the batched version of get. For every key which isn't done, it updates the
+// corresponding value in vss, if a newer version of the key is found in the levels.
+func (s *levelsController) multiGet(keys [][]byte, vss []y.ValueStruct, done []bool) error {
+	if s.kv.IsClosed() {
+		return ErrDBClosed
+	}
+	hashes := make([]uint32, len(keys))
+	for i, key := range keys {
+		hashes[i] = y.Hash(y.ParseKey(key))
+	}
+	// Iterate the levels from 0 on upward, for the same reason as in get.
+	for _, h := range s.levels {
+		if err := h.multiGet(keys, hashes, vss, done); err != nil {
+			return y.Wrapf(err, "multiGet at level %d", h.level)
+		}
+	}
+	for i, key := range keys {
+		if done[i] || vss[i].Version == 0 {
+			continue
+		}
+		// The latest version of the key might have been deleted by a range tombstone.
+		delTs := s.kv.rangeDels.deletedBy(y.ParseKey(key), vss[i].Version, y.ParseTs(key))
+		if delTs > 0 {
+			vss[i] = y.ValueStruct{Meta: bitDelete, Version: delTs}
+		}
+	}
+	return nil
+}
+
+// multiGet looks up the keys which aren't done in the level. The keys must be sorted, and hashes
+// must hold the hashes of the keys without their timestamps. An iterator is created only once for
+// every table, and reused for all the keys in the table.
+func (s *levelHandler) multiGet(keys [][]byte, hashes []uint32, vss []y.ValueStruct,
+	done []bool) error {
+	s.RLock()
+	tables := make([]*table.Table, len(s.tables))
+	copy(tables, s.tables)
+	for _, t := range tables {
+		t.IncrRef()
+	}
+	s.RUnlock()
+	defer func() {
+		_ = decrRefs(tables)
+	}()
+
+	lookup := func(th *table.Table, it **table.Iterator, i int) {
+		key := keys[i]
+		if th.DoesNotHave(hashes[i]) {
+			y.NumLSMBloomHits.Add(s.strLevel, 1)
+			s.db.metrics.LSMBloomHits[s.level].Add(1)
+			return
+		}
+		if *it == nil {
+			*it = th.NewIterator(0)
+		}
+		y.NumLSMGets.Add(s.strLevel, 1)
+		s.db.metrics.LSMGets[s.level].Add(1)
+		(*it).Seek(key)
+		if !(*it).Valid() || !y.SameKey(key, (*it).Key()) {
+			return
+		}
+		if version := y.ParseTs((*it).Key()); vss[i].Version < version {
+			vss[i] = (*it).ValueCopy()
+			vss[i].Version = version
+			done[i] = version == y.ParseTs(key)
+		}
+	}
+
+	if s.level == 0 {
+		// Tables in level 0 can overlap, so every key has to be looked up in every table. Newer
+		// tables are at the end.
+		for j := len(tables) - 1; j >= 0; j-- {
+			th := tables[j]
+			var it *table.Iterator
+			left, right := y.ParseKey(th.Smallest()), y.ParseKey(th.Biggest())
+			for i, key := range keys {
+				if done[i] || bytes.Compare(y.ParseKey(key), left) < 0 ||
+					bytes.Compare(y.ParseKey(key), right) > 0 {
+					continue
+				}
+				lookup(th, &it, i)
+			}
+			if it != nil {
+				_ = it.Close()
+			}
+		}
+		return nil
+	}
+
+	// For level >= 1, the tables don't overlap. Walk the sorted keys and tables together.
+	var it *table.Iterator
+	j := 0
+	for i, key := range keys {
+		if done[i] {
+			continue
+		}
+		for j < len(tables) && y.CompareKeys(tables[j].Biggest(), key) < 0 {
+			if it != nil {
+				_ = it.Close()
+				it = nil
+			}
+			j++
+		}
+		if j == len(tables) {
+			break
+		}
+		lookup(tables[j], &it, i)
+	}
+	if it != nil {
+		_ = it.Close()
+	}
+	return nil
+}
+
+// pendingItem returns an item for the entry in the pending writes of the transaction.
+func (txn *Txn) pendingItem(key []byte, e *Entry) *Item {
+	if e.meta&bitMergeEntry > 0 {
+		if f := txn.db.opt.mergeFunc(key); f != nil {
+			// The operand is merged into the committed value of the key when the value is read.
+			return &Item{
+				meta:      e.meta,
+				vptr:      e.Value,
+				userMeta:  e.UserMeta,
+				key:       key,
+				version:   txn.readTs,
+				expiresAt: e.ExpiresAt,
+				txn:       txn,
+				merge:     f,
+				mergeTs:   txn.readTs,
+			}
+		}
+	}
+	return &Item{
+		meta:      e.meta,
+		val:       e.Value,
+		userMeta:  e.UserMeta,
+		key:       key,
+		status:    prefetched,
+		version:   txn.readTs,
+		expiresAt: e.ExpiresAt,
+	}
+}
+
+// newItem returns an item for the value of the key read from the DB.
+func (txn *Txn) newItem(key []byte, vs y.ValueStruct) *Item {
+	item := &Item{
+		key:       key,
+		version:   vs.Version,
+		meta:      vs.Meta,
+		userMeta:  vs.UserMeta,
+		vptr:      y.SafeCopy(nil, vs.Value),
+		txn:       txn,
+		expiresAt: vs.ExpiresAt,
+	}
+	if vs.Meta&bitMergeEntry > 0 {
+		item.merge = txn.db.opt.mergeFunc(key)
+		item.mergeTs = vs.Version - 1
+	}
+	return item
+}
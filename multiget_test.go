@@ -0,0 +1,102 @@
+/*
+ * Copyright 2020 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package badger
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMultiGet(t *testing.T) {
+	dir, err := ioutil.TempDir("", "badger-test")
+	require.NoError(t, err)
+	defer removeDir(dir)
+
+	opt := getTestOptions(dir).WithValueThreshold(32)
+	db, err := Open(opt)
+	require.NoError(t, err)
+
+	value := func(round, i int) []byte {
+		// Every other value goes to the value log.
+		return []byte(fmt.Sprintf("%d-%0*d", round, 16+(i%2)*64, i))
+	}
+	write := func(round, from, to, step int) {
+		for i := from; i < to; i += step {
+			txnSet(t, db, rangeKey(i), value(round, i), 0)
+		}
+	}
+	// Spread the keys across the lower levels, level 0 and the memtable.
+	write(0, 0, 500, 1)
+	require.NoError(t, db.Close())
+	db, err = Open(opt)
+	require.NoError(t, err)
+	require.NoError(t, db.Flatten(1))
+	write(1, 0, 500, 3)
+	require.NoError(t, db.Close())
+	db, err = Open(opt)
+	require.NoError(t, err)
+	defer func() { require.NoError(t, db.Close()) }()
+	write(2, 0, 500, 7)
+	for i := 0; i < 500; i += 11 {
+		txnDelete(t, db, rangeKey(i))
+	}
+
+	var keys [][]byte
+	for i := 0; i < 1000; i++ {
+		// Include missing and duplicate keys.
+		keys = append(keys, rangeKey(rand.Intn(600)))
+	}
+
+	check := func(txn *Txn) {
+		items, err := txn.MultiGet(keys)
+		require.NoError(t, err)
+		require.Len(t, items, len(keys))
+		for i, key := range keys {
+			expected, err := txn.Get(key)
+			if err == ErrKeyNotFound {
+				require.Nil(t, items[i], "key: %s", key)
+				continue
+			}
+			require.NoError(t, err)
+			require.NotNil(t, items[i], "key: %s", key)
+			require.Equal(t, key, items[i].Key())
+			require.Equal(t, expected.Version(), items[i].Version())
+			require.Equal(t, getItemValue(t, expected), getItemValue(t, items[i]))
+		}
+	}
+	require.NoError(t, db.View(func(txn *Txn) error {
+		check(txn)
+		return nil
+	}))
+
+	txn := db.NewTransaction(true)
+	defer txn.Discard()
+	require.NoError(t, txn.Set(rangeKey(1), []byte("pending")))
+	require.NoError(t, txn.Delete(rangeKey(2)))
+	check(txn)
+	items, err := txn.MultiGet([][]byte{rangeKey(2), rangeKey(1)})
+	require.NoError(t, err)
+	require.Nil(t, items[0])
+	require.Equal(t, []byte("pending"), getItemValue(t, items[1]))
+
+	_, err = txn.MultiGet([][]byte{rangeKey(1), nil})
+	require.Equal(t, ErrEmptyKey, err)
+}
@@ -17,10 +17,12 @@
 package badger
 
 import (
+	"bytes"
 	"os"
 	"time"
 
 	"github.com/dgraph-io/badger/v2/options"
+	"github.com/dgraph-io/badger/v2/pb"
 	"github.com/dgraph-io/badger/v2/table"
 	"github.com/dgraph-io/badger/v2/y"
 )
@@ -83,6 +85,7 @@ type Options struct {
 	// Encryption related options.
 	EncryptionKey                 []byte        // encryption key
 	EncryptionKeyRotationDuration time.Duration // key rotation duration
+	KeyManager                    KeyManager    // protects the data keys, instead of EncryptionKey
 
 	// BypassLockGaurd will bypass the lock guard on badger. Bypassing lock
 	// guard can cause data corruption if multiple badger instances are using
@@ -97,6 +100,21 @@ type Options struct {
 	// conflict detection is disabled.
 	DetectConflicts bool
 
+	// CompactionFilter is run on every key processed by compactions.
+	CompactionFilter CompactionFilter
+	// MergeOperators maps key prefixes to the merge functions used for the operands written via
+	// Txn.Merge.
+	MergeOperators map[string]MergeFunc
+	// LockTimeout and LockPolicy apply to the key locks taken by Txn.GetForUpdate.
+	LockTimeout time.Duration
+	LockPolicy  options.LockPolicy
+	// VersionRetention is how long the old versions of the keys are kept for DB.SnapshotAt.
+	VersionRetention time.Duration
+	// TTLCompactionInterval is how often the tables holding mostly expired keys are compacted, if
+	// set, and OnExpire is called for the expired keys dropped by compactions.
+	TTLCompactionInterval time.Duration
+	OnExpire              func(kv *pb.KV)
+
 	// Transaction start and commit timestamps are managed by end-user.
 	// This is only useful for databases built on top of Badger (like Dgraph).
 	// Not recommended for most users.
@@ -159,6 +177,8 @@ func DefaultOptions(path string) Options {
 		EncryptionKey:                 []byte{},
 		EncryptionKeyRotationDuration: 10 * 24 * time.Hour, // Default 10 days.
 		DetectConflicts:               true,
+		LockTimeout:                   10 * time.Second,
+		LockPolicy:                    options.DeadlockDetection,
 	}
 }
 
@@ -441,6 +461,18 @@ func (opt Options) WithEncryptionKey(key []byte) Options {
 	return opt
 }
 
+// WithKeyManager returns a new Options value with KeyManager set to the given value.
+//
+// The key manager protects the data keys used to encrypt the data with a master key, which can
+// live in an external key management service. Only one of KeyManager and EncryptionKey can be
+// set. The data is encrypted with AES-256 when a key manager is used.
+//
+// The default value of KeyManager is nil.
+func (opt Options) WithKeyManager(km KeyManager) Options {
+	opt.KeyManager = km
+	return opt
+}
+
 // WithEncryptionKeyRotationDuration returns new Options value with the duration set to
 // the given value.
 //
@@ -575,6 +607,121 @@ func (opt Options) WithDetectConflicts(b bool) Options {
 	return opt
 }
 
+// WithCompactionFilter returns a new Options value with CompactionFilter set to the given value.
+//
+// The compaction filter is called for every version of every key that compactions process and
+// no running transaction can read anymore, and decides whether the version should be kept,
+// removed or rewritten with a new value. This allows dropping logically dead records during
+// compaction, instead of scanning for them and deleting them via transactions. The value log
+// space used by removed or rewritten values is reclaimed by value log GC.
+//
+// The default value of CompactionFilter is nil.
+func (opt Options) WithCompactionFilter(f CompactionFilter) Options {
+	opt.CompactionFilter = f
+	return opt
+}
+
+// WithMergeOperator returns a new Options value with the merge function f registered for the keys
+// with the given prefix. If the prefixes of multiple merge functions match a key, the one with
+// the longest prefix is used. An empty prefix matches all the keys.
+//
+// Txn.Merge writes a merge operand for a key. The operands are folded into the latest value of
+// the key by reads, and compactions collapse them once no transaction can read the individual
+// versions anymore. Compactions fold the operands without the value they're merged into, so f
+// must be associative: f(f(a, b), c) must be equal to f(a, f(b, c)).
+//
+// The default value of MergeOperators is nil.
+func (opt Options) WithMergeOperator(prefix []byte, f MergeFunc) Options {
+	ops := make(map[string]MergeFunc, len(opt.MergeOperators)+1)
+	for p, mf := range opt.MergeOperators {
+		ops[p] = mf
+	}
+	ops[string(prefix)] = f
+	opt.MergeOperators = ops
+	return opt
+}
+
+// WithLockTimeout returns a new Options value with LockTimeout set to the given value.
+//
+// LockTimeout is the longest time Txn.GetForUpdate waits for the lock of a key, before returning
+// ErrLockTimeout. A value of 0 means no timeout.
+//
+// The default value of LockTimeout is 10 seconds.
+func (opt Options) WithLockTimeout(d time.Duration) Options {
+	opt.LockTimeout = d
+	return opt
+}
+
+// WithLockPolicy returns a new Options value with LockPolicy set to the given value.
+//
+// LockPolicy determines how the transactions waiting for the key locks of Txn.GetForUpdate are
+// kept from deadlocking. See options.LockPolicy.
+//
+// The default value of LockPolicy is options.DeadlockDetection.
+func (opt Options) WithLockPolicy(p options.LockPolicy) Options {
+	opt.LockPolicy = p
+	return opt
+}
+
+// WithVersionRetention returns a new Options value with VersionRetention set to the given value.
+//
+// VersionRetention is how long the versions of the keys overwritten or deleted are kept by
+// compactions, so that DB.SnapshotAt and DB.NewTransactionAsOf can read them. A value of 0 keeps
+// them only as long as they are visible to a running transaction. It is ignored in the managed
+// mode, where DB.SetDiscardTs decides which versions are kept.
+//
+// The default value of VersionRetention is 0.
+func (opt Options) WithVersionRetention(d time.Duration) Options {
+	opt.VersionRetention = d
+	return opt
+}
+
+// WithTTLCompactionInterval returns a new Options value with TTLCompactionInterval set to the
+// given value.
+//
+// TTLCompactionInterval is how often the tables are checked for expired keys. The tables in which
+// at least half of the keys are estimated to have expired are compacted, which drops the expired
+// keys from the LSM tree and lets the value log GC reclaim their values. The estimate is based on
+// the range of the expiry times of the keys in a table. A value of 0 disables these compactions,
+// leaving the expired keys to the regular compactions. A few minutes is a reasonable interval for
+// the DBs holding many keys with a TTL.
+//
+// The default value of TTLCompactionInterval is 0, which disables the TTL compactions.
+func (opt Options) WithTTLCompactionInterval(d time.Duration) Options {
+	opt.TTLCompactionInterval = d
+	return opt
+}
+
+// WithOnExpire returns a new Options value with OnExpire set to the given value.
+//
+// OnExpire is called with the keys whose latest version has expired, when the version is dropped
+// by a compaction. This can happen long after the expiry time, and it happens once per key
+// version, unless a compaction fails after dropping it. The keys of keyspaces are not included.
+//
+// OnExpire is called from a single goroutine, after the compactions queue the expired versions.
+// The compactions wait for the queue once it's full, so OnExpire should not block.
+//
+// The default value of OnExpire is nil.
+func (opt Options) WithOnExpire(f func(kv *pb.KV)) Options {
+	opt.OnExpire = f
+	return opt
+}
+
+// mergeFunc returns the merge function registered for the key, or nil if there is none.
+func (opt *Options) mergeFunc(key []byte) MergeFunc {
+	if len(opt.MergeOperators) == 0 || bytes.HasPrefix(key, badgerPrefix) {
+		return nil
+	}
+	var f MergeFunc
+	prefixLen := -1
+	for prefix, mf := range opt.MergeOperators {
+		if len(prefix) > prefixLen && bytes.HasPrefix(key, []byte(prefix)) {
+			f, prefixLen = mf, len(prefix)
+		}
+	}
+	return f
+}
+
 func (opt Options) getFileFlags() int {
 	var flags int
 	// opt.SyncWrites would be using msync to sync. All writes go through mmap.
@@ -55,3 +55,20 @@ const (
 	// ZSTD mode indicates that a block is compressed using ZSTD algorithm.
 	ZSTD CompressionType = 2
 )
+
+// LockPolicy specifies how the transactions waiting for the key locks taken by Txn.GetForUpdate
+// are kept from deadlocking.
+type LockPolicy int
+
+const (
+	// DeadlockDetection lets the transactions wait for the locks, unless waiting would close a
+	// cycle of transactions waiting for each other. The transaction requesting the lock is then
+	// aborted.
+	DeadlockDetection LockPolicy = iota
+	// WaitDie lets the older transactions wait for the locks held by younger ones, and aborts the
+	// younger transactions requesting the locks held by older ones.
+	WaitDie
+	// WoundWait aborts the younger transactions holding the locks requested by older ones, and
+	// lets the younger transactions wait for the locks held by older ones.
+	WoundWait
+)
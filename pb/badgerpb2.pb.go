@@ -4,8 +4,12 @@
 package pb
 
 import (
+	context "context"
 	fmt "fmt"
 	proto "github.com/golang/protobuf/proto"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
 	io "io"
 	math "math"
 	math_bits "math/bits"
@@ -516,545 +520,3819 @@ func (m *DataKey) GetCreatedAt() int64 {
 	return 0
 }
 
-func init() {
-	proto.RegisterEnum("badgerpb2.EncryptionAlgo", EncryptionAlgo_name, EncryptionAlgo_value)
-	proto.RegisterEnum("badgerpb2.ManifestChange_Operation", ManifestChange_Operation_name, ManifestChange_Operation_value)
-	proto.RegisterEnum("badgerpb2.Checksum_Algorithm", Checksum_Algorithm_name, Checksum_Algorithm_value)
-	proto.RegisterType((*KV)(nil), "badgerpb2.KV")
-	proto.RegisterType((*KVList)(nil), "badgerpb2.KVList")
-	proto.RegisterType((*ManifestChangeSet)(nil), "badgerpb2.ManifestChangeSet")
-	proto.RegisterType((*ManifestChange)(nil), "badgerpb2.ManifestChange")
-	proto.RegisterType((*Checksum)(nil), "badgerpb2.Checksum")
-	proto.RegisterType((*DataKey)(nil), "badgerpb2.DataKey")
+type BeginRequest struct {
+	Update               bool     `protobuf:"varint,1,opt,name=update,proto3" json:"update,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
 }
 
-func init() { proto.RegisterFile("badgerpb2.proto", fileDescriptor_e63e84f9f0d3998c) }
-
-var fileDescriptor_e63e84f9f0d3998c = []byte{
-	// 604 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x74, 0x53, 0xdd, 0x6e, 0xda, 0x4c,
-	0x10, 0x65, 0x8d, 0xc3, 0xcf, 0x90, 0x10, 0xbe, 0xd5, 0x57, 0xc9, 0x51, 0x15, 0x4a, 0x1d, 0x55,
-	0x42, 0x95, 0x0a, 0x2a, 0x54, 0xbd, 0x27, 0x80, 0x14, 0x44, 0xa2, 0x48, 0xdb, 0x28, 0x8a, 0x7a,
-	0x83, 0x16, 0x7b, 0x02, 0x16, 0xf8, 0x47, 0xbb, 0x8b, 0x55, 0x1e, 0xa2, 0xf7, 0x7d, 0xa4, 0x5e,
-	0xf6, 0xa2, 0x0f, 0x50, 0xa5, 0x2f, 0x52, 0xed, 0xda, 0xa1, 0x70, 0xd1, 0xbb, 0x99, 0x33, 0xe3,
-	0x39, 0xe3, 0x73, 0x66, 0xe1, 0x74, 0xce, 0xfd, 0x05, 0x8a, 0x64, 0xde, 0xeb, 0x24, 0x22, 0x56,
-	0x31, 0xad, 0xee, 0x00, 0xf7, 0x27, 0x01, 0x6b, 0x7a, 0x4f, 0x1b, 0x50, 0x5c, 0xe1, 0xd6, 0x21,
-	0x2d, 0xd2, 0x3e, 0x66, 0x3a, 0xa4, 0xff, 0xc3, 0x51, 0xca, 0xd7, 0x1b, 0x74, 0x2c, 0x83, 0x65,
-	0x09, 0x7d, 0x09, 0xd5, 0x8d, 0x44, 0x31, 0x0b, 0x51, 0x71, 0xa7, 0x68, 0x2a, 0x15, 0x0d, 0xdc,
-	0xa0, 0xe2, 0xd4, 0x81, 0x72, 0x8a, 0x42, 0x06, 0x71, 0xe4, 0xd8, 0x2d, 0xd2, 0xb6, 0xd9, 0x73,
-	0x4a, 0xcf, 0x01, 0xf0, 0x4b, 0x12, 0x08, 0x94, 0x33, 0xae, 0x9c, 0x23, 0x53, 0xac, 0xe6, 0xc8,
-	0x40, 0x51, 0x0a, 0xb6, 0x19, 0x58, 0x32, 0x03, 0x4d, 0xac, 0x99, 0xa4, 0x12, 0xc8, 0xc3, 0x59,
-	0xe0, 0x3b, 0xd0, 0x22, 0xed, 0x13, 0x56, 0xc9, 0x80, 0x89, 0x4f, 0x5f, 0x41, 0x2d, 0x2f, 0xfa,
-	0x71, 0x84, 0x4e, 0xad, 0x45, 0xda, 0x15, 0x06, 0x19, 0x34, 0x8a, 0x23, 0x74, 0x47, 0x50, 0x9a,
-	0xde, 0x5f, 0x07, 0x52, 0xd1, 0x73, 0xb0, 0x56, 0xa9, 0x43, 0x5a, 0xc5, 0x76, 0xad, 0x77, 0xd2,
-	0xf9, 0xab, 0xc4, 0xf4, 0x9e, 0x59, 0xab, 0x54, 0xd3, 0xf0, 0xf5, 0x3a, 0xf6, 0x66, 0x02, 0x1f,
-	0x0d, 0x8d, 0xcd, 0x2a, 0x06, 0x60, 0xf8, 0xe8, 0x5e, 0xc1, 0x7f, 0x37, 0x3c, 0x0a, 0x1e, 0x51,
-	0xaa, 0xe1, 0x92, 0x47, 0x0b, 0xfc, 0x84, 0x8a, 0xf6, 0xa1, 0xec, 0x99, 0x44, 0xe6, 0x53, 0xcf,
-	0xf6, 0xa6, 0x1e, 0xb6, 0xb3, 0xe7, 0x4e, 0xf7, 0xab, 0x05, 0xf5, 0xc3, 0x1a, 0xad, 0x83, 0x35,
-	0xf1, 0x8d, 0xe2, 0x36, 0xb3, 0x26, 0x3e, 0xed, 0x83, 0x75, 0x9b, 0x18, 0xb5, 0xeb, 0xbd, 0x8b,
-	0x7f, 0x8e, 0xec, 0xdc, 0x26, 0x28, 0xb8, 0x0a, 0xe2, 0x88, 0x59, 0xb7, 0x89, 0x76, 0xe9, 0x1a,
-	0x53, 0x5c, 0x1b, 0x2f, 0x4e, 0x58, 0x96, 0xd0, 0x17, 0x50, 0x5a, 0xe1, 0x56, 0x0b, 0x97, 0xf9,
-	0x70, 0xb4, 0xc2, 0xed, 0xc4, 0xa7, 0x97, 0x70, 0x8a, 0x91, 0x27, 0xb6, 0x89, 0xfe, 0x7c, 0xc6,
-	0xd7, 0x8b, 0xd8, 0x58, 0x51, 0x3f, 0xf8, 0x83, 0xf1, 0xae, 0x63, 0xb0, 0x5e, 0xc4, 0xac, 0x8e,
-	0x07, 0x39, 0x6d, 0x41, 0xcd, 0x8b, 0xc3, 0x44, 0xa0, 0x34, 0x3e, 0x97, 0x0c, 0xed, 0x3e, 0xe4,
-	0x5e, 0x40, 0x75, 0xb7, 0x23, 0x05, 0x28, 0x0d, 0xd9, 0x78, 0x70, 0x37, 0x6e, 0x14, 0x74, 0x3c,
-	0x1a, 0x5f, 0x8f, 0xef, 0xc6, 0x0d, 0xe2, 0xa6, 0x50, 0x19, 0x2e, 0xd1, 0x5b, 0xc9, 0x4d, 0x48,
-	0xdf, 0x83, 0x6d, 0x76, 0x21, 0x66, 0x97, 0xf3, 0xbd, 0x5d, 0x9e, 0x5b, 0x3a, 0x9a, 0x5a, 0x04,
-	0x6a, 0x19, 0x32, 0xd3, 0xaa, 0xcf, 0x55, 0x6e, 0x42, 0x23, 0x96, 0xcd, 0x74, 0xe8, 0xbe, 0x81,
-	0xea, 0xae, 0x29, 0x63, 0x1d, 0xf6, 0x7b, 0xc3, 0x46, 0x81, 0x1e, 0x43, 0xe5, 0xe1, 0xe1, 0x8a,
-	0xcb, 0xe5, 0xc7, 0x0f, 0x0d, 0xe2, 0x7a, 0x50, 0x1e, 0x71, 0xc5, 0xa7, 0xb8, 0xdd, 0x13, 0x89,
-	0xec, 0x8b, 0x44, 0xc1, 0xf6, 0xb9, 0xe2, 0xf9, 0xd9, 0x9b, 0x58, 0x5b, 0x15, 0xa4, 0xf9, 0xb9,
-	0x5b, 0x41, 0xaa, 0xcf, 0xd9, 0x13, 0xc8, 0x15, 0xfa, 0xfa, 0x9c, 0xb5, 0xc6, 0x45, 0x56, 0xcd,
-	0x91, 0x81, 0x7a, 0x7b, 0x06, 0xf5, 0x43, 0x15, 0x69, 0x19, 0x8a, 0x1c, 0x65, 0xa3, 0x70, 0xd9,
-	0xff, 0xfe, 0xd4, 0x24, 0x3f, 0x9e, 0x9a, 0xe4, 0xd7, 0x53, 0x93, 0x7c, 0xfb, 0xdd, 0x2c, 0x7c,
-	0x7e, 0xbd, 0x08, 0xd4, 0x72, 0x33, 0xef, 0x78, 0x71, 0xd8, 0xf5, 0x17, 0x82, 0x27, 0xcb, 0x77,
-	0x41, 0xdc, 0xcd, 0x34, 0xe8, 0xa6, 0xbd, 0x6e, 0x32, 0x9f, 0x97, 0xcc, 0xab, 0xed, 0xff, 0x09,
-	0x00, 0x00, 0xff, 0xff, 0xa7, 0xb8, 0x6c, 0x4c, 0xc8, 0x03, 0x00, 0x00,
+func (m *BeginRequest) Reset()         { *m = BeginRequest{} }
+func (m *BeginRequest) String() string { return proto.CompactTextString(m) }
+func (*BeginRequest) ProtoMessage()    {}
+func (*BeginRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_e63e84f9f0d3998c, []int{6}
+}
+func (m *BeginRequest) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *BeginRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_BeginRequest.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *BeginRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_BeginRequest.Merge(m, src)
+}
+func (m *BeginRequest) XXX_Size() int {
+	return m.Size()
+}
+func (m *BeginRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_BeginRequest.DiscardUnknown(m)
 }
 
-func (m *KV) Marshal() (dAtA []byte, err error) {
-	size := m.Size()
-	dAtA = make([]byte, size)
-	n, err := m.MarshalToSizedBuffer(dAtA[:size])
-	if err != nil {
-		return nil, err
+var xxx_messageInfo_BeginRequest proto.InternalMessageInfo
+
+func (m *BeginRequest) GetUpdate() bool {
+	if m != nil {
+		return m.Update
 	}
-	return dAtA[:n], nil
+	return false
 }
 
-func (m *KV) MarshalTo(dAtA []byte) (int, error) {
-	size := m.Size()
-	return m.MarshalToSizedBuffer(dAtA[:size])
+type BeginResponse struct {
+	// The transaction ID is only valid on the connection which began the transaction.
+	TxnId                uint64   `protobuf:"varint,1,opt,name=txn_id,json=txnId,proto3" json:"txn_id,omitempty"`
+	ReadTs               uint64   `protobuf:"varint,2,opt,name=read_ts,json=readTs,proto3" json:"read_ts,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
 }
 
-func (m *KV) MarshalToSizedBuffer(dAtA []byte) (int, error) {
-	i := len(dAtA)
-	_ = i
-	var l int
-	_ = l
-	if m.XXX_unrecognized != nil {
-		i -= len(m.XXX_unrecognized)
-		copy(dAtA[i:], m.XXX_unrecognized)
-	}
-	if m.StreamDone {
-		i--
-		if m.StreamDone {
-			dAtA[i] = 1
-		} else {
-			dAtA[i] = 0
+func (m *BeginResponse) Reset()         { *m = BeginResponse{} }
+func (m *BeginResponse) String() string { return proto.CompactTextString(m) }
+func (*BeginResponse) ProtoMessage()    {}
+func (*BeginResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_e63e84f9f0d3998c, []int{7}
+}
+func (m *BeginResponse) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *BeginResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_BeginResponse.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
 		}
-		i--
-		dAtA[i] = 0x58
-	}
-	if m.StreamId != 0 {
-		i = encodeVarintBadgerpb2(dAtA, i, uint64(m.StreamId))
-		i--
-		dAtA[i] = 0x50
-	}
-	if len(m.Meta) > 0 {
-		i -= len(m.Meta)
-		copy(dAtA[i:], m.Meta)
-		i = encodeVarintBadgerpb2(dAtA, i, uint64(len(m.Meta)))
-		i--
-		dAtA[i] = 0x32
-	}
-	if m.ExpiresAt != 0 {
-		i = encodeVarintBadgerpb2(dAtA, i, uint64(m.ExpiresAt))
-		i--
-		dAtA[i] = 0x28
-	}
-	if m.Version != 0 {
-		i = encodeVarintBadgerpb2(dAtA, i, uint64(m.Version))
-		i--
-		dAtA[i] = 0x20
-	}
-	if len(m.UserMeta) > 0 {
-		i -= len(m.UserMeta)
-		copy(dAtA[i:], m.UserMeta)
-		i = encodeVarintBadgerpb2(dAtA, i, uint64(len(m.UserMeta)))
-		i--
-		dAtA[i] = 0x1a
-	}
-	if len(m.Value) > 0 {
-		i -= len(m.Value)
-		copy(dAtA[i:], m.Value)
-		i = encodeVarintBadgerpb2(dAtA, i, uint64(len(m.Value)))
-		i--
-		dAtA[i] = 0x12
+		return b[:n], nil
 	}
-	if len(m.Key) > 0 {
-		i -= len(m.Key)
-		copy(dAtA[i:], m.Key)
-		i = encodeVarintBadgerpb2(dAtA, i, uint64(len(m.Key)))
-		i--
-		dAtA[i] = 0xa
+}
+func (m *BeginResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_BeginResponse.Merge(m, src)
+}
+func (m *BeginResponse) XXX_Size() int {
+	return m.Size()
+}
+func (m *BeginResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_BeginResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_BeginResponse proto.InternalMessageInfo
+
+func (m *BeginResponse) GetTxnId() uint64 {
+	if m != nil {
+		return m.TxnId
 	}
-	return len(dAtA) - i, nil
+	return 0
 }
 
-func (m *KVList) Marshal() (dAtA []byte, err error) {
-	size := m.Size()
-	dAtA = make([]byte, size)
-	n, err := m.MarshalToSizedBuffer(dAtA[:size])
-	if err != nil {
-		return nil, err
+func (m *BeginResponse) GetReadTs() uint64 {
+	if m != nil {
+		return m.ReadTs
 	}
-	return dAtA[:n], nil
+	return 0
 }
 
-func (m *KVList) MarshalTo(dAtA []byte) (int, error) {
-	size := m.Size()
-	return m.MarshalToSizedBuffer(dAtA[:size])
+type GetRequest struct {
+	TxnId                uint64   `protobuf:"varint,1,opt,name=txn_id,json=txnId,proto3" json:"txn_id,omitempty"`
+	Key                  []byte   `protobuf:"bytes,2,opt,name=key,proto3" json:"key,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
 }
 
-func (m *KVList) MarshalToSizedBuffer(dAtA []byte) (int, error) {
-	i := len(dAtA)
-	_ = i
-	var l int
-	_ = l
-	if m.XXX_unrecognized != nil {
-		i -= len(m.XXX_unrecognized)
-		copy(dAtA[i:], m.XXX_unrecognized)
-	}
-	if m.AllocRef != 0 {
-		i = encodeVarintBadgerpb2(dAtA, i, uint64(m.AllocRef))
-		i--
-		dAtA[i] = 0x50
-	}
-	if len(m.Kv) > 0 {
-		for iNdEx := len(m.Kv) - 1; iNdEx >= 0; iNdEx-- {
-			{
-				size, err := m.Kv[iNdEx].MarshalToSizedBuffer(dAtA[:i])
-				if err != nil {
-					return 0, err
-				}
-				i -= size
-				i = encodeVarintBadgerpb2(dAtA, i, uint64(size))
-			}
-			i--
-			dAtA[i] = 0xa
+func (m *GetRequest) Reset()         { *m = GetRequest{} }
+func (m *GetRequest) String() string { return proto.CompactTextString(m) }
+func (*GetRequest) ProtoMessage()    {}
+func (*GetRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_e63e84f9f0d3998c, []int{8}
+}
+func (m *GetRequest) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *GetRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_GetRequest.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
 		}
+		return b[:n], nil
 	}
-	return len(dAtA) - i, nil
+}
+func (m *GetRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetRequest.Merge(m, src)
+}
+func (m *GetRequest) XXX_Size() int {
+	return m.Size()
+}
+func (m *GetRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetRequest.DiscardUnknown(m)
 }
 
-func (m *ManifestChangeSet) Marshal() (dAtA []byte, err error) {
-	size := m.Size()
-	dAtA = make([]byte, size)
-	n, err := m.MarshalToSizedBuffer(dAtA[:size])
-	if err != nil {
-		return nil, err
+var xxx_messageInfo_GetRequest proto.InternalMessageInfo
+
+func (m *GetRequest) GetTxnId() uint64 {
+	if m != nil {
+		return m.TxnId
 	}
-	return dAtA[:n], nil
+	return 0
 }
 
-func (m *ManifestChangeSet) MarshalTo(dAtA []byte) (int, error) {
-	size := m.Size()
-	return m.MarshalToSizedBuffer(dAtA[:size])
+func (m *GetRequest) GetKey() []byte {
+	if m != nil {
+		return m.Key
+	}
+	return nil
 }
 
-func (m *ManifestChangeSet) MarshalToSizedBuffer(dAtA []byte) (int, error) {
-	i := len(dAtA)
-	_ = i
-	var l int
-	_ = l
-	if m.XXX_unrecognized != nil {
-		i -= len(m.XXX_unrecognized)
-		copy(dAtA[i:], m.XXX_unrecognized)
-	}
-	if len(m.Changes) > 0 {
-		for iNdEx := len(m.Changes) - 1; iNdEx >= 0; iNdEx-- {
-			{
-				size, err := m.Changes[iNdEx].MarshalToSizedBuffer(dAtA[:i])
-				if err != nil {
-					return 0, err
-				}
-				i -= size
-				i = encodeVarintBadgerpb2(dAtA, i, uint64(size))
-			}
-			i--
-			dAtA[i] = 0xa
-		}
-	}
-	return len(dAtA) - i, nil
+type GetResponse struct {
+	// The item holds the key, value, user_meta, version and expires_at.
+	Item                 *KV      `protobuf:"bytes,1,opt,name=item,proto3" json:"item,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
 }
 
-func (m *ManifestChange) Marshal() (dAtA []byte, err error) {
-	size := m.Size()
-	dAtA = make([]byte, size)
-	n, err := m.MarshalToSizedBuffer(dAtA[:size])
-	if err != nil {
-		return nil, err
+func (m *GetResponse) Reset()         { *m = GetResponse{} }
+func (m *GetResponse) String() string { return proto.CompactTextString(m) }
+func (*GetResponse) ProtoMessage()    {}
+func (*GetResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_e63e84f9f0d3998c, []int{9}
+}
+func (m *GetResponse) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *GetResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_GetResponse.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
 	}
-	return dAtA[:n], nil
+}
+func (m *GetResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetResponse.Merge(m, src)
+}
+func (m *GetResponse) XXX_Size() int {
+	return m.Size()
+}
+func (m *GetResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetResponse.DiscardUnknown(m)
 }
 
-func (m *ManifestChange) MarshalTo(dAtA []byte) (int, error) {
-	size := m.Size()
-	return m.MarshalToSizedBuffer(dAtA[:size])
+var xxx_messageInfo_GetResponse proto.InternalMessageInfo
+
+func (m *GetResponse) GetItem() *KV {
+	if m != nil {
+		return m.Item
+	}
+	return nil
 }
 
-func (m *ManifestChange) MarshalToSizedBuffer(dAtA []byte) (int, error) {
-	i := len(dAtA)
-	_ = i
-	var l int
-	_ = l
-	if m.XXX_unrecognized != nil {
-		i -= len(m.XXX_unrecognized)
-		copy(dAtA[i:], m.XXX_unrecognized)
-	}
-	if m.Compression != 0 {
-		i = encodeVarintBadgerpb2(dAtA, i, uint64(m.Compression))
-		i--
-		dAtA[i] = 0x30
-	}
-	if m.EncryptionAlgo != 0 {
-		i = encodeVarintBadgerpb2(dAtA, i, uint64(m.EncryptionAlgo))
-		i--
-		dAtA[i] = 0x28
+type SetRequest struct {
+	TxnId                uint64   `protobuf:"varint,1,opt,name=txn_id,json=txnId,proto3" json:"txn_id,omitempty"`
+	Key                  []byte   `protobuf:"bytes,2,opt,name=key,proto3" json:"key,omitempty"`
+	Value                []byte   `protobuf:"bytes,3,opt,name=value,proto3" json:"value,omitempty"`
+	UserMeta             uint32   `protobuf:"varint,4,opt,name=user_meta,json=userMeta,proto3" json:"user_meta,omitempty"`
+	ExpiresAt            uint64   `protobuf:"varint,5,opt,name=expires_at,json=expiresAt,proto3" json:"expires_at,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *SetRequest) Reset()         { *m = SetRequest{} }
+func (m *SetRequest) String() string { return proto.CompactTextString(m) }
+func (*SetRequest) ProtoMessage()    {}
+func (*SetRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_e63e84f9f0d3998c, []int{10}
+}
+func (m *SetRequest) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *SetRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_SetRequest.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
 	}
-	if m.KeyId != 0 {
-		i = encodeVarintBadgerpb2(dAtA, i, uint64(m.KeyId))
-		i--
-		dAtA[i] = 0x20
+}
+func (m *SetRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SetRequest.Merge(m, src)
+}
+func (m *SetRequest) XXX_Size() int {
+	return m.Size()
+}
+func (m *SetRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_SetRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SetRequest proto.InternalMessageInfo
+
+func (m *SetRequest) GetTxnId() uint64 {
+	if m != nil {
+		return m.TxnId
 	}
-	if m.Level != 0 {
-		i = encodeVarintBadgerpb2(dAtA, i, uint64(m.Level))
-		i--
-		dAtA[i] = 0x18
+	return 0
+}
+
+func (m *SetRequest) GetKey() []byte {
+	if m != nil {
+		return m.Key
 	}
-	if m.Op != 0 {
-		i = encodeVarintBadgerpb2(dAtA, i, uint64(m.Op))
-		i--
-		dAtA[i] = 0x10
+	return nil
+}
+
+func (m *SetRequest) GetValue() []byte {
+	if m != nil {
+		return m.Value
 	}
-	if m.Id != 0 {
-		i = encodeVarintBadgerpb2(dAtA, i, uint64(m.Id))
-		i--
-		dAtA[i] = 0x8
+	return nil
+}
+
+func (m *SetRequest) GetUserMeta() uint32 {
+	if m != nil {
+		return m.UserMeta
 	}
-	return len(dAtA) - i, nil
+	return 0
 }
 
-func (m *Checksum) Marshal() (dAtA []byte, err error) {
-	size := m.Size()
-	dAtA = make([]byte, size)
-	n, err := m.MarshalToSizedBuffer(dAtA[:size])
-	if err != nil {
-		return nil, err
+func (m *SetRequest) GetExpiresAt() uint64 {
+	if m != nil {
+		return m.ExpiresAt
 	}
-	return dAtA[:n], nil
+	return 0
 }
 
-func (m *Checksum) MarshalTo(dAtA []byte) (int, error) {
-	size := m.Size()
-	return m.MarshalToSizedBuffer(dAtA[:size])
+type SetResponse struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
 }
 
-func (m *Checksum) MarshalToSizedBuffer(dAtA []byte) (int, error) {
-	i := len(dAtA)
-	_ = i
-	var l int
-	_ = l
-	if m.XXX_unrecognized != nil {
-		i -= len(m.XXX_unrecognized)
-		copy(dAtA[i:], m.XXX_unrecognized)
+func (m *SetResponse) Reset()         { *m = SetResponse{} }
+func (m *SetResponse) String() string { return proto.CompactTextString(m) }
+func (*SetResponse) ProtoMessage()    {}
+func (*SetResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_e63e84f9f0d3998c, []int{11}
+}
+func (m *SetResponse) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *SetResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_SetResponse.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
 	}
-	if m.Sum != 0 {
-		i = encodeVarintBadgerpb2(dAtA, i, uint64(m.Sum))
-		i--
-		dAtA[i] = 0x10
+}
+func (m *SetResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SetResponse.Merge(m, src)
+}
+func (m *SetResponse) XXX_Size() int {
+	return m.Size()
+}
+func (m *SetResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_SetResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SetResponse proto.InternalMessageInfo
+
+type DeleteRequest struct {
+	TxnId                uint64   `protobuf:"varint,1,opt,name=txn_id,json=txnId,proto3" json:"txn_id,omitempty"`
+	Key                  []byte   `protobuf:"bytes,2,opt,name=key,proto3" json:"key,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *DeleteRequest) Reset()         { *m = DeleteRequest{} }
+func (m *DeleteRequest) String() string { return proto.CompactTextString(m) }
+func (*DeleteRequest) ProtoMessage()    {}
+func (*DeleteRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_e63e84f9f0d3998c, []int{12}
+}
+func (m *DeleteRequest) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *DeleteRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_DeleteRequest.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
 	}
-	if m.Algo != 0 {
-		i = encodeVarintBadgerpb2(dAtA, i, uint64(m.Algo))
-		i--
-		dAtA[i] = 0x8
+}
+func (m *DeleteRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_DeleteRequest.Merge(m, src)
+}
+func (m *DeleteRequest) XXX_Size() int {
+	return m.Size()
+}
+func (m *DeleteRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_DeleteRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_DeleteRequest proto.InternalMessageInfo
+
+func (m *DeleteRequest) GetTxnId() uint64 {
+	if m != nil {
+		return m.TxnId
 	}
-	return len(dAtA) - i, nil
+	return 0
 }
 
-func (m *DataKey) Marshal() (dAtA []byte, err error) {
-	size := m.Size()
-	dAtA = make([]byte, size)
-	n, err := m.MarshalToSizedBuffer(dAtA[:size])
-	if err != nil {
-		return nil, err
+func (m *DeleteRequest) GetKey() []byte {
+	if m != nil {
+		return m.Key
 	}
-	return dAtA[:n], nil
+	return nil
 }
 
-func (m *DataKey) MarshalTo(dAtA []byte) (int, error) {
-	size := m.Size()
-	return m.MarshalToSizedBuffer(dAtA[:size])
+type DeleteResponse struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
 }
 
-func (m *DataKey) MarshalToSizedBuffer(dAtA []byte) (int, error) {
-	i := len(dAtA)
-	_ = i
-	var l int
-	_ = l
-	if m.XXX_unrecognized != nil {
-		i -= len(m.XXX_unrecognized)
-		copy(dAtA[i:], m.XXX_unrecognized)
-	}
-	if m.CreatedAt != 0 {
-		i = encodeVarintBadgerpb2(dAtA, i, uint64(m.CreatedAt))
-		i--
-		dAtA[i] = 0x20
-	}
-	if len(m.Iv) > 0 {
-		i -= len(m.Iv)
-		copy(dAtA[i:], m.Iv)
-		i = encodeVarintBadgerpb2(dAtA, i, uint64(len(m.Iv)))
-		i--
-		dAtA[i] = 0x1a
-	}
-	if len(m.Data) > 0 {
-		i -= len(m.Data)
-		copy(dAtA[i:], m.Data)
-		i = encodeVarintBadgerpb2(dAtA, i, uint64(len(m.Data)))
-		i--
-		dAtA[i] = 0x12
-	}
-	if m.KeyId != 0 {
-		i = encodeVarintBadgerpb2(dAtA, i, uint64(m.KeyId))
-		i--
-		dAtA[i] = 0x8
+func (m *DeleteResponse) Reset()         { *m = DeleteResponse{} }
+func (m *DeleteResponse) String() string { return proto.CompactTextString(m) }
+func (*DeleteResponse) ProtoMessage()    {}
+func (*DeleteResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_e63e84f9f0d3998c, []int{13}
+}
+func (m *DeleteResponse) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *DeleteResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_DeleteResponse.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
 	}
-	return len(dAtA) - i, nil
+}
+func (m *DeleteResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_DeleteResponse.Merge(m, src)
+}
+func (m *DeleteResponse) XXX_Size() int {
+	return m.Size()
+}
+func (m *DeleteResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_DeleteResponse.DiscardUnknown(m)
 }
 
-func encodeVarintBadgerpb2(dAtA []byte, offset int, v uint64) int {
-	offset -= sovBadgerpb2(v)
-	base := offset
-	for v >= 1<<7 {
-		dAtA[offset] = uint8(v&0x7f | 0x80)
-		v >>= 7
-		offset++
+var xxx_messageInfo_DeleteResponse proto.InternalMessageInfo
+
+type CommitRequest struct {
+	TxnId                uint64   `protobuf:"varint,1,opt,name=txn_id,json=txnId,proto3" json:"txn_id,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *CommitRequest) Reset()         { *m = CommitRequest{} }
+func (m *CommitRequest) String() string { return proto.CompactTextString(m) }
+func (*CommitRequest) ProtoMessage()    {}
+func (*CommitRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_e63e84f9f0d3998c, []int{14}
+}
+func (m *CommitRequest) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *CommitRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_CommitRequest.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
 	}
-	dAtA[offset] = uint8(v)
-	return base
 }
-func (m *KV) Size() (n int) {
-	if m == nil {
-		return 0
+func (m *CommitRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_CommitRequest.Merge(m, src)
+}
+func (m *CommitRequest) XXX_Size() int {
+	return m.Size()
+}
+func (m *CommitRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_CommitRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_CommitRequest proto.InternalMessageInfo
+
+func (m *CommitRequest) GetTxnId() uint64 {
+	if m != nil {
+		return m.TxnId
 	}
-	var l int
-	_ = l
-	l = len(m.Key)
-	if l > 0 {
-		n += 1 + l + sovBadgerpb2(uint64(l))
+	return 0
+}
+
+type CommitResponse struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *CommitResponse) Reset()         { *m = CommitResponse{} }
+func (m *CommitResponse) String() string { return proto.CompactTextString(m) }
+func (*CommitResponse) ProtoMessage()    {}
+func (*CommitResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_e63e84f9f0d3998c, []int{15}
+}
+func (m *CommitResponse) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *CommitResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_CommitResponse.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
 	}
-	l = len(m.Value)
-	if l > 0 {
-		n += 1 + l + sovBadgerpb2(uint64(l))
+}
+func (m *CommitResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_CommitResponse.Merge(m, src)
+}
+func (m *CommitResponse) XXX_Size() int {
+	return m.Size()
+}
+func (m *CommitResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_CommitResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_CommitResponse proto.InternalMessageInfo
+
+type DiscardRequest struct {
+	TxnId                uint64   `protobuf:"varint,1,opt,name=txn_id,json=txnId,proto3" json:"txn_id,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *DiscardRequest) Reset()         { *m = DiscardRequest{} }
+func (m *DiscardRequest) String() string { return proto.CompactTextString(m) }
+func (*DiscardRequest) ProtoMessage()    {}
+func (*DiscardRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_e63e84f9f0d3998c, []int{16}
+}
+func (m *DiscardRequest) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *DiscardRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_DiscardRequest.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
 	}
-	l = len(m.UserMeta)
-	if l > 0 {
-		n += 1 + l + sovBadgerpb2(uint64(l))
+}
+func (m *DiscardRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_DiscardRequest.Merge(m, src)
+}
+func (m *DiscardRequest) XXX_Size() int {
+	return m.Size()
+}
+func (m *DiscardRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_DiscardRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_DiscardRequest proto.InternalMessageInfo
+
+func (m *DiscardRequest) GetTxnId() uint64 {
+	if m != nil {
+		return m.TxnId
 	}
-	if m.Version != 0 {
-		n += 1 + sovBadgerpb2(uint64(m.Version))
+	return 0
+}
+
+type DiscardResponse struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *DiscardResponse) Reset()         { *m = DiscardResponse{} }
+func (m *DiscardResponse) String() string { return proto.CompactTextString(m) }
+func (*DiscardResponse) ProtoMessage()    {}
+func (*DiscardResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_e63e84f9f0d3998c, []int{17}
+}
+func (m *DiscardResponse) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *DiscardResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_DiscardResponse.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
 	}
-	if m.ExpiresAt != 0 {
-		n += 1 + sovBadgerpb2(uint64(m.ExpiresAt))
+}
+func (m *DiscardResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_DiscardResponse.Merge(m, src)
+}
+func (m *DiscardResponse) XXX_Size() int {
+	return m.Size()
+}
+func (m *DiscardResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_DiscardResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_DiscardResponse proto.InternalMessageInfo
+
+type ScanRequest struct {
+	TxnId  uint64 `protobuf:"varint,1,opt,name=txn_id,json=txnId,proto3" json:"txn_id,omitempty"`
+	Prefix []byte `protobuf:"bytes,2,opt,name=prefix,proto3" json:"prefix,omitempty"`
+	// The key to start from. The scan starts from the prefix if it's smaller.
+	Start                []byte   `protobuf:"bytes,3,opt,name=start,proto3" json:"start,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ScanRequest) Reset()         { *m = ScanRequest{} }
+func (m *ScanRequest) String() string { return proto.CompactTextString(m) }
+func (*ScanRequest) ProtoMessage()    {}
+func (*ScanRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_e63e84f9f0d3998c, []int{18}
+}
+func (m *ScanRequest) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *ScanRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_ScanRequest.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
 	}
-	l = len(m.Meta)
-	if l > 0 {
-		n += 1 + l + sovBadgerpb2(uint64(l))
+}
+func (m *ScanRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ScanRequest.Merge(m, src)
+}
+func (m *ScanRequest) XXX_Size() int {
+	return m.Size()
+}
+func (m *ScanRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_ScanRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ScanRequest proto.InternalMessageInfo
+
+func (m *ScanRequest) GetTxnId() uint64 {
+	if m != nil {
+		return m.TxnId
 	}
-	if m.StreamId != 0 {
-		n += 1 + sovBadgerpb2(uint64(m.StreamId))
+	return 0
+}
+
+func (m *ScanRequest) GetPrefix() []byte {
+	if m != nil {
+		return m.Prefix
 	}
-	if m.StreamDone {
-		n += 2
+	return nil
+}
+
+func (m *ScanRequest) GetStart() []byte {
+	if m != nil {
+		return m.Start
 	}
-	if m.XXX_unrecognized != nil {
-		n += len(m.XXX_unrecognized)
+	return nil
+}
+
+type StreamRequest struct {
+	Prefix               []byte   `protobuf:"bytes,1,opt,name=prefix,proto3" json:"prefix,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *StreamRequest) Reset()         { *m = StreamRequest{} }
+func (m *StreamRequest) String() string { return proto.CompactTextString(m) }
+func (*StreamRequest) ProtoMessage()    {}
+func (*StreamRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_e63e84f9f0d3998c, []int{19}
+}
+func (m *StreamRequest) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *StreamRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_StreamRequest.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
 	}
-	return n
+}
+func (m *StreamRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_StreamRequest.Merge(m, src)
+}
+func (m *StreamRequest) XXX_Size() int {
+	return m.Size()
+}
+func (m *StreamRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_StreamRequest.DiscardUnknown(m)
 }
 
-func (m *KVList) Size() (n int) {
-	if m == nil {
-		return 0
+var xxx_messageInfo_StreamRequest proto.InternalMessageInfo
+
+func (m *StreamRequest) GetPrefix() []byte {
+	if m != nil {
+		return m.Prefix
 	}
-	var l int
-	_ = l
-	if len(m.Kv) > 0 {
-		for _, e := range m.Kv {
-			l = e.Size()
-			n += 1 + l + sovBadgerpb2(uint64(l))
+	return nil
+}
+
+type SubscribeRequest struct {
+	Prefixes             [][]byte `protobuf:"bytes,1,rep,name=prefixes,proto3" json:"prefixes,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *SubscribeRequest) Reset()         { *m = SubscribeRequest{} }
+func (m *SubscribeRequest) String() string { return proto.CompactTextString(m) }
+func (*SubscribeRequest) ProtoMessage()    {}
+func (*SubscribeRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_e63e84f9f0d3998c, []int{20}
+}
+func (m *SubscribeRequest) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *SubscribeRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_SubscribeRequest.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
 		}
+		return b[:n], nil
 	}
-	if m.AllocRef != 0 {
-		n += 1 + sovBadgerpb2(uint64(m.AllocRef))
+}
+func (m *SubscribeRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SubscribeRequest.Merge(m, src)
+}
+func (m *SubscribeRequest) XXX_Size() int {
+	return m.Size()
+}
+func (m *SubscribeRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_SubscribeRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SubscribeRequest proto.InternalMessageInfo
+
+func (m *SubscribeRequest) GetPrefixes() [][]byte {
+	if m != nil {
+		return m.Prefixes
 	}
-	if m.XXX_unrecognized != nil {
-		n += len(m.XXX_unrecognized)
+	return nil
+}
+
+func init() {
+	proto.RegisterEnum("badgerpb2.EncryptionAlgo", EncryptionAlgo_name, EncryptionAlgo_value)
+	proto.RegisterEnum("badgerpb2.ManifestChange_Operation", ManifestChange_Operation_name, ManifestChange_Operation_value)
+	proto.RegisterEnum("badgerpb2.Checksum_Algorithm", Checksum_Algorithm_name, Checksum_Algorithm_value)
+	proto.RegisterType((*KV)(nil), "badgerpb2.KV")
+	proto.RegisterType((*KVList)(nil), "badgerpb2.KVList")
+	proto.RegisterType((*ManifestChangeSet)(nil), "badgerpb2.ManifestChangeSet")
+	proto.RegisterType((*ManifestChange)(nil), "badgerpb2.ManifestChange")
+	proto.RegisterType((*Checksum)(nil), "badgerpb2.Checksum")
+	proto.RegisterType((*DataKey)(nil), "badgerpb2.DataKey")
+	proto.RegisterType((*BeginRequest)(nil), "badgerpb2.BeginRequest")
+	proto.RegisterType((*BeginResponse)(nil), "badgerpb2.BeginResponse")
+	proto.RegisterType((*GetRequest)(nil), "badgerpb2.GetRequest")
+	proto.RegisterType((*GetResponse)(nil), "badgerpb2.GetResponse")
+	proto.RegisterType((*SetRequest)(nil), "badgerpb2.SetRequest")
+	proto.RegisterType((*SetResponse)(nil), "badgerpb2.SetResponse")
+	proto.RegisterType((*DeleteRequest)(nil), "badgerpb2.DeleteRequest")
+	proto.RegisterType((*DeleteResponse)(nil), "badgerpb2.DeleteResponse")
+	proto.RegisterType((*CommitRequest)(nil), "badgerpb2.CommitRequest")
+	proto.RegisterType((*CommitResponse)(nil), "badgerpb2.CommitResponse")
+	proto.RegisterType((*DiscardRequest)(nil), "badgerpb2.DiscardRequest")
+	proto.RegisterType((*DiscardResponse)(nil), "badgerpb2.DiscardResponse")
+	proto.RegisterType((*ScanRequest)(nil), "badgerpb2.ScanRequest")
+	proto.RegisterType((*StreamRequest)(nil), "badgerpb2.StreamRequest")
+	proto.RegisterType((*SubscribeRequest)(nil), "badgerpb2.SubscribeRequest")
+}
+
+func init() { proto.RegisterFile("badgerpb2.proto", fileDescriptor_e63e84f9f0d3998c) }
+
+var fileDescriptor_e63e84f9f0d3998c = []byte{
+	// 987 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x94, 0x56, 0xdd, 0x6e, 0xe2, 0x46,
+	0x14, 0xc6, 0xc6, 0x31, 0x70, 0x08, 0x84, 0x8c, 0xba, 0x59, 0xc2, 0x2a, 0x29, 0xeb, 0x55, 0x77,
+	0xa3, 0x4a, 0x25, 0x5b, 0xd2, 0x5d, 0xb5, 0x52, 0xa5, 0x28, 0x01, 0xb4, 0x1b, 0x25, 0xab, 0x48,
+	0x26, 0x8a, 0x56, 0xbd, 0x41, 0x83, 0x7d, 0x02, 0x16, 0x60, 0xbb, 0x9e, 0x01, 0x25, 0x4f, 0xd0,
+	0xab, 0xde, 0xf7, 0x2d, 0xfa, 0x1a, 0xbd, 0xec, 0x45, 0x1f, 0xa0, 0x4a, 0x5f, 0xa4, 0x9a, 0xf1,
+	0xe0, 0xd8, 0xd9, 0xb0, 0xd1, 0xde, 0xf9, 0xfc, 0x7c, 0xe7, 0x9c, 0xf9, 0xe6, 0x9b, 0x03, 0xb0,
+	0x31, 0xa4, 0xee, 0x08, 0xa3, 0x70, 0xd8, 0x6e, 0x85, 0x51, 0xc0, 0x03, 0x52, 0x4a, 0x1c, 0xd6,
+	0x3f, 0x1a, 0xe8, 0xa7, 0x97, 0xa4, 0x06, 0xf9, 0x09, 0xde, 0xd4, 0xb5, 0xa6, 0xb6, 0xb7, 0x6e,
+	0x8b, 0x4f, 0xf2, 0x15, 0xac, 0x2d, 0xe8, 0x74, 0x8e, 0x75, 0x5d, 0xfa, 0x62, 0x83, 0x3c, 0x83,
+	0xd2, 0x9c, 0x61, 0x34, 0x98, 0x21, 0xa7, 0xf5, 0xbc, 0x8c, 0x14, 0x85, 0xe3, 0x03, 0x72, 0x4a,
+	0xea, 0x50, 0x58, 0x60, 0xc4, 0xbc, 0xc0, 0xaf, 0x1b, 0x4d, 0x6d, 0xcf, 0xb0, 0x97, 0x26, 0xd9,
+	0x01, 0xc0, 0xeb, 0xd0, 0x8b, 0x90, 0x0d, 0x28, 0xaf, 0xaf, 0xc9, 0x60, 0x49, 0x79, 0x8e, 0x38,
+	0x21, 0x60, 0xc8, 0x82, 0xa6, 0x2c, 0x28, 0xbf, 0x45, 0x27, 0xc6, 0x23, 0xa4, 0xb3, 0x81, 0xe7,
+	0xd6, 0xa1, 0xa9, 0xed, 0x55, 0xec, 0x62, 0xec, 0x38, 0x71, 0xc9, 0xd7, 0x50, 0x56, 0x41, 0x37,
+	0xf0, 0xb1, 0x5e, 0x6e, 0x6a, 0x7b, 0x45, 0x1b, 0x62, 0x57, 0x37, 0xf0, 0xd1, 0xea, 0x82, 0x79,
+	0x7a, 0x79, 0xe6, 0x31, 0x4e, 0x76, 0x40, 0x9f, 0x2c, 0xea, 0x5a, 0x33, 0xbf, 0x57, 0x6e, 0x57,
+	0x5a, 0x77, 0x4c, 0x9c, 0x5e, 0xda, 0xfa, 0x64, 0x21, 0xda, 0xd0, 0xe9, 0x34, 0x70, 0x06, 0x11,
+	0x5e, 0xc9, 0x36, 0x86, 0x5d, 0x94, 0x0e, 0x1b, 0xaf, 0xac, 0xf7, 0xb0, 0xf9, 0x81, 0xfa, 0xde,
+	0x15, 0x32, 0xde, 0x19, 0x53, 0x7f, 0x84, 0x7d, 0xe4, 0xe4, 0x00, 0x0a, 0x8e, 0x34, 0x98, 0xaa,
+	0xba, 0x9d, 0xaa, 0x9a, 0x4d, 0xb7, 0x97, 0x99, 0xd6, 0xef, 0x3a, 0x54, 0xb3, 0x31, 0x52, 0x05,
+	0xfd, 0xc4, 0x95, 0x8c, 0x1b, 0xb6, 0x7e, 0xe2, 0x92, 0x03, 0xd0, 0xcf, 0x43, 0xc9, 0x76, 0xb5,
+	0xfd, 0x62, 0x65, 0xc9, 0xd6, 0x79, 0x88, 0x11, 0xe5, 0x5e, 0xe0, 0xdb, 0xfa, 0x79, 0x28, 0x6e,
+	0xe9, 0x0c, 0x17, 0x38, 0x95, 0x77, 0x51, 0xb1, 0x63, 0x83, 0x3c, 0x01, 0x73, 0x82, 0x37, 0x82,
+	0xb8, 0xf8, 0x1e, 0xd6, 0x26, 0x78, 0x73, 0xe2, 0x92, 0x63, 0xd8, 0x40, 0xdf, 0x89, 0x6e, 0x42,
+	0x01, 0x1f, 0xd0, 0xe9, 0x28, 0x90, 0x57, 0x51, 0xcd, 0x9c, 0xa0, 0x97, 0x64, 0x1c, 0x4d, 0x47,
+	0x81, 0x5d, 0xc5, 0x8c, 0x4d, 0x9a, 0x50, 0x76, 0x82, 0x59, 0x18, 0x21, 0x93, 0xf7, 0x6c, 0xca,
+	0xb6, 0x69, 0x97, 0xf5, 0x02, 0x4a, 0xc9, 0x8c, 0x04, 0xc0, 0xec, 0xd8, 0xbd, 0xa3, 0x8b, 0x5e,
+	0x2d, 0x27, 0xbe, 0xbb, 0xbd, 0xb3, 0xde, 0x45, 0xaf, 0xa6, 0x59, 0x0b, 0x28, 0x76, 0xc6, 0xe8,
+	0x4c, 0xd8, 0x7c, 0x46, 0xbe, 0x07, 0x43, 0xce, 0xa2, 0xc9, 0x59, 0x76, 0x52, 0xb3, 0x2c, 0x53,
+	0x5a, 0xa2, 0x75, 0xe4, 0xf1, 0xf1, 0xcc, 0x96, 0xa9, 0x42, 0xae, 0x6c, 0x3e, 0x93, 0x64, 0x19,
+	0xb6, 0xf8, 0xb4, 0xbe, 0x81, 0x52, 0x92, 0x14, 0x77, 0xed, 0x1c, 0xb4, 0x3b, 0xb5, 0x1c, 0x59,
+	0x87, 0xe2, 0xc7, 0x8f, 0xef, 0x29, 0x1b, 0xbf, 0xfd, 0xa1, 0xa6, 0x59, 0x0e, 0x14, 0xba, 0x94,
+	0xd3, 0x53, 0xbc, 0x49, 0x91, 0xa4, 0xa5, 0x49, 0x22, 0x60, 0xb8, 0x94, 0x53, 0x25, 0x7b, 0xf9,
+	0x2d, 0xae, 0xca, 0x5b, 0x28, 0xb9, 0xeb, 0xde, 0x42, 0xc8, 0xd9, 0x89, 0x90, 0x72, 0x74, 0x85,
+	0x9c, 0x05, 0xc7, 0x79, 0xbb, 0xa4, 0x3c, 0x47, 0xdc, 0x7a, 0x09, 0xeb, 0xc7, 0x38, 0xf2, 0x7c,
+	0x1b, 0x7f, 0x9d, 0x23, 0xe3, 0x64, 0x0b, 0xcc, 0x79, 0xe8, 0x52, 0x8e, 0xb2, 0x53, 0xd1, 0x56,
+	0x96, 0x75, 0x08, 0x15, 0x95, 0xc7, 0xc2, 0xc0, 0x67, 0x28, 0x46, 0xe2, 0xd7, 0x7e, 0x6a, 0x24,
+	0x7e, 0xed, 0x9f, 0xb8, 0xe4, 0x29, 0x14, 0x22, 0xa4, 0xee, 0x80, 0x33, 0x75, 0x62, 0x53, 0x98,
+	0x17, 0xcc, 0x7a, 0x03, 0xf0, 0x0e, 0xf9, 0xb2, 0xcd, 0x0a, 0xb4, 0x7a, 0xda, 0x7a, 0xf2, 0xb4,
+	0xad, 0xd7, 0x50, 0x96, 0x30, 0xd5, 0xf5, 0x39, 0x18, 0x1e, 0xc7, 0x99, 0x44, 0x7d, 0xf2, 0x46,
+	0x64, 0xc8, 0xfa, 0x4d, 0x03, 0xe8, 0x7f, 0x79, 0xa7, 0xbb, 0x25, 0x92, 0x5f, 0xb9, 0x44, 0x8c,
+	0xf8, 0x69, 0x27, 0x4b, 0xe4, 0xf3, 0xab, 0xc2, 0xaa, 0x40, 0xb9, 0x7f, 0x37, 0xbb, 0xf5, 0x23,
+	0x54, 0xba, 0x38, 0x45, 0x8e, 0x5f, 0x4c, 0x42, 0x0d, 0xaa, 0x4b, 0xa4, 0xaa, 0xf5, 0x12, 0x2a,
+	0x9d, 0x60, 0x36, 0xf3, 0x1e, 0x39, 0xa6, 0x40, 0x2e, 0xf3, 0x14, 0xf2, 0x15, 0x54, 0xbb, 0x1e,
+	0x73, 0x68, 0xe4, 0x3e, 0x02, 0xdd, 0x84, 0x8d, 0x24, 0x51, 0x61, 0x6d, 0x28, 0xf7, 0x1d, 0xea,
+	0x3f, 0x32, 0xff, 0x16, 0x98, 0x61, 0x84, 0x57, 0xde, 0xb5, 0x3a, 0x82, 0xb2, 0x04, 0xc1, 0x8c,
+	0xd3, 0x88, 0x2f, 0x09, 0x96, 0x86, 0xf5, 0x0a, 0x2a, 0x7d, 0xb9, 0x0b, 0x53, 0x0a, 0x54, 0x70,
+	0x2d, 0x0d, 0xb7, 0x5a, 0x50, 0xeb, 0xcf, 0x87, 0xcc, 0x89, 0xbc, 0x61, 0xc2, 0x60, 0x03, 0x8a,
+	0x71, 0x54, 0x2d, 0xb8, 0x75, 0x3b, 0xb1, 0xbf, 0xdd, 0x86, 0x6a, 0x76, 0x3f, 0x90, 0x02, 0xe4,
+	0x29, 0xb2, 0x5a, 0xae, 0xfd, 0xa7, 0x01, 0xe6, 0xb1, 0x54, 0x0e, 0xf9, 0x19, 0xd6, 0xa4, 0xae,
+	0xc9, 0xd3, 0x94, 0x96, 0xd2, 0x2f, 0xa2, 0x51, 0xff, 0x34, 0xa0, 0xe8, 0xc8, 0x91, 0xb7, 0x90,
+	0x7f, 0x87, 0x9c, 0x3c, 0x49, 0xa5, 0xdc, 0x89, 0xbc, 0xb1, 0x75, 0xdf, 0x9d, 0xc6, 0xf5, 0xef,
+	0xe1, 0xfa, 0x0f, 0xe3, 0xfa, 0x19, 0xdc, 0x21, 0x98, 0xb1, 0x10, 0x48, 0x7a, 0xaa, 0x8c, 0xaa,
+	0x1a, 0xdb, 0x0f, 0x44, 0xd2, 0x05, 0x62, 0x3d, 0x64, 0x0a, 0x64, 0xa4, 0xd4, 0xd8, 0x7e, 0x20,
+	0x92, 0x14, 0x38, 0x86, 0x82, 0x52, 0x05, 0xc9, 0x34, 0xca, 0x48, 0xaa, 0xd1, 0x78, 0x28, 0x94,
+	0xd4, 0x78, 0x03, 0x86, 0x90, 0x11, 0xc9, 0x9c, 0xf3, 0x4e, 0x57, 0x8d, 0xcd, 0xcc, 0xb3, 0x16,
+	0xbf, 0x8c, 0x56, 0xee, 0xb5, 0x46, 0x7e, 0x02, 0x33, 0x56, 0x4a, 0x66, 0xf6, 0x8c, 0x78, 0x56,
+	0x41, 0x0f, 0xa1, 0x94, 0x68, 0x87, 0x3c, 0x4b, 0xa3, 0xef, 0x29, 0x6a, 0x45, 0x81, 0xe3, 0x83,
+	0xbf, 0x6e, 0x77, 0xb5, 0xbf, 0x6f, 0x77, 0xb5, 0x7f, 0x6f, 0x77, 0xb5, 0x3f, 0xfe, 0xdb, 0xcd,
+	0xfd, 0xf2, 0x7c, 0xe4, 0xf1, 0xf1, 0x7c, 0xd8, 0x72, 0x82, 0xd9, 0xbe, 0x3b, 0x8a, 0x68, 0x38,
+	0xfe, 0xce, 0x0b, 0xf6, 0x63, 0xf0, 0xfe, 0xa2, 0xbd, 0x1f, 0x0e, 0x87, 0xa6, 0xfc, 0x07, 0x73,
+	0xf0, 0xff, 0x00, 0x98, 0x13, 0x89, 0xcc, 0xd4, 0x08, 0x00, 0x00,
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion4
+
+// BadgerClient is the client API for Badger service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type BadgerClient interface {
+	Begin(ctx context.Context, in *BeginRequest, opts ...grpc.CallOption) (*BeginResponse, error)
+	Get(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*GetResponse, error)
+	Set(ctx context.Context, in *SetRequest, opts ...grpc.CallOption) (*SetResponse, error)
+	Delete(ctx context.Context, in *DeleteRequest, opts ...grpc.CallOption) (*DeleteResponse, error)
+	Commit(ctx context.Context, in *CommitRequest, opts ...grpc.CallOption) (*CommitResponse, error)
+	Discard(ctx context.Context, in *DiscardRequest, opts ...grpc.CallOption) (*DiscardResponse, error)
+	// Scan streams the items seen by a transaction, in batches.
+	Scan(ctx context.Context, in *ScanRequest, opts ...grpc.CallOption) (Badger_ScanClient, error)
+	// Stream streams the latest version of the keys.
+	Stream(ctx context.Context, in *StreamRequest, opts ...grpc.CallOption) (Badger_StreamClient, error)
+	// Subscribe streams the updates of the keys.
+	Subscribe(ctx context.Context, in *SubscribeRequest, opts ...grpc.CallOption) (Badger_SubscribeClient, error)
+}
+
+type badgerClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewBadgerClient(cc *grpc.ClientConn) BadgerClient {
+	return &badgerClient{cc}
+}
+
+func (c *badgerClient) Begin(ctx context.Context, in *BeginRequest, opts ...grpc.CallOption) (*BeginResponse, error) {
+	out := new(BeginResponse)
+	err := c.cc.Invoke(ctx, "/badgerpb2.Badger/Begin", in, out, opts...)
+	if err != nil {
+		return nil, err
 	}
-	return n
+	return out, nil
 }
 
-func (m *ManifestChangeSet) Size() (n int) {
-	if m == nil {
-		return 0
+func (c *badgerClient) Get(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*GetResponse, error) {
+	out := new(GetResponse)
+	err := c.cc.Invoke(ctx, "/badgerpb2.Badger/Get", in, out, opts...)
+	if err != nil {
+		return nil, err
 	}
-	var l int
-	_ = l
-	if len(m.Changes) > 0 {
-		for _, e := range m.Changes {
-			l = e.Size()
-			n += 1 + l + sovBadgerpb2(uint64(l))
-		}
+	return out, nil
+}
+
+func (c *badgerClient) Set(ctx context.Context, in *SetRequest, opts ...grpc.CallOption) (*SetResponse, error) {
+	out := new(SetResponse)
+	err := c.cc.Invoke(ctx, "/badgerpb2.Badger/Set", in, out, opts...)
+	if err != nil {
+		return nil, err
 	}
-	if m.XXX_unrecognized != nil {
-		n += len(m.XXX_unrecognized)
+	return out, nil
+}
+
+func (c *badgerClient) Delete(ctx context.Context, in *DeleteRequest, opts ...grpc.CallOption) (*DeleteResponse, error) {
+	out := new(DeleteResponse)
+	err := c.cc.Invoke(ctx, "/badgerpb2.Badger/Delete", in, out, opts...)
+	if err != nil {
+		return nil, err
 	}
-	return n
+	return out, nil
 }
 
-func (m *ManifestChange) Size() (n int) {
-	if m == nil {
-		return 0
+func (c *badgerClient) Commit(ctx context.Context, in *CommitRequest, opts ...grpc.CallOption) (*CommitResponse, error) {
+	out := new(CommitResponse)
+	err := c.cc.Invoke(ctx, "/badgerpb2.Badger/Commit", in, out, opts...)
+	if err != nil {
+		return nil, err
 	}
-	var l int
-	_ = l
-	if m.Id != 0 {
-		n += 1 + sovBadgerpb2(uint64(m.Id))
+	return out, nil
+}
+
+func (c *badgerClient) Discard(ctx context.Context, in *DiscardRequest, opts ...grpc.CallOption) (*DiscardResponse, error) {
+	out := new(DiscardResponse)
+	err := c.cc.Invoke(ctx, "/badgerpb2.Badger/Discard", in, out, opts...)
+	if err != nil {
+		return nil, err
 	}
-	if m.Op != 0 {
-		n += 1 + sovBadgerpb2(uint64(m.Op))
+	return out, nil
+}
+
+func (c *badgerClient) Scan(ctx context.Context, in *ScanRequest, opts ...grpc.CallOption) (Badger_ScanClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_Badger_serviceDesc.Streams[0], "/badgerpb2.Badger/Scan", opts...)
+	if err != nil {
+		return nil, err
 	}
-	if m.Level != 0 {
-		n += 1 + sovBadgerpb2(uint64(m.Level))
+	x := &badgerScanClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
 	}
-	if m.KeyId != 0 {
-		n += 1 + sovBadgerpb2(uint64(m.KeyId))
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
 	}
-	if m.EncryptionAlgo != 0 {
-		n += 1 + sovBadgerpb2(uint64(m.EncryptionAlgo))
+	return x, nil
+}
+
+type Badger_ScanClient interface {
+	Recv() (*KVList, error)
+	grpc.ClientStream
+}
+
+type badgerScanClient struct {
+	grpc.ClientStream
+}
+
+func (x *badgerScanClient) Recv() (*KVList, error) {
+	m := new(KVList)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
 	}
-	if m.Compression != 0 {
-		n += 1 + sovBadgerpb2(uint64(m.Compression))
+	return m, nil
+}
+
+func (c *badgerClient) Stream(ctx context.Context, in *StreamRequest, opts ...grpc.CallOption) (Badger_StreamClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_Badger_serviceDesc.Streams[1], "/badgerpb2.Badger/Stream", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &badgerStreamClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Badger_StreamClient interface {
+	Recv() (*KVList, error)
+	grpc.ClientStream
+}
+
+type badgerStreamClient struct {
+	grpc.ClientStream
+}
+
+func (x *badgerStreamClient) Recv() (*KVList, error) {
+	m := new(KVList)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *badgerClient) Subscribe(ctx context.Context, in *SubscribeRequest, opts ...grpc.CallOption) (Badger_SubscribeClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_Badger_serviceDesc.Streams[2], "/badgerpb2.Badger/Subscribe", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &badgerSubscribeClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Badger_SubscribeClient interface {
+	Recv() (*KVList, error)
+	grpc.ClientStream
+}
+
+type badgerSubscribeClient struct {
+	grpc.ClientStream
+}
+
+func (x *badgerSubscribeClient) Recv() (*KVList, error) {
+	m := new(KVList)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// BadgerServer is the server API for Badger service.
+type BadgerServer interface {
+	Begin(context.Context, *BeginRequest) (*BeginResponse, error)
+	Get(context.Context, *GetRequest) (*GetResponse, error)
+	Set(context.Context, *SetRequest) (*SetResponse, error)
+	Delete(context.Context, *DeleteRequest) (*DeleteResponse, error)
+	Commit(context.Context, *CommitRequest) (*CommitResponse, error)
+	Discard(context.Context, *DiscardRequest) (*DiscardResponse, error)
+	// Scan streams the items seen by a transaction, in batches.
+	Scan(*ScanRequest, Badger_ScanServer) error
+	// Stream streams the latest version of the keys.
+	Stream(*StreamRequest, Badger_StreamServer) error
+	// Subscribe streams the updates of the keys.
+	Subscribe(*SubscribeRequest, Badger_SubscribeServer) error
+}
+
+// UnimplementedBadgerServer can be embedded to have forward compatible implementations.
+type UnimplementedBadgerServer struct {
+}
+
+func (*UnimplementedBadgerServer) Begin(ctx context.Context, req *BeginRequest) (*BeginResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Begin not implemented")
+}
+func (*UnimplementedBadgerServer) Get(ctx context.Context, req *GetRequest) (*GetResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Get not implemented")
+}
+func (*UnimplementedBadgerServer) Set(ctx context.Context, req *SetRequest) (*SetResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Set not implemented")
+}
+func (*UnimplementedBadgerServer) Delete(ctx context.Context, req *DeleteRequest) (*DeleteResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Delete not implemented")
+}
+func (*UnimplementedBadgerServer) Commit(ctx context.Context, req *CommitRequest) (*CommitResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Commit not implemented")
+}
+func (*UnimplementedBadgerServer) Discard(ctx context.Context, req *DiscardRequest) (*DiscardResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Discard not implemented")
+}
+func (*UnimplementedBadgerServer) Scan(req *ScanRequest, srv Badger_ScanServer) error {
+	return status.Errorf(codes.Unimplemented, "method Scan not implemented")
+}
+func (*UnimplementedBadgerServer) Stream(req *StreamRequest, srv Badger_StreamServer) error {
+	return status.Errorf(codes.Unimplemented, "method Stream not implemented")
+}
+func (*UnimplementedBadgerServer) Subscribe(req *SubscribeRequest, srv Badger_SubscribeServer) error {
+	return status.Errorf(codes.Unimplemented, "method Subscribe not implemented")
+}
+
+func RegisterBadgerServer(s *grpc.Server, srv BadgerServer) {
+	s.RegisterService(&_Badger_serviceDesc, srv)
+}
+
+func _Badger_Begin_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BeginRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BadgerServer).Begin(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/badgerpb2.Badger/Begin",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BadgerServer).Begin(ctx, req.(*BeginRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Badger_Get_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BadgerServer).Get(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/badgerpb2.Badger/Get",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BadgerServer).Get(ctx, req.(*GetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Badger_Set_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BadgerServer).Set(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/badgerpb2.Badger/Set",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BadgerServer).Set(ctx, req.(*SetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Badger_Delete_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BadgerServer).Delete(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/badgerpb2.Badger/Delete",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BadgerServer).Delete(ctx, req.(*DeleteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Badger_Commit_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CommitRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BadgerServer).Commit(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/badgerpb2.Badger/Commit",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BadgerServer).Commit(ctx, req.(*CommitRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Badger_Discard_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DiscardRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BadgerServer).Discard(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/badgerpb2.Badger/Discard",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BadgerServer).Discard(ctx, req.(*DiscardRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Badger_Scan_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ScanRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(BadgerServer).Scan(m, &badgerScanServer{stream})
+}
+
+type Badger_ScanServer interface {
+	Send(*KVList) error
+	grpc.ServerStream
+}
+
+type badgerScanServer struct {
+	grpc.ServerStream
+}
+
+func (x *badgerScanServer) Send(m *KVList) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Badger_Stream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(BadgerServer).Stream(m, &badgerStreamServer{stream})
+}
+
+type Badger_StreamServer interface {
+	Send(*KVList) error
+	grpc.ServerStream
+}
+
+type badgerStreamServer struct {
+	grpc.ServerStream
+}
+
+func (x *badgerStreamServer) Send(m *KVList) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Badger_Subscribe_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SubscribeRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(BadgerServer).Subscribe(m, &badgerSubscribeServer{stream})
+}
+
+type Badger_SubscribeServer interface {
+	Send(*KVList) error
+	grpc.ServerStream
+}
+
+type badgerSubscribeServer struct {
+	grpc.ServerStream
+}
+
+func (x *badgerSubscribeServer) Send(m *KVList) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+var _Badger_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "badgerpb2.Badger",
+	HandlerType: (*BadgerServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Begin",
+			Handler:    _Badger_Begin_Handler,
+		},
+		{
+			MethodName: "Get",
+			Handler:    _Badger_Get_Handler,
+		},
+		{
+			MethodName: "Set",
+			Handler:    _Badger_Set_Handler,
+		},
+		{
+			MethodName: "Delete",
+			Handler:    _Badger_Delete_Handler,
+		},
+		{
+			MethodName: "Commit",
+			Handler:    _Badger_Commit_Handler,
+		},
+		{
+			MethodName: "Discard",
+			Handler:    _Badger_Discard_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Scan",
+			Handler:       _Badger_Scan_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "Stream",
+			Handler:       _Badger_Stream_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "Subscribe",
+			Handler:       _Badger_Subscribe_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "badgerpb2.proto",
+}
+
+func (m *KV) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
 	}
+	return dAtA[:n], nil
+}
+
+func (m *KV) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *KV) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
 	if m.XXX_unrecognized != nil {
-		n += len(m.XXX_unrecognized)
+		i -= len(m.XXX_unrecognized)
+		copy(dAtA[i:], m.XXX_unrecognized)
+	}
+	if m.StreamDone {
+		i--
+		if m.StreamDone {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
+		}
+		i--
+		dAtA[i] = 0x58
+	}
+	if m.StreamId != 0 {
+		i = encodeVarintBadgerpb2(dAtA, i, uint64(m.StreamId))
+		i--
+		dAtA[i] = 0x50
+	}
+	if len(m.Meta) > 0 {
+		i -= len(m.Meta)
+		copy(dAtA[i:], m.Meta)
+		i = encodeVarintBadgerpb2(dAtA, i, uint64(len(m.Meta)))
+		i--
+		dAtA[i] = 0x32
+	}
+	if m.ExpiresAt != 0 {
+		i = encodeVarintBadgerpb2(dAtA, i, uint64(m.ExpiresAt))
+		i--
+		dAtA[i] = 0x28
+	}
+	if m.Version != 0 {
+		i = encodeVarintBadgerpb2(dAtA, i, uint64(m.Version))
+		i--
+		dAtA[i] = 0x20
+	}
+	if len(m.UserMeta) > 0 {
+		i -= len(m.UserMeta)
+		copy(dAtA[i:], m.UserMeta)
+		i = encodeVarintBadgerpb2(dAtA, i, uint64(len(m.UserMeta)))
+		i--
+		dAtA[i] = 0x1a
+	}
+	if len(m.Value) > 0 {
+		i -= len(m.Value)
+		copy(dAtA[i:], m.Value)
+		i = encodeVarintBadgerpb2(dAtA, i, uint64(len(m.Value)))
+		i--
+		dAtA[i] = 0x12
+	}
+	if len(m.Key) > 0 {
+		i -= len(m.Key)
+		copy(dAtA[i:], m.Key)
+		i = encodeVarintBadgerpb2(dAtA, i, uint64(len(m.Key)))
+		i--
+		dAtA[i] = 0xa
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *KVList) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *KVList) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *KVList) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.XXX_unrecognized != nil {
+		i -= len(m.XXX_unrecognized)
+		copy(dAtA[i:], m.XXX_unrecognized)
+	}
+	if m.AllocRef != 0 {
+		i = encodeVarintBadgerpb2(dAtA, i, uint64(m.AllocRef))
+		i--
+		dAtA[i] = 0x50
+	}
+	if len(m.Kv) > 0 {
+		for iNdEx := len(m.Kv) - 1; iNdEx >= 0; iNdEx-- {
+			{
+				size, err := m.Kv[iNdEx].MarshalToSizedBuffer(dAtA[:i])
+				if err != nil {
+					return 0, err
+				}
+				i -= size
+				i = encodeVarintBadgerpb2(dAtA, i, uint64(size))
+			}
+			i--
+			dAtA[i] = 0xa
+		}
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *ManifestChangeSet) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *ManifestChangeSet) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *ManifestChangeSet) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.XXX_unrecognized != nil {
+		i -= len(m.XXX_unrecognized)
+		copy(dAtA[i:], m.XXX_unrecognized)
+	}
+	if len(m.Changes) > 0 {
+		for iNdEx := len(m.Changes) - 1; iNdEx >= 0; iNdEx-- {
+			{
+				size, err := m.Changes[iNdEx].MarshalToSizedBuffer(dAtA[:i])
+				if err != nil {
+					return 0, err
+				}
+				i -= size
+				i = encodeVarintBadgerpb2(dAtA, i, uint64(size))
+			}
+			i--
+			dAtA[i] = 0xa
+		}
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *ManifestChange) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *ManifestChange) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *ManifestChange) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.XXX_unrecognized != nil {
+		i -= len(m.XXX_unrecognized)
+		copy(dAtA[i:], m.XXX_unrecognized)
+	}
+	if m.Compression != 0 {
+		i = encodeVarintBadgerpb2(dAtA, i, uint64(m.Compression))
+		i--
+		dAtA[i] = 0x30
+	}
+	if m.EncryptionAlgo != 0 {
+		i = encodeVarintBadgerpb2(dAtA, i, uint64(m.EncryptionAlgo))
+		i--
+		dAtA[i] = 0x28
+	}
+	if m.KeyId != 0 {
+		i = encodeVarintBadgerpb2(dAtA, i, uint64(m.KeyId))
+		i--
+		dAtA[i] = 0x20
+	}
+	if m.Level != 0 {
+		i = encodeVarintBadgerpb2(dAtA, i, uint64(m.Level))
+		i--
+		dAtA[i] = 0x18
+	}
+	if m.Op != 0 {
+		i = encodeVarintBadgerpb2(dAtA, i, uint64(m.Op))
+		i--
+		dAtA[i] = 0x10
+	}
+	if m.Id != 0 {
+		i = encodeVarintBadgerpb2(dAtA, i, uint64(m.Id))
+		i--
+		dAtA[i] = 0x8
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *Checksum) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *Checksum) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *Checksum) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.XXX_unrecognized != nil {
+		i -= len(m.XXX_unrecognized)
+		copy(dAtA[i:], m.XXX_unrecognized)
+	}
+	if m.Sum != 0 {
+		i = encodeVarintBadgerpb2(dAtA, i, uint64(m.Sum))
+		i--
+		dAtA[i] = 0x10
+	}
+	if m.Algo != 0 {
+		i = encodeVarintBadgerpb2(dAtA, i, uint64(m.Algo))
+		i--
+		dAtA[i] = 0x8
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *DataKey) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *DataKey) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *DataKey) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.XXX_unrecognized != nil {
+		i -= len(m.XXX_unrecognized)
+		copy(dAtA[i:], m.XXX_unrecognized)
+	}
+	if m.CreatedAt != 0 {
+		i = encodeVarintBadgerpb2(dAtA, i, uint64(m.CreatedAt))
+		i--
+		dAtA[i] = 0x20
+	}
+	if len(m.Iv) > 0 {
+		i -= len(m.Iv)
+		copy(dAtA[i:], m.Iv)
+		i = encodeVarintBadgerpb2(dAtA, i, uint64(len(m.Iv)))
+		i--
+		dAtA[i] = 0x1a
+	}
+	if len(m.Data) > 0 {
+		i -= len(m.Data)
+		copy(dAtA[i:], m.Data)
+		i = encodeVarintBadgerpb2(dAtA, i, uint64(len(m.Data)))
+		i--
+		dAtA[i] = 0x12
+	}
+	if m.KeyId != 0 {
+		i = encodeVarintBadgerpb2(dAtA, i, uint64(m.KeyId))
+		i--
+		dAtA[i] = 0x8
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *BeginRequest) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *BeginRequest) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *BeginRequest) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.XXX_unrecognized != nil {
+		i -= len(m.XXX_unrecognized)
+		copy(dAtA[i:], m.XXX_unrecognized)
+	}
+	if m.Update {
+		i--
+		if m.Update {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
+		}
+		i--
+		dAtA[i] = 0x8
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *BeginResponse) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *BeginResponse) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *BeginResponse) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.XXX_unrecognized != nil {
+		i -= len(m.XXX_unrecognized)
+		copy(dAtA[i:], m.XXX_unrecognized)
+	}
+	if m.ReadTs != 0 {
+		i = encodeVarintBadgerpb2(dAtA, i, uint64(m.ReadTs))
+		i--
+		dAtA[i] = 0x10
+	}
+	if m.TxnId != 0 {
+		i = encodeVarintBadgerpb2(dAtA, i, uint64(m.TxnId))
+		i--
+		dAtA[i] = 0x8
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *GetRequest) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *GetRequest) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *GetRequest) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.XXX_unrecognized != nil {
+		i -= len(m.XXX_unrecognized)
+		copy(dAtA[i:], m.XXX_unrecognized)
+	}
+	if len(m.Key) > 0 {
+		i -= len(m.Key)
+		copy(dAtA[i:], m.Key)
+		i = encodeVarintBadgerpb2(dAtA, i, uint64(len(m.Key)))
+		i--
+		dAtA[i] = 0x12
+	}
+	if m.TxnId != 0 {
+		i = encodeVarintBadgerpb2(dAtA, i, uint64(m.TxnId))
+		i--
+		dAtA[i] = 0x8
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *GetResponse) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *GetResponse) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *GetResponse) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.XXX_unrecognized != nil {
+		i -= len(m.XXX_unrecognized)
+		copy(dAtA[i:], m.XXX_unrecognized)
+	}
+	if m.Item != nil {
+		{
+			size, err := m.Item.MarshalToSizedBuffer(dAtA[:i])
+			if err != nil {
+				return 0, err
+			}
+			i -= size
+			i = encodeVarintBadgerpb2(dAtA, i, uint64(size))
+		}
+		i--
+		dAtA[i] = 0xa
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *SetRequest) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *SetRequest) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *SetRequest) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.XXX_unrecognized != nil {
+		i -= len(m.XXX_unrecognized)
+		copy(dAtA[i:], m.XXX_unrecognized)
+	}
+	if m.ExpiresAt != 0 {
+		i = encodeVarintBadgerpb2(dAtA, i, uint64(m.ExpiresAt))
+		i--
+		dAtA[i] = 0x28
+	}
+	if m.UserMeta != 0 {
+		i = encodeVarintBadgerpb2(dAtA, i, uint64(m.UserMeta))
+		i--
+		dAtA[i] = 0x20
+	}
+	if len(m.Value) > 0 {
+		i -= len(m.Value)
+		copy(dAtA[i:], m.Value)
+		i = encodeVarintBadgerpb2(dAtA, i, uint64(len(m.Value)))
+		i--
+		dAtA[i] = 0x1a
+	}
+	if len(m.Key) > 0 {
+		i -= len(m.Key)
+		copy(dAtA[i:], m.Key)
+		i = encodeVarintBadgerpb2(dAtA, i, uint64(len(m.Key)))
+		i--
+		dAtA[i] = 0x12
+	}
+	if m.TxnId != 0 {
+		i = encodeVarintBadgerpb2(dAtA, i, uint64(m.TxnId))
+		i--
+		dAtA[i] = 0x8
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *SetResponse) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *SetResponse) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *SetResponse) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.XXX_unrecognized != nil {
+		i -= len(m.XXX_unrecognized)
+		copy(dAtA[i:], m.XXX_unrecognized)
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *DeleteRequest) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *DeleteRequest) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *DeleteRequest) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.XXX_unrecognized != nil {
+		i -= len(m.XXX_unrecognized)
+		copy(dAtA[i:], m.XXX_unrecognized)
+	}
+	if len(m.Key) > 0 {
+		i -= len(m.Key)
+		copy(dAtA[i:], m.Key)
+		i = encodeVarintBadgerpb2(dAtA, i, uint64(len(m.Key)))
+		i--
+		dAtA[i] = 0x12
+	}
+	if m.TxnId != 0 {
+		i = encodeVarintBadgerpb2(dAtA, i, uint64(m.TxnId))
+		i--
+		dAtA[i] = 0x8
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *DeleteResponse) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *DeleteResponse) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *DeleteResponse) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.XXX_unrecognized != nil {
+		i -= len(m.XXX_unrecognized)
+		copy(dAtA[i:], m.XXX_unrecognized)
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *CommitRequest) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *CommitRequest) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *CommitRequest) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.XXX_unrecognized != nil {
+		i -= len(m.XXX_unrecognized)
+		copy(dAtA[i:], m.XXX_unrecognized)
+	}
+	if m.TxnId != 0 {
+		i = encodeVarintBadgerpb2(dAtA, i, uint64(m.TxnId))
+		i--
+		dAtA[i] = 0x8
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *CommitResponse) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *CommitResponse) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *CommitResponse) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.XXX_unrecognized != nil {
+		i -= len(m.XXX_unrecognized)
+		copy(dAtA[i:], m.XXX_unrecognized)
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *DiscardRequest) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *DiscardRequest) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *DiscardRequest) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.XXX_unrecognized != nil {
+		i -= len(m.XXX_unrecognized)
+		copy(dAtA[i:], m.XXX_unrecognized)
+	}
+	if m.TxnId != 0 {
+		i = encodeVarintBadgerpb2(dAtA, i, uint64(m.TxnId))
+		i--
+		dAtA[i] = 0x8
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *DiscardResponse) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *DiscardResponse) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *DiscardResponse) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.XXX_unrecognized != nil {
+		i -= len(m.XXX_unrecognized)
+		copy(dAtA[i:], m.XXX_unrecognized)
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *ScanRequest) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *ScanRequest) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *ScanRequest) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.XXX_unrecognized != nil {
+		i -= len(m.XXX_unrecognized)
+		copy(dAtA[i:], m.XXX_unrecognized)
+	}
+	if len(m.Start) > 0 {
+		i -= len(m.Start)
+		copy(dAtA[i:], m.Start)
+		i = encodeVarintBadgerpb2(dAtA, i, uint64(len(m.Start)))
+		i--
+		dAtA[i] = 0x1a
+	}
+	if len(m.Prefix) > 0 {
+		i -= len(m.Prefix)
+		copy(dAtA[i:], m.Prefix)
+		i = encodeVarintBadgerpb2(dAtA, i, uint64(len(m.Prefix)))
+		i--
+		dAtA[i] = 0x12
+	}
+	if m.TxnId != 0 {
+		i = encodeVarintBadgerpb2(dAtA, i, uint64(m.TxnId))
+		i--
+		dAtA[i] = 0x8
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *StreamRequest) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *StreamRequest) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *StreamRequest) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.XXX_unrecognized != nil {
+		i -= len(m.XXX_unrecognized)
+		copy(dAtA[i:], m.XXX_unrecognized)
+	}
+	if len(m.Prefix) > 0 {
+		i -= len(m.Prefix)
+		copy(dAtA[i:], m.Prefix)
+		i = encodeVarintBadgerpb2(dAtA, i, uint64(len(m.Prefix)))
+		i--
+		dAtA[i] = 0xa
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *SubscribeRequest) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *SubscribeRequest) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *SubscribeRequest) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.XXX_unrecognized != nil {
+		i -= len(m.XXX_unrecognized)
+		copy(dAtA[i:], m.XXX_unrecognized)
+	}
+	if len(m.Prefixes) > 0 {
+		for iNdEx := len(m.Prefixes) - 1; iNdEx >= 0; iNdEx-- {
+			i -= len(m.Prefixes[iNdEx])
+			copy(dAtA[i:], m.Prefixes[iNdEx])
+			i = encodeVarintBadgerpb2(dAtA, i, uint64(len(m.Prefixes[iNdEx])))
+			i--
+			dAtA[i] = 0xa
+		}
+	}
+	return len(dAtA) - i, nil
+}
+
+func encodeVarintBadgerpb2(dAtA []byte, offset int, v uint64) int {
+	offset -= sovBadgerpb2(v)
+	base := offset
+	for v >= 1<<7 {
+		dAtA[offset] = uint8(v&0x7f | 0x80)
+		v >>= 7
+		offset++
+	}
+	dAtA[offset] = uint8(v)
+	return base
+}
+func (m *KV) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	l = len(m.Key)
+	if l > 0 {
+		n += 1 + l + sovBadgerpb2(uint64(l))
+	}
+	l = len(m.Value)
+	if l > 0 {
+		n += 1 + l + sovBadgerpb2(uint64(l))
+	}
+	l = len(m.UserMeta)
+	if l > 0 {
+		n += 1 + l + sovBadgerpb2(uint64(l))
+	}
+	if m.Version != 0 {
+		n += 1 + sovBadgerpb2(uint64(m.Version))
+	}
+	if m.ExpiresAt != 0 {
+		n += 1 + sovBadgerpb2(uint64(m.ExpiresAt))
+	}
+	l = len(m.Meta)
+	if l > 0 {
+		n += 1 + l + sovBadgerpb2(uint64(l))
+	}
+	if m.StreamId != 0 {
+		n += 1 + sovBadgerpb2(uint64(m.StreamId))
+	}
+	if m.StreamDone {
+		n += 2
+	}
+	if m.XXX_unrecognized != nil {
+		n += len(m.XXX_unrecognized)
+	}
+	return n
+}
+
+func (m *KVList) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if len(m.Kv) > 0 {
+		for _, e := range m.Kv {
+			l = e.Size()
+			n += 1 + l + sovBadgerpb2(uint64(l))
+		}
+	}
+	if m.AllocRef != 0 {
+		n += 1 + sovBadgerpb2(uint64(m.AllocRef))
+	}
+	if m.XXX_unrecognized != nil {
+		n += len(m.XXX_unrecognized)
+	}
+	return n
+}
+
+func (m *ManifestChangeSet) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if len(m.Changes) > 0 {
+		for _, e := range m.Changes {
+			l = e.Size()
+			n += 1 + l + sovBadgerpb2(uint64(l))
+		}
+	}
+	if m.XXX_unrecognized != nil {
+		n += len(m.XXX_unrecognized)
+	}
+	return n
+}
+
+func (m *ManifestChange) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.Id != 0 {
+		n += 1 + sovBadgerpb2(uint64(m.Id))
+	}
+	if m.Op != 0 {
+		n += 1 + sovBadgerpb2(uint64(m.Op))
+	}
+	if m.Level != 0 {
+		n += 1 + sovBadgerpb2(uint64(m.Level))
+	}
+	if m.KeyId != 0 {
+		n += 1 + sovBadgerpb2(uint64(m.KeyId))
+	}
+	if m.EncryptionAlgo != 0 {
+		n += 1 + sovBadgerpb2(uint64(m.EncryptionAlgo))
+	}
+	if m.Compression != 0 {
+		n += 1 + sovBadgerpb2(uint64(m.Compression))
+	}
+	if m.XXX_unrecognized != nil {
+		n += len(m.XXX_unrecognized)
+	}
+	return n
+}
+
+func (m *Checksum) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.Algo != 0 {
+		n += 1 + sovBadgerpb2(uint64(m.Algo))
+	}
+	if m.Sum != 0 {
+		n += 1 + sovBadgerpb2(uint64(m.Sum))
+	}
+	if m.XXX_unrecognized != nil {
+		n += len(m.XXX_unrecognized)
+	}
+	return n
+}
+
+func (m *DataKey) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.KeyId != 0 {
+		n += 1 + sovBadgerpb2(uint64(m.KeyId))
+	}
+	l = len(m.Data)
+	if l > 0 {
+		n += 1 + l + sovBadgerpb2(uint64(l))
+	}
+	l = len(m.Iv)
+	if l > 0 {
+		n += 1 + l + sovBadgerpb2(uint64(l))
+	}
+	if m.CreatedAt != 0 {
+		n += 1 + sovBadgerpb2(uint64(m.CreatedAt))
+	}
+	if m.XXX_unrecognized != nil {
+		n += len(m.XXX_unrecognized)
+	}
+	return n
+}
+
+func (m *BeginRequest) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.Update {
+		n += 2
+	}
+	if m.XXX_unrecognized != nil {
+		n += len(m.XXX_unrecognized)
+	}
+	return n
+}
+
+func (m *BeginResponse) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.TxnId != 0 {
+		n += 1 + sovBadgerpb2(uint64(m.TxnId))
+	}
+	if m.ReadTs != 0 {
+		n += 1 + sovBadgerpb2(uint64(m.ReadTs))
+	}
+	if m.XXX_unrecognized != nil {
+		n += len(m.XXX_unrecognized)
+	}
+	return n
+}
+
+func (m *GetRequest) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.TxnId != 0 {
+		n += 1 + sovBadgerpb2(uint64(m.TxnId))
+	}
+	l = len(m.Key)
+	if l > 0 {
+		n += 1 + l + sovBadgerpb2(uint64(l))
+	}
+	if m.XXX_unrecognized != nil {
+		n += len(m.XXX_unrecognized)
+	}
+	return n
+}
+
+func (m *GetResponse) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.Item != nil {
+		l = m.Item.Size()
+		n += 1 + l + sovBadgerpb2(uint64(l))
+	}
+	if m.XXX_unrecognized != nil {
+		n += len(m.XXX_unrecognized)
+	}
+	return n
+}
+
+func (m *SetRequest) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.TxnId != 0 {
+		n += 1 + sovBadgerpb2(uint64(m.TxnId))
+	}
+	l = len(m.Key)
+	if l > 0 {
+		n += 1 + l + sovBadgerpb2(uint64(l))
+	}
+	l = len(m.Value)
+	if l > 0 {
+		n += 1 + l + sovBadgerpb2(uint64(l))
+	}
+	if m.UserMeta != 0 {
+		n += 1 + sovBadgerpb2(uint64(m.UserMeta))
+	}
+	if m.ExpiresAt != 0 {
+		n += 1 + sovBadgerpb2(uint64(m.ExpiresAt))
+	}
+	if m.XXX_unrecognized != nil {
+		n += len(m.XXX_unrecognized)
+	}
+	return n
+}
+
+func (m *SetResponse) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.XXX_unrecognized != nil {
+		n += len(m.XXX_unrecognized)
+	}
+	return n
+}
+
+func (m *DeleteRequest) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.TxnId != 0 {
+		n += 1 + sovBadgerpb2(uint64(m.TxnId))
+	}
+	l = len(m.Key)
+	if l > 0 {
+		n += 1 + l + sovBadgerpb2(uint64(l))
+	}
+	if m.XXX_unrecognized != nil {
+		n += len(m.XXX_unrecognized)
+	}
+	return n
+}
+
+func (m *DeleteResponse) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.XXX_unrecognized != nil {
+		n += len(m.XXX_unrecognized)
+	}
+	return n
+}
+
+func (m *CommitRequest) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.TxnId != 0 {
+		n += 1 + sovBadgerpb2(uint64(m.TxnId))
+	}
+	if m.XXX_unrecognized != nil {
+		n += len(m.XXX_unrecognized)
+	}
+	return n
+}
+
+func (m *CommitResponse) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.XXX_unrecognized != nil {
+		n += len(m.XXX_unrecognized)
+	}
+	return n
+}
+
+func (m *DiscardRequest) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.TxnId != 0 {
+		n += 1 + sovBadgerpb2(uint64(m.TxnId))
+	}
+	if m.XXX_unrecognized != nil {
+		n += len(m.XXX_unrecognized)
+	}
+	return n
+}
+
+func (m *DiscardResponse) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.XXX_unrecognized != nil {
+		n += len(m.XXX_unrecognized)
+	}
+	return n
+}
+
+func (m *ScanRequest) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.TxnId != 0 {
+		n += 1 + sovBadgerpb2(uint64(m.TxnId))
+	}
+	l = len(m.Prefix)
+	if l > 0 {
+		n += 1 + l + sovBadgerpb2(uint64(l))
+	}
+	l = len(m.Start)
+	if l > 0 {
+		n += 1 + l + sovBadgerpb2(uint64(l))
+	}
+	if m.XXX_unrecognized != nil {
+		n += len(m.XXX_unrecognized)
+	}
+	return n
+}
+
+func (m *StreamRequest) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	l = len(m.Prefix)
+	if l > 0 {
+		n += 1 + l + sovBadgerpb2(uint64(l))
+	}
+	if m.XXX_unrecognized != nil {
+		n += len(m.XXX_unrecognized)
+	}
+	return n
+}
+
+func (m *SubscribeRequest) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if len(m.Prefixes) > 0 {
+		for _, b := range m.Prefixes {
+			l = len(b)
+			n += 1 + l + sovBadgerpb2(uint64(l))
+		}
+	}
+	if m.XXX_unrecognized != nil {
+		n += len(m.XXX_unrecognized)
+	}
+	return n
+}
+
+func sovBadgerpb2(x uint64) (n int) {
+	return (math_bits.Len64(x|1) + 6) / 7
+}
+func sozBadgerpb2(x uint64) (n int) {
+	return sovBadgerpb2(uint64((x << 1) ^ uint64((int64(x) >> 63))))
+}
+func (m *KV) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowBadgerpb2
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: KV: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: KV: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Key", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowBadgerpb2
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthBadgerpb2
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex < 0 {
+				return ErrInvalidLengthBadgerpb2
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Key = append(m.Key[:0], dAtA[iNdEx:postIndex]...)
+			if m.Key == nil {
+				m.Key = []byte{}
+			}
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Value", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowBadgerpb2
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthBadgerpb2
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex < 0 {
+				return ErrInvalidLengthBadgerpb2
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Value = append(m.Value[:0], dAtA[iNdEx:postIndex]...)
+			if m.Value == nil {
+				m.Value = []byte{}
+			}
+			iNdEx = postIndex
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field UserMeta", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowBadgerpb2
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthBadgerpb2
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex < 0 {
+				return ErrInvalidLengthBadgerpb2
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.UserMeta = append(m.UserMeta[:0], dAtA[iNdEx:postIndex]...)
+			if m.UserMeta == nil {
+				m.UserMeta = []byte{}
+			}
+			iNdEx = postIndex
+		case 4:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Version", wireType)
+			}
+			m.Version = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowBadgerpb2
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Version |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 5:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ExpiresAt", wireType)
+			}
+			m.ExpiresAt = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowBadgerpb2
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.ExpiresAt |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 6:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Meta", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowBadgerpb2
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthBadgerpb2
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex < 0 {
+				return ErrInvalidLengthBadgerpb2
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Meta = append(m.Meta[:0], dAtA[iNdEx:postIndex]...)
+			if m.Meta == nil {
+				m.Meta = []byte{}
+			}
+			iNdEx = postIndex
+		case 10:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field StreamId", wireType)
+			}
+			m.StreamId = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowBadgerpb2
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.StreamId |= uint32(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 11:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field StreamDone", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowBadgerpb2
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.StreamDone = bool(v != 0)
+		default:
+			iNdEx = preIndex
+			skippy, err := skipBadgerpb2(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthBadgerpb2
+			}
+			if (iNdEx + skippy) < 0 {
+				return ErrInvalidLengthBadgerpb2
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.XXX_unrecognized = append(m.XXX_unrecognized, dAtA[iNdEx:iNdEx+skippy]...)
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *KVList) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowBadgerpb2
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: KVList: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: KVList: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Kv", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowBadgerpb2
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthBadgerpb2
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthBadgerpb2
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Kv = append(m.Kv, &KV{})
+			if err := m.Kv[len(m.Kv)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 10:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field AllocRef", wireType)
+			}
+			m.AllocRef = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowBadgerpb2
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.AllocRef |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		default:
+			iNdEx = preIndex
+			skippy, err := skipBadgerpb2(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthBadgerpb2
+			}
+			if (iNdEx + skippy) < 0 {
+				return ErrInvalidLengthBadgerpb2
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.XXX_unrecognized = append(m.XXX_unrecognized, dAtA[iNdEx:iNdEx+skippy]...)
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *ManifestChangeSet) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowBadgerpb2
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: ManifestChangeSet: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: ManifestChangeSet: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Changes", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowBadgerpb2
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthBadgerpb2
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthBadgerpb2
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Changes = append(m.Changes, &ManifestChange{})
+			if err := m.Changes[len(m.Changes)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipBadgerpb2(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthBadgerpb2
+			}
+			if (iNdEx + skippy) < 0 {
+				return ErrInvalidLengthBadgerpb2
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.XXX_unrecognized = append(m.XXX_unrecognized, dAtA[iNdEx:iNdEx+skippy]...)
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *ManifestChange) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowBadgerpb2
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: ManifestChange: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: ManifestChange: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Id", wireType)
+			}
+			m.Id = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowBadgerpb2
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Id |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Op", wireType)
+			}
+			m.Op = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowBadgerpb2
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Op |= ManifestChange_Operation(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 3:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Level", wireType)
+			}
+			m.Level = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowBadgerpb2
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Level |= uint32(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 4:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field KeyId", wireType)
+			}
+			m.KeyId = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowBadgerpb2
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.KeyId |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 5:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field EncryptionAlgo", wireType)
+			}
+			m.EncryptionAlgo = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowBadgerpb2
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.EncryptionAlgo |= EncryptionAlgo(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 6:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Compression", wireType)
+			}
+			m.Compression = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowBadgerpb2
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Compression |= uint32(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		default:
+			iNdEx = preIndex
+			skippy, err := skipBadgerpb2(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthBadgerpb2
+			}
+			if (iNdEx + skippy) < 0 {
+				return ErrInvalidLengthBadgerpb2
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.XXX_unrecognized = append(m.XXX_unrecognized, dAtA[iNdEx:iNdEx+skippy]...)
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *Checksum) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowBadgerpb2
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: Checksum: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: Checksum: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Algo", wireType)
+			}
+			m.Algo = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowBadgerpb2
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Algo |= Checksum_Algorithm(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Sum", wireType)
+			}
+			m.Sum = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowBadgerpb2
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Sum |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		default:
+			iNdEx = preIndex
+			skippy, err := skipBadgerpb2(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthBadgerpb2
+			}
+			if (iNdEx + skippy) < 0 {
+				return ErrInvalidLengthBadgerpb2
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.XXX_unrecognized = append(m.XXX_unrecognized, dAtA[iNdEx:iNdEx+skippy]...)
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *DataKey) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowBadgerpb2
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: DataKey: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: DataKey: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field KeyId", wireType)
+			}
+			m.KeyId = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowBadgerpb2
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.KeyId |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Data", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowBadgerpb2
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthBadgerpb2
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex < 0 {
+				return ErrInvalidLengthBadgerpb2
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Data = append(m.Data[:0], dAtA[iNdEx:postIndex]...)
+			if m.Data == nil {
+				m.Data = []byte{}
+			}
+			iNdEx = postIndex
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Iv", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowBadgerpb2
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthBadgerpb2
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex < 0 {
+				return ErrInvalidLengthBadgerpb2
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Iv = append(m.Iv[:0], dAtA[iNdEx:postIndex]...)
+			if m.Iv == nil {
+				m.Iv = []byte{}
+			}
+			iNdEx = postIndex
+		case 4:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field CreatedAt", wireType)
+			}
+			m.CreatedAt = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowBadgerpb2
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.CreatedAt |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		default:
+			iNdEx = preIndex
+			skippy, err := skipBadgerpb2(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthBadgerpb2
+			}
+			if (iNdEx + skippy) < 0 {
+				return ErrInvalidLengthBadgerpb2
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.XXX_unrecognized = append(m.XXX_unrecognized, dAtA[iNdEx:iNdEx+skippy]...)
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *BeginRequest) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowBadgerpb2
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: BeginRequest: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: BeginRequest: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Update", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowBadgerpb2
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.Update = bool(v != 0)
+		default:
+			iNdEx = preIndex
+			skippy, err := skipBadgerpb2(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthBadgerpb2
+			}
+			if (iNdEx + skippy) < 0 {
+				return ErrInvalidLengthBadgerpb2
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.XXX_unrecognized = append(m.XXX_unrecognized, dAtA[iNdEx:iNdEx+skippy]...)
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *BeginResponse) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowBadgerpb2
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: BeginResponse: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: BeginResponse: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field TxnId", wireType)
+			}
+			m.TxnId = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowBadgerpb2
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.TxnId |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ReadTs", wireType)
+			}
+			m.ReadTs = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowBadgerpb2
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.ReadTs |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		default:
+			iNdEx = preIndex
+			skippy, err := skipBadgerpb2(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthBadgerpb2
+			}
+			if (iNdEx + skippy) < 0 {
+				return ErrInvalidLengthBadgerpb2
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.XXX_unrecognized = append(m.XXX_unrecognized, dAtA[iNdEx:iNdEx+skippy]...)
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *GetRequest) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowBadgerpb2
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: GetRequest: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: GetRequest: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field TxnId", wireType)
+			}
+			m.TxnId = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowBadgerpb2
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.TxnId |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Key", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowBadgerpb2
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthBadgerpb2
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex < 0 {
+				return ErrInvalidLengthBadgerpb2
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Key = append(m.Key[:0], dAtA[iNdEx:postIndex]...)
+			if m.Key == nil {
+				m.Key = []byte{}
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipBadgerpb2(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthBadgerpb2
+			}
+			if (iNdEx + skippy) < 0 {
+				return ErrInvalidLengthBadgerpb2
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.XXX_unrecognized = append(m.XXX_unrecognized, dAtA[iNdEx:iNdEx+skippy]...)
+			iNdEx += skippy
+		}
 	}
-	return n
-}
 
-func (m *Checksum) Size() (n int) {
-	if m == nil {
-		return 0
-	}
-	var l int
-	_ = l
-	if m.Algo != 0 {
-		n += 1 + sovBadgerpb2(uint64(m.Algo))
-	}
-	if m.Sum != 0 {
-		n += 1 + sovBadgerpb2(uint64(m.Sum))
-	}
-	if m.XXX_unrecognized != nil {
-		n += len(m.XXX_unrecognized)
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
 	}
-	return n
+	return nil
 }
-
-func (m *DataKey) Size() (n int) {
-	if m == nil {
-		return 0
-	}
-	var l int
-	_ = l
-	if m.KeyId != 0 {
-		n += 1 + sovBadgerpb2(uint64(m.KeyId))
-	}
-	l = len(m.Data)
-	if l > 0 {
-		n += 1 + l + sovBadgerpb2(uint64(l))
-	}
-	l = len(m.Iv)
-	if l > 0 {
-		n += 1 + l + sovBadgerpb2(uint64(l))
-	}
-	if m.CreatedAt != 0 {
-		n += 1 + sovBadgerpb2(uint64(m.CreatedAt))
-	}
-	if m.XXX_unrecognized != nil {
-		n += len(m.XXX_unrecognized)
+func (m *GetResponse) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowBadgerpb2
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: GetResponse: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: GetResponse: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Item", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowBadgerpb2
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthBadgerpb2
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthBadgerpb2
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.Item == nil {
+				m.Item = &KV{}
+			}
+			if err := m.Item.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipBadgerpb2(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthBadgerpb2
+			}
+			if (iNdEx + skippy) < 0 {
+				return ErrInvalidLengthBadgerpb2
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.XXX_unrecognized = append(m.XXX_unrecognized, dAtA[iNdEx:iNdEx+skippy]...)
+			iNdEx += skippy
+		}
 	}
-	return n
-}
 
-func sovBadgerpb2(x uint64) (n int) {
-	return (math_bits.Len64(x|1) + 6) / 7
-}
-func sozBadgerpb2(x uint64) (n int) {
-	return sovBadgerpb2(uint64((x << 1) ^ uint64((int64(x) >> 63))))
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
 }
-func (m *KV) Unmarshal(dAtA []byte) error {
+func (m *SetRequest) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -1077,13 +4355,32 @@ func (m *KV) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: KV: wiretype end group for non-group")
+			return fmt.Errorf("proto: SetRequest: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: KV: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: SetRequest: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field TxnId", wireType)
+			}
+			m.TxnId = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowBadgerpb2
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.TxnId |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 2:
 			if wireType != 2 {
 				return fmt.Errorf("proto: wrong wireType = %d for field Key", wireType)
 			}
@@ -1117,7 +4414,7 @@ func (m *KV) Unmarshal(dAtA []byte) error {
 				m.Key = []byte{}
 			}
 			iNdEx = postIndex
-		case 2:
+		case 3:
 			if wireType != 2 {
 				return fmt.Errorf("proto: wrong wireType = %d for field Value", wireType)
 			}
@@ -1151,11 +4448,11 @@ func (m *KV) Unmarshal(dAtA []byte) error {
 				m.Value = []byte{}
 			}
 			iNdEx = postIndex
-		case 3:
-			if wireType != 2 {
+		case 4:
+			if wireType != 0 {
 				return fmt.Errorf("proto: wrong wireType = %d for field UserMeta", wireType)
 			}
-			var byteLen int
+			m.UserMeta = 0
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowBadgerpb2
@@ -1165,50 +4462,143 @@ func (m *KV) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				byteLen |= int(b&0x7F) << shift
+				m.UserMeta |= uint32(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			if byteLen < 0 {
+		case 5:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ExpiresAt", wireType)
+			}
+			m.ExpiresAt = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowBadgerpb2
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.ExpiresAt |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		default:
+			iNdEx = preIndex
+			skippy, err := skipBadgerpb2(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
 				return ErrInvalidLengthBadgerpb2
 			}
-			postIndex := iNdEx + byteLen
-			if postIndex < 0 {
+			if (iNdEx + skippy) < 0 {
+				return ErrInvalidLengthBadgerpb2
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.XXX_unrecognized = append(m.XXX_unrecognized, dAtA[iNdEx:iNdEx+skippy]...)
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *SetResponse) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowBadgerpb2
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: SetResponse: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: SetResponse: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		default:
+			iNdEx = preIndex
+			skippy, err := skipBadgerpb2(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthBadgerpb2
+			}
+			if (iNdEx + skippy) < 0 {
 				return ErrInvalidLengthBadgerpb2
 			}
-			if postIndex > l {
+			if (iNdEx + skippy) > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.UserMeta = append(m.UserMeta[:0], dAtA[iNdEx:postIndex]...)
-			if m.UserMeta == nil {
-				m.UserMeta = []byte{}
+			m.XXX_unrecognized = append(m.XXX_unrecognized, dAtA[iNdEx:iNdEx+skippy]...)
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *DeleteRequest) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowBadgerpb2
 			}
-			iNdEx = postIndex
-		case 4:
-			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Version", wireType)
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
 			}
-			m.Version = 0
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowBadgerpb2
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				m.Version |= uint64(b&0x7F) << shift
-				if b < 0x80 {
-					break
-				}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
 			}
-		case 5:
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: DeleteRequest: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: DeleteRequest: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
 			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field ExpiresAt", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field TxnId", wireType)
 			}
-			m.ExpiresAt = 0
+			m.TxnId = 0
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowBadgerpb2
@@ -1218,14 +4608,14 @@ func (m *KV) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				m.ExpiresAt |= uint64(b&0x7F) << shift
+				m.TxnId |= uint64(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-		case 6:
+		case 2:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Meta", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Key", wireType)
 			}
 			var byteLen int
 			for shift := uint(0); ; shift += 7 {
@@ -1252,50 +4642,65 @@ func (m *KV) Unmarshal(dAtA []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.Meta = append(m.Meta[:0], dAtA[iNdEx:postIndex]...)
-			if m.Meta == nil {
-				m.Meta = []byte{}
+			m.Key = append(m.Key[:0], dAtA[iNdEx:postIndex]...)
+			if m.Key == nil {
+				m.Key = []byte{}
 			}
 			iNdEx = postIndex
-		case 10:
-			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field StreamId", wireType)
+		default:
+			iNdEx = preIndex
+			skippy, err := skipBadgerpb2(dAtA[iNdEx:])
+			if err != nil {
+				return err
 			}
-			m.StreamId = 0
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowBadgerpb2
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				m.StreamId |= uint32(b&0x7F) << shift
-				if b < 0x80 {
-					break
-				}
+			if skippy < 0 {
+				return ErrInvalidLengthBadgerpb2
 			}
-		case 11:
-			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field StreamDone", wireType)
+			if (iNdEx + skippy) < 0 {
+				return ErrInvalidLengthBadgerpb2
 			}
-			var v int
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowBadgerpb2
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				v |= int(b&0x7F) << shift
-				if b < 0x80 {
-					break
-				}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
 			}
-			m.StreamDone = bool(v != 0)
+			m.XXX_unrecognized = append(m.XXX_unrecognized, dAtA[iNdEx:iNdEx+skippy]...)
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *DeleteResponse) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowBadgerpb2
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: DeleteResponse: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: DeleteResponse: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
 		default:
 			iNdEx = preIndex
 			skippy, err := skipBadgerpb2(dAtA[iNdEx:])
@@ -1321,7 +4726,7 @@ func (m *KV) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *KVList) Unmarshal(dAtA []byte) error {
+func (m *CommitRequest) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -1344,17 +4749,17 @@ func (m *KVList) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: KVList: wiretype end group for non-group")
+			return fmt.Errorf("proto: CommitRequest: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: KVList: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: CommitRequest: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Kv", wireType)
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field TxnId", wireType)
 			}
-			var msglen int
+			m.TxnId = 0
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowBadgerpb2
@@ -1364,31 +4769,124 @@ func (m *KVList) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				msglen |= int(b&0x7F) << shift
+				m.TxnId |= uint64(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			if msglen < 0 {
+		default:
+			iNdEx = preIndex
+			skippy, err := skipBadgerpb2(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
 				return ErrInvalidLengthBadgerpb2
 			}
-			postIndex := iNdEx + msglen
-			if postIndex < 0 {
+			if (iNdEx + skippy) < 0 {
 				return ErrInvalidLengthBadgerpb2
 			}
-			if postIndex > l {
+			if (iNdEx + skippy) > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.Kv = append(m.Kv, &KV{})
-			if err := m.Kv[len(m.Kv)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+			m.XXX_unrecognized = append(m.XXX_unrecognized, dAtA[iNdEx:iNdEx+skippy]...)
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *CommitResponse) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowBadgerpb2
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: CommitResponse: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: CommitResponse: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		default:
+			iNdEx = preIndex
+			skippy, err := skipBadgerpb2(dAtA[iNdEx:])
+			if err != nil {
 				return err
 			}
-			iNdEx = postIndex
-		case 10:
+			if skippy < 0 {
+				return ErrInvalidLengthBadgerpb2
+			}
+			if (iNdEx + skippy) < 0 {
+				return ErrInvalidLengthBadgerpb2
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.XXX_unrecognized = append(m.XXX_unrecognized, dAtA[iNdEx:iNdEx+skippy]...)
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *DiscardRequest) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowBadgerpb2
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: DiscardRequest: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: DiscardRequest: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
 			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field AllocRef", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field TxnId", wireType)
 			}
-			m.AllocRef = 0
+			m.TxnId = 0
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowBadgerpb2
@@ -1398,7 +4896,7 @@ func (m *KVList) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				m.AllocRef |= uint64(b&0x7F) << shift
+				m.TxnId |= uint64(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
@@ -1428,7 +4926,7 @@ func (m *KVList) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *ManifestChangeSet) Unmarshal(dAtA []byte) error {
+func (m *DiscardResponse) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -1451,46 +4949,12 @@ func (m *ManifestChangeSet) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: ManifestChangeSet: wiretype end group for non-group")
+			return fmt.Errorf("proto: DiscardResponse: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: ManifestChangeSet: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: DiscardResponse: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
-		case 1:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Changes", wireType)
-			}
-			var msglen int
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowBadgerpb2
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				msglen |= int(b&0x7F) << shift
-				if b < 0x80 {
-					break
-				}
-			}
-			if msglen < 0 {
-				return ErrInvalidLengthBadgerpb2
-			}
-			postIndex := iNdEx + msglen
-			if postIndex < 0 {
-				return ErrInvalidLengthBadgerpb2
-			}
-			if postIndex > l {
-				return io.ErrUnexpectedEOF
-			}
-			m.Changes = append(m.Changes, &ManifestChange{})
-			if err := m.Changes[len(m.Changes)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
-				return err
-			}
-			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
 			skippy, err := skipBadgerpb2(dAtA[iNdEx:])
@@ -1516,7 +4980,7 @@ func (m *ManifestChangeSet) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *ManifestChange) Unmarshal(dAtA []byte) error {
+func (m *ScanRequest) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -1539,17 +5003,17 @@ func (m *ManifestChange) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: ManifestChange: wiretype end group for non-group")
+			return fmt.Errorf("proto: ScanRequest: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: ManifestChange: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: ScanRequest: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
 			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Id", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field TxnId", wireType)
 			}
-			m.Id = 0
+			m.TxnId = 0
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowBadgerpb2
@@ -1559,16 +5023,16 @@ func (m *ManifestChange) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				m.Id |= uint64(b&0x7F) << shift
+				m.TxnId |= uint64(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
 		case 2:
-			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Op", wireType)
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Prefix", wireType)
 			}
-			m.Op = 0
+			var byteLen int
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowBadgerpb2
@@ -1578,54 +5042,31 @@ func (m *ManifestChange) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				m.Op |= ManifestChange_Operation(b&0x7F) << shift
+				byteLen |= int(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-		case 3:
-			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Level", wireType)
+			if byteLen < 0 {
+				return ErrInvalidLengthBadgerpb2
 			}
-			m.Level = 0
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowBadgerpb2
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				m.Level |= uint32(b&0x7F) << shift
-				if b < 0x80 {
-					break
-				}
+			postIndex := iNdEx + byteLen
+			if postIndex < 0 {
+				return ErrInvalidLengthBadgerpb2
 			}
-		case 4:
-			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field KeyId", wireType)
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
 			}
-			m.KeyId = 0
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowBadgerpb2
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				m.KeyId |= uint64(b&0x7F) << shift
-				if b < 0x80 {
-					break
-				}
+			m.Prefix = append(m.Prefix[:0], dAtA[iNdEx:postIndex]...)
+			if m.Prefix == nil {
+				m.Prefix = []byte{}
 			}
-		case 5:
-			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field EncryptionAlgo", wireType)
+			iNdEx = postIndex
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Start", wireType)
 			}
-			m.EncryptionAlgo = 0
+			var byteLen int
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowBadgerpb2
@@ -1635,30 +5076,26 @@ func (m *ManifestChange) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				m.EncryptionAlgo |= EncryptionAlgo(b&0x7F) << shift
+				byteLen |= int(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-		case 6:
-			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Compression", wireType)
+			if byteLen < 0 {
+				return ErrInvalidLengthBadgerpb2
 			}
-			m.Compression = 0
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowBadgerpb2
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				m.Compression |= uint32(b&0x7F) << shift
-				if b < 0x80 {
-					break
-				}
+			postIndex := iNdEx + byteLen
+			if postIndex < 0 {
+				return ErrInvalidLengthBadgerpb2
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Start = append(m.Start[:0], dAtA[iNdEx:postIndex]...)
+			if m.Start == nil {
+				m.Start = []byte{}
 			}
+			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
 			skippy, err := skipBadgerpb2(dAtA[iNdEx:])
@@ -1684,7 +5121,7 @@ func (m *ManifestChange) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *Checksum) Unmarshal(dAtA []byte) error {
+func (m *StreamRequest) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -1707,17 +5144,17 @@ func (m *Checksum) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: Checksum: wiretype end group for non-group")
+			return fmt.Errorf("proto: StreamRequest: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: Checksum: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: StreamRequest: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
-			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Algo", wireType)
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Prefix", wireType)
 			}
-			m.Algo = 0
+			var byteLen int
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowBadgerpb2
@@ -1727,30 +5164,26 @@ func (m *Checksum) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				m.Algo |= Checksum_Algorithm(b&0x7F) << shift
+				byteLen |= int(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-		case 2:
-			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Sum", wireType)
+			if byteLen < 0 {
+				return ErrInvalidLengthBadgerpb2
 			}
-			m.Sum = 0
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowBadgerpb2
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				m.Sum |= uint64(b&0x7F) << shift
-				if b < 0x80 {
-					break
-				}
+			postIndex := iNdEx + byteLen
+			if postIndex < 0 {
+				return ErrInvalidLengthBadgerpb2
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
 			}
+			m.Prefix = append(m.Prefix[:0], dAtA[iNdEx:postIndex]...)
+			if m.Prefix == nil {
+				m.Prefix = []byte{}
+			}
+			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
 			skippy, err := skipBadgerpb2(dAtA[iNdEx:])
@@ -1776,7 +5209,7 @@ func (m *Checksum) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *DataKey) Unmarshal(dAtA []byte) error {
+func (m *SubscribeRequest) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -1799,68 +5232,15 @@ func (m *DataKey) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: DataKey: wiretype end group for non-group")
+			return fmt.Errorf("proto: SubscribeRequest: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: DataKey: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: SubscribeRequest: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
-			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field KeyId", wireType)
-			}
-			m.KeyId = 0
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowBadgerpb2
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				m.KeyId |= uint64(b&0x7F) << shift
-				if b < 0x80 {
-					break
-				}
-			}
-		case 2:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Data", wireType)
-			}
-			var byteLen int
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowBadgerpb2
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				byteLen |= int(b&0x7F) << shift
-				if b < 0x80 {
-					break
-				}
-			}
-			if byteLen < 0 {
-				return ErrInvalidLengthBadgerpb2
-			}
-			postIndex := iNdEx + byteLen
-			if postIndex < 0 {
-				return ErrInvalidLengthBadgerpb2
-			}
-			if postIndex > l {
-				return io.ErrUnexpectedEOF
-			}
-			m.Data = append(m.Data[:0], dAtA[iNdEx:postIndex]...)
-			if m.Data == nil {
-				m.Data = []byte{}
-			}
-			iNdEx = postIndex
-		case 3:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Iv", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Prefixes", wireType)
 			}
 			var byteLen int
 			for shift := uint(0); ; shift += 7 {
@@ -1887,30 +5267,9 @@ func (m *DataKey) Unmarshal(dAtA []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.Iv = append(m.Iv[:0], dAtA[iNdEx:postIndex]...)
-			if m.Iv == nil {
-				m.Iv = []byte{}
-			}
+			m.Prefixes = append(m.Prefixes, make([]byte, postIndex-iNdEx))
+			copy(m.Prefixes[len(m.Prefixes)-1], dAtA[iNdEx:postIndex])
 			iNdEx = postIndex
-		case 4:
-			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field CreatedAt", wireType)
-			}
-			m.CreatedAt = 0
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowBadgerpb2
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				m.CreatedAt |= int64(b&0x7F) << shift
-				if b < 0x80 {
-					break
-				}
-			}
 		default:
 			iNdEx = preIndex
 			skippy, err := skipBadgerpb2(dAtA[iNdEx:])
@@ -29,6 +29,9 @@ type subscriber struct {
 	prefixes  [][]byte
 	sendCh    chan<- *pb.KVList
 	subCloser *z.Closer
+	// withMeta is set for the subscribers which need the meta of the entries in KV.Meta, instead
+	// of the user meta.
+	withMeta bool
 }
 
 type publisher struct {
@@ -84,6 +87,10 @@ func (p *publisher) publishUpdates(reqs requests) {
 	batchedUpdates := make(map[uint64]*pb.KVList)
 	for _, req := range reqs {
 		for _, e := range req.Entries {
+			if e.meta&bitRangeDelete > 0 {
+				p.publishRangeDelete(e, batchedUpdates)
+				continue
+			}
 			ids := p.indexer.Get(e.Key)
 			if len(ids) > 0 {
 				k := y.SafeCopy(nil, e.Key)
@@ -94,11 +101,26 @@ func (p *publisher) publishUpdates(reqs requests) {
 					ExpiresAt: e.ExpiresAt,
 					Version:   y.ParseTs(k),
 				}
+				var withMeta *pb.KV
 				for id := range ids {
 					if _, ok := batchedUpdates[id]; !ok {
 						batchedUpdates[id] = &pb.KVList{}
 					}
-					batchedUpdates[id].Kv = append(batchedUpdates[id].Kv, kv)
+					out := kv
+					if p.subscribers[id].withMeta {
+						if withMeta == nil {
+							withMeta = &pb.KV{
+								Key:       kv.Key,
+								Value:     kv.Value,
+								UserMeta:  []byte{e.UserMeta},
+								Meta:      []byte{e.meta &^ (bitTxn | bitFinTxn)},
+								ExpiresAt: kv.ExpiresAt,
+								Version:   kv.Version,
+							}
+						}
+						out = withMeta
+					}
+					batchedUpdates[id].Kv = append(batchedUpdates[id].Kv, out)
 				}
 			}
 		}
@@ -109,7 +131,34 @@ func (p *publisher) publishUpdates(reqs requests) {
 	}
 }
 
-func (p *publisher) newSubscriber(c *z.Closer, prefixes ...[]byte) (<-chan *pb.KVList, uint64) {
+// publishRangeDelete adds the range tombstone to the updates of the subscribers which need the
+// meta of the entries, and have a prefix overlapping with the deleted range. Must be called under
+// p.Lock.
+func (p *publisher) publishRangeDelete(e *Entry, batchedUpdates map[uint64]*pb.KVList) {
+	var kv *pb.KV
+	for id, s := range p.subscribers {
+		if !s.withMeta {
+			continue
+		}
+		if kv == nil {
+			start, end, err := parseRangeDelKey(y.ParseKey(e.Key))
+			if err != nil {
+				return
+			}
+			kv = rangeDeleteChange(start, end, y.ParseTs(e.Key))
+		}
+		if !hasOverlappingPrefix(kv.Key, kv.Value, s.prefixes) {
+			continue
+		}
+		if _, ok := batchedUpdates[id]; !ok {
+			batchedUpdates[id] = &pb.KVList{}
+		}
+		batchedUpdates[id].Kv = append(batchedUpdates[id].Kv, kv)
+	}
+}
+
+func (p *publisher) newSubscriber(c *z.Closer, withMeta bool,
+	prefixes ...[]byte) (<-chan *pb.KVList, uint64) {
 	p.Lock()
 	defer p.Unlock()
 	ch := make(chan *pb.KVList, 1000)
@@ -120,6 +169,7 @@ func (p *publisher) newSubscriber(c *z.Closer, prefixes ...[]byte) (<-chan *pb.K
 		prefixes:  prefixes,
 		sendCh:    ch,
 		subCloser: c,
+		withMeta:  withMeta,
 	}
 	for _, prefix := range prefixes {
 		p.indexer.Add(prefix, id)
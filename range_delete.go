@@ -0,0 +1,385 @@
+/*
+ * Copyright 2020 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package badger
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/dgraph-io/badger/v2/table"
+	"github.com/dgraph-io/badger/v2/y"
+	"github.com/pkg/errors"
+)
+
+// rangeDelPrefix is the prefix of the internal keys which store range tombstones. The key of a
+// tombstone is rangeDelPrefix followed by the uvarint encoded length of the start key, the start
+// key and the end key. Encoding both the ends in the key ensures that compaction never discards
+// a tombstone in favour of a newer tombstone covering a smaller range.
+var rangeDelPrefix = []byte("!badger!rangedel")
+
+// rangeTombstone deletes all the keys in [start, end) with a version lower than its own.
+type rangeTombstone struct {
+	start   []byte
+	end     []byte
+	version uint64
+}
+
+// covers returns true if the key lies within the range of the tombstone.
+func (rt rangeTombstone) covers(key []byte) bool {
+	return bytes.Compare(rt.start, key) <= 0 && bytes.Compare(key, rt.end) < 0
+}
+
+func rangeDelKey(start, end []byte) []byte {
+	var lenBuf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(lenBuf[:], uint64(len(start)))
+
+	out := make([]byte, 0, len(rangeDelPrefix)+n+len(start)+len(end))
+	out = append(out, rangeDelPrefix...)
+	out = append(out, lenBuf[:n]...)
+	out = append(out, start...)
+	return append(out, end...)
+}
+
+func parseRangeDelKey(key []byte) (start, end []byte, err error) {
+	if !bytes.HasPrefix(key, rangeDelPrefix) {
+		return nil, nil, errors.Errorf("Invalid range tombstone key: %q", key)
+	}
+	buf := key[len(rangeDelPrefix):]
+	sz, n := binary.Uvarint(buf)
+	if n <= 0 || uint64(len(buf)-n) < sz {
+		return nil, nil, errors.Errorf("Invalid range tombstone key: %q", key)
+	}
+	buf = buf[n:]
+	return buf[:sz], buf[sz:], nil
+}
+
+// rangeDelList holds all the range tombstones known to the DB, sorted by their start key. The
+// tombstones are kept in memory so that reads and compactions can consult them without touching
+// the LSM tree. They are rebuilt from the LSM tree when the DB is opened, and removed once they no
+// longer hide any version, see levelsController.dropRangeTombstone.
+type rangeDelList struct {
+	sync.RWMutex
+	tombstones []rangeTombstone
+	// fragments splits the ranges of the tombstones into disjoint ranges, sorted by start key, so
+	// that the tombstones covering a key are found by a binary search.
+	fragments []rangeFragment
+}
+
+// rangeFragment is a range of keys covered by the same range tombstones.
+type rangeFragment struct {
+	start []byte
+	end   []byte
+	// versions holds the versions of the tombstones covering the range, in increasing order.
+	versions []uint64
+}
+
+func (l *rangeDelList) add(rts ...rangeTombstone) {
+	l.Lock()
+	defer l.Unlock()
+
+	for _, rt := range rts {
+		idx := sort.Search(len(l.tombstones), func(i int) bool {
+			return bytes.Compare(l.tombstones[i].start, rt.start) > 0
+		})
+		l.tombstones = append(l.tombstones, rangeTombstone{})
+		copy(l.tombstones[idx+1:], l.tombstones[idx:])
+		l.tombstones[idx] = rt
+	}
+	l.fragment()
+}
+
+// remove removes the tombstone with the same range and version as rt, if there is one.
+func (l *rangeDelList) remove(rt rangeTombstone) {
+	l.Lock()
+	defer l.Unlock()
+
+	for i, t := range l.tombstones {
+		if t.version == rt.version && bytes.Equal(t.start, rt.start) && bytes.Equal(t.end, rt.end) {
+			l.tombstones = append(l.tombstones[:i], l.tombstones[i+1:]...)
+			l.fragment()
+			return
+		}
+	}
+}
+
+// fragment rebuilds the fragments from the tombstones. Must be called under l.Lock.
+func (l *rangeDelList) fragment() {
+	bounds := make([][]byte, 0, 2*len(l.tombstones))
+	for _, rt := range l.tombstones {
+		bounds = append(bounds, rt.start, rt.end)
+	}
+	sort.Slice(bounds, func(i, j int) bool { return bytes.Compare(bounds[i], bounds[j]) < 0 })
+
+	l.fragments = l.fragments[:0]
+	// active holds the tombstones covering the current fragment. The tombstones are sorted by
+	// start key, so next is the first one which hasn't started yet.
+	var active []rangeTombstone
+	next := 0
+	for i := 0; i+1 < len(bounds); i++ {
+		start, end := bounds[i], bounds[i+1]
+		if bytes.Equal(start, end) {
+			continue
+		}
+		for ; next < len(l.tombstones) && bytes.Compare(l.tombstones[next].start, start) <= 0; next++ {
+			active = append(active, l.tombstones[next])
+		}
+		live := active[:0]
+		for _, rt := range active {
+			if bytes.Compare(rt.end, start) > 0 {
+				live = append(live, rt)
+			}
+		}
+		active = live
+		if len(active) == 0 {
+			continue
+		}
+		versions := make([]uint64, 0, len(active))
+		for _, rt := range active {
+			versions = append(versions, rt.version)
+		}
+		sort.Slice(versions, func(i, j int) bool { return versions[i] < versions[j] })
+		l.fragments = append(l.fragments, rangeFragment{start: start, end: end, versions: versions})
+	}
+}
+
+func (l *rangeDelList) reset() {
+	l.Lock()
+	defer l.Unlock()
+	l.tombstones = nil
+	l.fragments = nil
+}
+
+func (l *rangeDelList) empty() bool {
+	l.RLock()
+	defer l.RUnlock()
+	return len(l.tombstones) == 0
+}
+
+// deletedBy returns the highest version of a tombstone which covers the key at the given version,
+// and is visible at readTs. It returns zero if no such tombstone exists. Internal keys are never
+// covered by a tombstone.
+func (l *rangeDelList) deletedBy(key []byte, version, readTs uint64) uint64 {
+	if bytes.HasPrefix(key, badgerPrefix) {
+		return 0
+	}
+	l.RLock()
+	defer l.RUnlock()
+
+	idx := sort.Search(len(l.fragments), func(i int) bool {
+		return bytes.Compare(l.fragments[i].end, key) > 0
+	})
+	if idx == len(l.fragments) || bytes.Compare(l.fragments[idx].start, key) > 0 {
+		return 0
+	}
+	versions := l.fragments[idx].versions
+	// The highest version visible at readTs.
+	n := sort.Search(len(versions), func(i int) bool { return versions[i] > readTs })
+	if n == 0 || versions[n-1] <= version {
+		return 0
+	}
+	return versions[n-1]
+}
+
+// isRangeDeleted returns true if the key at the given version has been deleted by a range
+// tombstone visible at readTs.
+func (db *DB) isRangeDeleted(key []byte, version, readTs uint64) bool {
+	return db.rangeDels.deletedBy(key, version, readTs) > 0
+}
+
+// parseRangeTombstone returns the range tombstone stored under the given versioned key.
+func parseRangeTombstone(key []byte) (rangeTombstone, error) {
+	start, end, err := parseRangeDelKey(y.ParseKey(key))
+	if err != nil {
+		return rangeTombstone{}, err
+	}
+	return rangeTombstone{
+		start:   y.SafeCopy(nil, start),
+		end:     y.SafeCopy(nil, end),
+		version: y.ParseTs(key),
+	}, nil
+}
+
+// addRangeTombstone registers the range tombstone stored under the given versioned key.
+func (db *DB) addRangeTombstone(key []byte) error {
+	rt, err := parseRangeTombstone(key)
+	if err != nil {
+		return err
+	}
+	db.rangeDels.add(rt)
+	return nil
+}
+
+// loadRangeTombstones rebuilds the list of range tombstones by reading them from the memtables
+// and the LSM tree.
+func (db *DB) loadRangeTombstones() error {
+	tables, decr := db.getMemTables()
+	defer decr()
+
+	opt := IteratorOptions{Prefix: rangeDelPrefix}
+	var iters []y.Iterator
+	for _, mt := range tables {
+		iters = append(iters, mt.sl.NewUniIterator(false))
+	}
+	iters = db.lc.appendIterators(iters, &opt)
+	if len(iters) == 0 {
+		return nil
+	}
+	it := table.NewMergeIterator(iters, false)
+	defer it.Close()
+
+	var rts []rangeTombstone
+	for it.Seek(y.KeyWithTs(rangeDelPrefix, math.MaxUint64)); it.Valid(); it.Next() {
+		if !bytes.HasPrefix(it.Key(), rangeDelPrefix) {
+			break
+		}
+		if it.Value().Meta&bitRangeDelete == 0 {
+			continue
+		}
+		rt, err := parseRangeTombstone(it.Key())
+		if err != nil {
+			return err
+		}
+		rts = append(rts, rt)
+	}
+	db.rangeDels.add(rts...)
+	return nil
+}
+
+// rangeTombstoneObsolete returns true if no key in the range of the tombstone has a version older
+// than the tombstone, in the memtables or in the LSM tree. Such a tombstone doesn't hide anything,
+// and can be dropped once it is visible to all the running transactions.
+func (db *DB) rangeTombstoneObsolete(rt rangeTombstone) bool {
+	// DropPrefix runs compactions while holding the DB lock. Keep the tombstone for now, instead
+	// of waiting for the lock.
+	if !db.TryRLock() {
+		return false
+	}
+	// The memtables are picked before the levels, so that a key being flushed or compacted
+	// concurrently is seen at least once.
+	tables, decr := db.getMemTablesLocked()
+	db.RUnlock()
+	defer decr()
+
+	opt := IteratorOptions{LowerBound: rt.start, UpperBound: rt.end, AllVersions: true}
+	var iters []y.Iterator
+	for _, mt := range tables {
+		iters = append(iters, mt.sl.NewUniIterator(false))
+	}
+	iters = db.lc.appendIterators(iters, &opt)
+	if len(iters) == 0 {
+		return true
+	}
+	it := table.NewMergeIterator(iters, false)
+	defer it.Close()
+
+	for it.Seek(y.KeyWithTs(rt.start, math.MaxUint64)); it.Valid(); it.Next() {
+		key := y.ParseKey(it.Key())
+		if bytes.Compare(key, rt.end) >= 0 {
+			break
+		}
+		if bytes.HasPrefix(key, badgerPrefix) {
+			// Internal keys are never covered by a tombstone.
+			continue
+		}
+		if y.ParseTs(it.Key()) < rt.version {
+			return false
+		}
+	}
+	return true
+}
+
+// rangeTombstoneObsolete returns true if the range tombstone stored under the given versioned
+// key no longer hides any version.
+func (s *levelsController) rangeTombstoneObsolete(key []byte) bool {
+	rt, err := parseRangeTombstone(key)
+	if err != nil {
+		s.kv.opt.Errorf("Unable to parse range tombstone %q: %v", key, err)
+		return false
+	}
+	return s.kv.rangeTombstoneObsolete(rt)
+}
+
+// dropRangeTombstone removes the range tombstone stored under the given versioned key from the
+// in-memory list. It is called by the compactions dropping the tombstone from the LSM tree.
+func (s *levelsController) dropRangeTombstone(key []byte) {
+	rt, err := parseRangeTombstone(key)
+	if err != nil {
+		s.kv.opt.Errorf("Unable to parse range tombstone %q: %v", key, err)
+		return
+	}
+	s.kv.rangeDels.remove(rt)
+}
+
+// DeleteRange deletes all the keys in the range [start, end).
+//
+// This is done by adding a single range tombstone at the commit timestamp, instead of a delete
+// marker per key. Any reads happening before this timestamp would be unaffected. Any reads after
+// this commit would not see the keys in the range, except those written by this or later
+// transactions. The deleted keys are physically removed during compactions.
+//
+// Note that the range tombstone is not considered for conflict detection. Concurrent
+// transactions writing to keys within the range won't conflict with this transaction.
+//
+// The range must not have keys covered by a secondary index (see DB.AddIndex), since the entries
+// of the index can't be updated without reading the deleted keys.
+//
+// The current transaction keeps a reference to the start and end byte slices. Users must not
+// modify them until the end of the transaction.
+func (txn *Txn) DeleteRange(start, end []byte) error {
+	switch {
+	case len(start) == 0:
+		return ErrEmptyKey
+	case bytes.Compare(start, end) >= 0:
+		return ErrInvalidRange
+	case bytes.HasPrefix(start, badgerPrefix) || bytes.HasPrefix(end, badgerPrefix):
+		return ErrInvalidKey
+	case txn.indexes.overlaps(start, end):
+		return errors.Errorf("Cannot delete range [%q, %q), which has keys covered by an index",
+			start, end)
+	}
+	e := &Entry{
+		Key:  rangeDelKey(start, end),
+		meta: bitRangeDelete,
+	}
+	if err := txn.modifyInternal(e); err != nil {
+		return err
+	}
+	// Writes done so far in this transaction within the range must be deleted as well. They would
+	// otherwise be committed at the same version as the tombstone and survive it.
+	for k, pe := range txn.pendingWrites {
+		if pe.meta&bitRangeDelete == 0 && bytes.Compare(start, pe.Key) <= 0 &&
+			bytes.Compare(pe.Key, end) < 0 {
+			txn.setPending(k, nil, false)
+		}
+	}
+	txn.rangeDels = append(txn.rangeDels, rangeTombstone{start: start, end: end})
+	return nil
+}
+
+// pendingRangeDeleted returns true if the committed version of key is hidden by a DeleteRange
+// call done within this transaction.
+func (txn *Txn) pendingRangeDeleted(key []byte) bool {
+	for _, rt := range txn.rangeDels {
+		if rt.covers(key) {
+			return true
+		}
+	}
+	return false
+}
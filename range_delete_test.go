@@ -0,0 +1,315 @@
+/*
+ * Copyright 2020 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package badger
+
+import (
+	"fmt"
+	"io/ioutil"
+	"testing"
+
+	"github.com/dgraph-io/badger/v2/y"
+	"github.com/stretchr/testify/require"
+)
+
+func rangeKey(i int) []byte {
+	return []byte(fmt.Sprintf("key%03d", i))
+}
+
+// countKeys returns the number of keys visible to the given transaction.
+func countKeys(t *testing.T, txn *Txn, reverse bool) int {
+	opt := DefaultIteratorOptions
+	opt.Reverse = reverse
+	it := txn.NewIterator(opt)
+	defer it.Close()
+
+	var count int
+	for it.Rewind(); it.Valid(); it.Next() {
+		count++
+	}
+	return count
+}
+
+func TestRangeDelKey(t *testing.T) {
+	key := rangeDelKey([]byte("start"), []byte("the end"))
+	start, end, err := parseRangeDelKey(key)
+	require.NoError(t, err)
+	require.Equal(t, []byte("start"), start)
+	require.Equal(t, []byte("the end"), end)
+
+	_, _, err = parseRangeDelKey([]byte("foo"))
+	require.Error(t, err)
+}
+
+func TestDeleteRange(t *testing.T) {
+	dir, err := ioutil.TempDir("", "badger-test")
+	require.NoError(t, err)
+	defer removeDir(dir)
+
+	db, err := Open(getTestOptions(dir))
+	require.NoError(t, err)
+
+	for i := 0; i < 100; i++ {
+		txnSet(t, db, rangeKey(i), []byte("val"), 0)
+	}
+
+	// This transaction must not see the range deletion.
+	old := db.NewTransaction(false)
+	defer old.Discard()
+
+	require.Equal(t, ErrInvalidRange, db.Update(func(txn *Txn) error {
+		return txn.DeleteRange(rangeKey(20), rangeKey(20))
+	}))
+	require.Equal(t, ErrInvalidKey, db.Update(func(txn *Txn) error {
+		return txn.DeleteRange(badgerPrefix, rangeKey(20))
+	}))
+	require.NoError(t, db.Update(func(txn *Txn) error {
+		return txn.DeleteRange(rangeKey(20), rangeKey(50))
+	}))
+
+	check := func(db *DB) {
+		require.NoError(t, db.View(func(txn *Txn) error {
+			for i := 0; i < 100; i++ {
+				_, err := txn.Get(rangeKey(i))
+				if i >= 20 && i < 50 {
+					require.Equal(t, ErrKeyNotFound, err, "key: %s", rangeKey(i))
+				} else {
+					require.NoError(t, err, "key: %s", rangeKey(i))
+				}
+			}
+			require.Equal(t, 70, countKeys(t, txn, false))
+			require.Equal(t, 70, countKeys(t, txn, true))
+			return nil
+		}))
+	}
+	check(db)
+
+	_, err = old.Get(rangeKey(30))
+	require.NoError(t, err)
+	require.Equal(t, 100, countKeys(t, old, false))
+
+	// Writes after the range deletion must be visible.
+	txnSet(t, db, rangeKey(30), []byte("new"), 0)
+	require.NoError(t, db.View(func(txn *Txn) error {
+		item, err := txn.Get(rangeKey(30))
+		require.NoError(t, err)
+		require.Equal(t, []byte("new"), getItemValue(t, item))
+		require.Equal(t, 71, countKeys(t, txn, false))
+		return nil
+	}))
+	txnDelete(t, db, rangeKey(30))
+	old.Discard()
+	require.NoError(t, db.Close())
+
+	// The range tombstones must survive a restart.
+	db, err = Open(getTestOptions(dir))
+	require.NoError(t, err)
+	defer func() { require.NoError(t, db.Close()) }()
+	check(db)
+}
+
+func TestDeleteRangeWithinTxn(t *testing.T) {
+	runBadgerTest(t, nil, func(t *testing.T, db *DB) {
+		for i := 0; i < 10; i++ {
+			txnSet(t, db, rangeKey(i), []byte("val"), 0)
+		}
+
+		txn := db.NewTransaction(true)
+		defer txn.Discard()
+		require.NoError(t, txn.Set(rangeKey(11), []byte("val")))
+		require.NoError(t, txn.DeleteRange(rangeKey(5), rangeKey(20)))
+		require.NoError(t, txn.Set(rangeKey(7), []byte("val")))
+
+		_, err := txn.Get(rangeKey(6))
+		require.Equal(t, ErrKeyNotFound, err)
+		_, err = txn.Get(rangeKey(11))
+		require.Equal(t, ErrKeyNotFound, err)
+		_, err = txn.Get(rangeKey(7))
+		require.NoError(t, err)
+		// key000 to key004 and key007.
+		require.Equal(t, 6, countKeys(t, txn, false))
+		require.Equal(t, 6, countKeys(t, txn, true))
+		require.NoError(t, txn.Commit())
+
+		require.NoError(t, db.View(func(txn *Txn) error {
+			require.Equal(t, 6, countKeys(t, txn, false))
+			_, err := txn.Get(rangeKey(7))
+			require.NoError(t, err)
+			return nil
+		}))
+	})
+}
+
+func TestWriteBatchDeleteRange(t *testing.T) {
+	runBadgerTest(t, nil, func(t *testing.T, db *DB) {
+		wb := db.NewWriteBatch()
+		for i := 0; i < 100; i++ {
+			require.NoError(t, wb.Set(rangeKey(i), []byte("val")))
+		}
+		require.NoError(t, wb.Flush())
+
+		wb = db.NewWriteBatch()
+		require.NoError(t, wb.DeleteRange(rangeKey(10), rangeKey(90)))
+		require.NoError(t, wb.Flush())
+
+		require.NoError(t, db.View(func(txn *Txn) error {
+			require.Equal(t, 20, countKeys(t, txn, false))
+			return nil
+		}))
+	})
+}
+
+func TestDeleteRangeCompaction(t *testing.T) {
+	opt := DefaultOptions("").WithNumCompactors(0).WithNumVersionsToKeep(1)
+	opt.managedTxns = true
+
+	run := func(t *testing.T, discardTs uint64, after []keyValVersion) {
+		runBadgerTest(t, &opt, func(t *testing.T, db *DB) {
+			tombstone := string(rangeDelKey([]byte("b"), []byte("d")))
+			l0 := []keyValVersion{
+				{tombstone, "", 3, bitRangeDelete}, {"b", "new", 4, 0}, {"c", "bar", 2, 0},
+			}
+			l1 := []keyValVersion{{"a", "bar", 1, 0}, {"b", "bar", 1, 0}, {"d", "bar", 1, 0}}
+			createAndOpen(db, l0, 0)
+			createAndOpen(db, l1, 1)
+			require.NoError(t, db.addRangeTombstone(y.KeyWithTs([]byte(tombstone), 3)))
+
+			db.SetDiscardTs(discardTs)
+			getAllAndCheck(t, db, []keyValVersion{
+				{tombstone, "", 3, bitRangeDelete}, {"a", "bar", 1, 0},
+				{"b", "new", 4, 0}, {"b", "bar", 1, bitDelete}, {"c", "bar", 2, bitDelete},
+				{"d", "bar", 1, 0},
+			})
+			cdef := compactDef{
+				thisLevel: db.lc.levels[0],
+				nextLevel: db.lc.levels[1],
+				top:       db.lc.levels[0].tables,
+				bot:       db.lc.levels[1].tables,
+				t:         db.lc.levelTargets(),
+			}
+			cdef.t.baseLevel = 1
+			require.NoError(t, db.lc.runCompactDef(-1, 0, cdef))
+			getAllAndCheck(t, db, after)
+		})
+	}
+	t.Run("tombstone above discardTs", func(t *testing.T) {
+		tombstone := string(rangeDelKey([]byte("b"), []byte("d")))
+		run(t, 2, []keyValVersion{
+			{tombstone, "", 3, bitRangeDelete}, {"a", "bar", 1, 0},
+			{"b", "new", 4, 0}, {"b", "bar", 1, bitDelete}, {"c", "bar", 2, bitDelete},
+			{"d", "bar", 1, 0},
+		})
+	})
+	t.Run("tombstone below discardTs", func(t *testing.T) {
+		tombstone := string(rangeDelKey([]byte("b"), []byte("d")))
+		run(t, 10, []keyValVersion{
+			{tombstone, "", 3, bitRangeDelete}, {"a", "bar", 1, 0},
+			{"b", "new", 4, 0}, {"d", "bar", 1, 0},
+		})
+	})
+}
+
+func TestRangeDelListDeletedBy(t *testing.T) {
+	var l rangeDelList
+	l.add(
+		rangeTombstone{start: []byte("b"), end: []byte("f"), version: 5},
+		rangeTombstone{start: []byte("d"), end: []byte("h"), version: 3},
+	)
+	l.add(rangeTombstone{start: []byte("a"), end: []byte("c"), version: 7})
+
+	cases := []struct {
+		key             string
+		version, readTs uint64
+		delTs           uint64
+	}{
+		{"0", 1, 10, 0},
+		{"a", 1, 10, 7},
+		{"a", 1, 6, 0},
+		{"b", 1, 10, 7},
+		{"b", 1, 6, 5},
+		{"b", 6, 6, 0},
+		{"c", 1, 10, 5},
+		{"e", 1, 10, 5},
+		{"e", 1, 4, 3},
+		{"e", 4, 10, 5},
+		{"g", 1, 10, 3},
+		{"g", 3, 10, 0},
+		{"h", 1, 10, 0},
+		{"!badger!foo", 1, 10, 0},
+	}
+	for _, c := range cases {
+		require.Equal(t, c.delTs, l.deletedBy([]byte(c.key), c.version, c.readTs),
+			"key %q at version %d, read at %d", c.key, c.version, c.readTs)
+	}
+
+	l.remove(rangeTombstone{start: []byte("b"), end: []byte("f"), version: 5})
+	require.Equal(t, uint64(0), l.deletedBy([]byte("c"), 1, 10))
+	require.Equal(t, uint64(3), l.deletedBy([]byte("e"), 1, 10))
+	require.Equal(t, uint64(7), l.deletedBy([]byte("b"), 1, 10))
+
+	l.reset()
+	require.True(t, l.empty())
+	require.Equal(t, uint64(0), l.deletedBy([]byte("e"), 1, 10))
+}
+
+func TestDeleteRangeDropTombstone(t *testing.T) {
+	opt := DefaultOptions("").WithNumCompactors(0).WithNumVersionsToKeep(1)
+	opt.managedTxns = true
+
+	tombstone := string(rangeDelKey([]byte("b"), []byte("d")))
+	run := func(t *testing.T, kvs []keyValVersion, after []keyValVersion, dropped bool) {
+		runBadgerTest(t, &opt, func(t *testing.T, db *DB) {
+			last := len(db.lc.levels) - 1
+			createAndOpen(db, kvs, last-1)
+			require.NoError(t, db.addRangeTombstone(y.KeyWithTs([]byte(tombstone), 3)))
+			require.NoError(t, db.addRangeTombstone(y.KeyWithTs([]byte(tombstone), 2)))
+
+			db.SetDiscardTs(10)
+			cdef := compactDef{
+				thisLevel: db.lc.levels[last-1],
+				nextLevel: db.lc.levels[last],
+				top:       db.lc.levels[last-1].tables,
+				t:         db.lc.levelTargets(),
+			}
+			cdef.t.baseLevel = 1
+			require.NoError(t, db.lc.runCompactDef(-1, last-1, cdef))
+			getAllAndCheck(t, db, after)
+			require.Equal(t, dropped, db.rangeDels.empty())
+			if !dropped {
+				// The older tombstone over the same range is gone.
+				require.Len(t, db.rangeDels.tombstones, 1)
+			}
+		})
+	}
+	t.Run("older versions left", func(t *testing.T) {
+		// The compaction drops c@1, but the tombstone is checked against the tree as it was
+		// before the compaction.
+		run(t, []keyValVersion{
+			{tombstone, "", 3, bitRangeDelete}, {tombstone, "", 2, bitRangeDelete},
+			{"a", "bar", 1, 0}, {"c", "bar", 1, 0},
+		}, []keyValVersion{
+			{tombstone, "", 3, bitRangeDelete}, {"a", "bar", 1, 0},
+		}, false)
+	})
+	t.Run("no older versions", func(t *testing.T) {
+		run(t, []keyValVersion{
+			{tombstone, "", 3, bitRangeDelete}, {tombstone, "", 2, bitRangeDelete},
+			{"a", "bar", 1, 0}, {"c", "new", 4, 0},
+		}, []keyValVersion{
+			{"a", "bar", 1, 0}, {"c", "new", 4, 0},
+		}, true)
+	})
+}
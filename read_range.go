@@ -0,0 +1,190 @@
+/*
+ * Copyright 2020 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package badger
+
+import (
+	"bytes"
+	"sort"
+)
+
+// readRange is the range of keys [start, end) read by an iterator. A nil start or end leaves the
+// range unbounded on that side.
+type readRange struct {
+	start []byte
+	end   []byte
+}
+
+func (r readRange) empty() bool {
+	return r.start != nil && r.end != nil && bytes.Compare(r.start, r.end) >= 0
+}
+
+// overlaps returns true if the range has any key in common with [start, end). A nil end leaves
+// [start, end) unbounded.
+func (r readRange) overlaps(start, end []byte) bool {
+	if r.end != nil && bytes.Compare(start, r.end) >= 0 {
+		return false
+	}
+	return r.start == nil || end == nil || bytes.Compare(r.start, end) < 0
+}
+
+// containsAny returns true if any of the sorted keys lies within the range.
+func (r readRange) containsAny(keys []string) bool {
+	idx := sort.SearchStrings(keys, string(r.start))
+	return idx < len(keys) && (r.end == nil || keys[idx] < string(r.end))
+}
+
+// prefixEnd returns the smallest key greater than all the keys having the prefix, or nil if there
+// is none.
+func prefixEnd(prefix []byte) []byte {
+	for i := len(prefix) - 1; i >= 0; i-- {
+		if prefix[i] < 0xff {
+			end := append([]byte{}, prefix[:i+1]...)
+			end[i]++
+			return end
+		}
+	}
+	return nil
+}
+
+// keyAfter returns the smallest key greater than key.
+func keyAfter(key []byte) []byte {
+	return append(append(make([]byte, 0, len(key)+1), key...), 0)
+}
+
+func maxKey(a, b []byte) []byte {
+	if bytes.Compare(a, b) < 0 {
+		return b
+	}
+	return a
+}
+
+// minEnd returns the smaller of two range ends, where nil is unbounded.
+func minEnd(a, b []byte) []byte {
+	switch {
+	case a == nil:
+		return b
+	case b == nil || bytes.Compare(a, b) < 0:
+		return a
+	}
+	return b
+}
+
+// addReadRange records a range of keys read by an iterator, so that the transaction conflicts
+// with the transactions committing writes into the range after it started. Unlike addReadKey,
+// this also catches the keys inserted into the range, which the iterator never saw.
+func (txn *Txn) addReadRange(r readRange) {
+	if !txn.update || r.empty() {
+		return
+	}
+	txn.readsLock.Lock()
+	txn.readRanges = append(txn.readRanges, r)
+	txn.readsLock.Unlock()
+}
+
+// recordReadRange adds the range of keys covered by the iterator since the last Seek to the read
+// set of the transaction. The range spans from the seek key to the current item, or to the end
+// of the keys the iterator can return, if it has run past them.
+func (it *Iterator) recordReadRange() {
+	if !it.seeked || !it.txn.update {
+		return
+	}
+	it.seeked = false
+
+	// The keys the iterator can return lie within [lo, hi).
+	lo, hi := maxKey(it.opt.Prefix, it.opt.LowerBound), it.opt.UpperBound
+	if len(hi) == 0 {
+		hi = nil
+	}
+	if len(it.opt.Prefix) > 0 {
+		if it.opt.prefixIsKey {
+			hi = minEnd(hi, keyAfter(it.opt.Prefix))
+		} else {
+			hi = minEnd(hi, prefixEnd(it.opt.Prefix))
+		}
+	}
+
+	var r readRange
+	if !it.opt.Reverse {
+		r = readRange{start: maxKey(lo, it.seekKey), end: hi}
+		if it.item != nil {
+			r.end = minEnd(hi, keyAfter(it.item.key))
+		}
+	} else {
+		r = readRange{start: lo, end: hi}
+		if len(it.seekKey) > 0 {
+			r.end = minEnd(hi, keyAfter(it.seekKey))
+		}
+		if it.item != nil {
+			r.start = maxKey(lo, it.item.key)
+		}
+	}
+	if len(r.start) == 0 {
+		r.start = nil
+	}
+	r.start = append([]byte{}, r.start...)
+	if r.end != nil {
+		r.end = append([]byte{}, r.end...)
+	}
+
+	if it.opt.InternalAccess {
+		it.txn.addReadRange(r)
+		return
+	}
+	// The internal keys are skipped by the iterator, the writes to them must not conflict.
+	it.txn.addReadRange(readRange{start: r.start, end: minEnd(r.end, badgerPrefix)})
+	it.txn.addReadRange(readRange{start: maxKey(r.start, prefixEnd(badgerPrefix)), end: r.end})
+}
+
+// hasRangeConflict returns true if the committed transaction wrote to any of the ranges read by
+// txn. It must be called while holding the oracle lock.
+func (txn *Txn) hasRangeConflict(ct *committedTxn) bool {
+	if len(txn.readRanges) == 0 {
+		return false
+	}
+	keys := ct.sortedKeys()
+	for _, r := range txn.readRanges {
+		if r.containsAny(keys) {
+			return true
+		}
+		for _, rt := range ct.rangeDels {
+			if r.overlaps(rt.start, rt.end) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// writtenKeys returns the keys written by the transaction, in no particular order.
+func (txn *Txn) writtenKeys() []string {
+	keys := make([]string, 0, len(txn.pendingWrites))
+	for k := range txn.pendingWrites {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// sortedKeys returns the keys written by the committed transaction, in sorted order. They are
+// sorted the first time they are checked against a range, since most of the committed
+// transactions never are. It must be called while holding the oracle lock.
+func (ct *committedTxn) sortedKeys() []string {
+	if !ct.sorted {
+		sort.Strings(ct.keys)
+		ct.sorted = true
+	}
+	return ct.keys
+}
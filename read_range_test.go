@@ -0,0 +1,152 @@
+/*
+ * Copyright 2020 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package badger
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrefixEnd(t *testing.T) {
+	require.Equal(t, []byte("ab"), prefixEnd([]byte("aa")))
+	require.Equal(t, []byte{'a', 0x01}, prefixEnd([]byte{'a', 0x00, 0xff}))
+	require.Nil(t, prefixEnd([]byte{0xff, 0xff}))
+	require.Nil(t, prefixEnd(nil))
+}
+
+// scanKeys iterates over the keys of txn with the given options, starting from seek, and stops
+// after visiting limit keys. It returns the keys visited.
+func scanKeys(txn *Txn, opt IteratorOptions, seek string, limit int) []string {
+	it := txn.NewIterator(opt)
+	defer it.Close()
+	var keys []string
+	for it.Seek([]byte(seek)); it.Valid() && len(keys) < limit; it.Next() {
+		keys = append(keys, string(it.Item().Key()))
+	}
+	return keys
+}
+
+func TestReadRangeConflict(t *testing.T) {
+	runBadgerTest(t, nil, func(t *testing.T, db *DB) {
+		txnSet(t, db, []byte("room1/10:00"), []byte("alice"), 0)
+		txnSet(t, db, []byte("room2/10:00"), []byte("bob"), 0)
+
+		// Each transaction books room1 at 11:00 if it has less than two bookings. Only one of them
+		// may succeed.
+		book := func(who string) *Txn {
+			txn := db.NewTransaction(true)
+			opt := DefaultIteratorOptions
+			opt.Prefix = []byte("room1/")
+			require.Len(t, scanKeys(txn, opt, "", 100), 1)
+			require.NoError(t, txn.Set([]byte("room1/11:00"), []byte(who)))
+			return txn
+		}
+		txn1, txn2 := book("carol"), book("dave")
+		defer txn1.Discard()
+		defer txn2.Discard()
+		require.NoError(t, txn1.Commit())
+		require.Equal(t, ErrConflict, txn2.Commit())
+
+		// A phantom inserted into the scanned range.
+		txn := db.NewTransaction(true)
+		defer txn.Discard()
+		opt := DefaultIteratorOptions
+		opt.Prefix = []byte("room1/")
+		require.Len(t, scanKeys(txn, opt, "", 100), 2)
+		txnSet(t, db, []byte("room1/12:00"), []byte("erin"), 0)
+		require.NoError(t, txn.Set([]byte("summary"), []byte("2 bookings")))
+		require.Equal(t, ErrConflict, txn.Commit())
+
+		// Writes outside of the scanned range don't conflict.
+		txn = db.NewTransaction(true)
+		defer txn.Discard()
+		require.Len(t, scanKeys(txn, opt, "", 100), 3)
+		txnSet(t, db, []byte("room2/12:00"), []byte("frank"), 0)
+		txnSet(t, db, []byte("room0"), []byte("frank"), 0)
+		require.NoError(t, txn.Set([]byte("summary"), []byte("3 bookings")))
+		require.NoError(t, txn.Commit())
+
+		// Range deletes overlapping the scanned range conflict.
+		txn = db.NewTransaction(true)
+		defer txn.Discard()
+		require.Len(t, scanKeys(txn, opt, "", 100), 3)
+		del := db.NewTransaction(true)
+		require.NoError(t, del.DeleteRange([]byte("room1/11:30"), []byte("room1/11:40")))
+		require.NoError(t, del.Commit())
+		require.NoError(t, txn.Set([]byte("summary"), []byte("3 bookings")))
+		require.Equal(t, ErrConflict, txn.Commit())
+	})
+}
+
+func TestReadRangePartialScan(t *testing.T) {
+	runBadgerTest(t, nil, func(t *testing.T, db *DB) {
+		for _, k := range []string{"b", "d", "f", "h"} {
+			txnSet(t, db, []byte(k), []byte(k), 0)
+		}
+		test := func(opt IteratorOptions, seek string, limit int, insert string,
+			conflict bool) {
+			txn := db.NewTransaction(true)
+			defer txn.Discard()
+			scanKeys(txn, opt, seek, limit)
+			txnSet(t, db, []byte(insert), []byte("x"), 0)
+			defer txnDelete(t, db, []byte(insert))
+			require.NoError(t, txn.Set([]byte("z"), []byte("z")))
+			err := txn.Commit()
+			if conflict {
+				require.Equal(t, ErrConflict, err, "insert %q", insert)
+			} else {
+				require.NoError(t, err, "insert %q", insert)
+			}
+		}
+		opt := DefaultIteratorOptions
+		// The scan reads "d" and stops at "f".
+		test(opt, "c", 1, "b1", false)
+		test(opt, "c", 1, "c1", true)
+		test(opt, "c", 1, "e", true)
+		test(opt, "c", 1, "g", false)
+
+		opt.Reverse = true
+		// The scan reads "f" and stops at "d".
+		test(opt, "g", 1, "g1", false)
+		test(opt, "g", 1, "g", true)
+		test(opt, "g", 1, "e1", true)
+		test(opt, "g", 1, "c1", false)
+
+		opt = DefaultIteratorOptions
+		opt.LowerBound, opt.UpperBound = []byte("c"), []byte("g")
+		test(opt, "", 100, "c0", true)
+		test(opt, "", 100, "g", false)
+		test(opt, "", 100, "b1", false)
+	})
+}
+
+func TestReadRangeSavepoint(t *testing.T) {
+	runBadgerTest(t, nil, func(t *testing.T, db *DB) {
+		txn := db.NewTransaction(true)
+		defer txn.Discard()
+		sp := txn.Savepoint()
+		opt := DefaultIteratorOptions
+		opt.Prefix = []byte("p")
+		scanKeys(txn, opt, "", 100)
+		require.NoError(t, txn.RollbackTo(sp))
+
+		txnSet(t, db, []byte("p1"), []byte("x"), 0)
+		require.NoError(t, txn.Set([]byte("q"), []byte("q")))
+		require.NoError(t, txn.Commit())
+	})
+}
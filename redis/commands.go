@@ -0,0 +1,502 @@
+/*
+ * Copyright 2020 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package redis
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dgraph-io/badger/v2"
+	"github.com/pkg/errors"
+)
+
+// command is a Redis command, run within a transaction.
+type command struct {
+	// arity is the number of arguments, including the name of the command. A negative arity is
+	// the minimum number of arguments, like in Redis.
+	arity int
+	// write is set for the commands which modify the DB. noTxn is set for the commands which
+	// don't access it.
+	write, noTxn bool
+	run          func(c *conn, txn *badger.Txn, args [][]byte) (interface{}, error)
+}
+
+var commands map[string]*command
+
+func init() {
+	commands = map[string]*command{
+		"ping":    {arity: -1, noTxn: true, run: ping},
+		"echo":    {arity: 2, noTxn: true, run: echo},
+		"select":  {arity: 2, noTxn: true, run: selectDB},
+		"command": {arity: -1, noTxn: true, run: commandInfo},
+		"get":     {arity: 2, run: get},
+		"set":     {arity: -3, write: true, run: set},
+		"del":     {arity: -2, write: true, run: del},
+		"exists":  {arity: -2, run: exists},
+		"expire":  {arity: 3, write: true, run: expire},
+		"persist": {arity: 2, write: true, run: persist},
+		"ttl":     {arity: 2, run: ttl},
+		"scan":    {arity: -2, run: scan},
+		"incr":    {arity: 2, write: true, run: incrBy(1)},
+		"decr":    {arity: 2, write: true, run: incrBy(-1)},
+		"incrby":  {arity: 3, write: true, run: incrBy(0)},
+		"decrby":  {arity: 3, write: true, run: incrBy(0)},
+	}
+}
+
+func ping(c *conn, _ *badger.Txn, args [][]byte) (interface{}, error) {
+	switch len(args) {
+	case 1:
+		return simpleString("PONG"), nil
+	case 2:
+		return args[1], nil
+	default:
+		return arityError("ping"), nil
+	}
+}
+
+func echo(c *conn, _ *badger.Txn, args [][]byte) (interface{}, error) {
+	return args[1], nil
+}
+
+// selectDB only accepts the database 0, which is the DB.
+func selectDB(c *conn, _ *badger.Txn, args [][]byte) (interface{}, error) {
+	if string(args[1]) != "0" {
+		return errorReply("ERR DB index is out of range"), nil
+	}
+	return okReply, nil
+}
+
+// commandInfo replies to the COMMAND command with no information, which the clients accept.
+func commandInfo(c *conn, _ *badger.Txn, args [][]byte) (interface{}, error) {
+	return []interface{}{}, nil
+}
+
+// getItem returns the item of key, or nil if the key doesn't exist.
+func getItem(txn *badger.Txn, key []byte) (*badger.Item, error) {
+	item, err := txn.Get(key)
+	if err == badger.ErrKeyNotFound {
+		return nil, nil
+	}
+	return item, err
+}
+
+func get(c *conn, txn *badger.Txn, args [][]byte) (interface{}, error) {
+	item, err := getItem(txn, args[1])
+	if item == nil || err != nil {
+		return nil, err
+	}
+	return item.ValueCopy(nil)
+}
+
+// set implements SET key value [EX seconds|PX milliseconds|KEEPTTL] [NX|XX].
+func set(c *conn, txn *badger.Txn, args [][]byte) (interface{}, error) {
+	e := badger.NewEntry(args[1], args[2])
+	var nx, xx, keepTTL bool
+	var ttl time.Duration
+	for i := 3; i < len(args); i++ {
+		switch opt := strings.ToLower(string(args[i])); {
+		case opt == "nx" && !xx:
+			nx = true
+		case opt == "xx" && !nx:
+			xx = true
+		case opt == "keepttl" && ttl == 0:
+			keepTTL = true
+		case (opt == "ex" || opt == "px") && ttl == 0 && !keepTTL && i+1 < len(args):
+			i++
+			n, err := strconv.ParseInt(string(args[i]), 10, 64)
+			if err != nil {
+				return notIntegerError(), nil
+			}
+			if n <= 0 {
+				return errorReply("ERR invalid expire time in 'set' command"), nil
+			}
+			unit := time.Second
+			if opt == "px" {
+				unit = time.Millisecond
+			}
+			if n > math.MaxInt64/int64(unit) {
+				return errorReply("ERR invalid expire time in 'set' command"), nil
+			}
+			ttl = time.Duration(n) * unit
+		default:
+			return syntaxError(), nil
+		}
+	}
+	if nx || xx || keepTTL {
+		item, err := getItem(txn, args[1])
+		switch {
+		case err != nil:
+			return nil, err
+		case nx && item != nil, xx && item == nil:
+			return nil, nil
+		case keepTTL && item != nil:
+			e.ExpiresAt = item.ExpiresAt()
+		}
+	}
+	if ttl > 0 {
+		// The DB expires the keys at the granularity of seconds.
+		e = e.WithTTL((ttl + time.Second - 1).Truncate(time.Second))
+	}
+	if err := txn.SetEntry(e); err != nil {
+		return nil, err
+	}
+	return okReply, nil
+}
+
+func del(c *conn, txn *badger.Txn, args [][]byte) (interface{}, error) {
+	var n int64
+	for _, key := range args[1:] {
+		item, err := getItem(txn, key)
+		if err != nil {
+			return nil, err
+		}
+		if item == nil {
+			continue
+		}
+		if err := txn.Delete(key); err != nil {
+			return nil, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+func exists(c *conn, txn *badger.Txn, args [][]byte) (interface{}, error) {
+	var n int64
+	for _, key := range args[1:] {
+		item, err := getItem(txn, key)
+		if err != nil {
+			return nil, err
+		}
+		if item != nil {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// rewrite writes the value of item again, with the expiry set by f.
+func rewrite(txn *badger.Txn, item *badger.Item, f func(e *badger.Entry) *badger.Entry) error {
+	val, err := item.ValueCopy(nil)
+	if err != nil {
+		return err
+	}
+	e := badger.NewEntry(item.KeyCopy(nil), val).WithMeta(item.UserMeta())
+	return txn.SetEntry(f(e))
+}
+
+func expire(c *conn, txn *badger.Txn, args [][]byte) (interface{}, error) {
+	secs, err := strconv.ParseInt(string(args[2]), 10, 64)
+	if err != nil || secs > math.MaxInt64/int64(time.Second) {
+		return notIntegerError(), nil
+	}
+	item, err := getItem(txn, args[1])
+	if item == nil || err != nil {
+		return int64(0), err
+	}
+	if secs <= 0 {
+		return int64(1), txn.Delete(args[1])
+	}
+	err = rewrite(txn, item, func(e *badger.Entry) *badger.Entry {
+		return e.WithTTL(time.Duration(secs) * time.Second)
+	})
+	return int64(1), err
+}
+
+func persist(c *conn, txn *badger.Txn, args [][]byte) (interface{}, error) {
+	item, err := getItem(txn, args[1])
+	if item == nil || item.ExpiresAt() == 0 || err != nil {
+		return int64(0), err
+	}
+	err = rewrite(txn, item, func(e *badger.Entry) *badger.Entry { return e })
+	return int64(1), err
+}
+
+func ttl(c *conn, txn *badger.Txn, args [][]byte) (interface{}, error) {
+	item, err := getItem(txn, args[1])
+	switch {
+	case err != nil:
+		return nil, err
+	case item == nil:
+		return int64(-2), nil
+	case item.ExpiresAt() == 0:
+		return int64(-1), nil
+	}
+	left := int64(item.ExpiresAt()) - time.Now().Unix()
+	if left < 0 {
+		left = 0
+	}
+	return left, nil
+}
+
+// IncrMerge is the merge function of the counters of INCR, INCRBY, DECR and DECRBY, which add
+// their increments to the integer value of the key as merge operands. The DB served by a Server
+// must register it for all the keys, via Options.WithMergeOperator(nil, IncrMerge). An increment
+// which doesn't apply to the value, because the value isn't an integer or the result would
+// overflow, is dropped. The commands check for that beforehand.
+func IncrMerge(existing, operand []byte) []byte {
+	n, err := strconv.ParseInt(string(existing), 10, 64)
+	if err != nil {
+		return existing
+	}
+	d, err := strconv.ParseInt(string(operand), 10, 64)
+	if err != nil || overflows(n, d) {
+		return existing
+	}
+	return []byte(strconv.FormatInt(n+d, 10))
+}
+
+func overflows(n, d int64) bool {
+	return (d > 0 && n > math.MaxInt64-d) || (d < 0 && n < math.MinInt64-d)
+}
+
+// counterReply is the reply of INCR and its variants before the commit, with the value of the
+// counter as seen by the transaction. The increments committed concurrently, which don't conflict
+// with the merge operands, are added to it once the transaction is committed. See conn.run.
+type counterReply struct {
+	key []byte
+	n   int64
+}
+
+// incrBy returns the implementation of INCR and DECR for a delta, or of INCRBY and DECRBY for a
+// delta of 0. The delta is written as a merge operand (see IncrMerge), so that the concurrent
+// increments of a key don't conflict. The counters with a TTL are rewritten along with their
+// expiry instead, which the merge operands can't keep.
+func incrBy(delta int64) func(c *conn, txn *badger.Txn, args [][]byte) (interface{}, error) {
+	return func(c *conn, txn *badger.Txn, args [][]byte) (interface{}, error) {
+		d := delta
+		if d == 0 {
+			var err error
+			if d, err = strconv.ParseInt(string(args[2]), 10, 64); err != nil {
+				return notIntegerError(), nil
+			}
+			if strings.ToLower(string(args[0])) == "decrby" {
+				if d == math.MinInt64 {
+					return errorReply("ERR decrement would overflow"), nil
+				}
+				d = -d
+			}
+		}
+		key := args[1]
+		n, expiresAt, err := counterValue(c, txn, key)
+		switch {
+		case err == errNotInteger:
+			return notIntegerError(), nil
+		case err != nil:
+			return nil, err
+		case overflows(n, d):
+			return errorReply("ERR increment or decrement would overflow"), nil
+		}
+		n += d
+		if expiresAt == 0 {
+			err = txn.Merge(key, []byte(strconv.FormatInt(d, 10)))
+		} else {
+			// Like in Redis, the expiry of the key is kept.
+			e := badger.NewEntry(key, []byte(strconv.FormatInt(n, 10)))
+			e.ExpiresAt = expiresAt
+			err = txn.SetEntry(e)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return counterReply{key: key, n: n}, nil
+	}
+}
+
+var errNotInteger = errors.New("Value is not an integer")
+
+// counterValue returns the integer value of the key as seen by the transaction, and its expiry.
+// The value is read by merging a zero increment into it, since the pending writes of the
+// transaction are read without a conflict. The expiry is read from the latest committed value,
+// since the merge operands don't carry it. Only the counters with a TTL are read for a conflict.
+func counterValue(c *conn, txn *badger.Txn, key []byte) (int64, uint64, error) {
+	var expiresAt uint64
+	if err := c.s.db.View(func(view *badger.Txn) error {
+		item, err := getItem(view, key)
+		if item != nil {
+			expiresAt = item.ExpiresAt()
+		}
+		return err
+	}); err != nil {
+		return 0, 0, err
+	}
+
+	var item *badger.Item
+	var err error
+	if expiresAt > 0 {
+		item, err = getItem(txn, key)
+	} else {
+		sp := txn.Savepoint()
+		if err = txn.Merge(key, []byte("0")); err != nil {
+			return 0, 0, err
+		}
+		item, err = getItem(txn, key)
+		if rerr := txn.RollbackTo(sp); err == nil {
+			err = rerr
+		}
+	}
+	if item == nil || err != nil {
+		return 0, 0, err
+	}
+	val, err := item.ValueCopy(nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	n, err := strconv.ParseInt(string(val), 10, 64)
+	if err != nil {
+		return 0, 0, errNotInteger
+	}
+	return n, item.ExpiresAt(), nil
+}
+
+// scan implements SCAN cursor [MATCH pattern] [COUNT count]. The cursors are kept by the
+// connection, as the keys of the DB can't be represented by integers.
+func scan(c *conn, txn *badger.Txn, args [][]byte) (interface{}, error) {
+	cursor, err := strconv.ParseUint(string(args[1]), 10, 64)
+	if err != nil {
+		return errorReply("ERR invalid cursor"), nil
+	}
+	var start []byte
+	if cursor != 0 {
+		var ok bool
+		if start, ok = c.cursors[cursor]; !ok {
+			return errorReply("ERR invalid cursor"), nil
+		}
+	}
+	var pattern []byte
+	count := 10
+	for i := 2; i < len(args); i++ {
+		switch opt := strings.ToLower(string(args[i])); {
+		case opt == "match" && i+1 < len(args):
+			i++
+			pattern = args[i]
+		case opt == "count" && i+1 < len(args):
+			i++
+			n, err := strconv.Atoi(string(args[i]))
+			if err != nil {
+				return notIntegerError(), nil
+			}
+			if n < 1 {
+				return syntaxError(), nil
+			}
+			count = n
+		default:
+			return syntaxError(), nil
+		}
+	}
+
+	opt := badger.DefaultIteratorOptions
+	opt.PrefetchValues = false
+	it := txn.NewIterator(opt)
+	defer it.Close()
+	keys := []interface{}{}
+	var next uint64
+	for it.Seek(start); it.Valid(); it.Next() {
+		key := it.Item().KeyCopy(nil)
+		if count == 0 {
+			next = c.addCursor(key)
+			break
+		}
+		count--
+		if pattern == nil || match(pattern, key) {
+			keys = append(keys, key)
+		}
+	}
+	return []interface{}{[]byte(strconv.FormatUint(next, 10)), keys}, nil
+}
+
+// match returns true if key matches the glob-style pattern, supporting *, ?, [...] and \ like in
+// Redis.
+func match(pattern, key []byte) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for len(pattern) > 1 && pattern[1] == '*' {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 1 {
+				return true
+			}
+			for i := 0; i <= len(key); i++ {
+				if match(pattern[1:], key[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if len(key) == 0 {
+				return false
+			}
+			key = key[1:]
+			pattern = pattern[1:]
+		case '[':
+			if len(key) == 0 {
+				return false
+			}
+			var matched bool
+			if matched, pattern = matchClass(pattern[1:], key[0]); !matched {
+				return false
+			}
+			key = key[1:]
+		default:
+			if pattern[0] == '\\' && len(pattern) > 1 {
+				pattern = pattern[1:]
+			}
+			if len(key) == 0 || key[0] != pattern[0] {
+				return false
+			}
+			key = key[1:]
+			pattern = pattern[1:]
+		}
+	}
+	return len(key) == 0
+}
+
+// matchClass matches b against the character class at the start of pattern, after its '['. It
+// returns the rest of the pattern, after the class.
+func matchClass(pattern []byte, b byte) (bool, []byte) {
+	not := len(pattern) > 0 && pattern[0] == '^'
+	if not {
+		pattern = pattern[1:]
+	}
+	var matched bool
+	for len(pattern) > 0 && pattern[0] != ']' {
+		switch {
+		case pattern[0] == '\\' && len(pattern) > 1:
+			matched = matched || pattern[1] == b
+			pattern = pattern[2:]
+		case len(pattern) > 2 && pattern[1] == '-' && pattern[2] != ']':
+			lo, hi := pattern[0], pattern[2]
+			if lo > hi {
+				lo, hi = hi, lo
+			}
+			matched = matched || (b >= lo && b <= hi)
+			pattern = pattern[3:]
+		default:
+			matched = matched || pattern[0] == b
+			pattern = pattern[1:]
+		}
+	}
+	if len(pattern) > 0 {
+		// Skip the closing bracket.
+		pattern = pattern[1:]
+	}
+	return matched != not, pattern
+}
@@ -0,0 +1,174 @@
+/*
+ * Copyright 2020 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package redis
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// The replies of the commands are represented by these types, and written in RESP2 by writeReply:
+//
+//	simpleString  Simple string, like +OK.
+//	errorReply    Error, like -ERR syntax error.
+//	int64         Integer.
+//	[]byte        Bulk string.
+//	nil           Null bulk string.
+//	[]interface{} Array of replies.
+//	nilArray      Null array.
+type (
+	simpleString string
+	errorReply   string
+	nilArray     struct{}
+)
+
+const (
+	okReply     = simpleString("OK")
+	queuedReply = simpleString("QUEUED")
+
+	// maxBulkLen and maxArrayLen bound the requests of the clients. The bulk strings larger than
+	// bulkChunk, and the arrays longer than argsChunk, are read without preallocating them.
+	maxBulkLen  = 512 << 20
+	maxArrayLen = 1 << 20
+	bulkChunk   = 64 << 10
+	argsChunk   = 1024
+)
+
+var errProtocol = errors.New("Protocol error")
+
+func syntaxError() errorReply {
+	return "ERR syntax error"
+}
+
+func notIntegerError() errorReply {
+	return "ERR value is not an integer or out of range"
+}
+
+func arityError(name string) errorReply {
+	return errorReply("ERR wrong number of arguments for '" + name + "' command")
+}
+
+// readCommand reads a command, sent either as an array of bulk strings, or inline, as a line of
+// arguments separated by spaces.
+func readCommand(r *bufio.Reader) ([][]byte, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 || line[0] != '*' {
+		return bytes.Fields(line), nil
+	}
+	n, err := strconv.Atoi(string(line[1:]))
+	if err != nil || n > maxArrayLen {
+		return nil, errors.Wrapf(errProtocol, "invalid multibulk length %q", line[1:])
+	}
+	capacity := n
+	if capacity > argsChunk {
+		capacity = argsChunk
+	}
+	args := make([][]byte, 0, capacity)
+	for i := 0; i < n; i++ {
+		line, err := readLine(r)
+		if err != nil {
+			return nil, err
+		}
+		if len(line) == 0 || line[0] != '$' {
+			return nil, errors.Wrapf(errProtocol, "expected '$', got %q", line)
+		}
+		sz, err := strconv.Atoi(string(line[1:]))
+		if err != nil || sz < 0 || sz > maxBulkLen {
+			return nil, errors.Wrapf(errProtocol, "invalid bulk length %q", line[1:])
+		}
+		arg, err := readBulk(r, sz)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+	return args, nil
+}
+
+// readBulk reads a bulk string of sz bytes followed by CRLF. The length is declared by the client,
+// so the buffer grows as the data arrives rather than being allocated upfront.
+func readBulk(r *bufio.Reader, sz int) ([]byte, error) {
+	var buf bytes.Buffer
+	if sz+2 <= bulkChunk {
+		buf.Grow(sz + 2)
+	}
+	if _, err := io.CopyN(&buf, r, int64(sz)+2); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	if !bytes.HasSuffix(buf.Bytes(), []byte("\r\n")) {
+		return nil, errors.Wrap(errProtocol, "bulk string not terminated by CRLF")
+	}
+	return buf.Bytes()[:sz], nil
+}
+
+// readLine reads a line, without its CRLF or LF terminator.
+func readLine(r *bufio.Reader) ([]byte, error) {
+	line, err := r.ReadBytes('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = line[:len(line)-1]
+	if len(line) > 0 && line[len(line)-1] == '\r' {
+		line = line[:len(line)-1]
+	}
+	return line, nil
+}
+
+// writeReply writes a reply in RESP2.
+func writeReply(w *bufio.Writer, reply interface{}) {
+	switch r := reply.(type) {
+	case simpleString:
+		w.WriteByte('+')
+		w.WriteString(string(r))
+	case errorReply:
+		w.WriteByte('-')
+		w.WriteString(string(r))
+	case int64:
+		w.WriteByte(':')
+		w.WriteString(strconv.FormatInt(r, 10))
+	case []byte:
+		w.WriteByte('$')
+		w.WriteString(strconv.Itoa(len(r)))
+		w.WriteString("\r\n")
+		w.Write(r)
+	case nil:
+		w.WriteString("$-1")
+	case nilArray:
+		w.WriteString("*-1")
+	case []interface{}:
+		w.WriteByte('*')
+		w.WriteString(strconv.Itoa(len(r)))
+		w.WriteString("\r\n")
+		for _, elem := range r {
+			writeReply(w, elem)
+		}
+		return
+	default:
+		panic(errors.Errorf("Invalid reply type %T", reply))
+	}
+	w.WriteString("\r\n")
+}
@@ -0,0 +1,326 @@
+/*
+ * Copyright 2020 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package redis serves a DB to the Redis clients, via the RESP2 protocol. The keys hold strings,
+// and expire via the TTL of the entries. The commands run within the transactions of the DB, and
+// the commands queued by MULTI run within a single transaction.
+package redis
+
+import (
+	"bufio"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/dgraph-io/badger/v2"
+	"github.com/dgraph-io/ristretto/z"
+	"github.com/pkg/errors"
+)
+
+const (
+	// maxCommitRetries is the number of times a transaction is retried on conflicts.
+	maxCommitRetries = 100
+	// maxCursors is the number of SCAN cursors kept per connection.
+	maxCursors = 1024
+)
+
+// Server serves a DB to the Redis clients.
+type Server struct {
+	db     *badger.DB
+	closer *z.Closer
+
+	sync.Mutex
+	conns  map[net.Conn]struct{}
+	closed bool
+}
+
+// NewServer returns a Server for db, which must not be opened in managed mode. The counters of
+// INCR and its variants are merge operands, so db must be opened with
+// Options.WithMergeOperator(nil, IncrMerge).
+func NewServer(db *badger.DB) *Server {
+	return &Server{
+		db:     db,
+		closer: z.NewCloser(0),
+		conns:  make(map[net.Conn]struct{}),
+	}
+}
+
+// Serve serves the clients connecting to l, until l or the Server is closed.
+func (s *Server) Serve(l net.Listener) error {
+	for {
+		nc, err := l.Accept()
+		if err != nil {
+			s.Lock()
+			closed := s.closed
+			s.Unlock()
+			if closed {
+				return nil
+			}
+			return err
+		}
+		s.Lock()
+		if s.closed {
+			s.Unlock()
+			nc.Close()
+			return nil
+		}
+		s.conns[nc] = struct{}{}
+		s.closer.AddRunning(1)
+		s.Unlock()
+		go func() {
+			defer s.closer.Done()
+			c := &conn{
+				s:       s,
+				nc:      nc,
+				r:       bufio.NewReader(nc),
+				w:       bufio.NewWriter(nc),
+				cursors: make(map[uint64][]byte),
+			}
+			c.serve()
+			s.Lock()
+			delete(s.conns, nc)
+			s.Unlock()
+			nc.Close()
+		}()
+	}
+}
+
+// Close disconnects the clients. It must be called before the DB is closed.
+func (s *Server) Close() error {
+	s.Lock()
+	s.closed = true
+	for nc := range s.conns {
+		nc.Close()
+	}
+	s.Unlock()
+	s.closer.SignalAndWait()
+	return nil
+}
+
+// conn is a connection of a client.
+type conn struct {
+	s  *Server
+	nc net.Conn
+	r  *bufio.Reader
+	w  *bufio.Writer
+
+	// multi is set after MULTI, and queued holds the commands to run on EXEC. multiErr is set
+	// if an invalid command was queued.
+	multi    bool
+	multiErr bool
+	queued   [][][]byte
+
+	cursors    map[uint64][]byte
+	nextCursor uint64
+}
+
+func (c *conn) serve() {
+	for {
+		args, err := readCommand(c.r)
+		if errors.Cause(err) == errProtocol {
+			writeReply(c.w, errorReply("ERR "+err.Error()))
+			c.w.Flush()
+			return
+		}
+		if err != nil {
+			return
+		}
+		if len(args) == 0 {
+			continue
+		}
+		quit := strings.ToLower(string(args[0])) == "quit"
+		if quit {
+			writeReply(c.w, okReply)
+		} else {
+			writeReply(c.w, c.handle(args))
+		}
+		// The replies of the pipelined commands are written together.
+		if c.r.Buffered() == 0 || quit {
+			if err := c.w.Flush(); err != nil || quit {
+				return
+			}
+		}
+	}
+}
+
+func (c *conn) handle(args [][]byte) interface{} {
+	name := strings.ToLower(string(args[0]))
+	switch name {
+	case "multi":
+		if c.multi {
+			return errorReply("ERR MULTI calls can not be nested")
+		}
+		c.multi = true
+		return okReply
+	case "exec":
+		if !c.multi {
+			return errorReply("ERR EXEC without MULTI")
+		}
+		queued, multiErr := c.queued, c.multiErr
+		c.resetMulti()
+		if multiErr {
+			return errorReply("EXECABORT Transaction discarded because of previous errors.")
+		}
+		return c.run(queued)
+	case "discard":
+		if !c.multi {
+			return errorReply("ERR DISCARD without MULTI")
+		}
+		c.resetMulti()
+		return okReply
+	}
+
+	cmd, ok := commands[name]
+	var reply interface{}
+	switch {
+	case !ok:
+		reply = errorReply("ERR unknown command '" + string(args[0]) + "'")
+	case (cmd.arity > 0 && len(args) != cmd.arity) || len(args) < -cmd.arity:
+		reply = arityError(name)
+	case c.multi:
+		c.queued = append(c.queued, args)
+		return queuedReply
+	default:
+		replies := c.run([][][]byte{args})
+		if r, ok := replies.([]interface{}); ok {
+			return r[0]
+		}
+		return replies
+	}
+	if c.multi {
+		c.multiErr = true
+	}
+	return reply
+}
+
+func (c *conn) resetMulti() {
+	c.multi, c.multiErr, c.queued = false, false, nil
+}
+
+// run runs the commands within a transaction, retried on conflicts. It returns the array of
+// their replies, or an error reply if the transaction failed.
+func (c *conn) run(cmds [][][]byte) interface{} {
+	var update, needTxn bool
+	for _, args := range cmds {
+		cmd := commands[strings.ToLower(string(args[0]))]
+		update = update || cmd.write
+		needTxn = needTxn || !cmd.noTxn
+	}
+
+	for i := 0; ; i++ {
+		var txn *badger.Txn
+		if needTxn {
+			txn = c.s.db.NewTransaction(update)
+		}
+		replies, err := c.runTxn(txn, cmds)
+		if err == nil && update {
+			err = c.commit(txn, replies)
+		}
+		if txn != nil {
+			txn.Discard()
+		}
+		switch {
+		case err == badger.ErrConflict && i < maxCommitRetries:
+			continue
+		case err != nil:
+			return errorReply("ERR " + err.Error())
+		}
+		return replies
+	}
+}
+
+func (c *conn) runTxn(txn *badger.Txn, cmds [][][]byte) ([]interface{}, error) {
+	replies := make([]interface{}, 0, len(cmds))
+	for _, args := range cmds {
+		cmd := commands[strings.ToLower(string(args[0]))]
+		reply, err := cmd.run(c, txn, args)
+		if err != nil {
+			return nil, err
+		}
+		replies = append(replies, reply)
+	}
+	return replies, nil
+}
+
+// commit commits the transaction, and replaces the counterReplies with the values of the counters
+// once committed. The increments are merge operands, which don't conflict with the increments
+// committed concurrently, so the values read by the transaction are offset by those increments.
+func (c *conn) commit(txn *badger.Txn, replies []interface{}) error {
+	offsets := make(map[string]int64)
+	for _, r := range replies {
+		if cr, ok := r.(counterReply); ok {
+			offsets[string(cr.key)] = 0
+		}
+	}
+	if len(offsets) == 0 {
+		return txn.Commit()
+	}
+	// The values as of the end of the transaction are pending writes, read without a conflict.
+	seen := make(map[string]int64)
+	for key := range offsets {
+		if n, ok := counterAt(txn, []byte(key)); ok {
+			seen[key] = n
+		}
+	}
+	// The read transaction keeps the versions written by the commit until they are read.
+	pin := c.s.db.NewTransaction(false)
+	defer pin.Discard()
+	if err := txn.Commit(); err != nil {
+		return err
+	}
+	// The commit is done, so the values seen by the transaction are returned if the committed
+	// ones can't be read.
+	if view, err := c.s.db.NewTransactionAsOf(txn.CommitTs()); err == nil {
+		for key, n := range seen {
+			if m, ok := counterAt(view, []byte(key)); ok {
+				offsets[key] = m - n
+			}
+		}
+		view.Discard()
+	}
+	for i, r := range replies {
+		if cr, ok := r.(counterReply); ok {
+			replies[i] = cr.n + offsets[string(cr.key)]
+		}
+	}
+	return nil
+}
+
+// counterAt returns the integer value of the key read by txn, if any.
+func counterAt(txn *badger.Txn, key []byte) (int64, bool) {
+	item, err := txn.Get(key)
+	if err != nil {
+		return 0, false
+	}
+	val, err := item.ValueCopy(nil)
+	if err != nil {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(string(val), 10, 64)
+	return n, err == nil
+}
+
+// addCursor returns a new SCAN cursor, which continues from key.
+func (c *conn) addCursor(key []byte) uint64 {
+	if len(c.cursors) >= maxCursors {
+		// The clients rarely leave scans unfinished, so the cursors are simply dropped.
+		c.cursors = make(map[uint64][]byte)
+	}
+	c.nextCursor++
+	c.cursors[c.nextCursor] = key
+	return c.nextCursor
+}
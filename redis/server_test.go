@@ -0,0 +1,279 @@
+/*
+ * Copyright 2020 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package redis
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/dgraph-io/badger/v2"
+	"github.com/stretchr/testify/require"
+)
+
+// client is a minimal Redis client, returning the replies with the types used by writeReply.
+type client struct {
+	t  *testing.T
+	nc net.Conn
+	r  *bufio.Reader
+}
+
+func (c *client) do(args ...string) interface{} {
+	cmd := make([]interface{}, len(args))
+	for i, a := range args {
+		cmd[i] = []byte(a)
+	}
+	w := bufio.NewWriter(c.nc)
+	writeReply(w, cmd)
+	require.NoError(c.t, w.Flush())
+	return c.read()
+}
+
+func (c *client) read() interface{} {
+	line, err := readLine(c.r)
+	require.NoError(c.t, err)
+	switch line[0] {
+	case '+':
+		return simpleString(line[1:])
+	case '-':
+		return errorReply(line[1:])
+	case ':':
+		n, err := strconv.ParseInt(string(line[1:]), 10, 64)
+		require.NoError(c.t, err)
+		return n
+	case '$':
+		n, err := strconv.Atoi(string(line[1:]))
+		require.NoError(c.t, err)
+		if n < 0 {
+			return nil
+		}
+		buf := make([]byte, n+2)
+		_, err = io.ReadFull(c.r, buf)
+		require.NoError(c.t, err)
+		return buf[:n]
+	case '*':
+		n, err := strconv.Atoi(string(line[1:]))
+		require.NoError(c.t, err)
+		if n < 0 {
+			return nilArray{}
+		}
+		arr := []interface{}{}
+		for i := 0; i < n; i++ {
+			arr = append(arr, c.read())
+		}
+		return arr
+	}
+	c.t.Fatalf("Invalid reply %q", line)
+	return nil
+}
+
+// serveDB serves a new DB, and returns a function connecting new clients to it.
+func serveDB(t *testing.T) (*badger.DB, func() *client, func()) {
+	dir, err := ioutil.TempDir("", "badger-test")
+	require.NoError(t, err)
+	opt := badger.DefaultOptions(dir).WithLogger(nil).WithMergeOperator(nil, IncrMerge)
+	db, err := badger.Open(opt)
+	require.NoError(t, err)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	srv := NewServer(db)
+	go srv.Serve(l)
+
+	var clients []*client
+	connect := func() *client {
+		nc, err := net.Dial("tcp", l.Addr().String())
+		require.NoError(t, err)
+		c := &client{t: t, nc: nc, r: bufio.NewReader(nc)}
+		clients = append(clients, c)
+		return c
+	}
+	return db, connect, func() {
+		for _, c := range clients {
+			c.nc.Close()
+		}
+		require.NoError(t, srv.Close())
+		require.NoError(t, l.Close())
+		require.NoError(t, db.Close())
+		require.NoError(t, os.RemoveAll(dir))
+	}
+}
+
+func TestCommands(t *testing.T) {
+	db, connect, cleanup := serveDB(t)
+	defer cleanup()
+	c := connect()
+
+	require.Equal(t, simpleString("PONG"), c.do("PING"))
+	require.Equal(t, nil, c.do("GET", "a"))
+	require.Equal(t, okReply, c.do("SET", "a", "1"))
+	require.Equal(t, []byte("1"), c.do("get", "a"))
+	require.Equal(t, nil, c.do("SET", "a", "2", "NX"))
+	require.Equal(t, nil, c.do("SET", "b", "2", "XX"))
+	require.Equal(t, syntaxError(), c.do("SET", "a", "2", "NX", "XX"))
+	require.Equal(t, int64(1), c.do("EXISTS", "a", "b"))
+
+	// Expiry.
+	require.Equal(t, int64(-1), c.do("TTL", "a"))
+	require.Equal(t, int64(-2), c.do("TTL", "b"))
+	require.Equal(t, int64(1), c.do("EXPIRE", "a", "100"))
+	ttl := c.do("TTL", "a").(int64)
+	require.True(t, ttl > 98 && ttl <= 100, "%d", ttl)
+	require.Equal(t, okReply, c.do("SET", "a", "3", "KEEPTTL"))
+	require.True(t, c.do("TTL", "a").(int64) > 98)
+	require.Equal(t, int64(1), c.do("PERSIST", "a"))
+	require.Equal(t, int64(-1), c.do("TTL", "a"))
+	require.Equal(t, okReply, c.do("SET", "b", "x", "EX", "100"))
+	require.NoError(t, db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte("b"))
+		require.NoError(t, err)
+		require.NotZero(t, item.ExpiresAt())
+		return nil
+	}))
+	require.Equal(t, int64(1), c.do("EXPIRE", "b", "0"))
+	require.Equal(t, nil, c.do("GET", "b"))
+
+	// Counters.
+	require.Equal(t, int64(4), c.do("INCR", "a"))
+	require.Equal(t, int64(-10), c.do("DECRBY", "a", "14"))
+	require.Equal(t, int64(1), c.do("INCR", "counter"))
+	require.Equal(t, okReply, c.do("SET", "ttl", "1", "EX", "100"))
+	require.Equal(t, int64(2), c.do("INCR", "ttl"))
+	require.True(t, c.do("TTL", "ttl").(int64) > 98)
+	require.Equal(t, okReply, c.do("SET", "s", "text"))
+	require.Equal(t, notIntegerError(), c.do("INCR", "s"))
+	require.Equal(t, okReply, c.do("SET", "max", "9223372036854775807"))
+	require.Equal(t, errorReply("ERR increment or decrement would overflow"),
+		c.do("INCR", "max"))
+
+	require.Equal(t, int64(2), c.do("DEL", "a", "s", "missing"))
+	require.Equal(t, arityError("get"), c.do("GET"))
+	require.Equal(t, errorReply("ERR unknown command 'FOO'"), c.do("FOO"))
+}
+
+func TestIncrConcurrent(t *testing.T) {
+	_, connect, cleanup := serveDB(t)
+	defer cleanup()
+
+	var wg sync.WaitGroup
+	replies := make(chan interface{}, 400)
+	for i := 0; i < 8; i++ {
+		c := connect()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				replies <- c.do("INCR", "counter")
+			}
+		}()
+	}
+	wg.Wait()
+	close(replies)
+	require.Equal(t, []byte("400"), connect().do("GET", "counter"))
+
+	// Each increment returns the value of the counter once it was committed.
+	seen := make(map[interface{}]bool)
+	for r := range replies {
+		require.False(t, seen[r], "%v returned twice", r)
+		seen[r] = true
+	}
+	require.True(t, seen[int64(1)] && seen[int64(400)])
+}
+
+func TestMultiExec(t *testing.T) {
+	_, connect, cleanup := serveDB(t)
+	defer cleanup()
+	c := connect()
+
+	require.Equal(t, okReply, c.do("MULTI"))
+	require.Equal(t, queuedReply, c.do("SET", "a", "1"))
+	require.Equal(t, queuedReply, c.do("INCR", "a"))
+	require.Equal(t, queuedReply, c.do("GET", "a"))
+	// The commands of the transaction aren't visible before EXEC.
+	require.Equal(t, nil, connect().do("GET", "a"))
+	require.Equal(t, []interface{}{okReply, int64(2), []byte("2")}, c.do("EXEC"))
+	require.Equal(t, []byte("2"), connect().do("GET", "a"))
+
+	require.Equal(t, errorReply("ERR EXEC without MULTI"), c.do("EXEC"))
+	require.Equal(t, okReply, c.do("MULTI"))
+	require.Equal(t, queuedReply, c.do("SET", "a", "3"))
+	require.Equal(t, okReply, c.do("DISCARD"))
+	require.Equal(t, []byte("2"), c.do("GET", "a"))
+
+	// An invalid command aborts the transaction.
+	require.Equal(t, okReply, c.do("MULTI"))
+	require.Equal(t, queuedReply, c.do("SET", "a", "4"))
+	require.Equal(t, arityError("set"), c.do("SET", "a"))
+	require.Equal(t,
+		errorReply("EXECABORT Transaction discarded because of previous errors."), c.do("EXEC"))
+	require.Equal(t, []byte("2"), c.do("GET", "a"))
+}
+
+func TestScan(t *testing.T) {
+	_, connect, cleanup := serveDB(t)
+	defer cleanup()
+	c := connect()
+
+	for i := 0; i < 25; i++ {
+		require.Equal(t, okReply, c.do("SET", fmt.Sprintf("key%02d", i), "v"))
+	}
+	require.Equal(t, okReply, c.do("SET", "other", "v"))
+
+	var keys []string
+	cursor := "0"
+	for {
+		reply := c.do("SCAN", cursor, "MATCH", "key*", "COUNT", "7").([]interface{})
+		for _, k := range reply[1].([]interface{}) {
+			keys = append(keys, string(k.([]byte)))
+		}
+		if cursor = string(reply[0].([]byte)); cursor == "0" {
+			break
+		}
+	}
+	require.Len(t, keys, 25)
+	require.Equal(t, "key00", keys[0])
+	require.Equal(t, "key24", keys[24])
+	require.Equal(t, errorReply("ERR invalid cursor"), c.do("SCAN", "12345"))
+}
+
+func TestMatch(t *testing.T) {
+	for _, tc := range []struct {
+		pattern, key string
+		match        bool
+	}{
+		{"*", "", true},
+		{"a*", "abc", true},
+		{"a*c", "abbbc", true},
+		{"a*c", "abbbd", false},
+		{"a?c", "abc", true},
+		{"a?c", "ac", false},
+		{"[ab]x", "bx", true},
+		{"[^ab]x", "bx", false},
+		{"[a-c]x", "cx", true},
+		{"[a-c]x", "dx", false},
+		{`a\*`, "a*", true},
+		{`a\*`, "ab", false},
+	} {
+		require.Equal(t, tc.match, match([]byte(tc.pattern), []byte(tc.key)),
+			"%q %q", tc.pattern, tc.key)
+	}
+}
@@ -0,0 +1,723 @@
+/*
+ * Copyright 2020 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package badger
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/dgraph-io/badger/v2/pb"
+	"github.com/dgraph-io/badger/v2/y"
+	"github.com/dgraph-io/ristretto/z"
+	"github.com/pkg/errors"
+)
+
+// replicaTsKey holds the commit timestamp up to which a replica has applied the changes of its
+// primary.
+var replicaTsKey = []byte("!badger!replica")
+
+// localKeyPrefixes are the prefixes of the internal keys which hold the state of the DB itself,
+// rather than of its data, and aren't replicated. The secondary indexes are maintained by the
+// functions registered on each DB, and must be added to the replicas separately.
+var localKeyPrefixes = [][]byte{
+	txnKey,
+	replicaTsKey,
+	clockKey,
+	snapshotPrefix,
+	indexPrefix,
+	indexMetaPrefix,
+}
+
+// isReplicatedKey returns true if the key is shipped by a primary to its replicas. The user keys
+// are, and so are the internal keys which aren't local to the DB, like the keyspaces and the range
+// tombstones.
+func isReplicatedKey(key []byte) bool {
+	if !bytes.HasPrefix(key, badgerPrefix) {
+		return true
+	}
+	for _, prefix := range localKeyPrefixes {
+		if bytes.HasPrefix(key, prefix) {
+			return false
+		}
+	}
+	return true
+}
+
+const (
+	// replicationHeartbeat is the interval at which a primary tells its idle replicas its latest
+	// commit timestamp.
+	replicationHeartbeat = time.Second
+	// replicationMaxBackoff is the longest a replica waits before reconnecting to its primary.
+	replicationMaxBackoff = 5 * time.Second
+)
+
+var (
+	// ErrReplicaBehind is returned to a replica by a primary, if the replica has to be synced
+	// again from a snapshot, because the changes it needs aren't in the backlog of the primary.
+	ErrReplicaBehind = errors.New("Replica is too far behind the primary")
+
+	errPrimaryClosed = errors.New("Primary closed")
+)
+
+// ReplicationMessageType is the type of a ReplicationMessage.
+type ReplicationMessageType byte
+
+const (
+	// ReplicationHello is sent by a replica when it connects to its primary. Ts is the commit
+	// timestamp up to which the replica has applied the changes.
+	ReplicationHello ReplicationMessageType = iota + 1
+	// ReplicationBatch holds the changes committed at Ts by the primary.
+	ReplicationBatch
+	// ReplicationSnapshotStart starts the snapshot of the primary at Ts, which replaces all the
+	// data of the replica.
+	ReplicationSnapshotStart
+	// ReplicationSnapshot holds the key-values of the snapshot, as produced by a Stream.
+	ReplicationSnapshot
+	// ReplicationSnapshotDone marks the end of the snapshot.
+	ReplicationSnapshotDone
+	// ReplicationHeartbeat is sent by the primary when it's idle. Ts is its latest commit
+	// timestamp.
+	ReplicationHeartbeat
+)
+
+// ReplicationMessage is a message exchanged between a primary and its replicas.
+type ReplicationMessage struct {
+	Type ReplicationMessageType
+	Ts   uint64
+	KVs  *pb.KVList
+}
+
+// Marshal encodes the message. It can be used by the transports which need to send the messages as
+// bytes.
+func (m *ReplicationMessage) Marshal() ([]byte, error) {
+	var kvs []byte
+	if m.KVs != nil {
+		var err error
+		if kvs, err = m.KVs.Marshal(); err != nil {
+			return nil, err
+		}
+	}
+	buf := make([]byte, 9+len(kvs))
+	buf[0] = byte(m.Type)
+	binary.BigEndian.PutUint64(buf[1:9], m.Ts)
+	copy(buf[9:], kvs)
+	return buf, nil
+}
+
+// Unmarshal decodes the message encoded by Marshal.
+func (m *ReplicationMessage) Unmarshal(buf []byte) error {
+	if len(buf) < 9 {
+		return errors.Errorf("Replication message is too short: %d bytes", len(buf))
+	}
+	m.Type = ReplicationMessageType(buf[0])
+	m.Ts = binary.BigEndian.Uint64(buf[1:9])
+	m.KVs = nil
+	if len(buf) > 9 {
+		m.KVs = &pb.KVList{}
+		return m.KVs.Unmarshal(buf[9:])
+	}
+	return nil
+}
+
+// ReplicationConn carries the replication messages between a primary and a replica. Send and Recv
+// are called by a single goroutine each, but Close can be called concurrently with them, and must
+// unblock them.
+type ReplicationConn interface {
+	Send(msg *ReplicationMessage) error
+	Recv() (*ReplicationMessage, error)
+	Close() error
+}
+
+// ReplicationListener accepts the connections of the replicas on a primary.
+type ReplicationListener interface {
+	Accept() (ReplicationConn, error)
+	Close() error
+}
+
+// ReplicationDialer connects a replica to its primary.
+type ReplicationDialer interface {
+	Dial() (ReplicationConn, error)
+}
+
+// replBatch holds the changes committed at ts.
+type replBatch struct {
+	ts   uint64
+	kvs  *pb.KVList
+	size int64
+}
+
+// Primary ships the changes committed on a DB to its replicas. The recent changes are kept in a
+// backlog, from which the replicas catch up after being disconnected. The replicas which are too
+// far behind are synced again from a snapshot of the DB.
+//
+// The DB must not be opened in managed mode, so that the changes are committed in the order of
+// their commit timestamps.
+type Primary struct {
+	db          *DB
+	backlogSize int64
+	closer      *z.Closer
+
+	sync.Mutex
+	backlog []replBatch
+	size    int64
+	// startTs is the commit timestamp up to which the changes have been removed from the backlog,
+	// or were committed before the Primary was created.
+	startTs uint64
+	lastTs  uint64
+	// notify is closed and replaced when a batch is added to the backlog.
+	notify chan struct{}
+	conns  map[ReplicationConn]struct{}
+	closed bool
+}
+
+// NewPrimary starts shipping the changes committed on the DB to the replicas, which connect via
+// Primary.Serve. backlogSize is the size of the changes kept in memory, for the replicas to catch
+// up after being disconnected. It should be large enough to hold the changes committed while a
+// snapshot is sent to a replica. Only one Primary can be created for a DB.
+func (db *DB) NewPrimary(backlogSize int64) (*Primary, error) {
+	switch {
+	case db.opt.managedTxns:
+		return nil, errors.New("A primary cannot be opened in managed mode")
+	case db.opt.ReadOnly:
+		return nil, errors.New("A primary cannot be opened in read-only mode")
+	}
+	p := &Primary{
+		db:          db,
+		backlogSize: backlogSize,
+		closer:      z.NewCloser(0),
+		notify:      make(chan struct{}),
+		conns:       make(map[ReplicationConn]struct{}),
+	}
+	db.primaryLock.Lock()
+	if db.primary != nil {
+		db.primaryLock.Unlock()
+		return nil, errors.New("The DB already has a primary")
+	}
+	db.primary = p
+	db.primaryLock.Unlock()
+
+	// The commits which were in flight while the Primary was installed might not have been added
+	// to the backlog. Reading at the latest commit waits for them to be done.
+	txn := db.NewTransaction(false)
+	startTs := txn.readTs
+	txn.Discard()
+	p.Lock()
+	defer p.Unlock()
+	p.startTs = startTs
+	for len(p.backlog) > 0 && p.backlog[0].ts <= startTs {
+		p.size -= p.backlog[0].size
+		p.backlog = p.backlog[1:]
+	}
+	if p.lastTs < startTs {
+		p.lastTs = startTs
+	}
+	return p, nil
+}
+
+// add adds the changes of the requests, which have just been written, to the backlog. It is called
+// by the write goroutine.
+func (p *Primary) add(reqs []*request) {
+	p.Lock()
+	defer p.Unlock()
+
+	var added bool
+	for _, req := range reqs {
+		var b replBatch
+		for _, e := range req.Entries {
+			key, version := y.ParseKey(e.Key), y.ParseTs(e.Key)
+			switch {
+			case version <= p.lastTs:
+				// The entries rewritten by the value log GC keep their versions.
+				continue
+			case e.meta&bitFinTxn > 0:
+				continue
+			case !isReplicatedKey(key):
+				continue
+			}
+			if b.kvs == nil {
+				b.ts = version
+				b.kvs = &pb.KVList{}
+			}
+			kv := &pb.KV{
+				Key:       y.SafeCopy(nil, key),
+				Value:     y.SafeCopy(nil, e.Value),
+				UserMeta:  []byte{e.UserMeta},
+				Meta:      []byte{e.meta &^ (bitTxn | bitFinTxn | bitValuePointer)},
+				ExpiresAt: e.ExpiresAt,
+				Version:   version,
+			}
+			b.kvs.Kv = append(b.kvs.Kv, kv)
+			b.size += int64(kv.Size())
+		}
+		if b.kvs == nil {
+			continue
+		}
+		p.backlog = append(p.backlog, b)
+		p.size += b.size
+		p.lastTs = b.ts
+		added = true
+	}
+	// The last batch is always kept, so that the replicas which are up to date can be served.
+	for p.size > p.backlogSize && len(p.backlog) > 1 {
+		p.startTs = p.backlog[0].ts
+		p.size -= p.backlog[0].size
+		p.backlog = p.backlog[1:]
+	}
+	if added {
+		close(p.notify)
+		p.notify = make(chan struct{})
+	}
+}
+
+// next returns the batches committed after ts, and a channel closed when more are added.
+func (p *Primary) next(ts uint64) ([]replBatch, chan struct{}, error) {
+	p.Lock()
+	defer p.Unlock()
+	switch {
+	case p.closed:
+		return nil, nil, errPrimaryClosed
+	case ts < p.startTs:
+		return nil, nil, ErrReplicaBehind
+	}
+	// The backlog is sorted by the commit timestamps.
+	i := len(p.backlog)
+	for i > 0 && p.backlog[i-1].ts > ts {
+		i--
+	}
+	return p.backlog[i:], p.notify, nil
+}
+
+// covers returns true if the replica which has applied the changes up to ts can be served from the
+// backlog.
+func (p *Primary) covers(ts uint64) bool {
+	p.Lock()
+	defer p.Unlock()
+	return ts >= p.startTs && ts <= p.lastTs
+}
+
+// Serve accepts the replicas from l, and ships them the changes, until l or the Primary is closed.
+func (p *Primary) Serve(l ReplicationListener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if p.isClosed() {
+				return nil
+			}
+			return err
+		}
+		p.Lock()
+		if p.closed {
+			p.Unlock()
+			conn.Close()
+			return nil
+		}
+		p.conns[conn] = struct{}{}
+		p.closer.AddRunning(1)
+		p.Unlock()
+		go func() {
+			defer p.closer.Done()
+			if err := p.serveConn(conn); err != nil && !p.isClosed() {
+				p.db.opt.Warningf("While serving replica: %v", err)
+			}
+			p.Lock()
+			delete(p.conns, conn)
+			p.Unlock()
+			conn.Close()
+		}()
+	}
+}
+
+func (p *Primary) isClosed() bool {
+	p.Lock()
+	defer p.Unlock()
+	return p.closed
+}
+
+func (p *Primary) serveConn(conn ReplicationConn) error {
+	hello, err := conn.Recv()
+	if err != nil {
+		return err
+	}
+	if hello.Type != ReplicationHello {
+		return errors.Errorf("Expected hello from replica, got message type %d", hello.Type)
+	}
+	ts := hello.Ts
+	if !p.covers(ts) {
+		if ts, err = p.sendSnapshot(conn); err != nil {
+			return err
+		}
+	}
+
+	ticker := time.NewTicker(replicationHeartbeat)
+	defer ticker.Stop()
+	for {
+		batches, notify, err := p.next(ts)
+		if err != nil {
+			return err
+		}
+		for _, b := range batches {
+			msg := &ReplicationMessage{Type: ReplicationBatch, Ts: b.ts, KVs: b.kvs}
+			if err := conn.Send(msg); err != nil {
+				return err
+			}
+			ts = b.ts
+		}
+		if len(batches) > 0 {
+			continue
+		}
+		select {
+		case <-notify:
+		case <-ticker.C:
+			msg := &ReplicationMessage{Type: ReplicationHeartbeat, Ts: ts}
+			if err := conn.Send(msg); err != nil {
+				return err
+			}
+		case <-p.closer.HasBeenClosed():
+			return errPrimaryClosed
+		}
+	}
+}
+
+// sendSnapshot sends a snapshot of the DB to the replica. It returns the timestamp of the snapshot.
+func (p *Primary) sendSnapshot(conn ReplicationConn) (uint64, error) {
+	// Keeping the read transaction open protects the snapshot from compactions.
+	txn := p.db.NewTransaction(false)
+	defer txn.Discard()
+	readTs := txn.readTs
+	p.db.opt.Infof("Sending snapshot at %d to replica", readTs)
+
+	if err := conn.Send(&ReplicationMessage{
+		Type: ReplicationSnapshotStart, Ts: readTs}); err != nil {
+		return 0, err
+	}
+	stream := p.db.newStream()
+	stream.LogPrefix = "Primary.Snapshot"
+	stream.readTs = readTs
+	stream.KeyToList = latestToList(1)
+	stream.chooseInternal = isReplicatedKey
+	stream.Send = func(buf *z.Buffer) error {
+		list, err := BufferToKVList(buf)
+		if err != nil {
+			return err
+		}
+		return conn.Send(&ReplicationMessage{Type: ReplicationSnapshot, KVs: list})
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-p.closer.HasBeenClosed():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	if err := stream.Orchestrate(ctx); err != nil {
+		return 0, err
+	}
+	err := conn.Send(&ReplicationMessage{Type: ReplicationSnapshotDone, Ts: readTs})
+	return readTs, err
+}
+
+// Close disconnects the replicas, and stops shipping the changes. It must be called before the DB
+// is closed.
+func (p *Primary) Close() error {
+	p.Lock()
+	if p.closed {
+		p.Unlock()
+		return nil
+	}
+	p.closed = true
+	for conn := range p.conns {
+		conn.Close()
+	}
+	p.Unlock()
+	p.closer.SignalAndWait()
+
+	p.db.primaryLock.Lock()
+	p.db.primary = nil
+	p.db.primaryLock.Unlock()
+	return nil
+}
+
+// Replica applies the changes shipped by a primary to a DB opened in managed mode, at the commit
+// timestamps of the primary. The replicated data must be read via the transactions of the replica,
+// which read at the timestamp up to which the changes have been applied. The replica reconnects to
+// the primary when the connection is lost, and resumes where it stopped.
+//
+// The DB must not be written to by other means. The replica doesn't set the discard timestamp of
+// the DB, see DB.SetDiscardTs. While a snapshot of the primary is received, all the data of the
+// replica is dropped. The keyspaces and the range deletions are replicated, but the secondary
+// indexes and the named snapshots are local to each DB.
+type Replica struct {
+	db     *DB
+	dialer ReplicationDialer
+	closer *z.Closer
+
+	// readTs is the commit timestamp of the primary up to which the changes have been applied,
+	// and primaryTs the latest one received from the primary. Accessed atomically.
+	readTs    uint64
+	primaryTs uint64
+
+	sync.Mutex
+	conn   ReplicationConn
+	closed bool
+}
+
+// NewReplica starts replicating the changes of the primary reached via dialer into the DB, which
+// must be opened in managed mode.
+func (db *DB) NewReplica(dialer ReplicationDialer) (*Replica, error) {
+	switch {
+	case !db.opt.managedTxns:
+		return nil, errors.New("A replica must be opened in managed mode")
+	case db.opt.ReadOnly:
+		return nil, errors.New("A replica cannot be opened in read-only mode")
+	}
+	r := &Replica{db: db, dialer: dialer, closer: z.NewCloser(1)}
+	txn := db.NewTransactionAt(^uint64(0), false)
+	defer txn.Discard()
+	item, err := txn.Get(replicaTsKey)
+	switch {
+	case err == ErrKeyNotFound:
+	case err != nil:
+		return nil, err
+	default:
+		if err := item.Value(func(val []byte) error {
+			r.setReadTs(binary.BigEndian.Uint64(val))
+			return nil
+		}); err != nil {
+			return nil, err
+		}
+	}
+	go r.run()
+	return r, nil
+}
+
+// ReadTs returns the commit timestamp of the primary, up to which the changes have been applied.
+func (r *Replica) ReadTs() uint64 {
+	return atomic.LoadUint64(&r.readTs)
+}
+
+// Lag returns the number of commit timestamps by which the replica is behind the primary, as of
+// the last message from the primary.
+func (r *Replica) Lag() uint64 {
+	readTs, primaryTs := r.ReadTs(), atomic.LoadUint64(&r.primaryTs)
+	if primaryTs < readTs {
+		return 0
+	}
+	return primaryTs - readTs
+}
+
+// NewTransaction returns a read-only transaction, which reads the replicated data at ReadTs.
+func (r *Replica) NewTransaction() *Txn {
+	return r.db.NewTransactionAt(r.ReadTs(), false)
+}
+
+// View runs fn within a read-only transaction of the replica, see NewTransaction.
+func (r *Replica) View(fn func(txn *Txn) error) error {
+	txn := r.NewTransaction()
+	defer txn.Discard()
+	return fn(txn)
+}
+
+func (r *Replica) run() {
+	defer r.closer.Done()
+
+	backoff := 100 * time.Millisecond
+	for {
+		applied := r.ReadTs()
+		err := r.replicate()
+		if r.isClosed() {
+			return
+		}
+		r.db.opt.Warningf("Replication from primary stopped: %v. Reconnecting...", err)
+		if r.ReadTs() > applied {
+			backoff = 100 * time.Millisecond
+		}
+		select {
+		case <-time.After(backoff):
+		case <-r.closer.HasBeenClosed():
+			return
+		}
+		if backoff *= 2; backoff > replicationMaxBackoff {
+			backoff = replicationMaxBackoff
+		}
+	}
+}
+
+func (r *Replica) isClosed() bool {
+	r.Lock()
+	defer r.Unlock()
+	return r.closed
+}
+
+// replicate applies the changes received via a single connection to the primary.
+func (r *Replica) replicate() error {
+	conn, err := r.dialer.Dial()
+	if err != nil {
+		return err
+	}
+	r.Lock()
+	if r.closed {
+		r.Unlock()
+		return conn.Close()
+	}
+	r.conn = conn
+	r.Unlock()
+	defer func() {
+		r.Lock()
+		r.conn = nil
+		r.Unlock()
+		conn.Close()
+	}()
+
+	if err := conn.Send(&ReplicationMessage{
+		Type: ReplicationHello, Ts: r.ReadTs()}); err != nil {
+		return err
+	}
+	var sw *StreamWriter
+	defer func() {
+		if sw != nil {
+			sw.Cancel()
+		}
+	}()
+	for {
+		msg, err := conn.Recv()
+		if err != nil {
+			return err
+		}
+		switch msg.Type {
+		case ReplicationBatch:
+			if err := r.apply(msg.Ts, msg.KVs); err != nil {
+				return err
+			}
+			r.setPrimaryTs(msg.Ts)
+		case ReplicationHeartbeat:
+			r.setPrimaryTs(msg.Ts)
+		case ReplicationSnapshotStart:
+			r.db.opt.Infof("Receiving snapshot at %d from primary", msg.Ts)
+			sw = r.db.NewStreamWriter()
+			if err := sw.Prepare(); err != nil {
+				return err
+			}
+		case ReplicationSnapshot:
+			if sw == nil {
+				return errors.New("Snapshot data received before its start")
+			}
+			buf := z.NewBuffer(1 << 20)
+			for _, kv := range msg.KVs.GetKv() {
+				KVToBuffer(kv, buf)
+			}
+			err := sw.Write(buf)
+			buf.Release()
+			if err != nil {
+				return err
+			}
+		case ReplicationSnapshotDone:
+			if sw == nil {
+				return errors.New("Snapshot end received before its start")
+			}
+			err := sw.Flush()
+			sw = nil
+			if err != nil {
+				return err
+			}
+			if err := r.apply(msg.Ts, nil); err != nil {
+				return err
+			}
+			r.setPrimaryTs(msg.Ts)
+		default:
+			return errors.Errorf("Unexpected replication message type %d", msg.Type)
+		}
+	}
+}
+
+// apply writes the changes committed at ts by the primary, along with ts itself.
+func (r *Replica) apply(ts uint64, kvs *pb.KVList) error {
+	txn := r.db.newTransaction(true, true)
+	defer txn.Discard()
+	for _, kv := range kvs.GetKv() {
+		e := &Entry{
+			Key:       kv.Key,
+			Value:     kv.Value,
+			ExpiresAt: kv.ExpiresAt,
+		}
+		if len(kv.Meta) > 0 {
+			e.meta = kv.Meta[0]
+		}
+		if len(kv.UserMeta) > 0 {
+			e.UserMeta = kv.UserMeta[0]
+		}
+		if err := txn.modifyInternal(e); err != nil {
+			return err
+		}
+	}
+	var tsBuf [8]byte
+	binary.BigEndian.PutUint64(tsBuf[:], ts)
+	if err := txn.modifyInternal(&Entry{Key: replicaTsKey, Value: tsBuf[:]}); err != nil {
+		return err
+	}
+	if err := txn.CommitAt(ts, nil); err != nil {
+		return y.Wrapf(err, "while applying changes at %d", ts)
+	}
+	for _, kv := range kvs.GetKv() {
+		if bytes.HasPrefix(kv.Key, keyspaceDefPrefix) {
+			if err := r.db.addKeyspaceDef(kv.Value); err != nil {
+				return err
+			}
+		}
+	}
+	r.setReadTs(ts)
+	return nil
+}
+
+func (r *Replica) setReadTs(ts uint64) {
+	atomic.StoreUint64(&r.readTs, ts)
+	r.db.metrics.ReplicaReadTs.Set(int64(ts))
+	r.updateLag()
+}
+
+func (r *Replica) setPrimaryTs(ts uint64) {
+	if ts > atomic.LoadUint64(&r.primaryTs) {
+		atomic.StoreUint64(&r.primaryTs, ts)
+		r.db.metrics.ReplicaPrimaryTs.Set(int64(ts))
+	}
+	r.updateLag()
+}
+
+func (r *Replica) updateLag() {
+	r.db.metrics.ReplicaLag.Set(int64(r.Lag()))
+}
+
+// Close stops the replication. It must be called before the DB is closed.
+func (r *Replica) Close() error {
+	r.Lock()
+	if r.closed {
+		r.Unlock()
+		return nil
+	}
+	r.closed = true
+	if r.conn != nil {
+		r.conn.Close()
+	}
+	r.Unlock()
+	r.closer.SignalAndWait()
+	return nil
+}
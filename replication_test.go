@@ -0,0 +1,242 @@
+/*
+ * Copyright 2020 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package badger
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// openReplication opens a primary and a replica DB, connected via the pipe transport.
+func openReplication(t *testing.T, backlogSize int64) (*DB, *Primary, *DB, *Replica,
+	*PipeReplicationTransport, func()) {
+	pdir, err := ioutil.TempDir("", "badger-test")
+	require.NoError(t, err)
+	rdir, err := ioutil.TempDir("", "badger-test")
+	require.NoError(t, err)
+	pdb, err := Open(getTestOptions(pdir))
+	require.NoError(t, err)
+	rdb, err := OpenManaged(getTestOptions(rdir))
+	require.NoError(t, err)
+
+	primary, err := pdb.NewPrimary(backlogSize)
+	require.NoError(t, err)
+	transport := NewPipeReplicationTransport()
+	go primary.Serve(transport)
+	replica, err := rdb.NewReplica(transport)
+	require.NoError(t, err)
+	return pdb, primary, rdb, replica, transport, func() {
+		require.NoError(t, replica.Close())
+		require.NoError(t, primary.Close())
+		require.NoError(t, transport.Close())
+		require.NoError(t, rdb.Close())
+		require.NoError(t, pdb.Close())
+		removeDir(pdir)
+		removeDir(rdir)
+	}
+}
+
+// requireReplicated waits for the replica to catch up, and checks that it holds the same data as
+// the primary.
+func requireReplicated(t *testing.T, pdb *DB, replica *Replica) {
+	readTs := pdb.MaxVersion()
+	waitUntil(t, func() bool { return replica.ReadTs() >= readTs })
+	read := func(txn *Txn) map[string]string {
+		m := make(map[string]string)
+		it := txn.NewIterator(DefaultIteratorOptions)
+		defer it.Close()
+		for it.Rewind(); it.Valid(); it.Next() {
+			m[string(it.Item().Key())] = string(getItemValue(t, it.Item()))
+		}
+		return m
+	}
+	var want map[string]string
+	require.NoError(t, pdb.View(func(txn *Txn) error {
+		want = read(txn)
+		return nil
+	}))
+	require.NoError(t, replica.View(func(txn *Txn) error {
+		require.Equal(t, want, read(txn))
+		return nil
+	}))
+	require.Equal(t, uint64(0), replica.Lag())
+}
+
+func TestReplication(t *testing.T) {
+	pdb, primary, rdb, replica, _, cleanup := openReplication(t, 1<<20)
+	defer cleanup()
+
+	key := func(i int) []byte { return []byte(fmt.Sprintf("key%03d", i)) }
+	for i := 0; i < 100; i++ {
+		txnSet(t, pdb, key(i), []byte(fmt.Sprintf("val%d", i)), 0)
+	}
+	requireReplicated(t, pdb, replica)
+
+	txnDelete(t, pdb, key(0))
+	require.NoError(t, pdb.Update(func(txn *Txn) error {
+		return txn.DeleteRange(key(10), key(20))
+	}))
+	txnSet(t, pdb, key(1), []byte("updated"), 0x01)
+	requireReplicated(t, pdb, replica)
+
+	// The versions of the replica are the commit timestamps of the primary.
+	require.NoError(t, replica.View(func(txn *Txn) error {
+		item, err := txn.Get(key(1))
+		require.NoError(t, err)
+		require.Equal(t, pdb.MaxVersion(), item.Version())
+		require.Equal(t, byte(0x01), item.UserMeta())
+		return nil
+	}))
+
+	// The replica reconnects, and resumes from the backlog.
+	primary.Lock()
+	for conn := range primary.conns {
+		conn.Close()
+	}
+	primary.Unlock()
+	for i := 100; i < 110; i++ {
+		txnSet(t, pdb, key(i), []byte("after"), 0)
+	}
+	requireReplicated(t, pdb, replica)
+	require.Equal(t, int64(replica.ReadTs()), rdb.Metrics().ReplicaReadTs.Value())
+
+}
+
+func TestReplicationSnapshot(t *testing.T) {
+	pdb, primary, _, replica, transport, cleanup := openReplication(t, 1<<10)
+	defer cleanup()
+
+	key := func(i int) []byte { return []byte(fmt.Sprintf("key%03d", i)) }
+	txnSet(t, pdb, key(0), []byte("first"), 0)
+	requireReplicated(t, pdb, replica)
+
+	// Stop the replica, and write more than the backlog holds.
+	require.NoError(t, replica.Close())
+	for i := 1; i < 200; i++ {
+		txnSet(t, pdb, key(i), []byte(fmt.Sprintf("val%d", i)), 0)
+	}
+	txnDelete(t, pdb, key(0))
+	primary.Lock()
+	require.True(t, primary.startTs > replica.ReadTs())
+	primary.Unlock()
+
+	// A new replica resumes from the timestamp stored in the DB, and is synced from a snapshot.
+	replica, err := replica.db.NewReplica(transport)
+	require.NoError(t, err)
+	defer replica.Close()
+	requireReplicated(t, pdb, replica)
+	txnSet(t, pdb, key(0), []byte("again"), 0)
+	requireReplicated(t, pdb, replica)
+}
+
+func TestReplicationInternalKeys(t *testing.T) {
+	pdb, primary, rdb, replica, transport, cleanup := openReplication(t, 1<<10)
+	defer cleanup()
+
+	write := func(ks *Keyspace, val string) {
+		require.NoError(t, pdb.Update(func(txn *Txn) error {
+			for i := 0; i < 10; i++ {
+				require.NoError(t, ks.Set(txn, rangeKey(i), []byte(val)))
+			}
+			return nil
+		}))
+		require.NoError(t, pdb.Update(func(txn *Txn) error {
+			return txn.DeleteRange([]byte(val+"0"), []byte(val+"5"))
+		}))
+	}
+	check := func(name, val string) {
+		requireReplicated(t, pdb, replica)
+		ks, err := rdb.Keyspace(name)
+		require.NoError(t, err)
+		require.NoError(t, replica.View(func(txn *Txn) error {
+			for i := 0; i < 10; i++ {
+				item, err := ks.Get(txn, rangeKey(i))
+				require.NoError(t, err)
+				require.Equal(t, []byte(val), getItemValue(t, item))
+			}
+			return nil
+		}))
+		require.Equal(t, len(pdb.rangeDels.tombstones), len(rdb.rangeDels.tombstones))
+		// The named snapshots are local to the primary.
+		_, ok := rdb.orc.getSnapshot("snap")
+		require.False(t, ok)
+	}
+
+	// The keyspaces and the range deletions are shipped with the changes.
+	ks, err := pdb.CreateKeyspace("live", pdb.DefaultKeyspaceOptions())
+	require.NoError(t, err)
+	_, err = pdb.CreateSnapshot("snap")
+	require.NoError(t, err)
+	write(ks, "live")
+	check("live", "live")
+
+	// And with the snapshots of the primary.
+	require.NoError(t, replica.Close())
+	ks, err = pdb.CreateKeyspace("snapshot", pdb.DefaultKeyspaceOptions())
+	require.NoError(t, err)
+	write(ks, "snapshot")
+	for i := 0; i < 100; i++ {
+		txnSet(t, pdb, []byte(fmt.Sprintf("key%03d", i)), []byte("value"), 0)
+	}
+	primary.Lock()
+	require.True(t, primary.startTs > replica.ReadTs())
+	primary.Unlock()
+	replica, err = rdb.NewReplica(transport)
+	require.NoError(t, err)
+	defer replica.Close()
+	check("live", "live")
+	check("snapshot", "snapshot")
+}
+
+func TestReplicationTCP(t *testing.T) {
+	pdir, err := ioutil.TempDir("", "badger-test")
+	require.NoError(t, err)
+	defer removeDir(pdir)
+	rdir, err := ioutil.TempDir("", "badger-test")
+	require.NoError(t, err)
+	defer removeDir(rdir)
+	pdb, err := Open(getTestOptions(pdir))
+	require.NoError(t, err)
+	defer func() { require.NoError(t, pdb.Close()) }()
+	rdb, err := OpenManaged(getTestOptions(rdir))
+	require.NoError(t, err)
+	defer func() { require.NoError(t, rdb.Close()) }()
+
+	// Written before the primary exists, so only available via a snapshot.
+	txnSet(t, pdb, []byte("before"), []byte("value"), 0)
+
+	primary, err := pdb.NewPrimary(1 << 20)
+	require.NoError(t, err)
+	defer func() { require.NoError(t, primary.Close()) }()
+	l, err := NewTCPReplicationListener("127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+	go primary.Serve(l)
+
+	addr := l.(interface{ Addr() net.Addr }).Addr().String()
+	replica, err := rdb.NewReplica(NewTCPReplicationDialer(addr))
+	require.NoError(t, err)
+	defer func() { require.NoError(t, replica.Close()) }()
+	requireReplicated(t, pdb, replica)
+
+	txnSet(t, pdb, []byte("after"), []byte("value"), 0)
+	requireReplicated(t, pdb, replica)
+}
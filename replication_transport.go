@@ -0,0 +1,222 @@
+/*
+ * Copyright 2020 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package badger
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// replicationTimeout is the deadline of the reads and writes of the TCP replication transport. The
+// heartbeats of the primary keep an idle connection alive.
+const replicationTimeout = 10 * time.Second
+
+// maxReplicationMessageSize is the largest message accepted by the TCP replication transport.
+const maxReplicationMessageSize = 1 << 30
+
+var errReplicationConnClosed = errors.New("Replication connection closed")
+
+// PipeReplicationTransport connects a primary and its replicas in the same process. It is both the
+// ReplicationListener of the primary, and the ReplicationDialer of the replicas.
+type PipeReplicationTransport struct {
+	conns  chan *pipeReplicationConn
+	closed chan struct{}
+	once   sync.Once
+}
+
+// NewPipeReplicationTransport returns a new in-process replication transport.
+func NewPipeReplicationTransport() *PipeReplicationTransport {
+	return &PipeReplicationTransport{
+		conns:  make(chan *pipeReplicationConn),
+		closed: make(chan struct{}),
+	}
+}
+
+// Dial implements ReplicationDialer. It blocks until the connection is accepted.
+func (t *PipeReplicationTransport) Dial() (ReplicationConn, error) {
+	a, b := newPipeReplicationConns()
+	select {
+	case t.conns <- b:
+		return a, nil
+	case <-t.closed:
+		return nil, errReplicationConnClosed
+	}
+}
+
+// Accept implements ReplicationListener.
+func (t *PipeReplicationTransport) Accept() (ReplicationConn, error) {
+	select {
+	case c := <-t.conns:
+		return c, nil
+	case <-t.closed:
+		return nil, errReplicationConnClosed
+	}
+}
+
+// Close implements ReplicationListener.
+func (t *PipeReplicationTransport) Close() error {
+	t.once.Do(func() { close(t.closed) })
+	return nil
+}
+
+// pipeReplicationConn is one end of an in-process connection. The messages aren't copied, so they
+// must not be modified once sent.
+type pipeReplicationConn struct {
+	in, out chan *ReplicationMessage
+	// closed is shared by both the ends.
+	closed chan struct{}
+	once   *sync.Once
+}
+
+func newPipeReplicationConns() (*pipeReplicationConn, *pipeReplicationConn) {
+	ab, ba := make(chan *ReplicationMessage, 16), make(chan *ReplicationMessage, 16)
+	closed, once := make(chan struct{}), &sync.Once{}
+	return &pipeReplicationConn{in: ba, out: ab, closed: closed, once: once},
+		&pipeReplicationConn{in: ab, out: ba, closed: closed, once: once}
+}
+
+func (c *pipeReplicationConn) Send(msg *ReplicationMessage) error {
+	select {
+	case c.out <- msg:
+		return nil
+	case <-c.closed:
+		return errReplicationConnClosed
+	}
+}
+
+func (c *pipeReplicationConn) Recv() (*ReplicationMessage, error) {
+	select {
+	case msg := <-c.in:
+		return msg, nil
+	case <-c.closed:
+		return nil, errReplicationConnClosed
+	}
+}
+
+func (c *pipeReplicationConn) Close() error {
+	c.once.Do(func() { close(c.closed) })
+	return nil
+}
+
+// tcpReplicationListener accepts the replicas over TCP.
+type tcpReplicationListener struct {
+	l net.Listener
+}
+
+// NewTCPReplicationListener listens for the replicas on the TCP address addr.
+func NewTCPReplicationListener(addr string) (ReplicationListener, error) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return &tcpReplicationListener{l: l}, nil
+}
+
+// Addr returns the address on which the listener accepts the replicas.
+func (l *tcpReplicationListener) Addr() net.Addr {
+	return l.l.Addr()
+}
+
+func (l *tcpReplicationListener) Accept() (ReplicationConn, error) {
+	c, err := l.l.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return newTCPReplicationConn(c), nil
+}
+
+func (l *tcpReplicationListener) Close() error {
+	return l.l.Close()
+}
+
+type tcpReplicationDialer struct {
+	addr string
+}
+
+// NewTCPReplicationDialer returns a ReplicationDialer, which connects to the primary listening on
+// the TCP address addr.
+func NewTCPReplicationDialer(addr string) ReplicationDialer {
+	return &tcpReplicationDialer{addr: addr}
+}
+
+func (d *tcpReplicationDialer) Dial() (ReplicationConn, error) {
+	c, err := net.DialTimeout("tcp", d.addr, replicationTimeout)
+	if err != nil {
+		return nil, err
+	}
+	return newTCPReplicationConn(c), nil
+}
+
+// tcpReplicationConn sends every message as its length, followed by the message encoded via
+// ReplicationMessage.Marshal.
+type tcpReplicationConn struct {
+	c net.Conn
+	r *bufio.Reader
+}
+
+func newTCPReplicationConn(c net.Conn) *tcpReplicationConn {
+	return &tcpReplicationConn{c: c, r: bufio.NewReader(c)}
+}
+
+func (c *tcpReplicationConn) Send(msg *ReplicationMessage) error {
+	buf, err := msg.Marshal()
+	if err != nil {
+		return err
+	}
+	var lenBuf [4]byte
+	binary.BigEndian.PutUint32(lenBuf[:], uint32(len(buf)))
+	if err := c.c.SetWriteDeadline(time.Now().Add(replicationTimeout)); err != nil {
+		return err
+	}
+	if _, err := c.c.Write(append(lenBuf[:], buf...)); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (c *tcpReplicationConn) Recv() (*ReplicationMessage, error) {
+	if err := c.c.SetReadDeadline(time.Now().Add(replicationTimeout)); err != nil {
+		return nil, err
+	}
+	var lenBuf [4]byte
+	if _, err := io.ReadFull(c.r, lenBuf[:]); err != nil {
+		return nil, err
+	}
+	sz := binary.BigEndian.Uint32(lenBuf[:])
+	if sz > maxReplicationMessageSize {
+		return nil, errors.Errorf("Replication message of %d bytes is too large", sz)
+	}
+	buf := make([]byte, sz)
+	if _, err := io.ReadFull(c.r, buf); err != nil {
+		return nil, err
+	}
+	msg := &ReplicationMessage{}
+	if err := msg.Unmarshal(buf); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+func (c *tcpReplicationConn) Close() error {
+	return c.c.Close()
+}
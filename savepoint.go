@@ -0,0 +1,120 @@
+/*
+ * Copyright 2020 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package badger
+
+import "github.com/dgraph-io/ristretto/z"
+
+// Savepoint marks a state of a transaction, which can be restored via Txn.RollbackTo.
+type Savepoint struct {
+	txn *Txn
+	// depth is the index of the savepoint in txn.savepoints.
+	depth int
+
+	undoLen, readsLen, readRangesLen, lockedReadsLen, duplicatesLen, rangeDelsLen int
+	size, count                                                                   int64
+}
+
+// undoWrite records the entry of a key in pendingWrites, before it was replaced or removed.
+type undoWrite struct {
+	key string
+	old *Entry
+	// newConflictKey is set if the write added the fingerprint of the key to conflictKeys.
+	newConflictKey bool
+}
+
+// Savepoint returns a savepoint marking the current state of the transaction. The writes, deletes
+// and reads done after it are undone by RollbackTo.
+//
+// Savepoints are nested: rolling back to a savepoint invalidates the savepoints created after
+// it, but not the savepoint itself, which can be rolled back to again.
+func (txn *Txn) Savepoint() *Savepoint {
+	txn.readsLock.Lock()
+	readsLen, readRangesLen := len(txn.reads), len(txn.readRanges)
+	txn.readsLock.Unlock()
+	sp := &Savepoint{
+		txn:            txn,
+		depth:          len(txn.savepoints),
+		undoLen:        len(txn.undo),
+		readsLen:       readsLen,
+		readRangesLen:  readRangesLen,
+		lockedReadsLen: len(txn.lockedReads),
+		duplicatesLen:  len(txn.duplicateWrites),
+		rangeDelsLen:   len(txn.rangeDels),
+		size:           txn.size,
+		count:          txn.count,
+	}
+	txn.savepoints = append(txn.savepoints, sp)
+	return sp
+}
+
+// RollbackTo undoes the writes, deletes and reads done by the transaction after the savepoint.
+// The undone reads are not considered for conflict detection anymore, but the key locks taken by
+// GetForUpdate are kept. The iterators created before the rollback keep seeing the undone writes.
+//
+// ErrInvalidSavepoint is returned if the savepoint belongs to another transaction, or was created
+// after the savepoint last rolled back to.
+func (txn *Txn) RollbackTo(sp *Savepoint) error {
+	switch {
+	case txn.discarded:
+		return ErrDiscardedTxn
+	case sp == nil || sp.txn != txn || sp.depth >= len(txn.savepoints) ||
+		txn.savepoints[sp.depth] != sp:
+		return ErrInvalidSavepoint
+	}
+	for i := len(txn.undo) - 1; i >= sp.undoLen; i-- {
+		u := txn.undo[i]
+		if u.old == nil {
+			delete(txn.pendingWrites, u.key)
+		} else {
+			txn.pendingWrites[u.key] = u.old
+		}
+		if u.newConflictKey {
+			delete(txn.conflictKeys, z.MemHash([]byte(u.key)))
+		}
+		txn.undo[i] = undoWrite{}
+	}
+	txn.undo = txn.undo[:sp.undoLen]
+
+	txn.readsLock.Lock()
+	txn.reads = txn.reads[:sp.readsLen]
+	txn.readRanges = txn.readRanges[:sp.readRangesLen]
+	txn.readsLock.Unlock()
+	txn.lockedReads = txn.lockedReads[:sp.lockedReadsLen]
+	txn.duplicateWrites = txn.duplicateWrites[:sp.duplicatesLen]
+	txn.rangeDels = txn.rangeDels[:sp.rangeDelsLen]
+	txn.size, txn.count = sp.size, sp.count
+	txn.savepoints = txn.savepoints[:sp.depth+1]
+	return nil
+}
+
+// setPending sets the pending write of key to e, or removes it if e is nil. newConflictKey is set
+// if the write added the fingerprint of the key to conflictKeys. The previous entry is recorded, if
+// there is a savepoint to roll back to.
+func (txn *Txn) setPending(key string, e *Entry, newConflictKey bool) {
+	if len(txn.savepoints) > 0 {
+		txn.undo = append(txn.undo, undoWrite{
+			key:            key,
+			old:            txn.pendingWrites[key],
+			newConflictKey: newConflictKey,
+		})
+	}
+	if e == nil {
+		delete(txn.pendingWrites, key)
+	} else {
+		txn.pendingWrites[key] = e
+	}
+}
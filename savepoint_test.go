@@ -0,0 +1,120 @@
+/*
+ * Copyright 2020 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package badger
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSavepoint(t *testing.T) {
+	runBadgerTest(t, nil, func(t *testing.T, db *DB) {
+		txnSet(t, db, []byte("a"), []byte("a0"), 0)
+
+		txn := db.NewTransaction(true)
+		defer txn.Discard()
+		require.NoError(t, txn.Set([]byte("b"), []byte("b1")))
+		sp1 := txn.Savepoint()
+		require.NoError(t, txn.Set([]byte("b"), []byte("b2")))
+		require.NoError(t, txn.Delete([]byte("a")))
+		sp2 := txn.Savepoint()
+		require.NoError(t, txn.Set([]byte("c"), []byte("c3")))
+		require.NoError(t, txn.DeleteRange([]byte("a"), []byte("z")))
+
+		requireValue := func(key, val string) {
+			item, err := txn.Get([]byte(key))
+			if val == "" {
+				require.Equal(t, ErrKeyNotFound, err, key)
+				return
+			}
+			require.NoError(t, err, key)
+			require.Equal(t, []byte(val), getItemValue(t, item), key)
+		}
+		requireValue("b", "")
+		requireValue("c", "")
+
+		require.NoError(t, txn.RollbackTo(sp2))
+		requireValue("a", "")
+		requireValue("b", "b2")
+		requireValue("c", "")
+		require.Equal(t, 2, len(txn.pendingWrites))
+
+		// Rolling back to sp1 invalidates sp2, but sp1 can be rolled back to again.
+		require.NoError(t, txn.RollbackTo(sp1))
+		require.Equal(t, ErrInvalidSavepoint, txn.RollbackTo(sp2))
+		requireValue("a", "a0")
+		requireValue("b", "b1")
+		require.NoError(t, txn.Set([]byte("d"), []byte("d1")))
+		require.NoError(t, txn.RollbackTo(sp1))
+		requireValue("d", "")
+
+		other := db.NewTransaction(true)
+		defer other.Discard()
+		require.Equal(t, ErrInvalidSavepoint, other.RollbackTo(sp1))
+
+		require.NoError(t, txn.Commit())
+		require.Equal(t, ErrDiscardedTxn, txn.RollbackTo(sp1))
+		require.NoError(t, db.View(func(txn *Txn) error {
+			require.Equal(t, 2, countKeys(t, txn, false))
+			return nil
+		}))
+	})
+}
+
+func TestSavepointConflicts(t *testing.T) {
+	runBadgerTest(t, nil, func(t *testing.T, db *DB) {
+		// The reads undone by a rollback don't conflict.
+		txn := db.NewTransaction(true)
+		defer txn.Discard()
+		sp := txn.Savepoint()
+		_, err := txn.Get([]byte("read"))
+		require.Equal(t, ErrKeyNotFound, err)
+		require.NoError(t, txn.RollbackTo(sp))
+		txnSet(t, db, []byte("read"), []byte("v"), 0)
+		require.NoError(t, txn.Set([]byte("x"), []byte("v")))
+		require.NoError(t, txn.Commit())
+
+		// Neither do the writes undone by a rollback.
+		reader := db.NewTransaction(true)
+		defer reader.Discard()
+		_, err = reader.Get([]byte("written"))
+		require.Equal(t, ErrKeyNotFound, err)
+
+		txn = db.NewTransaction(true)
+		defer txn.Discard()
+		sp = txn.Savepoint()
+		require.NoError(t, txn.Set([]byte("written"), []byte("v")))
+		require.NoError(t, txn.RollbackTo(sp))
+		require.NoError(t, txn.Set([]byte("y"), []byte("v")))
+		require.NoError(t, txn.Commit())
+
+		require.NoError(t, reader.Set([]byte("z"), []byte("v")))
+		require.NoError(t, reader.Commit())
+
+		// The reads done before the savepoint still conflict.
+		txn = db.NewTransaction(true)
+		defer txn.Discard()
+		_, err = txn.Get([]byte("read"))
+		require.NoError(t, err)
+		sp = txn.Savepoint()
+		require.NoError(t, txn.RollbackTo(sp))
+		txnSet(t, db, []byte("read"), []byte("v2"), 0)
+		require.NoError(t, txn.Set([]byte("x"), []byte("v2")))
+		require.Equal(t, ErrConflict, txn.Commit())
+	})
+}
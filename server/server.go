@@ -0,0 +1,391 @@
+/*
+ * Copyright 2020 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package server serves a DB remotely via the Badger gRPC service. See the client package for the
+// Go client.
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"sync"
+	"time"
+
+	"github.com/dgraph-io/badger/v2"
+	"github.com/dgraph-io/badger/v2/pb"
+	"github.com/dgraph-io/ristretto/z"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// maxScanBatchCount and maxScanBatchSize bound the KVLists sent by Scan.
+	maxScanBatchCount = 1000
+	maxScanBatchSize  = 4 << 20
+)
+
+// Server implements pb.BadgerServer for a DB, which must not be opened in managed mode. The
+// transactions are kept on the server, between the calls of the clients. They are discarded if
+// they aren't used for the transaction timeout, so that the clients which go away don't hold
+// them forever. The transaction IDs are random, and only valid on the connection which began the
+// transaction, so that the clients can't use the transactions of each other.
+type Server struct {
+	db         *badger.DB
+	txnTimeout time.Duration
+	closer     *z.Closer
+
+	sync.Mutex
+	txns map[uint64]*serverTxn
+}
+
+type serverTxn struct {
+	// The transactions can't be used concurrently.
+	sync.Mutex
+	txn *badger.Txn
+	// conn identifies the connection which began the transaction.
+	conn string
+	// lastUsed is protected by the lock of the Server.
+	lastUsed time.Time
+}
+
+// New returns a Server for db. The transactions unused for txnTimeout are discarded.
+func New(db *badger.DB, txnTimeout time.Duration) *Server {
+	s := &Server{
+		db:         db,
+		txnTimeout: txnTimeout,
+		closer:     z.NewCloser(1),
+		txns:       make(map[uint64]*serverTxn),
+	}
+	go s.discardIdleTxns()
+	return s
+}
+
+// Register registers the Server on a gRPC server.
+func (s *Server) Register(gs *grpc.Server) {
+	pb.RegisterBadgerServer(gs, s)
+}
+
+// Close discards the open transactions. It must be called once the gRPC server has stopped, and
+// before the DB is closed.
+func (s *Server) Close() {
+	s.closer.SignalAndWait()
+	s.Lock()
+	defer s.Unlock()
+	for id, t := range s.txns {
+		t.Lock()
+		t.txn.Discard()
+		t.Unlock()
+		delete(s.txns, id)
+	}
+}
+
+func (s *Server) discardIdleTxns() {
+	defer s.closer.Done()
+
+	ticker := time.NewTicker(s.txnTimeout / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-s.closer.HasBeenClosed():
+			return
+		}
+		var idle []*serverTxn
+		s.Lock()
+		for id, t := range s.txns {
+			if time.Since(t.lastUsed) > s.txnTimeout {
+				idle = append(idle, t)
+				delete(s.txns, id)
+			}
+		}
+		s.Unlock()
+		for _, t := range idle {
+			t.Lock()
+			t.txn.Discard()
+			t.Unlock()
+		}
+	}
+}
+
+// connID identifies the connection of the client making the call with ctx.
+func connID(ctx context.Context) string {
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		return p.Addr.Network() + "://" + p.Addr.String()
+	}
+	return ""
+}
+
+// acquire locks the transaction with the given ID, begun on the connection of ctx. If remove is
+// set, the transaction is removed from the Server as well.
+func (s *Server) acquire(ctx context.Context, id uint64, remove bool) (*serverTxn, error) {
+	s.Lock()
+	t, ok := s.txns[id]
+	ok = ok && t.conn == connID(ctx)
+	if ok {
+		t.lastUsed = time.Now()
+		if remove {
+			delete(s.txns, id)
+		}
+	}
+	s.Unlock()
+	if !ok {
+		// The transaction has been committed or discarded, possibly for being idle.
+		return nil, toStatus(badger.ErrDiscardedTxn)
+	}
+	t.Lock()
+	return t, nil
+}
+
+// newTxnID returns a random transaction ID, which isn't used by any other transaction. Must be
+// called under s.Lock.
+func (s *Server) newTxnID() (uint64, error) {
+	var buf [8]byte
+	for {
+		if _, err := rand.Read(buf[:]); err != nil {
+			return 0, err
+		}
+		id := binary.BigEndian.Uint64(buf[:])
+		if _, ok := s.txns[id]; !ok && id != 0 {
+			return id, nil
+		}
+	}
+}
+
+// Begin starts a transaction.
+func (s *Server) Begin(ctx context.Context, in *pb.BeginRequest) (*pb.BeginResponse, error) {
+	t := &serverTxn{
+		txn:      s.db.NewTransaction(in.Update),
+		conn:     connID(ctx),
+		lastUsed: time.Now(),
+	}
+	s.Lock()
+	id, err := s.newTxnID()
+	if err == nil {
+		s.txns[id] = t
+	}
+	s.Unlock()
+	if err != nil {
+		t.txn.Discard()
+		return nil, toStatus(err)
+	}
+	return &pb.BeginResponse{TxnId: id, ReadTs: t.txn.ReadTs()}, nil
+}
+
+// Get looks up a key within a transaction.
+func (s *Server) Get(ctx context.Context, in *pb.GetRequest) (*pb.GetResponse, error) {
+	t, err := s.acquire(ctx, in.TxnId, false)
+	if err != nil {
+		return nil, err
+	}
+	defer t.Unlock()
+	item, err := t.txn.Get(in.Key)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	kv, err := itemToKV(item)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &pb.GetResponse{Item: kv}, nil
+}
+
+// Set sets a key within a transaction.
+func (s *Server) Set(ctx context.Context, in *pb.SetRequest) (*pb.SetResponse, error) {
+	t, err := s.acquire(ctx, in.TxnId, false)
+	if err != nil {
+		return nil, err
+	}
+	defer t.Unlock()
+	e := badger.NewEntry(in.Key, in.Value).WithMeta(byte(in.UserMeta))
+	e.ExpiresAt = in.ExpiresAt
+	if err := t.txn.SetEntry(e); err != nil {
+		return nil, toStatus(err)
+	}
+	return &pb.SetResponse{}, nil
+}
+
+// Delete deletes a key within a transaction.
+func (s *Server) Delete(ctx context.Context, in *pb.DeleteRequest) (*pb.DeleteResponse, error) {
+	t, err := s.acquire(ctx, in.TxnId, false)
+	if err != nil {
+		return nil, err
+	}
+	defer t.Unlock()
+	if err := t.txn.Delete(in.Key); err != nil {
+		return nil, toStatus(err)
+	}
+	return &pb.DeleteResponse{}, nil
+}
+
+// Commit commits a transaction.
+func (s *Server) Commit(ctx context.Context, in *pb.CommitRequest) (*pb.CommitResponse, error) {
+	t, err := s.acquire(ctx, in.TxnId, true)
+	if err != nil {
+		return nil, err
+	}
+	defer t.Unlock()
+	if err := t.txn.Commit(); err != nil {
+		return nil, toStatus(err)
+	}
+	return &pb.CommitResponse{}, nil
+}
+
+// Discard discards a transaction.
+func (s *Server) Discard(ctx context.Context, in *pb.DiscardRequest) (*pb.DiscardResponse,
+	error) {
+	t, err := s.acquire(ctx, in.TxnId, true)
+	if err != nil {
+		return nil, err
+	}
+	defer t.Unlock()
+	t.txn.Discard()
+	return &pb.DiscardResponse{}, nil
+}
+
+// Scan sends the items with the given prefix, as seen by a transaction, starting from the start
+// key of the request. The transaction is only locked while a batch is read, so that it can be
+// used by the client while it scans.
+func (s *Server) Scan(in *pb.ScanRequest, srv pb.Badger_ScanServer) error {
+	start := in.Start
+	if bytes.Compare(start, in.Prefix) < 0 {
+		start = in.Prefix
+	}
+	for done := false; !done; {
+		if err := srv.Context().Err(); err != nil {
+			return err
+		}
+		list, next, err := s.scanBatch(srv.Context(), in.TxnId, in.Prefix, start)
+		if err != nil {
+			return err
+		}
+		if len(list.Kv) == 0 {
+			return nil
+		}
+		if err := srv.Send(list); err != nil {
+			return err
+		}
+		start, done = next, next == nil
+	}
+	return nil
+}
+
+// scanBatch reads a batch of the items with the given prefix, starting from start. It returns the
+// key to start the next batch from, or nil if the items have all been read.
+func (s *Server) scanBatch(ctx context.Context, id uint64, prefix,
+	start []byte) (*pb.KVList, []byte, error) {
+	t, err := s.acquire(ctx, id, false)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer t.Unlock()
+
+	opt := badger.DefaultIteratorOptions
+	opt.Prefix = prefix
+	it := t.txn.NewIterator(opt)
+	defer it.Close()
+
+	list := &pb.KVList{}
+	var size int
+	for it.Seek(start); it.Valid(); it.Next() {
+		if len(list.Kv) == maxScanBatchCount || size >= maxScanBatchSize {
+			return list, it.Item().KeyCopy(nil), nil
+		}
+		kv, err := itemToKV(it.Item())
+		if err != nil {
+			return nil, nil, toStatus(err)
+		}
+		list.Kv = append(list.Kv, kv)
+		size += kv.Size()
+	}
+	return list, nil, nil
+}
+
+// Stream sends the latest version of the keys with the given prefix, via DB.NewStream.
+func (s *Server) Stream(in *pb.StreamRequest, srv pb.Badger_StreamServer) error {
+	stream := s.db.NewStream()
+	stream.LogPrefix = "Server.Stream"
+	stream.Prefix = in.Prefix
+	stream.Send = func(buf *z.Buffer) error {
+		list, err := badger.BufferToKVList(buf)
+		if err != nil {
+			return err
+		}
+		out := list.Kv[:0]
+		for _, kv := range list.Kv {
+			if !kv.StreamDone {
+				out = append(out, kv)
+			}
+		}
+		if len(out) == 0 {
+			return nil
+		}
+		list.Kv = out
+		return srv.Send(list)
+	}
+	return toStatus(stream.Orchestrate(srv.Context()))
+}
+
+// Subscribe sends the updates of the keys with the given prefixes, via DB.Subscribe.
+func (s *Server) Subscribe(in *pb.SubscribeRequest, srv pb.Badger_SubscribeServer) error {
+	return toStatus(s.db.Subscribe(srv.Context(), srv.Send, in.Prefixes...))
+}
+
+func itemToKV(item *badger.Item) (*pb.KV, error) {
+	val, err := item.ValueCopy(nil)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.KV{
+		Key:       item.KeyCopy(nil),
+		Value:     val,
+		UserMeta:  []byte{item.UserMeta()},
+		Version:   item.Version(),
+		ExpiresAt: item.ExpiresAt(),
+	}, nil
+}
+
+// toStatus converts the errors of the DB to gRPC status errors, keeping their messages, so that
+// the clients can recognize them.
+func toStatus(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	code := codes.Unknown
+	switch err {
+	case badger.ErrKeyNotFound:
+		code = codes.NotFound
+	case badger.ErrConflict:
+		code = codes.Aborted
+	case badger.ErrTxnTooBig:
+		code = codes.ResourceExhausted
+	case badger.ErrReadOnlyTxn, badger.ErrDiscardedTxn:
+		code = codes.FailedPrecondition
+	case badger.ErrEmptyKey, badger.ErrInvalidKey:
+		code = codes.InvalidArgument
+	case context.Canceled:
+		code = codes.Canceled
+	case context.DeadlineExceeded:
+		code = codes.DeadlineExceeded
+	}
+	return status.Error(code, err.Error())
+}
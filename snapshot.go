@@ -0,0 +1,225 @@
+/*
+ * Copyright 2020 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package badger
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/dgraph-io/badger/v2/table"
+	"github.com/dgraph-io/badger/v2/y"
+	"github.com/pkg/errors"
+)
+
+// snapshotPrefix is the prefix of the internal keys which store the named snapshots. The key of a
+// snapshot is snapshotPrefix followed by the snapshot name.
+var snapshotPrefix = []byte("!badger!snapshot!")
+
+// Snapshot is a named read timestamp, created by DB.CreateSnapshot. The versions of the keys
+// visible at the timestamp are kept by compactions until the snapshot is released, including
+// across restarts.
+type Snapshot struct {
+	Name      string
+	ReadTs    uint64
+	CreatedAt time.Time
+}
+
+func snapshotKey(name string) []byte {
+	return append(y.SafeCopy(nil, snapshotPrefix), name...)
+}
+
+// minSnapshotTs returns the lowest read timestamp of the named snapshots. Must be called under
+// o.Lock.
+func (o *oracle) minSnapshotTs() uint64 {
+	min := uint64(math.MaxUint64)
+	for _, s := range o.snapshots {
+		if s.ReadTs < min {
+			min = s.ReadTs
+		}
+	}
+	return min
+}
+
+func (o *oracle) getSnapshot(name string) (Snapshot, bool) {
+	o.Lock()
+	defer o.Unlock()
+	s, ok := o.snapshots[name]
+	return s, ok
+}
+
+func (o *oracle) setSnapshot(s Snapshot) {
+	o.Lock()
+	defer o.Unlock()
+	o.snapshots[s.Name] = s
+}
+
+func (o *oracle) deleteSnapshot(name string) {
+	o.Lock()
+	defer o.Unlock()
+	delete(o.snapshots, name)
+}
+
+// resetSnapshots removes the snapshots, whose keys are dropped by DropAll.
+func (o *oracle) resetSnapshots() {
+	o.Lock()
+	defer o.Unlock()
+	o.snapshots = make(map[string]Snapshot)
+}
+
+// CreateSnapshot creates a snapshot of the DB with the given name, at the timestamp a new
+// transaction would read at. The snapshot can be read via OpenSnapshot until it is released by
+// ReleaseSnapshot, even after the DB is reopened. Meanwhile, compactions keep the versions of the
+// keys visible to it, so a long-lived snapshot costs disk space.
+//
+// ErrSnapshotExists is returned if a snapshot with the name exists already. Snapshots are not
+// supported in the managed mode, where DB.SetDiscardTs decides which versions are kept.
+func (db *DB) CreateSnapshot(name string) (Snapshot, error) {
+	switch {
+	case len(name) == 0:
+		return Snapshot{}, errors.New("Snapshot name cannot be empty")
+	case db.opt.ReadOnly:
+		return Snapshot{}, errors.New("Cannot create a snapshot in read-only mode")
+	case db.opt.managedTxns:
+		return Snapshot{}, ErrManagedTxn
+	}
+
+	db.snapshotLock.Lock()
+	defer db.snapshotLock.Unlock()
+	if _, ok := db.orc.getSnapshot(name); ok {
+		return Snapshot{}, ErrSnapshotExists
+	}
+
+	txn := db.newTransaction(true, false)
+	defer txn.Discard()
+	s := Snapshot{Name: name, ReadTs: txn.readTs, CreatedAt: time.Now()}
+	val, err := json.Marshal(s)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	if err := txn.modifyInternal(&Entry{Key: snapshotKey(name), Value: val}); err != nil {
+		return Snapshot{}, err
+	}
+	// The versions visible at the read timestamp are kept for the transaction until it's done.
+	// Pin them for the snapshot before that.
+	db.orc.setSnapshot(s)
+	if err := txn.Commit(); err != nil {
+		db.orc.deleteSnapshot(name)
+		return Snapshot{}, y.Wrapf(err, "while writing snapshot %q", name)
+	}
+	return s, nil
+}
+
+// OpenSnapshot returns a read-only transaction reading the snapshot with the given name. The
+// transaction keeps working if the snapshot is released before it is discarded.
+//
+// ErrSnapshotNotFound is returned if the snapshot doesn't exist.
+func (db *DB) OpenSnapshot(name string) (*Txn, error) {
+	db.orc.Lock()
+	s, ok := db.orc.snapshots[name]
+	if ok {
+		// Pin the versions for the transaction like NewTransactionAsOf does.
+		db.orc.asOfReads[s.ReadTs]++
+	}
+	db.orc.Unlock()
+	if !ok {
+		return nil, ErrSnapshotNotFound
+	}
+	txn := db.newTransactionAt(s.ReadTs)
+	txn.asOf = true
+	return txn, nil
+}
+
+// ListSnapshots returns the snapshots of the DB, sorted by name.
+func (db *DB) ListSnapshots() []Snapshot {
+	db.orc.Lock()
+	out := make([]Snapshot, 0, len(db.orc.snapshots))
+	for _, s := range db.orc.snapshots {
+		out = append(out, s)
+	}
+	db.orc.Unlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// ReleaseSnapshot removes the snapshot with the given name, so that compactions can discard the
+// versions of the keys kept for it.
+//
+// ErrSnapshotNotFound is returned if the snapshot doesn't exist.
+func (db *DB) ReleaseSnapshot(name string) error {
+	if db.opt.ReadOnly {
+		return errors.New("Cannot release a snapshot in read-only mode")
+	}
+	db.snapshotLock.Lock()
+	defer db.snapshotLock.Unlock()
+	if _, ok := db.orc.getSnapshot(name); !ok {
+		return ErrSnapshotNotFound
+	}
+
+	txn := db.newTransaction(true, false)
+	defer txn.Discard()
+	if err := txn.modifyInternal(&Entry{Key: snapshotKey(name), meta: bitDelete}); err != nil {
+		return err
+	}
+	if err := txn.Commit(); err != nil {
+		return y.Wrapf(err, "while deleting snapshot %q", name)
+	}
+	db.orc.deleteSnapshot(name)
+	return nil
+}
+
+// loadSnapshots loads the snapshots written by CreateSnapshot into the oracle.
+func (db *DB) loadSnapshots() error {
+	tables, decr := db.getMemTables()
+	defer decr()
+
+	opt := IteratorOptions{Prefix: snapshotPrefix}
+	var iters []y.Iterator
+	for _, mt := range tables {
+		iters = append(iters, mt.sl.NewUniIterator(false))
+	}
+	iters = db.lc.appendIterators(iters, &opt)
+	if len(iters) == 0 {
+		return nil
+	}
+	it := table.NewMergeIterator(iters, false)
+	defer it.Close()
+
+	var lastKey []byte
+	for it.Seek(y.KeyWithTs(snapshotPrefix, math.MaxUint64)); it.Valid(); it.Next() {
+		if !bytes.HasPrefix(it.Key(), snapshotPrefix) {
+			break
+		}
+		// Only the latest version of a snapshot is used.
+		if y.SameKey(it.Key(), lastKey) {
+			continue
+		}
+		lastKey = y.SafeCopy(lastKey, it.Key())
+		vs := it.Value()
+		if isDeletedOrExpired(vs.Meta, vs.ExpiresAt) {
+			continue
+		}
+		var s Snapshot
+		if err := json.Unmarshal(vs.Value, &s); err != nil {
+			return y.Wrapf(err, "while decoding snapshot %q", it.Key())
+		}
+		db.orc.snapshots[s.Name] = s
+	}
+	return nil
+}
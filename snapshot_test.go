@@ -0,0 +1,131 @@
+/*
+ * Copyright 2020 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package badger
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNamedSnapshot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "badger-test")
+	require.NoError(t, err)
+	defer removeDir(dir)
+	opt := getTestOptions(dir).WithNumCompactors(0)
+	db, err := Open(opt)
+	require.NoError(t, err)
+
+	txnSet(t, db, []byte("key"), []byte("v1"), 0)
+	txnSet(t, db, []byte("gone"), []byte("v1"), 0)
+	s, err := db.CreateSnapshot("nightly")
+	require.NoError(t, err)
+	require.Equal(t, "nightly", s.Name)
+	_, err = db.CreateSnapshot("nightly")
+	require.Equal(t, ErrSnapshotExists, err)
+	_, err = db.CreateSnapshot("")
+	require.Error(t, err)
+
+	txnSet(t, db, []byte("key"), []byte("v2"), 0)
+	txnDelete(t, db, []byte("gone"))
+	txnSet(t, db, []byte("new"), []byte("v2"), 0)
+	_, err = db.CreateSnapshot("hourly")
+	require.NoError(t, err)
+
+	check := func(db *DB) {
+		txn, err := db.OpenSnapshot("nightly")
+		require.NoError(t, err)
+		defer txn.Discard()
+		require.Equal(t, s.ReadTs, txn.ReadTs())
+		require.Equal(t, []byte("v1"), getItemValue(t, mustGet(t, txn, "key")))
+		require.Equal(t, []byte("v1"), getItemValue(t, mustGet(t, txn, "gone")))
+		_, err = txn.Get([]byte("new"))
+		require.Equal(t, ErrKeyNotFound, err)
+		require.Equal(t, 2, countKeys(t, txn, false))
+		require.Equal(t, ErrReadOnlyTxn, txn.Set([]byte("key"), []byte("v3")))
+	}
+	check(db)
+	list := db.ListSnapshots()
+	require.Len(t, list, 2)
+	require.Equal(t, "hourly", list[0].Name)
+	require.Equal(t, s, list[1])
+
+	// The snapshots are persisted, and their versions survive compactions.
+	require.NoError(t, db.Close())
+	db, err = Open(opt)
+	require.NoError(t, err)
+	cdef := compactDef{
+		thisLevel: db.lc.levels[0],
+		nextLevel: db.lc.levels[1],
+		top:       db.lc.levels[0].tables,
+		bot:       db.lc.levels[1].tables,
+		t:         db.lc.levelTargets(),
+	}
+	cdef.t.baseLevel = 1
+	require.NoError(t, db.lc.runCompactDef(-1, 0, cdef))
+	check(db)
+	require.Len(t, db.ListSnapshots(), 2)
+
+	// A transaction reading a snapshot keeps working after the release.
+	txn, err := db.OpenSnapshot("nightly")
+	require.NoError(t, err)
+	require.NoError(t, db.ReleaseSnapshot("nightly"))
+	require.Equal(t, []byte("v1"), getItemValue(t, mustGet(t, txn, "key")))
+	txn.Discard()
+	require.Equal(t, ErrSnapshotNotFound, db.ReleaseSnapshot("nightly"))
+	_, err = db.OpenSnapshot("nightly")
+	require.Equal(t, ErrSnapshotNotFound, err)
+
+	require.NoError(t, db.Close())
+	db, err = Open(opt)
+	require.NoError(t, err)
+	defer func() { require.NoError(t, db.Close()) }()
+	list = db.ListSnapshots()
+	require.Len(t, list, 1)
+	require.Equal(t, "hourly", list[0].Name)
+	_, err = db.OpenSnapshot("nightly")
+	require.Equal(t, ErrSnapshotNotFound, err)
+}
+
+func TestNamedSnapshotValueThreshold(t *testing.T) {
+	dir, err := ioutil.TempDir("", "badger-test")
+	require.NoError(t, err)
+	defer removeDir(dir)
+	// The snapshots are read from the LSM tree while opening the DB, so they must not be moved to
+	// the value log.
+	opt := getTestOptions(dir).WithValueThreshold(8)
+	db, err := Open(opt)
+	require.NoError(t, err)
+	txnSet(t, db, []byte("key"), []byte("v1"), 0)
+	s, err := db.CreateSnapshot("nightly")
+	require.NoError(t, err)
+	txnSet(t, db, []byte("key"), []byte("v2"), 0)
+	require.NoError(t, db.Close())
+
+	db, err = Open(opt)
+	require.NoError(t, err)
+	defer func() { require.NoError(t, db.Close()) }()
+	list := db.ListSnapshots()
+	require.Len(t, list, 1)
+	require.Equal(t, s.Name, list[0].Name)
+	require.Equal(t, s.ReadTs, list[0].ReadTs)
+	txn, err := db.OpenSnapshot("nightly")
+	require.NoError(t, err)
+	defer txn.Discard()
+	require.Equal(t, []byte("v1"), getItemValue(t, mustGet(t, txn, "key")))
+}
@@ -0,0 +1,281 @@
+/*
+ * Copyright 2020 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package badger
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"hash/crc32"
+	"math"
+	"os"
+	"strings"
+
+	"github.com/dgraph-io/badger/v2/pb"
+	"github.com/dgraph-io/badger/v2/table"
+	"github.com/dgraph-io/badger/v2/y"
+	"github.com/dgraph-io/ristretto/z"
+	"github.com/pkg/errors"
+)
+
+// sstValueFileSize is the initial size of the value file of an SSTWriter. The file grows as
+// needed.
+const sstValueFileSize = 64 << 20
+
+// SSTWriter writes sorted key-value pairs into a standalone table file, without opening a DB.
+// The file can later be ingested into a DB via DB.IngestExternalFiles. Values larger than
+// Options.ValueThreshold are written to a sidecar value file, which has the same path as the
+// table file, with the ".sst" extension replaced by ".vlog". The sidecar file is only kept if
+// at least one value was written to it.
+//
+// The table is built using the Compression, ZSTDCompressionLevel, BlockSize, BloomFalsePositive
+// and ChecksumVerificationMode options. If a KeyRegistry is provided, the table and the value
+// file are encrypted using its latest data key.
+//
+// SSTWriter is not thread safe.
+type SSTWriter struct {
+	path    string
+	opt     Options
+	topt    table.Options
+	builder *table.Builder
+	vlog    *logFile
+	buf     bytes.Buffer
+	lastKey []byte
+	// numValues is the number of values written to the value file.
+	numValues int
+}
+
+// NewSSTWriter creates a new SSTWriter, which writes to the table file at the given path. The
+// file must not exist. The registry can be nil, in which case no encryption is done.
+func NewSSTWriter(path string, opt Options, registry *KeyRegistry) (*SSTWriter, error) {
+	if registry == nil {
+		registry = newKeyRegistry(KeyRegistryOptions{})
+	}
+	lf := &logFile{
+		path:     sstValuePath(path),
+		registry: registry,
+		writeAt:  vlogHeaderSize,
+		opt:      opt,
+	}
+	// Opening a new file bootstraps its header, with the latest data key of the registry.
+	err := lf.open(lf.path, os.O_RDWR|os.O_CREATE|os.O_EXCL, sstValueFileSize)
+	if err != z.NewFile {
+		if err == nil {
+			err = errors.Errorf("File already exists: %s", lf.path)
+		}
+		return nil, y.Wrapf(err, "while creating value file for SSTWriter")
+	}
+
+	topt := table.Options{
+		TableSize:            uint64(opt.BaseTableSize),
+		BlockSize:            opt.BlockSize,
+		BloomFalsePositive:   opt.BloomFalsePositive,
+		ChkMode:              opt.ChecksumVerificationMode,
+		Compression:          opt.Compression,
+		ZSTDCompressionLevel: opt.ZSTDCompressionLevel,
+		// Use the same data key for the table and the value file.
+		DataKey: lf.dataKey,
+	}
+	return &SSTWriter{
+		path:    path,
+		opt:     opt,
+		topt:    topt,
+		builder: table.NewTableBuilder(topt),
+		vlog:    lf,
+	}, nil
+}
+
+// TableOptions returns the table options used to build the table. These must be passed to
+// DB.IngestExternalFiles, to ingest the table.
+func (w *SSTWriter) TableOptions() table.Options {
+	return w.topt
+}
+
+// Add adds a key-value pair with the given version to the table. Keys must be added in sorted
+// order, and multiple versions of the same key in decreasing order of version.
+func (w *SSTWriter) Add(key []byte, version uint64, value []byte, userMeta byte) error {
+	return w.add(&Entry{
+		Key:      y.KeyWithTs(key, version),
+		Value:    value,
+		UserMeta: userMeta,
+	})
+}
+
+// Delete adds a delete marker for the key with the given version to the table. It follows the
+// same ordering requirements as Add.
+func (w *SSTWriter) Delete(key []byte, version uint64) error {
+	return w.add(&Entry{Key: y.KeyWithTs(key, version), meta: bitDelete})
+}
+
+func (w *SSTWriter) add(e *Entry) error {
+	if w.builder == nil {
+		return errors.New("SSTWriter is already finished")
+	}
+	if len(e.Key) == 8 {
+		return ErrEmptyKey
+	}
+	if len(w.lastKey) > 0 && y.CompareKeys(e.Key, w.lastKey) <= 0 {
+		return errors.Errorf("keys not in sorted order (last key: %s, key: %s)",
+			hex.Dump(w.lastKey), hex.Dump(e.Key))
+	}
+	w.lastKey = y.SafeCopy(w.lastKey, e.Key)
+
+	vs := y.ValueStruct{
+		Value:    e.Value,
+		Meta:     e.meta,
+		UserMeta: e.UserMeta,
+	}
+	if e.meta&bitDelete > 0 || len(e.Value) <= w.opt.ValueThreshold {
+		w.builder.Add(e.Key, vs, 0)
+		return nil
+	}
+	vp, err := w.writeValue(e)
+	if err != nil {
+		return err
+	}
+	vs.Value = vp.Encode()
+	vs.Meta |= bitValuePointer
+	w.builder.Add(e.Key, vs, vp.Len)
+	return nil
+}
+
+// writeValue appends the entry to the value file, and returns a pointer to it.
+func (w *SSTWriter) writeValue(e *Entry) (valuePointer, error) {
+	lf := w.vlog
+	w.buf.Reset()
+	plen, err := lf.encodeEntry(&w.buf, e, lf.writeAt)
+	if err != nil {
+		return valuePointer{}, err
+	}
+	// Keep space for zeroing out the header of the next entry.
+	end := int64(lf.writeAt) + int64(plen) + maxHeaderSize
+	if end > math.MaxUint32 {
+		return valuePointer{}, errors.Errorf("Value file %s exceeds the maximum size", lf.path)
+	}
+	if end > int64(len(lf.Data)) {
+		sz := 2 * int64(len(lf.Data))
+		if sz < end {
+			sz = end
+		}
+		if sz > math.MaxUint32 {
+			sz = math.MaxUint32
+		}
+		if err := lf.Truncate(sz); err != nil {
+			return valuePointer{}, y.Wrapf(err, "while growing value file %s", lf.path)
+		}
+	}
+
+	vp := valuePointer{Fid: lf.fid, Len: uint32(plen), Offset: lf.writeAt}
+	y.AssertTrue(plen == copy(lf.Data[lf.writeAt:], w.buf.Bytes()))
+	lf.writeAt += uint32(plen)
+	lf.zeroNextEntry()
+	w.numValues++
+	return vp, nil
+}
+
+// Finish writes out the table file, and the value file if any values were written to it. The
+// writer can't be used after Finish is called.
+func (w *SSTWriter) Finish() error {
+	if w.builder == nil {
+		return errors.New("SSTWriter is already finished")
+	}
+	defer func() {
+		w.builder.Close()
+		w.builder = nil
+	}()
+	if err := w.finishValueFile(); err != nil {
+		return err
+	}
+	if w.builder.Empty() {
+		return errors.New("Cannot write an empty table")
+	}
+
+	bd := w.builder.Done()
+	mf, err := z.OpenMmapFile(w.path, os.O_CREATE|os.O_RDWR|os.O_EXCL, bd.Size)
+	if err != nil && err != z.NewFile {
+		return y.Wrapf(err, "while creating table: %s", w.path)
+	}
+	written := bd.Copy(mf.Data)
+	y.AssertTrue(written == len(mf.Data))
+	if err := z.Msync(mf.Data); err != nil {
+		_ = mf.Close(-1)
+		return y.Wrapf(err, "while calling msync on %s", w.path)
+	}
+	return mf.Close(-1)
+}
+
+func (w *SSTWriter) finishValueFile() error {
+	lf := w.vlog
+	if w.numValues == 0 {
+		if err := lf.Delete(); err != nil {
+			return y.Wrapf(err, "while deleting value file: %s", lf.path)
+		}
+		return nil
+	}
+	if err := lf.Truncate(int64(lf.writeAt)); err != nil {
+		return y.Wrapf(err, "while truncating value file: %s", lf.path)
+	}
+	return lf.Close(-1)
+}
+
+// sstValuePath returns the path of the value file for the table file at the given path.
+func sstValuePath(path string) string {
+	return strings.TrimSuffix(path, ".sst") + ".vlog"
+}
+
+// openSSTValueFile opens the value file written by an SSTWriter, if it exists. The data key must
+// be the one used to build the table.
+func openSSTValueFile(path string, dk *pb.DataKey) (*logFile, error) {
+	path = sstValuePath(path)
+	mf, err := z.OpenMmapFile(path, os.O_RDONLY, 0)
+	if err != nil {
+		if os.IsNotExist(errors.Cause(err)) {
+			return nil, nil
+		}
+		return nil, y.Wrapf(err, "while opening value file: %s", path)
+	}
+	lf := &logFile{MmapFile: mf, path: path, size: uint32(len(mf.Data)), dataKey: dk}
+	if len(mf.Data) < vlogHeaderSize {
+		_ = mf.Close(-1)
+		return nil, errors.Errorf("Value file %s is too small", path)
+	}
+	if keyID := binary.BigEndian.Uint64(mf.Data[:8]); keyID != lf.keyID() {
+		_ = mf.Close(-1)
+		return nil, errors.Errorf("Value file %s is encrypted with data key %d, expected %d",
+			path, keyID, lf.keyID())
+	}
+	lf.baseIV = mf.Data[8:vlogHeaderSize]
+	return lf, nil
+}
+
+// readValue returns the value pointed to by vp, after verifying its checksum.
+func (lf *logFile) readValue(vp valuePointer) ([]byte, error) {
+	buf, err := lf.read(vp, nil)
+	if err != nil {
+		return nil, y.Wrapf(err, "while reading value from %s at %+v", lf.path, vp)
+	}
+	if len(buf) < crc32.Size ||
+		crc32.Checksum(buf[:len(buf)-crc32.Size], y.CastagnoliCrcTable) !=
+			y.BytesToU32(buf[len(buf)-crc32.Size:]) {
+		return nil, y.Wrapf(y.ErrChecksumMismatch, "value corrupted in %s at %+v", lf.path, vp)
+	}
+	e, err := lf.decodeEntry(buf, vp.Offset)
+	if err != nil {
+		return nil, err
+	}
+	return e.Value, nil
+}
@@ -0,0 +1,127 @@
+/*
+ * Copyright 2020 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package badger
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/badger/v2/options"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSSTWriter(t *testing.T) {
+	value := func(i int) []byte {
+		// Every other value goes to the value file.
+		return []byte(fmt.Sprintf("%0*d", 16+(i%2)*64, i))
+	}
+	test := func(t *testing.T, registry *KeyRegistry) {
+		edir, err := ioutil.TempDir("", "badger-external")
+		require.NoError(t, err)
+		defer removeDir(edir)
+
+		opt := DefaultOptions("").WithValueThreshold(32).WithCompression(options.ZSTD).
+			WithBlockSize(1 << 10)
+		path := filepath.Join(edir, "000001.sst")
+		w, err := NewSSTWriter(path, opt, registry)
+		require.NoError(t, err)
+		for i := 0; i < 1000; i++ {
+			if i%10 == 9 {
+				require.NoError(t, w.Delete(rangeKey(i), 2))
+			} else {
+				require.NoError(t, w.Add(rangeKey(i), 2, value(i), byte(i)))
+			}
+			require.NoError(t, w.Add(rangeKey(i), 1, []byte("old"), 0))
+		}
+		require.Error(t, w.Add(rangeKey(0), 1, []byte("unsorted"), 0))
+		require.NoError(t, w.Finish())
+		require.Error(t, w.Finish())
+		_, err = os.Stat(filepath.Join(edir, "000001.vlog"))
+		require.NoError(t, err)
+
+		dir, err := ioutil.TempDir("", "badger-test")
+		require.NoError(t, err)
+		defer removeDir(dir)
+		db, err := Open(getTestOptions(dir).WithIndexCacheSize(10 << 20).WithValueThreshold(32))
+		require.NoError(t, err)
+		defer func() { require.NoError(t, db.Close()) }()
+
+		require.NoError(t, db.IngestExternalFiles([]string{path}, w.TableOptions()))
+		require.NoError(t, db.View(func(txn *Txn) error {
+			for i := 0; i < 1000; i++ {
+				item, err := txn.Get(rangeKey(i))
+				if i%10 == 9 {
+					require.Equal(t, ErrKeyNotFound, err)
+					continue
+				}
+				require.NoError(t, err)
+				require.Equal(t, value(i), getItemValue(t, item))
+				require.Equal(t, byte(i), item.UserMeta())
+				// The large values are kept in the value log of the DB.
+				require.Equal(t, i%2 == 1, item.meta&bitValuePointer > 0)
+			}
+			return nil
+		}))
+	}
+
+	t.Run("plain", func(t *testing.T) {
+		test(t, nil)
+	})
+	t.Run("with encryption", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "badger-registry")
+		require.NoError(t, err)
+		defer removeDir(dir)
+		key := make([]byte, 32)
+		_, err = rand.Read(key)
+		require.NoError(t, err)
+		registry, err := OpenKeyRegistry(KeyRegistryOptions{
+			Dir:                           dir,
+			EncryptionKey:                 key,
+			EncryptionKeyRotationDuration: time.Hour,
+		})
+		require.NoError(t, err)
+		defer registry.Close()
+		test(t, registry)
+	})
+}
+
+func TestSSTWriterInlineValues(t *testing.T) {
+	edir, err := ioutil.TempDir("", "badger-external")
+	require.NoError(t, err)
+	defer removeDir(edir)
+
+	path := filepath.Join(edir, "000001.sst")
+	w, err := NewSSTWriter(path, DefaultOptions(""), nil)
+	require.NoError(t, err)
+	_, err = NewSSTWriter(path, DefaultOptions(""), nil)
+	require.Error(t, err, "value file already exists")
+
+	require.NoError(t, w.Add(rangeKey(0), 1, []byte("foo"), 0))
+	require.Equal(t, ErrEmptyKey, w.Add(nil, 1, []byte("foo"), 0))
+	require.NoError(t, w.Finish())
+
+	// The value file isn't kept, since all the values are in the table.
+	_, err = os.Stat(filepath.Join(edir, "000001.vlog"))
+	require.True(t, os.IsNotExist(err))
+	_, err = os.Stat(path)
+	require.NoError(t, err)
+}
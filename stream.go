@@ -44,7 +44,7 @@ var maxStreamSize = uint64(100 << 20) // 100MB
 // order, use Iterator.
 type Stream struct {
 	// Prefix to only iterate over certain range of keys. If set to nil (default), Stream would
-	// iterate over the entire DB.
+	// iterate over the entire DB, including the keys and definitions of the keyspaces.
 	Prefix []byte
 
 	// Number of goroutines to use for iterating over key ranges. Defaults to 16.
@@ -87,6 +87,9 @@ type Stream struct {
 	kvChan       chan *z.Buffer
 	nextStreamId uint32
 	doneMarkers  bool
+	// chooseInternal returns true for the internal keys which are streamed along with the user
+	// keys. It defaults to the keys of the keyspaces.
+	chooseInternal func(key []byte) bool
 }
 
 // SendDoneMarkers when true would send out done markers on the stream. False by default.
@@ -173,7 +176,7 @@ func (st *Stream) produceRanges(ctx context.Context) {
 func (st *Stream) produceKVs(ctx context.Context, threadId int) error {
 	var txn *Txn
 	if st.readTs > 0 {
-		txn = st.db.NewTransactionAt(st.readTs, false)
+		txn = st.db.newTransactionAt(st.readTs)
 	} else {
 		txn = st.db.NewTransaction(false)
 	}
@@ -192,6 +195,9 @@ func (st *Stream) produceKVs(ctx context.Context, threadId int) error {
 		iterOpts.AllVersions = true
 		iterOpts.Prefix = st.Prefix
 		iterOpts.PrefetchValues = false
+		// The internal keys picked by chooseInternal are streamed along with the user keys.
+		// The other ones are skipped below.
+		iterOpts.InternalAccess = true
 		itr := txn.NewIterator(iterOpts)
 		itr.ThreadId = threadId
 		defer itr.Close()
@@ -227,6 +233,9 @@ func (st *Stream) produceKVs(ctx context.Context, threadId int) error {
 			if len(kr.right) > 0 && bytes.Compare(item.Key(), kr.right) >= 0 {
 				break
 			}
+			if bytes.HasPrefix(item.Key(), badgerPrefix) && !st.chooseInternal(item.Key()) {
+				continue
+			}
 			// Check if we should pick this key.
 			if st.ChooseKey != nil && !st.ChooseKey(item) {
 				continue
@@ -435,9 +444,10 @@ func (st *Stream) Orchestrate(ctx context.Context) error {
 
 func (db *DB) newStream() *Stream {
 	return &Stream{
-		db:        db,
-		NumGo:     8,
-		LogPrefix: "Badger.Stream",
+		db:             db,
+		NumGo:          8,
+		LogPrefix:      "Badger.Stream",
+		chooseInternal: isKeyspaceKey,
 	}
 }
 
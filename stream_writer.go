@@ -246,7 +246,14 @@ func (sw *StreamWriter) Flush() error {
 	if err := sw.db.syncDir(sw.db.opt.Dir); err != nil {
 		return err
 	}
-	return sw.db.lc.validate()
+	if err := sw.db.lc.validate(); err != nil {
+		return err
+	}
+	// The stream may contain range tombstones and keyspace definitions.
+	if err := sw.db.loadRangeTombstones(); err != nil {
+		return err
+	}
+	return sw.db.loadKeyspaces()
 }
 
 // Cancel signals all goroutines to exit. Calling defer sw.Cancel() immediately after creating a new StreamWriter
@@ -316,7 +323,7 @@ func (w *sortedWriter) handleRequests() {
 		for i, e := range req.Entries {
 			// If badger is running in InMemory mode, len(req.Ptrs) == 0.
 			var vs y.ValueStruct
-			if w.db.opt.skipVlog(e) {
+			if w.db.skipVlog(e) {
 				vs = y.ValueStruct{
 					Value:     e.Value,
 					Meta:      e.meta,
@@ -90,6 +90,11 @@ type Builder struct {
 	maxVersion    uint64
 	onDiskSize    uint32
 
+	// The expiry times of the entries which have one.
+	minExpiresAt  uint64
+	maxExpiresAt  uint64
+	expiringCount uint32
+
 	// Used to concurrently compress/encrypt blocks.
 	wg        sync.WaitGroup
 	blockChan chan *bblock
@@ -209,6 +214,15 @@ func (b *Builder) addHelper(key []byte, v y.ValueStruct, vpLen uint32) {
 	if version := y.ParseTs(key); version > b.maxVersion {
 		b.maxVersion = version
 	}
+	if v.ExpiresAt > 0 {
+		if b.expiringCount == 0 || v.ExpiresAt < b.minExpiresAt {
+			b.minExpiresAt = v.ExpiresAt
+		}
+		if v.ExpiresAt > b.maxExpiresAt {
+			b.maxExpiresAt = v.ExpiresAt
+		}
+		b.expiringCount++
+	}
 
 	// diffKey stores the difference of key with baseKey.
 	var diffKey []byte
@@ -530,6 +544,9 @@ func (b *Builder) buildIndex(bloom []byte) ([]byte, uint32) {
 	fb.TableIndexAddUncompressedSize(builder, b.uncompressedSize)
 	fb.TableIndexAddKeyCount(builder, uint32(len(b.keyHashes)))
 	fb.TableIndexAddOnDiskSize(builder, b.onDiskSize)
+	fb.TableIndexAddMinExpiresAt(builder, b.minExpiresAt)
+	fb.TableIndexAddMaxExpiresAt(builder, b.maxExpiresAt)
+	fb.TableIndexAddExpiringCount(builder, b.expiringCount)
 	builder.Finish(fb.TableIndexEnd(builder))
 
 	buf := builder.FinishedBytes()
@@ -34,18 +34,21 @@ type blockIterator struct {
 	val          []byte
 	entryOffsets []uint32
 	block        *block
+	// blockPos is the position of the block in the table.
+	blockPos int
 
 	// prevOverlap stores the overlap of the previous key with the base key.
 	// This avoids unnecessary copy of base key when the overlap is same for multiple keys.
 	prevOverlap uint16
 }
 
-func (itr *blockIterator) setBlock(b *block) {
+func (itr *blockIterator) setBlock(b *block, pos int) {
 	// Decrement the ref for the old block. If the old block was compressed, we
 	// might be able to reuse it.
 	itr.block.decrRef()
 
 	itr.block = b
+	itr.blockPos = pos
 	itr.err = nil
 	itr.idx = 0
 	itr.baseKey = itr.baseKey[:0]
@@ -168,6 +171,9 @@ type Iterator struct {
 	// Internally, Iterator is bidirectional. However, we only expose the
 	// unidirectional functionality for now.
 	opt int // Valid options are REVERSED and NOCACHE.
+
+	// lower and upper are the bounds set via SetBounds. These are keys without timestamps.
+	lower, upper []byte
 }
 
 // NewIterator returns a new iterator of the Table
@@ -183,6 +189,36 @@ func (itr *Iterator) Close() error {
 	return itr.t.DecrRef()
 }
 
+// SetBounds limits the blocks fetched by the iterator to the ones which can have keys in the
+// range [lower, upper). The bounds are keys without timestamps, and a nil bound is ignored. The
+// iterator doesn't move to the next block once all the keys in it are beyond the bound. Keys
+// beyond the bound can still be returned from the current block, so the caller must check them.
+func (itr *Iterator) SetBounds(lower, upper []byte) {
+	itr.lower, itr.upper = lower, upper
+}
+
+// blockAfterUpper returns true if all the keys in the block at idx are >= the upper bound.
+func (itr *Iterator) blockAfterUpper(idx int) bool {
+	if len(itr.upper) == 0 || idx >= itr.t.offsetsLength() {
+		return false
+	}
+	var ko fb.BlockOffset
+	y.AssertTrue(itr.t.offsets(&ko, idx))
+	return bytes.Compare(y.ParseKey(ko.KeyBytes()), itr.upper) >= 0
+}
+
+// blockBeforeLower returns true if all the keys in the block at idx are < the lower bound.
+func (itr *Iterator) blockBeforeLower(idx int) bool {
+	if len(itr.lower) == 0 || idx < 0 || idx+1 >= itr.t.offsetsLength() {
+		return false
+	}
+	// All the keys in the block are smaller than the first key of the next block. Even if the
+	// next block starts with the lower bound, this block can still have newer versions of it.
+	var ko fb.BlockOffset
+	y.AssertTrue(itr.t.offsets(&ko, idx+1))
+	return bytes.Compare(y.ParseKey(ko.KeyBytes()), itr.lower) < 0
+}
+
 func (itr *Iterator) reset() {
 	itr.bpos = 0
 	itr.err = nil
@@ -209,7 +245,7 @@ func (itr *Iterator) seekToFirst() {
 		itr.err = err
 		return
 	}
-	itr.bi.setBlock(block)
+	itr.bi.setBlock(block, itr.bpos)
 	itr.bi.seekToFirst()
 	itr.err = itr.bi.Error()
 }
@@ -226,19 +262,23 @@ func (itr *Iterator) seekToLast() {
 		itr.err = err
 		return
 	}
-	itr.bi.setBlock(block)
+	itr.bi.setBlock(block, itr.bpos)
 	itr.bi.seekToLast()
 	itr.err = itr.bi.Error()
 }
 
 func (itr *Iterator) seekHelper(blockIdx int, key []byte) {
 	itr.bpos = blockIdx
-	block, err := itr.t.block(blockIdx, itr.useCache())
-	if err != nil {
-		itr.err = err
-		return
+	// Reuse the block held by the block iterator, if it's the one we need. This avoids fetching
+	// the same block again, when seeking to nearby keys.
+	if len(itr.bi.data) == 0 || itr.bi.blockPos != blockIdx {
+		block, err := itr.t.block(blockIdx, itr.useCache())
+		if err != nil {
+			itr.err = err
+			return
+		}
+		itr.bi.setBlock(block, blockIdx)
 	}
-	itr.bi.setBlock(block)
 	itr.bi.seek(key, origin)
 	itr.err = itr.bi.Error()
 }
@@ -313,7 +353,7 @@ func (itr *Iterator) next() {
 			itr.err = err
 			return
 		}
-		itr.bi.setBlock(block)
+		itr.bi.setBlock(block, itr.bpos)
 		itr.bi.seekToFirst()
 		itr.err = itr.bi.Error()
 		return
@@ -323,6 +363,10 @@ func (itr *Iterator) next() {
 	if !itr.bi.Valid() {
 		itr.bpos++
 		itr.bi.data = nil
+		if itr.blockAfterUpper(itr.bpos) {
+			itr.err = io.EOF
+			return
+		}
 		itr.next()
 		return
 	}
@@ -341,7 +385,7 @@ func (itr *Iterator) prev() {
 			itr.err = err
 			return
 		}
-		itr.bi.setBlock(block)
+		itr.bi.setBlock(block, itr.bpos)
 		itr.bi.seekToLast()
 		itr.err = itr.bi.Error()
 		return
@@ -351,6 +395,10 @@ func (itr *Iterator) prev() {
 	if !itr.bi.Valid() {
 		itr.bpos--
 		itr.bi.data = nil
+		if itr.blockBeforeLower(itr.bpos) {
+			itr.err = io.EOF
+			return
+		}
 		itr.prev()
 		return
 	}
@@ -416,6 +464,8 @@ type ConcatIterator struct {
 	iters   []*Iterator // Corresponds to tables.
 	tables  []*Table    // Disregarding reversed, this is in ascending order.
 	options int         // Valid options are REVERSED and NOCACHE.
+
+	lower, upper []byte // Bounds passed on to the table iterators.
 }
 
 // NewConcatIterator creates a new concatenated iterator
@@ -437,6 +487,16 @@ func NewConcatIterator(tbls []*Table, opt int) *ConcatIterator {
 	}
 }
 
+// SetBounds sets the bounds on the iterators of all the tables. See Iterator.SetBounds.
+func (s *ConcatIterator) SetBounds(lower, upper []byte) {
+	s.lower, s.upper = lower, upper
+	for _, it := range s.iters {
+		if it != nil {
+			it.SetBounds(lower, upper)
+		}
+	}
+}
+
 func (s *ConcatIterator) setIdx(idx int) {
 	s.idx = idx
 	if idx < 0 || idx >= len(s.iters) {
@@ -445,6 +505,7 @@ func (s *ConcatIterator) setIdx(idx int) {
 	}
 	if s.iters[idx] == nil {
 		s.iters[idx] = s.tables[idx].NewIterator(s.options)
+		s.iters[idx].SetBounds(s.lower, s.upper)
 	}
 	s.cur = s.iters[s.idx]
 }
@@ -124,6 +124,9 @@ type cheapIndex struct {
 	KeyCount          uint32
 	UncompressedSize  uint32
 	OnDiskSize        uint32
+	MinExpiresAt      uint64
+	MaxExpiresAt      uint64
+	ExpiringCount     uint32
 	BloomFilterLength int
 	OffsetsLength     int
 }
@@ -149,6 +152,17 @@ func (t *Table) KeyCount() uint32 { return t.cheapIndex().KeyCount }
 // disk space occupied on the value log).
 func (t *Table) OnDiskSize() uint32 { return t.cheapIndex().OnDiskSize }
 
+// ExpiringKeyCount is the number of keys in this table which have an expiry time.
+func (t *Table) ExpiringKeyCount() uint32 { return t.cheapIndex().ExpiringCount }
+
+// MinExpiresAt returns the earliest expiry time of the keys in this table, or zero if no key has
+// an expiry time.
+func (t *Table) MinExpiresAt() uint64 { return t.cheapIndex().MinExpiresAt }
+
+// MaxExpiresAt returns the latest expiry time of the keys in this table, or zero if no key has an
+// expiry time.
+func (t *Table) MaxExpiresAt() uint64 { return t.cheapIndex().MaxExpiresAt }
+
 // CompressionType returns the compression algorithm used for block compression.
 func (t *Table) CompressionType() options.CompressionType {
 	return t.opt.Compression
@@ -419,6 +433,9 @@ func (t *Table) initIndex() (*fb.BlockOffset, error) {
 		KeyCount:          index.KeyCount(),
 		UncompressedSize:  index.UncompressedSize(),
 		OnDiskSize:        index.OnDiskSize(),
+		MinExpiresAt:      index.MinExpiresAt(),
+		MaxExpiresAt:      index.MaxExpiresAt(),
+		ExpiringCount:     index.ExpiringCount(),
 		OffsetsLength:     index.OffsetsLength(),
 		BloomFilterLength: index.BloomFilterLength(),
 	}
@@ -178,6 +178,57 @@ func TestSeek(t *testing.T) {
 	}
 }
 
+// TestSeekSameBlock checks that seeking within the block held by the iterator works, in both
+// directions, and after moving across blocks.
+func TestSeekSameBlock(t *testing.T) {
+	opts := getTestTableOptions()
+	table := buildTestTable(t, "k", 10000, opts)
+	defer table.DecrRef()
+
+	it := table.NewIterator(0)
+	defer it.Close()
+
+	for _, in := range []int{100, 102, 101, 5000, 5001, 100, 9999, 0} {
+		it.seek(y.KeyWithTs([]byte(fmt.Sprintf("k%04d", in)), 0))
+		require.True(t, it.Valid())
+		require.EqualValues(t, fmt.Sprintf("k%04d", in), string(y.ParseKey(it.Key())))
+		for i := 1; i < 100 && in+i < 10000; i++ {
+			it.next()
+			require.True(t, it.Valid())
+			require.EqualValues(t, fmt.Sprintf("k%04d", in+i), string(y.ParseKey(it.Key())))
+		}
+	}
+}
+
+func TestIteratorBounds(t *testing.T) {
+	opts := getTestTableOptions()
+	table := buildTestTable(t, "k", 10000, opts)
+	defer table.DecrRef()
+	require.True(t, table.offsetsLength() > 10)
+
+	for _, opt := range []int{0, REVERSED} {
+		it := table.NewIterator(opt)
+		defer it.Close()
+		it.SetBounds([]byte(key("k", 4000)), []byte(key("k", 5000)))
+
+		it.Seek(y.KeyWithTs([]byte(key("k", 4500)), 0))
+		var keys []string
+		for ; it.Valid(); it.Next() {
+			keys = append(keys, string(y.ParseKey(it.Key())))
+		}
+		// The iterator stops at the end of the block holding the bound, instead of the table.
+		require.True(t, len(keys) >= 500)
+		require.True(t, len(keys) < 4500)
+		if opt == 0 {
+			require.Contains(t, keys, key("k", 4999))
+			require.NotContains(t, keys, key("k", 9999))
+		} else {
+			require.Contains(t, keys, key("k", 4000))
+			require.NotContains(t, keys, key("k", 0))
+		}
+	}
+}
+
 func TestSeekForPrev(t *testing.T) {
 	opts := getTestTableOptions()
 	table := buildTestTable(t, "k", 10000, opts)
@@ -694,6 +745,33 @@ func TestTableChecksum(t *testing.T) {
 	})
 }
 
+func TestTableExpiryStats(t *testing.T) {
+	b := NewTableBuilder(getTestTableOptions())
+	defer b.Close()
+	for i := 0; i < 10; i++ {
+		vs := y.ValueStruct{Value: []byte("v")}
+		if i%2 == 0 {
+			vs.ExpiresAt = uint64(100 + i)
+		}
+		b.Add(y.KeyWithTs([]byte(key("k", i)), 1), vs, 0)
+	}
+	filename := fmt.Sprintf("%s%s%d.sst", os.TempDir(), string(os.PathSeparator), rand.Uint32())
+	tbl, err := CreateTable(filename, b)
+	require.NoError(t, err)
+	defer tbl.DecrRef()
+
+	require.Equal(t, uint32(10), tbl.KeyCount())
+	require.Equal(t, uint32(5), tbl.ExpiringKeyCount())
+	require.Equal(t, uint64(100), tbl.MinExpiresAt())
+	require.Equal(t, uint64(108), tbl.MaxExpiresAt())
+
+	tbl2 := buildTestTable(t, "k", 10, getTestTableOptions())
+	defer tbl2.DecrRef()
+	require.Zero(t, tbl2.ExpiringKeyCount())
+	require.Zero(t, tbl2.MinExpiresAt())
+	require.Zero(t, tbl2.MaxExpiresAt())
+}
+
 var cacheConfig = ristretto.Config{
 	NumCounters: 1000000 * 10,
 	MaxCost:     1000000,
@@ -0,0 +1,221 @@
+/*
+ * Copyright 2020 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package badger
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/dgraph-io/badger/v2/table"
+	"github.com/dgraph-io/badger/v2/y"
+	"github.com/pkg/errors"
+)
+
+// clockKey is the key of the samples of the clock, written along with the first commit in every
+// clockInterval when Options.VersionRetention is set. A sample records the latest timestamp
+// committed before the start of the interval.
+var clockKey = []byte("!badger!clock")
+
+// clockInterval is the resolution of DB.SnapshotAt. It is a variable for the tests.
+var clockInterval = time.Second
+
+type clockSample struct {
+	time int64 // Start of the interval, in Unix nanoseconds.
+	ts   uint64
+}
+
+// tick returns the start of the current clock interval, if the commit at commitTs is the first
+// one in the interval and a sample must be written along with it. Must be called under o.Lock.
+func (o *oracle) tick(commitTs uint64) int64 {
+	if o.retention == 0 || o.isManaged {
+		return 0
+	}
+	start := time.Now().Truncate(clockInterval).UnixNano()
+	if n := len(o.clock); n > 0 && start <= o.clock[n-1].time {
+		return 0
+	}
+	o.clock = append(o.clock, clockSample{time: start, ts: commitTs - 1})
+	return start
+}
+
+// tsAsOf returns the latest timestamp committed at the time t, rounded down to clockInterval. It
+// returns false if the time is before the oldest sample. Must be called under o.Lock.
+func (o *oracle) tsAsOf(t time.Time) (uint64, bool) {
+	nanos := t.UnixNano()
+	// The index of the first sample after t.
+	idx := sort.Search(len(o.clock), func(i int) bool { return o.clock[i].time > nanos })
+	switch {
+	case idx == 0:
+		return 0, false
+	case nanos < o.clock[idx-1].time+int64(clockInterval):
+		return o.clock[idx-1].ts, true
+	case idx < len(o.clock):
+		// Nothing was committed between the interval of the previous sample and this one.
+		return o.clock[idx].ts, true
+	}
+	return o.nextTxnTs - 1, true
+}
+
+// retentionTs returns the timestamp at or below which versions may be discarded without going
+// against Options.VersionRetention. The samples which aren't needed anymore are dropped. Must be
+// called under o.Lock.
+func (o *oracle) retentionTs() uint64 {
+	if o.retention == 0 {
+		return math.MaxUint64
+	}
+	horizon := time.Now().Add(-o.retention)
+	ts, ok := o.tsAsOf(horizon)
+	if !ok {
+		// The versions committed before the oldest sample may be within the retention.
+		return 0
+	}
+	// Keep the latest sample at or before the horizon.
+	idx := sort.Search(len(o.clock), func(i int) bool {
+		return o.clock[i].time > horizon.UnixNano()
+	})
+	if idx > 1 {
+		o.clock = append(o.clock[:0], o.clock[idx-1:]...)
+	}
+	return ts
+}
+
+// minAsOfTs returns the lowest read timestamp of the transactions created by NewTransactionAsOf
+// which are still running. Must be called under o.Lock.
+func (o *oracle) minAsOfTs() uint64 {
+	min := uint64(math.MaxUint64)
+	for ts := range o.asOfReads {
+		if ts < min {
+			min = ts
+		}
+	}
+	return min
+}
+
+// beginAsOf registers a read at readTs, keeping compactions from discarding the versions visible
+// to it until doneAsOf is called.
+func (o *oracle) beginAsOf(readTs uint64) error {
+	o.Lock()
+	if latest := o.nextTxnTs - 1; readTs > latest {
+		o.Unlock()
+		return errors.Errorf("Timestamp %d is ahead of the latest commit at %d", readTs, latest)
+	}
+	if readTs < o.discardAtOrBelowLocked() {
+		o.Unlock()
+		return ErrSnapshotTooOld
+	}
+	o.asOfReads[readTs]++
+	o.Unlock()
+
+	// Wait for the commits at or below readTs to be written, like readTs does.
+	return o.txnMark.WaitForMark(context.Background(), readTs)
+}
+
+func (o *oracle) doneAsOf(readTs uint64) {
+	o.Lock()
+	defer o.Unlock()
+	if o.asOfReads[readTs]--; o.asOfReads[readTs] == 0 {
+		delete(o.asOfReads, readTs)
+	}
+}
+
+// NewTransactionAsOf returns a read-only transaction reading the versions of the keys committed
+// at or before readTs, such as the Version of an Item read earlier. Unlike NewTransactionAt, it
+// is meant for the normal mode, in which the read timestamp is registered with the DB so that
+// the versions visible to the transaction are kept until it is discarded.
+//
+// ErrSnapshotTooOld is returned if the versions may have been discarded by compactions already.
+// See Options.VersionRetention.
+func (db *DB) NewTransactionAsOf(readTs uint64) (*Txn, error) {
+	if db.opt.managedTxns {
+		panic("Cannot use NewTransactionAsOf with managedDB=true. Use NewTransactionAt instead.")
+	}
+	if err := db.orc.beginAsOf(readTs); err != nil {
+		return nil, err
+	}
+	txn := db.newTransactionAt(readTs)
+	txn.asOf = true
+	return txn, nil
+}
+
+// SnapshotAt returns a read-only transaction reading the DB as it was at the given time. The time
+// is rounded down to a second, so the commits done within the second before it may be missed.
+//
+// The history is only recorded if Options.VersionRetention is set, and ErrSnapshotTooOld is
+// returned if t is further in the past than that.
+func (db *DB) SnapshotAt(t time.Time) (*Txn, error) {
+	if db.opt.managedTxns {
+		panic("Cannot use SnapshotAt with managedDB=true. Use NewTransactionAt instead.")
+	}
+	db.orc.Lock()
+	readTs, ok := db.orc.tsAsOf(t)
+	db.orc.Unlock()
+	if !ok {
+		return nil, ErrSnapshotTooOld
+	}
+	return db.NewTransactionAsOf(readTs)
+}
+
+func clockSampleEntry(commitTs uint64, start int64) *Entry {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], uint64(start))
+	return &Entry{Key: y.KeyWithTs(clockKey, commitTs), Value: buf[:]}
+}
+
+// loadClock loads the samples of the clock into the oracle.
+func (db *DB) loadClock() error {
+	if db.opt.VersionRetention == 0 || db.opt.managedTxns {
+		return nil
+	}
+	tables, decr := db.getMemTables()
+	defer decr()
+
+	opt := IteratorOptions{Prefix: clockKey, prefixIsKey: true}
+	var iters []y.Iterator
+	for _, mt := range tables {
+		iters = append(iters, mt.sl.NewUniIterator(false))
+	}
+	iters = db.lc.appendIterators(iters, &opt)
+	if len(iters) == 0 {
+		return nil
+	}
+	it := table.NewMergeIterator(iters, false)
+	defer it.Close()
+
+	var clock []clockSample
+	for it.Seek(y.KeyWithTs(clockKey, math.MaxUint64)); it.Valid(); it.Next() {
+		if !bytes.Equal(y.ParseKey(it.Key()), clockKey) {
+			break
+		}
+		vs := it.Value()
+		if len(vs.Value) != 8 || vs.Meta&bitValuePointer > 0 {
+			return errors.Errorf("Invalid clock sample: %x", vs.Value)
+		}
+		clock = append(clock, clockSample{
+			time: int64(binary.BigEndian.Uint64(vs.Value)),
+			ts:   y.ParseTs(it.Key()) - 1,
+		})
+	}
+	// The samples were read from the latest one to the oldest one.
+	for i := len(clock) - 1; i >= 0; i-- {
+		db.orc.clock = append(db.orc.clock, clock[i])
+	}
+	return nil
+}
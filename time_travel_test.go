@@ -0,0 +1,151 @@
+/*
+ * Copyright 2020 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package badger
+
+import (
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewTransactionAsOf(t *testing.T) {
+	opt := getTestOptions("").WithVersionRetention(time.Hour)
+	runBadgerTest(t, &opt, func(t *testing.T, db *DB) {
+		var versions []uint64
+		for _, val := range []string{"v1", "v2", "v3"} {
+			txnSet(t, db, []byte("key"), []byte(val), 0)
+			require.NoError(t, db.View(func(txn *Txn) error {
+				item, err := txn.Get([]byte("key"))
+				require.NoError(t, err)
+				versions = append(versions, item.Version())
+				return nil
+			}))
+		}
+		for i, val := range []string{"v1", "v2", "v3"} {
+			txn, err := db.NewTransactionAsOf(versions[i])
+			require.NoError(t, err)
+			require.Equal(t, val, string(getItemValue(t, mustGet(t, txn, "key"))))
+			txn.Discard()
+		}
+
+		txn, err := db.NewTransactionAsOf(versions[0] - 1)
+		require.NoError(t, err)
+		_, err = txn.Get([]byte("key"))
+		require.Equal(t, ErrKeyNotFound, err)
+		txn.Discard()
+
+		_, err = db.NewTransactionAsOf(versions[2] + 1)
+		require.Error(t, err)
+	})
+}
+
+func mustGet(t *testing.T, txn *Txn, key string) *Item {
+	item, err := txn.Get([]byte(key))
+	require.NoError(t, err)
+	return item
+}
+
+func TestSnapshotAt(t *testing.T) {
+	defer func(d time.Duration) { clockInterval = d }(clockInterval)
+	clockInterval = 10 * time.Millisecond
+
+	dir, err := ioutil.TempDir("", "badger-test")
+	require.NoError(t, err)
+	defer removeDir(dir)
+	// The samples of the clock are kept in the LSM tree, even with a small value threshold.
+	opt := getTestOptions(dir).WithVersionRetention(time.Hour).WithValueThreshold(4)
+	db, err := Open(opt)
+	require.NoError(t, err)
+
+	_, err = db.SnapshotAt(time.Now().Add(-time.Minute))
+	require.Equal(t, ErrSnapshotTooOld, err)
+
+	var times []time.Time
+	for _, val := range []string{"v1", "v2", "v3"} {
+		txnSet(t, db, []byte("key"), []byte(val), 0)
+		time.Sleep(3 * clockInterval)
+		times = append(times, time.Now())
+		time.Sleep(3 * clockInterval)
+	}
+	check := func(db *DB) {
+		for i, val := range []string{"v1", "v2", "v3"} {
+			txn, err := db.SnapshotAt(times[i])
+			require.NoError(t, err)
+			require.Equal(t, val, string(getItemValue(t, mustGet(t, txn, "key"))))
+			txn.Discard()
+		}
+		_, err = db.SnapshotAt(times[0].Add(-time.Minute))
+		require.Equal(t, ErrSnapshotTooOld, err)
+	}
+	check(db)
+
+	// The samples of the clock are persisted.
+	require.NoError(t, db.Close())
+	db, err = Open(opt)
+	require.NoError(t, err)
+	defer func() { require.NoError(t, db.Close()) }()
+	check(db)
+	txnSet(t, db, []byte("key"), []byte("v4"), 0)
+	check(db)
+}
+
+func TestVersionRetentionCompaction(t *testing.T) {
+	run := func(t *testing.T, retention time.Duration) {
+		dir, err := ioutil.TempDir("", "badger-test")
+		require.NoError(t, err)
+		defer removeDir(dir)
+		opt := getTestOptions(dir).WithNumCompactors(0).WithVersionRetention(retention)
+		db, err := Open(opt)
+		require.NoError(t, err)
+		txnSet(t, db, []byte("key"), []byte("v1"), 0)
+		txnSet(t, db, []byte("key"), []byte("v2"), 0)
+		// Closing the DB flushes the memtable to level 0.
+		require.NoError(t, db.Close())
+
+		db, err = Open(opt)
+		require.NoError(t, err)
+		defer func() { require.NoError(t, db.Close()) }()
+		cdef := compactDef{
+			thisLevel: db.lc.levels[0],
+			nextLevel: db.lc.levels[1],
+			top:       db.lc.levels[0].tables,
+			bot:       db.lc.levels[1].tables,
+			t:         db.lc.levelTargets(),
+		}
+		cdef.t.baseLevel = 1
+		require.NoError(t, db.lc.runCompactDef(-1, 0, cdef))
+
+		txn := db.newTransactionAt(1)
+		defer txn.Discard()
+		item, err := txn.Get([]byte("key"))
+		if retention == 0 {
+			require.Equal(t, ErrKeyNotFound, err)
+			_, err = db.NewTransactionAsOf(1)
+			require.Equal(t, ErrSnapshotTooOld, err)
+			return
+		}
+		require.NoError(t, err)
+		require.Equal(t, []byte("v1"), getItemValue(t, item))
+		asOf, err := db.NewTransactionAsOf(1)
+		require.NoError(t, err)
+		asOf.Discard()
+	}
+	t.Run("no retention", func(t *testing.T) { run(t, 0) })
+	t.Run("retention", func(t *testing.T) { run(t, time.Hour) })
+}
@@ -0,0 +1,197 @@
+/*
+ * Copyright 2020 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package badger
+
+import (
+	"bytes"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/dgraph-io/badger/v2/pb"
+	"github.com/dgraph-io/badger/v2/table"
+	"github.com/dgraph-io/badger/v2/y"
+	"github.com/dgraph-io/ristretto/z"
+)
+
+// ttlCompactionRatio is the estimated share of expired keys above which a table is compacted by the
+// TTL compactions.
+const ttlCompactionRatio = 0.5
+
+// expiredRatio estimates the share of the keys of the table which have expired at now, assuming
+// that the expiry times are spread evenly between the earliest and the latest one.
+func expiredRatio(t *table.Table, now uint64) float64 {
+	count, min, max := t.ExpiringKeyCount(), t.MinExpiresAt(), t.MaxExpiresAt()
+	if count == 0 || t.KeyCount() == 0 || now < min {
+		return 0
+	}
+	expired := float64(count)
+	if now < max {
+		expired *= float64(now-min) / float64(max-min)
+	}
+	return expired / float64(t.KeyCount())
+}
+
+// ttlTables returns the tables among the given ones whose estimated share of expired keys is at
+// least ttlCompactionRatio, the most expired first. The tables with versions above discardTs are
+// left out, as their expired keys might not be droppable yet.
+func ttlTables(tables []*table.Table, now, discardTs uint64) []*table.Table {
+	var out []*table.Table
+	ratios := make(map[*table.Table]float64)
+	for _, t := range tables {
+		if r := expiredRatio(t, now); r >= ttlCompactionRatio && t.MaxVersion() <= discardTs {
+			out = append(out, t)
+			ratios[t] = r
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return ratios[out[i]] > ratios[out[j]] })
+	return out
+}
+
+// levelTTLTables returns the candidates of the TTL compactions in level l. See ttlTables.
+func (s *levelsController) levelTTLTables(l int, now uint64) []*table.Table {
+	discardTs := s.kv.orc.discardAtOrBelow()
+	lh := s.levels[l]
+	lh.RLock()
+	defer lh.RUnlock()
+	return ttlTables(lh.tables, now, discardTs)
+}
+
+// runTTLCompactor periodically runs the TTL compactions, until lc is closed.
+func (s *levelsController) runTTLCompactor(lc *z.Closer) {
+	defer lc.Done()
+
+	ticker := time.NewTicker(s.kv.opt.TTLCompactionInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := s.ttlCompact(lc); err != nil {
+				s.kv.opt.Warningf("While running TTL compaction: %v", err)
+			}
+		case <-lc.HasBeenClosed():
+			return
+		}
+	}
+}
+
+// ttlCompact compacts the tables holding mostly expired keys, so that the space taken by the keys
+// is reclaimed without waiting for the levels to fill up. The tables of the last level are
+// rewritten in place, and the ones of the other levels are compacted to the next level, until
+// they reach a level where the expired keys can be dropped. The tables of level 0 are left to the
+// regular compactions, which pick them up quickly anyway.
+func (s *levelsController) ttlCompact(lc *z.Closer) error {
+	now := uint64(time.Now().Unix())
+	var compacted int
+	for l := 1; l < len(s.levels); l++ {
+		tables := s.levelTTLTables(l, now)
+		for i := range tables {
+			select {
+			case <-lc.HasBeenClosed():
+				return nil
+			default:
+			}
+
+			var err error
+			if l == len(s.levels)-1 {
+				err = s.rewriteTable(l, tables[i])
+			} else {
+				// fillTables picks the most expired table which isn't being compacted.
+				p := compactionPriority{level: l, score: 1.0, ttl: true, t: s.levelTargets()}
+				err = s.doCompact(-1, p)
+			}
+			switch err {
+			case nil:
+				compacted++
+			case errFillTables:
+			default:
+				return err
+			}
+		}
+	}
+	if compacted > 0 {
+		s.kv.opt.Infof("TTL compaction compacted %d tables", compacted)
+	}
+	return nil
+}
+
+// expiryQueueSize is the number of expired versions queued for Options.OnExpire. The compactions
+// wait for the queue once it is full.
+const expiryQueueSize = 1024
+
+// expiredVersion is an expired version of a key dropped by a compaction, queued for
+// Options.OnExpire.
+type expiredVersion struct {
+	key []byte
+	vs  y.ValueStruct
+}
+
+// queueExpired queues the expired version of the key being dropped by a compaction for
+// Options.OnExpire. The version is skipped if the compaction has seen a newer version of the key,
+// i.e. if it isn't the newest one.
+func (s *levelsController) queueExpired(key []byte, vs y.ValueStruct, newest bool) {
+	if !newest || vs.Meta&(bitDelete|bitMergeEntry) > 0 || vs.ExpiresAt == 0 ||
+		bytes.HasPrefix(key, badgerPrefix) {
+		return
+	}
+	vs.Value = y.SafeCopy(nil, vs.Value)
+	s.kv.expired <- expiredVersion{key: y.SafeCopy(nil, key), vs: vs}
+}
+
+// runOnExpire calls Options.OnExpire for the expired versions queued by the compactions, so that
+// the compactions don't wait for the callback nor for the reads it needs. The queue is drained
+// once lc is closed, which is done after the compactions are stopped.
+func (db *DB) runOnExpire(lc *z.Closer) {
+	defer lc.Done()
+	for {
+		select {
+		case ev := <-db.expired:
+			db.onExpire(ev)
+		case <-lc.HasBeenClosed():
+			for len(db.expired) > 0 {
+				db.onExpire(<-db.expired)
+			}
+			return
+		}
+	}
+}
+
+// onExpire calls Options.OnExpire for an expired version, if it's still the latest version of the
+// key, i.e. the key hasn't been written again since.
+func (db *DB) onExpire(ev expiredVersion) {
+	userKey, version := y.ParseKey(ev.key), y.ParseTs(ev.key)
+	latest, err := db.get(y.KeyWithTs(userKey, math.MaxUint64))
+	if err != nil {
+		db.opt.Errorf("Unable to read the latest version of expired key %q: %v", userKey, err)
+		return
+	}
+	if latest.Version != version {
+		return
+	}
+	val, err := db.valueCopy(ev.vs)
+	if err != nil {
+		db.opt.Errorf("Unable to read the value of expired key %q: %v", userKey, err)
+		return
+	}
+	db.opt.OnExpire(&pb.KV{
+		Key:       y.SafeCopy(nil, userKey),
+		Value:     val,
+		UserMeta:  []byte{ev.vs.UserMeta},
+		Version:   version,
+		ExpiresAt: ev.vs.ExpiresAt,
+	})
+}
@@ -0,0 +1,149 @@
+/*
+ * Copyright 2020 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package badger
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/badger/v2/pb"
+	"github.com/dgraph-io/badger/v2/table"
+	"github.com/dgraph-io/badger/v2/y"
+	"github.com/dgraph-io/ristretto/z"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTTLCompaction(t *testing.T) {
+	dir, err := ioutil.TempDir("", "badger-test")
+	require.NoError(t, err)
+	defer removeDir(dir)
+
+	var mu sync.Mutex
+	var expired []string
+	onExpire := func(kv *pb.KV) {
+		mu.Lock()
+		defer mu.Unlock()
+		require.Equal(t, fmt.Sprintf("val%s", kv.Key), string(kv.Value))
+		require.NotZero(t, kv.ExpiresAt)
+		expired = append(expired, string(kv.Key))
+	}
+	opt := getTestOptions(dir).WithNumCompactors(0).WithOnExpire(onExpire)
+	db, err := Open(opt)
+	require.NoError(t, err)
+
+	set := func(key string, ttl time.Duration) {
+		e := NewEntry([]byte(key), []byte("val"+key))
+		if ttl > 0 {
+			e = e.WithTTL(ttl)
+		}
+		require.NoError(t, db.Update(func(txn *Txn) error { return txn.SetEntry(e) }))
+	}
+	var want []string
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("session%03d", i)
+		set(key, 3*time.Second)
+		want = append(want, key)
+	}
+	for i := 0; i < 20; i++ {
+		set(fmt.Sprintf("user%03d", i), 0)
+	}
+	// The refreshed session doesn't expire.
+	set("session050", 0)
+	want = append(want[:50], want[51:]...)
+
+	// Closing the DB flushes the memtable to level 0, which gets compacted to the last level
+	// before the sessions expire.
+	require.NoError(t, db.Close())
+	db, err = Open(opt)
+	require.NoError(t, err)
+	defer func() { require.NoError(t, db.Close()) }()
+	// The refreshed session can only be dropped once the watermark has caught up with the replay.
+	require.NoError(t, db.orc.readMark.WaitForMark(context.Background(), db.MaxVersion()))
+	require.NoError(t, db.lc.doCompact(-1, compactionPriority{level: 0, score: 1.71}))
+	last := db.lc.lastLevel()
+	require.Len(t, last.tables, 1)
+	tbl := last.tables[0]
+	require.Equal(t, uint32(120), tbl.KeyCount())
+	require.Equal(t, uint32(99), tbl.ExpiringKeyCount())
+	require.Zero(t, expiredRatio(tbl, uint64(time.Now().Unix())-1))
+	mu.Lock()
+	require.Empty(t, expired)
+	mu.Unlock()
+
+	time.Sleep(time.Until(time.Unix(int64(tbl.MaxExpiresAt())+1, 0)))
+	require.InDelta(t, 99.0/120, expiredRatio(tbl, uint64(time.Now().Unix())), 0.01)
+	require.NoError(t, db.lc.ttlCompact(z.NewCloser(1)))
+
+	require.Len(t, last.tables, 1)
+	require.Equal(t, uint32(21), last.tables[0].KeyCount())
+	require.Zero(t, last.tables[0].ExpiringKeyCount())
+	// OnExpire is called once the compaction is done.
+	numExpired := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(expired)
+	}
+	waitUntil(t, func() bool { return numExpired() == len(want) })
+	mu.Lock()
+	sort.Strings(expired)
+	require.Equal(t, want, expired)
+	mu.Unlock()
+
+	// Nothing is left to compact.
+	require.NoError(t, db.lc.ttlCompact(z.NewCloser(1)))
+	time.Sleep(100 * time.Millisecond)
+	require.Equal(t, 99, numExpired())
+	require.NoError(t, db.View(func(txn *Txn) error {
+		require.Equal(t, 21, countKeys(t, txn, false))
+		return nil
+	}))
+}
+
+func TestExpiredRatio(t *testing.T) {
+	require.Zero(t, expiredRatio(buildTableWithExpiry(t, nil), 100))
+
+	tbl := buildTableWithExpiry(t, []uint64{0, 0, 100, 200, 300})
+	for now, ratio := range map[uint64]float64{50: 0, 100: 0, 200: 0.3, 300: 0.6, 400: 0.6} {
+		require.InDelta(t, ratio, expiredRatio(tbl, now), 0.001, "now %d", now)
+	}
+	require.Len(t, ttlTables([]*table.Table{tbl}, 300, 10), 1)
+	require.Empty(t, ttlTables([]*table.Table{tbl}, 200, 10))
+	// The versions above discardTs can't be dropped yet.
+	require.Empty(t, ttlTables([]*table.Table{tbl}, 300, 0))
+}
+
+// buildTableWithExpiry builds an in-memory table with a key for each of the given expiry times.
+func buildTableWithExpiry(t *testing.T, expiresAt []uint64) *table.Table {
+	opts := table.Options{BlockSize: 4 << 10, BloomFalsePositive: 0.01}
+	b := table.NewTableBuilder(opts)
+	defer b.Close()
+	if len(expiresAt) == 0 {
+		b.Add(y.KeyWithTs([]byte("foo"), 1), y.ValueStruct{}, 0)
+	}
+	for i, exp := range expiresAt {
+		key := y.KeyWithTs([]byte(fmt.Sprintf("key%03d", i)), 1)
+		b.Add(key, y.ValueStruct{Value: []byte("val"), ExpiresAt: exp}, 0)
+	}
+	tbl, err := table.OpenInMemoryTable(b.Finish(), 1, &opts)
+	require.NoError(t, err)
+	return tbl
+}
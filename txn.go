@@ -25,6 +25,7 @@ import (
 	"strconv"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/dgraph-io/badger/v2/y"
 	"github.com/dgraph-io/ristretto/z"
@@ -54,6 +55,15 @@ type oracle struct {
 	committedTxns []committedTxn
 	lastCleanupTs uint64
 
+	// retention is Options.VersionRetention, clock holds the samples of the clock used to find the
+	// versions to keep for it, and asOfReads counts the transactions created by
+	// NewTransactionAsOf per read timestamp. See time_travel.go.
+	retention time.Duration
+	clock     []clockSample
+	asOfReads map[uint64]int
+	// snapshots holds the named snapshots, whose versions are kept. See DB.CreateSnapshot.
+	snapshots map[string]Snapshot
+
 	// closer is used to stop watermarks.
 	closer *z.Closer
 }
@@ -62,12 +72,21 @@ type committedTxn struct {
 	ts uint64
 	// ConflictKeys Keeps track of the entries written at timestamp ts.
 	conflictKeys map[uint64]struct{}
+	// keys holds the keys written at timestamp ts, and rangeDels the ranges deleted. These are
+	// checked against the ranges read by iterators. sorted is set once the keys are sorted, see
+	// sortedKeys.
+	keys      []string
+	sorted    bool
+	rangeDels []rangeTombstone
 }
 
 func newOracle(opt Options) *oracle {
 	orc := &oracle{
 		isManaged:       opt.managedTxns,
 		detectConflicts: opt.DetectConflicts,
+		retention:       opt.VersionRetention,
+		asOfReads:       make(map[uint64]int),
+		snapshots:       make(map[string]Snapshot),
 		// We're not initializing nextTxnTs and readOnlyTs. It would be done after replay in Open.
 		//
 		// WaterMarks must be 64-bit aligned for atomic package, hence we must use pointers here.
@@ -126,20 +145,34 @@ func (o *oracle) setDiscardTs(ts uint64) {
 }
 
 func (o *oracle) discardAtOrBelow() uint64 {
+	o.Lock()
+	defer o.Unlock()
+	return o.discardAtOrBelowLocked()
+}
+
+// discardAtOrBelowLocked must be called under o.Lock.
+func (o *oracle) discardAtOrBelowLocked() uint64 {
 	if o.isManaged {
-		o.Lock()
-		defer o.Unlock()
 		return o.discardTs
 	}
-	return o.readMark.DoneUntil()
+	ts := o.readMark.DoneUntil()
+	// Keep the versions within the retention, and the ones visible to NewTransactionAsOf and the
+	// named snapshots.
+	for _, rts := range []uint64{o.retentionTs(), o.minAsOfTs(), o.minSnapshotTs()} {
+		if rts < ts {
+			ts = rts
+		}
+	}
+	return ts
 }
 
 // hasConflict must be called while having a lock.
 func (o *oracle) hasConflict(txn *Txn) bool {
-	if len(txn.reads) == 0 {
+	if len(txn.reads) == 0 && len(txn.lockedReads) == 0 && len(txn.readRanges) == 0 {
 		return false
 	}
-	for _, committedTxn := range o.committedTxns {
+	for i := range o.committedTxns {
+		committedTxn := &o.committedTxns[i]
 		// If the committedTxn.ts is less than txn.readTs that implies that the
 		// committedTxn finished before the current transaction started.
 		// We don't need to check for conflict in that case.
@@ -155,6 +188,16 @@ func (o *oracle) hasConflict(txn *Txn) bool {
 				return true
 			}
 		}
+		// The reads of GetForUpdate are done later, at their own timestamps.
+		for _, lr := range txn.lockedReads {
+			if _, has := committedTxn.conflictKeys[lr.fp]; has && committedTxn.ts > lr.ts {
+				return true
+			}
+		}
+		// A key inserted into a range read by an iterator wouldn't be found in the reads.
+		if txn.hasRangeConflict(committedTxn) {
+			return true
+		}
 	}
 
 	return false
@@ -177,6 +220,7 @@ func (o *oracle) newCommitTs(txn *Txn) uint64 {
 		ts = o.nextTxnTs
 		o.nextTxnTs++
 		o.txnMark.Begin(ts)
+		txn.clockSample = o.tick(ts)
 
 	} else {
 		// If commitTs is set, use it instead.
@@ -191,6 +235,8 @@ func (o *oracle) newCommitTs(txn *Txn) uint64 {
 		o.committedTxns = append(o.committedTxns, committedTxn{
 			ts:           ts,
 			conflictKeys: txn.conflictKeys,
+			keys:         txn.writtenKeys(),
+			rangeDels:    txn.rangeDels,
 		})
 	}
 
@@ -253,17 +299,35 @@ type Txn struct {
 	count    int64
 	db       *DB
 
-	reads []uint64 // contains fingerprints of keys read.
+	reads      []uint64    // contains fingerprints of keys read.
+	readRanges []readRange // contains the ranges of keys read by iterators.
 	// contains fingerprints of keys written. This is used for conflict detection.
 	conflictKeys map[uint64]struct{}
-	readsLock    sync.Mutex // guards the reads slice. See addReadKey.
+	readsLock    sync.Mutex // guards the reads and readRanges slices. See addReadKey.
 
 	pendingWrites   map[string]*Entry // cache stores any writes done by txn.
 	duplicateWrites []*Entry          // Used in managed mode to store duplicate entries.
+	rangeDels       []rangeTombstone  // Ranges deleted by this txn, see DeleteRange.
+
+	// savepoints holds the savepoints which can be rolled back to, and undo records the changes
+	// to pendingWrites since the first one. See Savepoint.
+	savepoints []*Savepoint
+	undo       []undoWrite
+
+	// locks holds the key locks taken by GetForUpdate, and lockedReads the reads done after
+	// locking the keys.
+	locks       *txnLocks
+	lockedReads []lockedRead
+
+	// clockSample is set if a sample of the clock must be written along with the commit.
+	clockSample int64
+	// indexes holds the secondary indexes maintained by the writes of the transaction.
+	indexes *indexSet
 
 	numIterators int32
 	discarded    bool
 	doneRead     bool
+	asOf         bool // asOf is set for the transactions created by NewTransactionAsOf.
 	update       bool // update is used to conditionally keep track of reads.
 }
 
@@ -359,8 +423,23 @@ func exceedsSize(prefix string, max int64, key []byte) error {
 }
 
 func (txn *Txn) modify(e *Entry) error {
-	const maxKeySize = 65000
+	if bytes.HasPrefix(e.Key, badgerPrefix) {
+		return ErrInvalidKey
+	}
+	if txn.indexes.covers(e.Key) {
+		return txn.modifyIndexed(e)
+	}
+	return txn.modifyInternal(e)
+}
+
+// maxKeySize is the maximum size of a key. Key length can't be more than uint16, as determined by
+// table::header. To keep things safe and allow badger move prefix and a timestamp suffix, let's
+// cut it down to 65000, instead of using 65536.
+const maxKeySize = 65000
 
+// modifyInternal is like modify, but also allows writing the internal keys with the !badger!
+// prefix.
+func (txn *Txn) modifyInternal(e *Entry) error {
 	switch {
 	case !txn.update:
 		return ErrReadOnlyTxn
@@ -368,12 +447,7 @@ func (txn *Txn) modify(e *Entry) error {
 		return ErrDiscardedTxn
 	case len(e.Key) == 0:
 		return ErrEmptyKey
-	case bytes.HasPrefix(e.Key, badgerPrefix):
-		return ErrInvalidKey
 	case len(e.Key) > maxKeySize:
-		// Key length can't be more than uint16, as determined by table::header.  To
-		// keep things safe and allow badger move prefix and a timestamp suffix, let's
-		// cut it down to 65000, instead of using 65536.
 		return exceedsSize("Key", maxKeySize, e.Key)
 	case int64(len(e.Value)) > txn.db.opt.ValueLogFileSize:
 		return exceedsSize("Value", txn.db.opt.ValueLogFileSize, e.Value)
@@ -387,9 +461,13 @@ func (txn *Txn) modify(e *Entry) error {
 
 	// The txn.conflictKeys is used for conflict detection. If conflict detection
 	// is disabled, we don't need to store key hashes in this map.
+	var newConflictKey bool
 	if txn.db.opt.DetectConflicts {
 		fp := z.MemHash(e.Key) // Avoid dealing with byte arrays.
-		txn.conflictKeys[fp] = struct{}{}
+		if _, ok := txn.conflictKeys[fp]; !ok {
+			txn.conflictKeys[fp] = struct{}{}
+			newConflictKey = true
+		}
 	}
 	// If a duplicate entry was inserted in managed mode, move it to the duplicate writes slice.
 	// Add the entry to duplicateWrites only if both the entries have different versions. For
@@ -397,7 +475,7 @@ func (txn *Txn) modify(e *Entry) error {
 	if oldEntry, ok := txn.pendingWrites[string(e.Key)]; ok && oldEntry.version != e.version {
 		txn.duplicateWrites = append(txn.duplicateWrites, oldEntry)
 	}
-	txn.pendingWrites[string(e.Key)] = e
+	txn.setPending(string(e.Key), e, newConflictKey)
 	return nil
 }
 
@@ -443,27 +521,23 @@ func (txn *Txn) Get(key []byte) (item *Item, rerr error) {
 	} else if txn.discarded {
 		return nil, ErrDiscardedTxn
 	}
+	start := time.Now()
+	defer func() { txn.db.metrics.GetLatency.Observe(time.Since(start)) }()
 
-	item = new(Item)
 	if txn.update {
 		if e, has := txn.pendingWrites[string(key)]; has && bytes.Equal(key, e.Key) {
 			if isDeletedOrExpired(e.meta, e.ExpiresAt) {
 				return nil, ErrKeyNotFound
 			}
 			// Fulfill from cache.
-			item.meta = e.meta
-			item.val = e.Value
-			item.userMeta = e.UserMeta
-			item.key = key
-			item.status = prefetched
-			item.version = txn.readTs
-			item.expiresAt = e.ExpiresAt
-			// We probably don't need to set db on item here.
-			return item, nil
+			return txn.pendingItem(key, e), nil
 		}
 		// Only track reads if this is update txn. No need to track read if txn serviced it
 		// internally.
 		txn.addReadKey(key)
+		if txn.pendingRangeDeleted(key) {
+			return nil, ErrKeyNotFound
+		}
 	}
 
 	seek := y.KeyWithTs(key, txn.readTs)
@@ -478,14 +552,7 @@ func (txn *Txn) Get(key []byte) (item *Item, rerr error) {
 		return nil, ErrKeyNotFound
 	}
 
-	item.key = key
-	item.version = vs.Version
-	item.meta = vs.Meta
-	item.userMeta = vs.UserMeta
-	item.vptr = y.SafeCopy(item.vptr, vs.Value)
-	item.txn = txn
-	item.expiresAt = vs.ExpiresAt
-	return item, nil
+	return txn.newItem(key, vs), nil
 }
 
 func (txn *Txn) addReadKey(key []byte) {
@@ -518,6 +585,17 @@ func (txn *Txn) Discard() {
 	if !txn.db.orc.isManaged {
 		txn.db.orc.doneRead(txn)
 	}
+	if txn.asOf {
+		txn.db.orc.doneAsOf(txn.readTs)
+	}
+	txn.releaseLocks()
+}
+
+// releaseLocks releases the key locks taken by GetForUpdate.
+func (txn *Txn) releaseLocks() {
+	if txn.locks != nil {
+		txn.db.locks.release(txn.locks)
+	}
 }
 
 func (txn *Txn) commitAndSend() (func() error, error) {
@@ -529,12 +607,16 @@ func (txn *Txn) commitAndSend() (func() error, error) {
 	orc.writeChLock.Lock()
 	defer orc.writeChLock.Unlock()
 
+	if txn.missesIndexes() {
+		return nil, ErrConflict
+	}
 	commitTs := orc.newCommitTs(txn)
 	// The commitTs can be zero if the transaction is running in managed mode.
 	// Individual entries might have their own timestamps.
 	if commitTs == 0 && !txn.db.opt.managedTxns {
 		return nil, ErrConflict
 	}
+	txn.commitTs = commitTs
 
 	keepTogether := true
 	setVersion := func(e *Entry) {
@@ -593,6 +675,11 @@ func (txn *Txn) commitAndSend() (func() error, error) {
 		}
 		entries = append(entries, e)
 	}
+	if keepTogether && txn.clockSample != 0 {
+		e := clockSampleEntry(commitTs, txn.clockSample)
+		e.meta = bitTxn
+		entries = append(entries[:len(entries)-1], e, entries[len(entries)-1])
+	}
 
 	req, err := txn.db.sendToWriteCh(entries)
 	if err != nil {
@@ -614,6 +701,10 @@ func (txn *Txn) commitPrecheck() error {
 	if txn.discarded {
 		return errors.New("Trying to commit a discarded txn")
 	}
+	if txn.locks != nil && txn.locks.isWounded() {
+		// An older transaction is waiting for the locks of this one.
+		return ErrConflict
+	}
 	keepTogether := true
 	for _, e := range txn.pendingWrites {
 		if e.version != 0 {
@@ -654,8 +745,11 @@ func (txn *Txn) Commit() error {
 	// txn.conflictKeys can be zero if conflict detection is turned off. So we
 	// should check txn.pendingWrites.
 	if len(txn.pendingWrites) == 0 {
+		txn.releaseLocks()
 		return nil // Nothing to do.
 	}
+	start := time.Now()
+	defer func() { txn.db.metrics.CommitLatency.Observe(time.Since(start)) }()
 	// Precheck before discarding txn.
 	if err := txn.commitPrecheck(); err != nil {
 		return err
@@ -705,6 +799,7 @@ func (txn *Txn) CommitWith(cb func(error)) {
 	}
 
 	if len(txn.pendingWrites) == 0 {
+		txn.releaseLocks()
 		// Do not run these callbacks from here, because the CommitWith and the
 		// callback might be acquiring the same locks. Instead run the callback
 		// from another goroutine.
@@ -734,6 +829,13 @@ func (txn *Txn) ReadTs() uint64 {
 	return txn.readTs
 }
 
+// CommitTs returns the commit timestamp of the transaction, once it has been committed, or zero if
+// it had nothing to commit. The values it wrote can be read at that timestamp via
+// NewTransactionAsOf, as long as the versions are kept.
+func (txn *Txn) CommitTs() uint64 {
+	return txn.commitTs
+}
+
 // NewTransaction creates a new transaction. Badger supports concurrent execution of transactions,
 // providing serializable snapshot isolation, avoiding write skews. Badger achieves this by tracking
 // the keys read and at Commit time, ensuring that these read keys weren't concurrently modified by
@@ -775,6 +877,7 @@ func (db *DB) newTransaction(update, isManaged bool) *Txn {
 			txn.conflictKeys = make(map[uint64]struct{})
 		}
 		txn.pendingWrites = make(map[string]*Entry)
+		txn.indexes = db.currentIndexes()
 	}
 	if !isManaged {
 		txn.readTs = db.orc.readTs()
@@ -782,6 +885,17 @@ func (db *DB) newTransaction(update, isManaged bool) *Txn {
 	return txn
 }
 
+// newTransactionAt returns a read-only transaction reading at readTs, in the normal mode as well
+// as in the managed mode. Unlike NewTransaction, it doesn't register readTs with the oracle, so the
+// caller must make sure that the versions visible at readTs aren't discarded meanwhile.
+func (db *DB) newTransactionAt(readTs uint64) *Txn {
+	txn := db.newTransaction(false, true)
+	txn.readTs = readTs
+	// There is no read to mark as done in the oracle when the transaction is discarded.
+	txn.doneRead = true
+	return txn
+}
+
 // View executes a function creating and managing a read-only transaction for the user. Error
 // returned by the function is relayed by the View method.
 // If View is used with managed transactions, it would assume a read timestamp of MaxUint64.
@@ -50,6 +50,8 @@ const (
 	bitDiscardEarlierVersions byte = 1 << 2 // Set if earlier versions can be discarded.
 	// Set if item shouldn't be discarded via compactions (used by merge operator)
 	bitMergeEntry byte = 1 << 3
+	// Set if the entry is a range tombstone, deleting all the keys within its range.
+	bitRangeDelete byte = 1 << 4
 	// The MSB 2 bits are for transactions.
 	bitTxn    byte = 1 << 6 // Set if the entry is part of a txn.
 	bitFinTxn byte = 1 << 7 // Set if the entry is to indicate end of txn in value log.
@@ -188,6 +190,7 @@ func (vlog *valueLog) rewrite(f *logFile) error {
 
 	y.AssertTrue(vlog.db != nil)
 	var count, moved int
+	var movedBytes int64
 	fe := func(e Entry) error {
 		count++
 		if count%100000 == 0 {
@@ -224,6 +227,7 @@ func (vlog *valueLog) rewrite(f *logFile) error {
 		// an older vlog file. See the comments in the else part.
 		if vp.Fid == f.fid && vp.Offset == e.offset {
 			moved++
+			movedBytes += int64(vp.Len)
 			// This new entry only contains the key, and a pointer to the value.
 			ne := new(Entry)
 			// Remove only the bitValuePointer and transaction markers. We
@@ -355,6 +359,9 @@ func (vlog *valueLog) rewrite(f *logFile) error {
 		vlog.filesLock.Unlock()
 	}
 
+	// The moved entries have been written again to the latest vlog file.
+	vlog.db.metrics.VlogGCReclaimedBytes.Add(int64(atomic.LoadUint32(&f.size)) - movedBytes)
+
 	if deleteFileNow {
 		if err := vlog.deleteLogFile(f); err != nil {
 			return err
@@ -449,7 +456,10 @@ type valueLog struct {
 	// A refcount of iterators -- when this hits zero, we can delete the filesToBeDeleted.
 	numActiveIterators int32
 
-	db                *DB
+	db *DB
+	// writeLock serializes the calls to write, which come from the write goroutine and from
+	// IngestExternalFiles.
+	writeLock         sync.Mutex
 	writableLogOffset uint32 // read by read, written by write. Must access via atomics.
 	numEntriesWritten uint32
 	opt               Options
@@ -778,11 +788,13 @@ func estimateRequestSize(req *request) uint64 {
 	return size
 }
 
-// write is thread-unsafe by design and should not be called concurrently.
+// write writes the entries of the requests to the value log, and sets their value pointers.
 func (vlog *valueLog) write(reqs []*request) error {
 	if vlog.db.opt.InMemory {
 		return nil
 	}
+	vlog.writeLock.Lock()
+	defer vlog.writeLock.Unlock()
 	// Validate writes before writing to vlog. Because, we don't want to partially write and return
 	// an error.
 	if err := vlog.validateWrites(reqs); err != nil {
@@ -823,7 +835,7 @@ func (vlog *valueLog) write(reqs []*request) error {
 
 	toDisk := func() error {
 		if vlog.woffset() > uint32(vlog.opt.ValueLogFileSize) ||
-			vlog.numEntriesWritten > vlog.opt.ValueLogMaxEntries {
+			vlog.numEntriesWritten > vlog.opt.ValueLogMaxEntries || vlog.db.staleKey(curlf) {
 			if err := curlf.doneWriting(vlog.woffset()); err != nil {
 				return err
 			}
@@ -846,7 +858,7 @@ func (vlog *valueLog) write(reqs []*request) error {
 			buf.Reset()
 
 			e := b.Entries[j]
-			if vlog.db.opt.skipVlog(e) {
+			if vlog.db.skipVlog(e) {
 				b.Ptrs = append(b.Ptrs, valuePointer{})
 				continue
 			}
@@ -881,6 +893,7 @@ func (vlog *valueLog) write(reqs []*request) error {
 		}
 		y.NumWrites.Add(int64(written))
 		y.NumBytesWritten.Add(int64(bytesWritten))
+		vlog.db.metrics.BytesWritten.Add(int64(bytesWritten))
 
 		vlog.numEntriesWritten += uint32(written)
 		// We write to disk here so that all entries that are part of the same transaction are
@@ -1091,6 +1104,36 @@ func (vlog *valueLog) runGC(discardRatio float64) error {
 	}
 }
 
+// reencrypt rewrites the value log files encrypted with a data key older than keyID. The file
+// being written to must have been replaced already. It returns ErrRejected if value log GC is
+// running.
+func (vlog *valueLog) reencrypt(keyID uint64) error {
+	select {
+	case vlog.garbageCh <- struct{}{}:
+		defer func() {
+			<-vlog.garbageCh
+		}()
+	default:
+		return ErrRejected
+	}
+
+	vlog.filesLock.RLock()
+	var stale []*logFile
+	for _, fid := range vlog.sortedFids() {
+		if lf := vlog.filesMap[fid]; fid < vlog.maxFid && lf.keyID() < keyID {
+			stale = append(stale, lf)
+		}
+	}
+	vlog.filesLock.RUnlock()
+
+	for _, lf := range stale {
+		if err := vlog.doRunGC(lf); err != nil {
+			return y.Wrapf(err, "while re-encrypting value log file %d", lf.fid)
+		}
+	}
+	return nil
+}
+
 func (vlog *valueLog) updateDiscardStats(stats map[uint32]int64) {
 	if vlog.opt.InMemory {
 		return
@@ -18,6 +18,8 @@ package y
 
 import "expvar"
 
+// These metrics are process-global, so they mix the numbers of all the DBs open in the process.
+// Use DB.Metrics for the metrics of a single DB.
 var (
 	// LSMSize has size of the LSM in bytes
 	LSMSize *expvar.Map